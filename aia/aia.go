@@ -0,0 +1,271 @@
+// Package aia completes certificate chains by following the Authority
+// Information Access caIssuers URLs (RFC 5280, section 4.2.2.1) of
+// certificates whose issuer is not otherwise available.
+package aia
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+const (
+	// DefaultMaxDepth is the default number of issuers that are followed
+	// above the starting certificate.
+	DefaultMaxDepth = 5
+
+	// DefaultMaxSize is the default limit for a single caIssuers download.
+	DefaultMaxSize = 1 << 20
+
+	defaultTimeout = 10 * time.Second
+)
+
+// ErrIncompleteChain is returned when no issuer could be found for a
+// certificate that is not self-signed.
+var ErrIncompleteChain = errors.New("incomplete certificate chain")
+
+// Cache stores certificates downloaded from caIssuers URLs. It is safe for
+// concurrent use and may be shared between fetchers.
+type Cache struct {
+	mu    sync.Mutex
+	certs map[string][]*x509.Certificate
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{certs: make(map[string][]*x509.Certificate)}
+}
+
+func (c *Cache) get(url string) ([]*x509.Certificate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	certs, ok := c.certs[url]
+	return certs, ok
+}
+
+func (c *Cache) put(url string, certs []*x509.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.certs[url] = certs
+}
+
+// Fetcher downloads issuer certificates from caIssuers URLs.
+type Fetcher struct {
+	// Client is used for downloads. If nil, a client with a 10 second
+	// timeout is used.
+	Client *http.Client
+
+	// MaxDepth limits how many issuers are followed above the starting
+	// certificate. If zero, DefaultMaxDepth is used.
+	MaxDepth int
+
+	// MaxSize limits the size in bytes of a single download. If zero,
+	// DefaultMaxSize is used.
+	MaxSize int64
+
+	// Cache holds previous downloads. If nil, nothing is cached.
+	Cache *Cache
+}
+
+// NewFetcher returns a Fetcher using client and a new Cache.
+func NewFetcher(client *http.Client) *Fetcher {
+	return &Fetcher{Client: client, Cache: NewCache()}
+}
+
+func (f *Fetcher) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	return &http.Client{Timeout: defaultTimeout}
+}
+
+func (f *Fetcher) maxDepth() int {
+	if f.MaxDepth > 0 {
+		return f.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+func (f *Fetcher) maxSize() int64 {
+	if f.MaxSize > 0 {
+		return f.MaxSize
+	}
+	return DefaultMaxSize
+}
+
+// FetchIssuers downloads the certificates published at the caIssuers URLs
+// of cert. Only HTTP(S) URLs are followed. Each URL may serve a single DER
+// or PEM certificate or a certs-only PKCS#7 bundle.
+func (f *Fetcher) FetchIssuers(cert *x509.Certificate) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	var lastErr error
+	for _, url := range cert.IssuingCertificateURL {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+		fetched, err := f.fetch(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		certs = append(certs, fetched...)
+	}
+	if len(certs) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return certs, nil
+}
+
+func (f *Fetcher) fetch(url string) ([]*x509.Certificate, error) {
+	if f.Cache != nil {
+		if certs, ok := f.Cache.get(url); ok {
+			return certs, nil
+		}
+	}
+
+	resp, err := f.client().Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("caIssuers server %s returned status %d", url, resp.StatusCode)
+	}
+
+	limit := f.maxSize()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("caIssuers response from %s exceeds %d bytes", url, limit)
+	}
+
+	certs, err := ParseCertificates(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificates from %s: %w", url, err)
+	}
+
+	if f.Cache != nil {
+		f.Cache.put(url, certs)
+	}
+	return certs, nil
+}
+
+// ParseCertificates parses a DER certificate, one or more PEM certificates,
+// or a certs-only PKCS#7 bundle (DER or PEM).
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		var certs []*x509.Certificate
+		for block != nil {
+			switch block.Type {
+			case "CERTIFICATE":
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				certs = append(certs, cert)
+			case "PKCS7":
+				p7, err := pkcs7.Parse(block.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				certs = append(certs, p7.Certificates...)
+			}
+			block, data = pem.Decode(data)
+		}
+		if len(certs) == 0 {
+			return nil, errors.New("no certificates in PEM data")
+		}
+		return certs, nil
+	}
+
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return []*x509.Certificate{cert}, nil
+	}
+
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		return nil, errors.New("data is neither a certificate nor a PKCS#7 bundle")
+	}
+	if len(p7.Certificates) == 0 {
+		return nil, errors.New("PKCS#7 bundle contains no certificates")
+	}
+	return p7.Certificates, nil
+}
+
+// CompleteChain returns the chain from cert up to a self-signed certificate,
+// taking issuers from known first and downloading the missing ones. The
+// returned chain starts with cert. When an issuer cannot be found the partial
+// chain is returned together with an error wrapping ErrIncompleteChain.
+func (f *Fetcher) CompleteChain(cert *x509.Certificate, known []*x509.Certificate) ([]*x509.Certificate, error) {
+	chain := []*x509.Certificate{cert}
+	seen := map[string]bool{string(cert.Raw): true}
+
+	current := cert
+	for depth := 0; ; depth++ {
+		if isSelfSigned(current) {
+			return chain, nil
+		}
+		if depth >= f.maxDepth() {
+			return chain, fmt.Errorf("%w: maximum depth %d reached at %q", ErrIncompleteChain, f.maxDepth(), current.Subject.String())
+		}
+
+		issuer := findIssuer(current, known)
+		if issuer == nil {
+			if len(current.IssuingCertificateURL) == 0 {
+				return chain, fmt.Errorf("%w: no issuer for %q and no caIssuers URL", ErrIncompleteChain, current.Subject.String())
+			}
+			fetched, err := f.FetchIssuers(current)
+			if err != nil {
+				return chain, fmt.Errorf("%w: %v", ErrIncompleteChain, err)
+			}
+			issuer = findIssuer(current, fetched)
+			if issuer == nil {
+				return chain, fmt.Errorf("%w: caIssuers of %q did not contain its issuer", ErrIncompleteChain, current.Subject.String())
+			}
+		}
+
+		if seen[string(issuer.Raw)] {
+			return chain, fmt.Errorf("%w: issuer loop at %q", ErrIncompleteChain, issuer.Subject.String())
+		}
+		seen[string(issuer.Raw)] = true
+		chain = append(chain, issuer)
+		current = issuer
+	}
+}
+
+// findIssuer returns the candidate whose subject matches the issuer of cert
+// and whose key verifies the signature of cert.
+func findIssuer(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for _, candidate := range candidates {
+		if !bytes.Equal(candidate.RawSubject, cert.RawIssuer) {
+			continue
+		}
+		if bytes.Equal(candidate.Raw, cert.Raw) {
+			continue
+		}
+		if cert.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
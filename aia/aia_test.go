@@ -0,0 +1,151 @@
+package aia
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+type testPKI struct {
+	root, intermediate, leaf *x509.Certificate
+	server                   *httptest.Server
+	hits                     atomic.Int32
+}
+
+func newTestCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, aiaURL string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if aiaURL != "" {
+		template.IssuingCertificateURL = []string{aiaURL}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// newTestPKI serves the intermediate as a PKCS#7 bundle and the root as DER.
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{}
+	mux := http.NewServeMux()
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	root, rootKey := newTestCert(t, "Root", true, nil, nil, "")
+	intermediate, intermediateKey := newTestCert(t, "Intermediate", true, root, rootKey, p.server.URL+"/root.cer")
+	leaf, _ := newTestCert(t, "Leaf", false, intermediate, intermediateKey, p.server.URL+"/intermediate.p7c")
+	p.root, p.intermediate, p.leaf = root, intermediate, leaf
+
+	bundle, err := pkcs7.DegenerateCertificate(intermediate.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/intermediate.p7c", func(w http.ResponseWriter, r *http.Request) {
+		p.hits.Add(1)
+		_, _ = w.Write(bundle)
+	})
+	mux.HandleFunc("/root.cer", func(w http.ResponseWriter, r *http.Request) {
+		p.hits.Add(1)
+		_, _ = w.Write(root.Raw)
+	})
+	return p
+}
+
+func TestCompleteChain(t *testing.T) {
+	p := newTestPKI(t)
+	f := NewFetcher(p.server.Client())
+
+	chain, err := f.CompleteChain(p.leaf, nil)
+	if err != nil {
+		t.Fatalf("CompleteChain() error = %v", err)
+	}
+	want := []*x509.Certificate{p.leaf, p.intermediate, p.root}
+	if len(chain) != len(want) {
+		t.Fatalf("chain length = %d, want %d", len(chain), len(want))
+	}
+	for i := range want {
+		if !chain[i].Equal(want[i]) {
+			t.Errorf("chain[%d] = %q, want %q", i, chain[i].Subject.CommonName, want[i].Subject.CommonName)
+		}
+	}
+
+	// A second run is served from the cache.
+	if _, err := f.CompleteChain(p.leaf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if hits := p.hits.Load(); hits != 2 {
+		t.Errorf("server hits = %d, want 2", hits)
+	}
+}
+
+func TestCompleteChainPrefersKnownCertificates(t *testing.T) {
+	p := newTestPKI(t)
+	f := NewFetcher(p.server.Client())
+
+	chain, err := f.CompleteChain(p.leaf, []*x509.Certificate{p.intermediate})
+	if err != nil {
+		t.Fatalf("CompleteChain() error = %v", err)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("chain length = %d, want 3", len(chain))
+	}
+	if hits := p.hits.Load(); hits != 1 {
+		t.Errorf("server hits = %d, want 1 (root only)", hits)
+	}
+}
+
+func TestCompleteChainLimits(t *testing.T) {
+	p := newTestPKI(t)
+
+	f := &Fetcher{Client: p.server.Client(), MaxDepth: 1}
+	chain, err := f.CompleteChain(p.leaf, nil)
+	if !errors.Is(err, ErrIncompleteChain) {
+		t.Fatalf("CompleteChain() error = %v, want ErrIncompleteChain", err)
+	}
+	if len(chain) != 2 {
+		t.Errorf("partial chain length = %d, want 2", len(chain))
+	}
+
+	f = &Fetcher{Client: p.server.Client(), MaxSize: 16}
+	if _, err := f.CompleteChain(p.leaf, nil); !errors.Is(err, ErrIncompleteChain) {
+		t.Errorf("CompleteChain() with tiny MaxSize error = %v, want ErrIncompleteChain", err)
+	}
+}
+
+func TestParseCertificatesRejectsGarbage(t *testing.T) {
+	if _, err := ParseCertificates([]byte("not a certificate")); err == nil {
+		t.Error("ParseCertificates() expected error")
+	}
+}
@@ -1,10 +1,17 @@
 package cli
 
 import (
+	"bytes"
+	"crypto"
+	"encoding/json"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/subnoto/pdfsign/sign"
+	"github.com/subnoto/pdfsign/verify"
 )
 
 func TestParseCertType(t *testing.T) {
@@ -40,6 +47,26 @@ func TestParseCertType(t *testing.T) {
 	}
 }
 
+func TestParseAccessPermissions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected sign.AccessPermission
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"print", sign.AllowPrinting, false},
+		{"print-hq, copy", sign.AllowPrinting | sign.AllowHighQualityPrinting | sign.AllowCopying, false},
+		{"fill-forms,annotate", sign.AllowFillingForms | sign.AllowAnnotations, false},
+		{"print,edit", 0, true},
+	}
+	for _, tt := range tests {
+		result, err := ParseAccessPermissions(tt.input)
+		if (err != nil) != tt.wantErr || result != tt.expected {
+			t.Errorf("ParseAccessPermissions(%q) = %#x, %v, want %#x", tt.input, result, err, tt.expected)
+		}
+	}
+}
+
 func TestUsage(t *testing.T) {
 	origArgs := os.Args
 	defer func() { os.Args = origArgs }()
@@ -129,3 +156,152 @@ func TestSignCommand_FlagParsing(t *testing.T) {
 		t.Error("SignPDF should not be called for insufficient args")
 	}
 }
+
+func TestVerdictExitCode(t *testing.T) {
+	tests := []struct {
+		verdict verify.Verdict
+		want    int
+	}{
+		{verify.Verdict{Status: verify.VerdictPassed}, ExitOK},
+		{verify.Verdict{Status: verify.VerdictInvalid, Violations: []verify.Violation{{Rule: verify.RuleIntegrity, Message: "signature does not verify"}}}, ExitInvalid},
+		{verify.Verdict{Status: verify.VerdictPolicyFailed, Violations: []verify.Violation{{Rule: verify.RuleMinSignatures, Signature: -1, Message: "too few"}}}, ExitPolicyFailed},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := verdictExitCode(&out, &tt.verdict); got != tt.want {
+			t.Errorf("verdictExitCode(%s) = %d, want %d", tt.verdict.Status, got, tt.want)
+		}
+		if !strings.Contains(out.String(), "Verdict: "+string(tt.verdict.Status)) {
+			t.Errorf("output %q does not report the verdict", out.String())
+		}
+		for _, v := range tt.verdict.Violations {
+			if !strings.Contains(out.String(), v.Rule+": "+v.Message) {
+				t.Errorf("output %q does not list violation %s", out.String(), v.Rule)
+			}
+		}
+	}
+}
+
+func TestInspectPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := InspectPDF(&buf, "../testfiles/testfile20.pdf", true); err != nil {
+		t.Fatalf("InspectPDF() error = %v", err)
+	}
+	var info sign.DocumentInfo
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		t.Fatalf("InspectPDF() printed invalid JSON: %v", err)
+	}
+	if info.Pages == 0 || info.Version == "" || strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("InspectPDF() = %s", buf.String())
+	}
+	if err := InspectPDF(&buf, "nonexistent.pdf", false); err == nil {
+		t.Error("InspectPDF() with a missing file: error = nil")
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ListRevisions(&buf, "../testfiles/testfile30.pdf", true); err != nil {
+		t.Fatalf("ListRevisions() error = %v", err)
+	}
+	var history sign.RevisionHistory
+	if err := json.Unmarshal(buf.Bytes(), &history); err != nil {
+		t.Fatalf("ListRevisions() printed invalid JSON: %v", err)
+	}
+	if len(history.Revisions) == 0 {
+		t.Fatalf("ListRevisions() = %s", buf.String())
+	}
+
+	output := filepath.Join(t.TempDir(), "original.pdf")
+	if err := ExtractRevision("../testfiles/testfile30.pdf", output, "", 1); err != nil {
+		t.Fatalf("ExtractRevision() error = %v", err)
+	}
+	if st, err := os.Stat(output); err != nil || st.Size() != history.Revisions[0].End {
+		t.Errorf("ExtractRevision() wrote %v, want %d bytes", st, history.Revisions[0].End)
+	}
+	if err := ExtractRevision("../testfiles/testfile30.pdf", output, "missing", 0); err == nil {
+		t.Error("ExtractRevision() for a missing signature field: error = nil")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("ExtractRevision() left %s behind on error", output)
+	}
+}
+
+func TestDiffPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := DiffPDF(&buf, "../testfiles/testfile50.pdf", "Signature 1", 0, 0, "text"); err != nil {
+		t.Fatalf("DiffPDF() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Changes after signature Signature 1, from revision 2 to 4") {
+		t.Errorf("DiffPDF() = %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := DiffPDF(&buf, "../testfiles/testfile50.pdf", "", 3, 4, "json"); err != nil {
+		t.Fatalf("DiffPDF() error = %v", err)
+	}
+	var diff sign.RevisionDiff
+	if err := json.Unmarshal(buf.Bytes(), &diff); err != nil {
+		t.Fatalf("DiffPDF() printed invalid JSON: %v", err)
+	}
+	if diff.From != 3 || diff.To != 4 || len(diff.Fields) == 0 {
+		t.Errorf("DiffPDF() = %s", buf.String())
+	}
+	if err := DiffPDF(&buf, "../testfiles/testfile50.pdf", "", 4, 3, "json"); err == nil {
+		t.Error("DiffPDF() from a later revision: error = nil")
+	}
+}
+
+func TestNewTSAAuthority(t *testing.T) {
+	certOut := filepath.Join(t.TempDir(), "tsa.crt")
+	authority, err := NewTSAAuthority(TSAOptions{
+		CertOut:          certOut,
+		Policy:           "1.3.6.1.4.1.99999.1",
+		AcceptedPolicies: "1.3.6.1.4.1.99999.2, 1.3.6.1.4.1.99999.3",
+		Accuracy:         time.Second,
+		Hash:             "sha384",
+	})
+	if err != nil {
+		t.Fatalf("NewTSAAuthority() error = %v", err)
+	}
+	if authority.Policy.String() != "1.3.6.1.4.1.99999.1" || len(authority.AcceptedPolicies) != 2 || authority.Hash != crypto.SHA384 {
+		t.Errorf("NewTSAAuthority() = %+v", authority)
+	}
+	if data, err := os.ReadFile(certOut); err != nil || !bytes.HasPrefix(data, []byte("-----BEGIN CERTIFICATE-----")) {
+		t.Errorf("generated certificate not written: %v", err)
+	}
+
+	for _, opts := range []TSAOptions{
+		{Policy: "1.2.x", Hash: "sha256"},
+		{Policy: "1.2.3", Hash: "md5"},
+		{Policy: "1.2.3", Hash: "sha256", CertPath: "tsa.crt"},
+	} {
+		if _, err := NewTSAAuthority(opts); err == nil {
+			t.Errorf("NewTSAAuthority(%+v) error = nil", opts)
+		}
+	}
+}
+
+func TestVerifyPDFErrorExitCode(t *testing.T) {
+	origExit, origDir, origPolicy := osExit, RevocationDir, PolicyFile
+	defer func() { osExit, RevocationDir, PolicyFile = origExit, origDir, origPolicy }()
+	var codes []int
+	osExit = func(code int) { codes = append(codes, code) }
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		name, input, dir, policy string
+	}{
+		{"input", missing, "", ""},
+		{"policy", "../testfiles/testfile30.pdf", "", missing},
+		{"revocation directory", "../testfiles/testfile30.pdf", missing, ""},
+	}
+	for _, tt := range tests {
+		codes = nil
+		RevocationDir, PolicyFile = tt.dir, tt.policy
+		VerifyPDF(tt.input, false, false, false, false, false, false, time.Second)
+		if len(codes) != 1 || codes[0] != ExitError {
+			t.Errorf("%s error: exit codes = %v, want [%d]", tt.name, codes, ExitError)
+		}
+	}
+}
@@ -8,8 +8,12 @@ import (
 func Usage() {
 	fmt.Printf("Usage: %s <command> [options] <args>\n\n", os.Args[0])
 	fmt.Println("Commands:")
-	fmt.Println("  sign    Sign a PDF file")
-	fmt.Println("  verify  Verify a PDF signature")
+	fmt.Println("  sign       Sign a PDF file")
+	fmt.Println("  verify     Verify a PDF signature")
+	fmt.Println("  inspect    Show the form fields and structure of a PDF")
+	fmt.Println("  revisions  List the revisions of a PDF or extract the one a signature covers")
+	fmt.Println("  diff       Show what changed after a signature or between two revisions")
+	fmt.Println("  tsa-server Run an RFC 3161 time-stamp authority")
 	fmt.Println("")
 	fmt.Printf("Use '%s <command> -h' for command-specific help\n", os.Args[0])
 	osExit(1)
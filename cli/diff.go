@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/subnoto/pdfsign/sign"
+)
+
+func DiffCommand() {
+	diffFlags := flag.NewFlagSet("diff", flag.ExitOnError)
+
+	var signature, format string
+	var from, to int
+	diffFlags.StringVar(&signature, "signature", "", "Compare the revision covered by the signature of this field (fully qualified name) to the last revision")
+	diffFlags.IntVar(&from, "from", 0, "First revision to compare (1 for the original document)")
+	diffFlags.IntVar(&to, "to", 0, "Last revision to compare (default the last revision)")
+	diffFlags.StringVar(&format, "format", "text", "Output format: text or json")
+
+	diffFlags.Usage = func() {
+		fmt.Printf("Usage: %s diff [options] <input.pdf>\n\n", os.Args[0])
+		fmt.Println("Show what changed in a PDF file after a signature, or between two of its revisions")
+		fmt.Println("\nOptions:")
+		diffFlags.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Printf("  %s diff -signature Signature1 signed.pdf\n", os.Args[0])
+		fmt.Printf("  %s diff -from 2 -to 3 -format json signed.pdf\n", os.Args[0])
+	}
+
+	if err := diffFlags.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse diff flags: %v", err)
+	}
+
+	if len(diffFlags.Args()) < 1 || (signature == "") == (from == 0) || format != "text" && format != "json" {
+		diffFlags.Usage()
+		osExit(ExitError)
+	}
+
+	if err := DiffPDF(os.Stdout, diffFlags.Arg(0), signature, from, to, format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		osExit(ExitError)
+	}
+}
+
+// DiffPDF writes the changes of the PDF file at input after the signature
+// of the field named signature, or else from revision from to revision to
+// (0 for the last revision), to w as text or JSON.
+func DiffPDF(w io.Writer, input, signature string, from, to int, format string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var diff *sign.RevisionDiff
+	if signature != "" {
+		diff, err = sign.DiffSignedRevision(f, st.Size(), signature)
+	} else {
+		diff, err = sign.DiffRevisions(f, st.Size(), from, to)
+	}
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	return diff.WriteText(w)
+}
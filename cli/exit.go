@@ -4,3 +4,11 @@ import "os"
 
 // Patchable os.Exit for testing
 var osExit = os.Exit
+
+// Exit codes of the verify command.
+const (
+	ExitOK           = 0 // every signature is intact and the policy holds
+	ExitError        = 1 // usage error or the file could not be verified
+	ExitInvalid      = 2 // at least one signature is broken
+	ExitPolicyFailed = 3 // signatures are intact but violate the policy
+)
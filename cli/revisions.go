@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/subnoto/pdfsign/sign"
+)
+
+func RevisionsCommand() {
+	revisionsFlags := flag.NewFlagSet("revisions", flag.ExitOnError)
+
+	var compact bool
+	var signature, output string
+	var revision int
+	revisionsFlags.BoolVar(&compact, "compact", false, "Print the JSON on a single line")
+	revisionsFlags.StringVar(&signature, "signature", "", "Write the revision covered by the signature of this field (fully qualified name) to -o")
+	revisionsFlags.IntVar(&revision, "revision", 0, "Write this revision (1 for the original document) to -o")
+	revisionsFlags.StringVar(&output, "o", "", "Output PDF file of -signature or -revision")
+
+	revisionsFlags.Usage = func() {
+		fmt.Printf("Usage: %s revisions [options] <input.pdf>\n\n", os.Args[0])
+		fmt.Println("List the incremental revisions of a PDF file as JSON, or extract one of them as a standalone PDF")
+		fmt.Println("\nOptions:")
+		revisionsFlags.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Printf("  %s revisions signed.pdf\n", os.Args[0])
+		fmt.Printf("  %s revisions -signature Signature1 -o as-signed.pdf signed.pdf\n", os.Args[0])
+		fmt.Printf("  %s revisions -revision 1 -o original.pdf signed.pdf\n", os.Args[0])
+	}
+
+	if err := revisionsFlags.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse revisions flags: %v", err)
+	}
+
+	if len(revisionsFlags.Args()) < 1 || (signature != "" || revision != 0) && output == "" || signature != "" && revision != 0 {
+		revisionsFlags.Usage()
+		osExit(ExitError)
+	}
+
+	input := revisionsFlags.Arg(0)
+	var err error
+	switch {
+	case signature != "" || revision != 0:
+		err = ExtractRevision(input, output, signature, revision)
+	default:
+		err = ListRevisions(os.Stdout, input, compact)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		osExit(ExitError)
+	}
+}
+
+// ListRevisions writes the revisions of the PDF file at input to w as JSON.
+func ListRevisions(w io.Writer, input string, compact bool) error {
+	history, err := sign.RevisionsFile(input)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	if !compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(history)
+}
+
+// ExtractRevision writes the revision of the PDF file at input covered by
+// the signature of the field named signature, or else revision number
+// revision, to output.
+func ExtractRevision(input, output, signature string, revision int) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if signature != "" {
+		_, err = sign.WriteSignedRevision(out, in, st.Size(), signature)
+	} else {
+		err = sign.WriteRevision(out, in, st.Size(), revision)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(output)
+	}
+	return err
+}
@@ -9,16 +9,107 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/subnoto/pdfsign/common"
 	"github.com/subnoto/pdfsign/sign"
 )
 
 var (
 	InfoName, InfoLocation, InfoReason, InfoContact, TSA string
 	CertType                                             string
+
+	// CompleteChain downloads intermediates missing from the supplied chain
+	// via the caIssuers URLs of the certificates before signing.
+	CompleteChain bool
+
+	// Password opens password-protected input documents.
+	Password string
+
+	// DecryptCert and DecryptKey name the recipient certificate and private
+	// key that open documents encrypted with the public-key security
+	// handler.
+	DecryptCert, DecryptKey string
+
+	// Recover opens PDFs with missing or broken cross-reference data by
+	// rebuilding it.
+	Recover bool
+
+	// EncryptOwnerPassword, when set, encrypts the signed document with
+	// AES-256; EncryptUserPassword and EncryptPermissions (a comma
+	// separated list, see ParseAccessPermissions) apply to its users.
+	EncryptOwnerPassword, EncryptUserPassword, EncryptPermissions string
 )
 
+// accessPermissionNames maps the names accepted by ParseAccessPermissions to
+// access permissions.
+var accessPermissionNames = map[string]sign.AccessPermission{
+	"print":      sign.AllowPrinting,
+	"print-hq":   sign.AllowPrinting | sign.AllowHighQualityPrinting,
+	"modify":     sign.AllowModifying,
+	"copy":       sign.AllowCopying,
+	"annotate":   sign.AllowAnnotations,
+	"fill-forms": sign.AllowFillingForms,
+	"extract":    sign.AllowExtracting,
+	"assemble":   sign.AllowAssembling,
+}
+
+// ParseAccessPermissions parses a comma separated list of access
+// permissions: print, print-hq, modify, copy, annotate, fill-forms, extract
+// and assemble.
+func ParseAccessPermissions(s string) (sign.AccessPermission, error) {
+	var perms sign.AccessPermission
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		p, ok := accessPermissionNames[name]
+		if !ok {
+			return 0, fmt.Errorf("invalid permission %q", name)
+		}
+		perms |= p
+	}
+	return perms, nil
+}
+
+// decryptionKey loads the recipient certificate and key named by
+// -decrypt-cert and -decrypt-key, or returns nil when they are not set.
+func decryptionKey() (*x509.Certificate, crypto.Decrypter) {
+	if DecryptCert == "" && DecryptKey == "" {
+		return nil, nil
+	}
+	if DecryptCert == "" || DecryptKey == "" {
+		log.Fatal("-decrypt-cert and -decrypt-key must be used together")
+	}
+	cert, key, _ := LoadCertificatesAndKey(DecryptCert, DecryptKey, "")
+	decrypter, ok := key.(crypto.Decrypter)
+	if !ok {
+		log.Fatal("the -decrypt-key private key cannot decrypt")
+	}
+	return cert, decrypter
+}
+
+// encryption returns the encryption requested by the -encrypt flags, or nil.
+func encryption() (*sign.Encryption, error) {
+	if EncryptOwnerPassword == "" {
+		if EncryptUserPassword != "" || EncryptPermissions != "" {
+			return nil, errors.New("-encrypt-owner-password is required to encrypt the output")
+		}
+		return nil, nil
+	}
+	perms, err := ParseAccessPermissions(EncryptPermissions)
+	if err != nil {
+		return nil, err
+	}
+	return &sign.Encryption{
+		UserPassword:  EncryptUserPassword,
+		OwnerPassword: EncryptOwnerPassword,
+		Permissions:   perms,
+	}, nil
+}
+
 func ParseCertType(s string) (sign.CertType, error) {
 	switch s {
 	case sign.CertificationSignature.String():
@@ -42,6 +133,14 @@ func SignCommand() {
 	signFlags.StringVar(&InfoReason, "reason", "", "Reason for signing")
 	signFlags.StringVar(&InfoContact, "contact", "", "Contact information for signatory")
 	signFlags.StringVar(&TSA, "tsa", "https://freetsa.org/tsr", "URL for Time-Stamp Authority")
+	signFlags.BoolVar(&CompleteChain, "complete-chain", false, "Download missing intermediate certificates from caIssuers URLs")
+	signFlags.StringVar(&Password, "password", "", "User or owner password of an encrypted input PDF")
+	signFlags.StringVar(&DecryptCert, "decrypt-cert", "", "Recipient certificate of a PDF encrypted with certificates (public-key security)")
+	signFlags.StringVar(&DecryptKey, "decrypt-key", "", "Private key of the -decrypt-cert recipient certificate")
+	signFlags.BoolVar(&Recover, "recover", false, "Rebuild missing or broken cross-reference data of the input PDF (the PDF is rewritten before signing)")
+	signFlags.StringVar(&EncryptOwnerPassword, "encrypt-owner-password", "", "Encrypt the signed PDF with AES-256 and this owner password")
+	signFlags.StringVar(&EncryptUserPassword, "encrypt-user-password", "", "User password of the encrypted PDF (default none)")
+	signFlags.StringVar(&EncryptPermissions, "encrypt-permissions", "", "Comma separated permissions of users of the encrypted PDF: print, print-hq, modify, copy, annotate, fill-forms, extract, assemble")
 	signFlags.StringVar(&CertType, "certType", "CertificationSignature", "Type of the certificate (CertificationSignature, ApprovalSignature, UsageRightsSignature, TimeStampSignature)")
 
 	signFlags.Usage = func() {
@@ -52,6 +151,9 @@ func SignCommand() {
 		fmt.Println("\nExamples:")
 		fmt.Printf("  %s sign -name \"John Doe\" input.pdf output.pdf cert.crt key.key\n", os.Args[0])
 		fmt.Printf("  %s sign -certType \"TimeStampSignature\" input.pdf output.pdf\n", os.Args[0])
+		fmt.Printf("  %s sign -password secret protected.pdf output.pdf cert.crt key.key\n", os.Args[0])
+		fmt.Printf("  %s sign -decrypt-cert me.crt -decrypt-key me.key encrypted.pdf output.pdf cert.crt key.key\n", os.Args[0])
+		fmt.Printf("  %s sign -encrypt-owner-password secret -encrypt-permissions print input.pdf output.pdf cert.crt key.key\n", os.Args[0])
 	}
 
 	if err := signFlags.Parse(os.Args[2:]); err != nil {
@@ -75,6 +177,11 @@ func signPDFImpl(input string, args []string) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	encrypt, err := encryption()
+	if err != nil {
+		log.Fatal(err)
+	}
+	decryptCert, decryptKey := decryptionKey()
 
 	if certTypeValue == sign.TimeStampSignature {
 		if len(args) < 2 {
@@ -82,7 +189,7 @@ func signPDFImpl(input string, args []string) {
 			osExit(1)
 		}
 		output := args[1]
-		TimeStampPDF(input, output, TSA)
+		timeStampPDF(input, output, TSA, encrypt, decryptCert, decryptKey)
 		return
 	}
 
@@ -101,7 +208,7 @@ func signPDFImpl(input string, args []string) {
 
 	cert, pkey, certificateChains := LoadCertificatesAndKey(certPath, keyPath, chainPath)
 
-	_, err = sign.SignFile(input, output, sign.SignData{
+	info, err := sign.SignFile(input, output, sign.SignData{
 		Signature: sign.SignDataSignature{
 			Info: sign.SignDataSignatureInfo{
 				Name:        InfoName,
@@ -113,10 +220,16 @@ func signPDFImpl(input string, args []string) {
 			CertType:   certTypeValue,
 			DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
 		},
-		Signer:            pkey,
-		DigestAlgorithm:   crypto.SHA256,
-		Certificate:       cert,
-		CertificateChains: certificateChains,
+		Signer:                pkey,
+		DigestAlgorithm:       crypto.SHA256,
+		Certificate:           cert,
+		CertificateChains:     certificateChains,
+		CompleteChain:         CompleteChain,
+		Password:              Password,
+		Encryption:            encrypt,
+		DecryptionCertificate: decryptCert,
+		DecryptionKey:         decryptKey,
+		Recover:               Recover,
 		TSA: sign.TSA{
 			URL: TSA,
 		},
@@ -124,6 +237,7 @@ func signPDFImpl(input string, args []string) {
 	if err != nil {
 		log.Println(err)
 	} else {
+		logWarnings(info)
 		log.Println("Signed PDF written to " + output)
 	}
 }
@@ -196,11 +310,20 @@ func LoadCertificateChain(chainPath string, cert *x509.Certificate) [][]*x509.Ce
 }
 
 func TimeStampPDF(input, output, tsa string) {
-	_, err := sign.SignFile(input, output, sign.SignData{
+	timeStampPDF(input, output, tsa, nil, nil, nil)
+}
+
+func timeStampPDF(input, output, tsa string, encrypt *sign.Encryption, decryptCert *x509.Certificate, decryptKey crypto.Decrypter) {
+	info, err := sign.SignFile(input, output, sign.SignData{
 		Signature: sign.SignDataSignature{
 			CertType: sign.TimeStampSignature,
 		},
-		DigestAlgorithm: crypto.SHA256,
+		DigestAlgorithm:       crypto.SHA256,
+		Password:              Password,
+		Encryption:            encrypt,
+		DecryptionCertificate: decryptCert,
+		DecryptionKey:         decryptKey,
+		Recover:               Recover,
 		TSA: sign.TSA{
 			URL: tsa,
 		},
@@ -208,6 +331,18 @@ func TimeStampPDF(input, output, tsa string) {
 	if err != nil {
 		log.Println(err)
 	} else {
+		logWarnings(info)
 		log.Println("Signed PDF written to " + output)
 	}
 }
+
+// logWarnings logs what was repaired to read the signed document and the
+// changes made although its signatures do not permit them.
+func logWarnings(info *common.SignatureInfo) {
+	for _, repair := range info.Repairs {
+		log.Printf("Warning: repaired document: %s", repair)
+	}
+	for _, warning := range info.Warnings {
+		log.Printf("Warning: %s", warning)
+	}
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"crypto"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subnoto/pdfsign/tsa"
+)
+
+// TSAOptions configures the time-stamp authority of the tsa-server command.
+type TSAOptions struct {
+	// CertPath, KeyPath and ChainPath name the TSA certificate, its RSA
+	// private key and the certificate chain. Without a certificate and key,
+	// a self-signed certificate is generated and written to CertOut.
+	CertPath, KeyPath, ChainPath string
+	CertOut                      string
+
+	// Policy is the policy OID of the tokens and AcceptedPolicies a comma
+	// separated list of other policy OIDs clients may request.
+	Policy, AcceptedPolicies string
+
+	// Accuracy is the accuracy stated in the tokens.
+	Accuracy time.Duration
+
+	// Hash names the digest of the token signature: sha256, sha384 or
+	// sha512.
+	Hash string
+}
+
+func TSAServerCommand() {
+	tsaFlags := flag.NewFlagSet("tsa-server", flag.ExitOnError)
+
+	var addr string
+	var opts TSAOptions
+	tsaFlags.StringVar(&addr, "addr", "localhost:3161", "Address to listen on")
+	tsaFlags.StringVar(&opts.CertPath, "cert", "", "TSA certificate (with the critical timeStamping extended key usage)")
+	tsaFlags.StringVar(&opts.KeyPath, "key", "", "Private RSA key of the TSA certificate")
+	tsaFlags.StringVar(&opts.ChainPath, "chain", "", "Certificate chain of the TSA certificate")
+	tsaFlags.StringVar(&opts.CertOut, "cert-out", "", "Write the generated certificate to this file when -cert and -key are not given")
+	tsaFlags.StringVar(&opts.Policy, "policy", tsa.DefaultPolicy.String(), "Policy OID of the tokens")
+	tsaFlags.StringVar(&opts.AcceptedPolicies, "accept-policies", "", "Comma separated list of other policy OIDs that requests may name")
+	tsaFlags.DurationVar(&opts.Accuracy, "accuracy", time.Second, "Accuracy of the time in the tokens (0 to omit)")
+	tsaFlags.StringVar(&opts.Hash, "hash", "sha256", "Digest of the token signature: sha256, sha384 or sha512")
+
+	tsaFlags.Usage = func() {
+		fmt.Printf("Usage: %s tsa-server [options]\n\n", os.Args[0])
+		fmt.Println("Run an RFC 3161 time-stamp authority over HTTP")
+		fmt.Println("\nOptions:")
+		tsaFlags.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Printf("  %s tsa-server -cert-out tsa.crt\n", os.Args[0])
+		fmt.Printf("  %s tsa-server -addr :3161 -cert tsa.crt -key tsa.key -chain chain.pem -policy 1.3.6.1.4.1.99999.1\n", os.Args[0])
+		fmt.Printf("  %s sign -tsa http://localhost:3161 input.pdf output.pdf cert.crt key.key\n", os.Args[0])
+	}
+
+	if err := tsaFlags.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse tsa-server flags: %v", err)
+	}
+
+	if len(tsaFlags.Args()) > 0 {
+		tsaFlags.Usage()
+		osExit(ExitError)
+	}
+
+	authority, err := NewTSAAuthority(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		osExit(ExitError)
+	}
+
+	log.Printf("Time-stamp authority %q listening on %s", authority.Certificate.Subject.String(), addr)
+	server := &http.Server{Addr: addr, Handler: authority, ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		osExit(ExitError)
+	}
+}
+
+// NewTSAAuthority returns the time-stamp authority configured by opts.
+func NewTSAAuthority(opts TSAOptions) (*tsa.Authority, error) {
+	authority := &tsa.Authority{Accuracy: opts.Accuracy}
+
+	var err error
+	if authority.Policy, err = ParseOID(opts.Policy); err != nil {
+		return nil, err
+	}
+	if opts.AcceptedPolicies != "" {
+		for _, s := range strings.Split(opts.AcceptedPolicies, ",") {
+			oid, err := ParseOID(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+			authority.AcceptedPolicies = append(authority.AcceptedPolicies, oid)
+		}
+	}
+	switch opts.Hash {
+	case "sha256":
+		authority.Hash = crypto.SHA256
+	case "sha384":
+		authority.Hash = crypto.SHA384
+	case "sha512":
+		authority.Hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("unsupported token digest %q", opts.Hash)
+	}
+
+	switch {
+	case opts.CertPath != "" && opts.KeyPath != "":
+		cert, key, chains := LoadCertificatesAndKey(opts.CertPath, opts.KeyPath, opts.ChainPath)
+		authority.Certificate, authority.Signer = cert, key
+		if len(chains) > 0 {
+			authority.Chain = chains[0][1:]
+		}
+	case opts.CertPath != "" || opts.KeyPath != "":
+		return nil, errors.New("-cert and -key must be used together")
+	default:
+		authority.Certificate, authority.Signer, err = tsa.GenerateCertificate("pdfsign test TSA", 365*24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Warning: no -cert and -key given, the tokens are signed with a generated self-signed certificate")
+		if opts.CertOut != "" {
+			certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: authority.Certificate.Raw})
+			if err := os.WriteFile(opts.CertOut, certPEM, 0o644); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return authority, nil
+}
+
+// ParseOID parses an object identifier in dotted notation.
+func ParseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
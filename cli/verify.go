@@ -1,16 +1,45 @@
 package cli
 
 import (
+	"crypto"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"time"
 
+	"github.com/subnoto/pdfsign/report"
+	"github.com/subnoto/pdfsign/sign"
 	"github.com/subnoto/pdfsign/verify"
 )
 
+// RevocationDir is a directory of DER CRLs and OCSP responses supplied for
+// offline revocation checking.
+var RevocationDir string
+
+// FetchAIA enables downloading missing intermediate certificates from the
+// caIssuers URLs of the embedded certificates.
+var FetchAIA bool
+
+// OutputFormat selects how verification results are printed: "json" (the
+// raw verify.Response), "etsi-xml", "etsi-json", "text" or "pdf".
+var OutputFormat string
+
+// PolicyFile names a JSON or YAML verification policy. If empty,
+// verify.DefaultPolicy is applied.
+var PolicyFile string
+
+// ReportCert, ReportKey and ReportChain name the service certificate, key and
+// optional chain used to sign the PDF report (-format pdf).
+var (
+	ReportCert  string
+	ReportKey   string
+	ReportChain string
+)
+
 func VerifyCommand() {
 	verifyFlags := flag.NewFlagSet("verify", flag.ExitOnError)
 
@@ -29,16 +58,42 @@ func VerifyCommand() {
 	verifyFlags.BoolVar(&validateTimestampCertificates, "validate-timestamp-certs", true, "Validate timestamp token certificates")
 	verifyFlags.BoolVar(&allowUntrustedRoots, "allow-untrusted-roots", false, "Allow certificates embedded in the PDF to be used as trusted roots (use with caution)")
 	verifyFlags.DurationVar(&httpTimeout, "http-timeout", 10*time.Second, "Timeout for external revocation checking requests")
+	verifyFlags.StringVar(&RevocationDir, "revocation-dir", "", "Directory of DER CRLs and OCSP responses to use for offline revocation checking")
+	verifyFlags.BoolVar(&FetchAIA, "aia", false, "Download missing intermediate certificates from caIssuers URLs")
+	verifyFlags.StringVar(&OutputFormat, "format", "json", "Output format: json, etsi-xml (ETSI TS 119 102-2 report), etsi-json, text or pdf")
+	verifyFlags.StringVar(&PolicyFile, "policy", "", "JSON or YAML verification policy deciding the exit code")
+	verifyFlags.StringVar(&DecryptCert, "decrypt-cert", "", "Recipient certificate of a PDF encrypted with certificates (public-key security)")
+	verifyFlags.StringVar(&DecryptKey, "decrypt-key", "", "Private key of the -decrypt-cert recipient certificate")
+	verifyFlags.StringVar(&Password, "password", "", "User or owner password of an encrypted PDF")
+	verifyFlags.BoolVar(&Recover, "recover", false, "Rebuild missing or broken cross-reference data and report the repairs")
+	verifyFlags.StringVar(&ReportCert, "report-cert", "", "Certificate used to sign the PDF report (-format pdf)")
+	verifyFlags.StringVar(&ReportKey, "report-key", "", "Private key used to sign the PDF report (-format pdf)")
+	verifyFlags.StringVar(&ReportChain, "report-chain", "", "Certificate chain of the report signing certificate")
 
 	verifyFlags.Usage = func() {
 		fmt.Printf("Usage: %s verify [options] <input.pdf>\n\n", os.Args[0])
-		fmt.Println("Verify the digital signature of a PDF file")
+		fmt.Println("Verify the digital signature of a PDF file (- reads it from standard input)")
 		fmt.Println("\nOptions:")
 		verifyFlags.PrintDefaults()
+		fmt.Println("\nExit codes:")
+		fmt.Println("  0  all signatures are intact and the policy is satisfied")
+		fmt.Println("  1  usage error or the file could not be verified")
+		fmt.Println("  2  at least one signature is invalid")
+		fmt.Println("  3  signatures are intact but the policy is not satisfied")
 		fmt.Println("\nExamples:")
 		fmt.Printf("  %s verify document.pdf\n", os.Args[0])
 		fmt.Printf("  %s verify -external -http-timeout=30s document.pdf\n", os.Args[0])
 		fmt.Printf("  %s verify -allow-untrusted-roots self-signed.pdf\n", os.Args[0])
+		fmt.Printf("  %s verify -revocation-dir ./revocation document.pdf\n", os.Args[0])
+		fmt.Printf("  %s verify -aia document.pdf\n", os.Args[0])
+		fmt.Printf("  %s verify -format etsi-xml document.pdf > document.validation.xml\n", os.Args[0])
+		fmt.Printf("  %s verify -policy policy.json document.pdf\n", os.Args[0])
+		fmt.Printf("  %s verify -policy policy.yaml document.pdf\n", os.Args[0])
+		fmt.Printf("  %s verify -format text document.pdf\n", os.Args[0])
+		fmt.Printf("  %s verify -password secret encrypted.pdf\n", os.Args[0])
+		fmt.Printf("  curl -s https://example.com/document.pdf | %s verify -\n", os.Args[0])
+		fmt.Printf("  %s verify -decrypt-cert me.crt -decrypt-key me.key encrypted.pdf\n", os.Args[0])
+		fmt.Printf("  %s verify -format pdf -report-cert service.crt -report-key service.key document.pdf > report.pdf\n", os.Args[0])
 	}
 
 	if err := verifyFlags.Parse(os.Args[2:]); err != nil {
@@ -47,7 +102,7 @@ func VerifyCommand() {
 
 	if len(verifyFlags.Args()) < 1 {
 		verifyFlags.Usage()
-		osExit(1)
+		osExit(ExitError)
 	}
 
 	input := verifyFlags.Arg(0)
@@ -57,15 +112,21 @@ func VerifyCommand() {
 
 func VerifyPDF(input string, enableExternalRevocation, requireDigitalSignatureKU, requireNonRepudiation,
 	trustSignatureTime, validateTimestampCertificates, allowUntrustedRoots bool, httpTimeout time.Duration) {
-	inputFile, err := os.Open(input)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer func() {
-		if err := inputFile.Close(); err != nil {
-			log.Printf("Warning: failed to close input file: %v", err)
+	var err error
+	inputFile := os.Stdin
+	if input != "-" {
+		inputFile, err = os.Open(input)
+		if err != nil {
+			fmt.Println(err)
+			osExit(ExitError)
+			return
 		}
-	}()
+		defer func() {
+			if err := inputFile.Close(); err != nil {
+				log.Printf("Warning: failed to close input file: %v", err)
+			}
+		}()
+	}
 
 	options := verify.DefaultVerifyOptions()
 	options.EnableExternalRevocationCheck = enableExternalRevocation
@@ -75,17 +136,135 @@ func VerifyPDF(input string, enableExternalRevocation, requireDigitalSignatureKU
 	options.ValidateTimestampCertificates = validateTimestampCertificates
 	options.AllowUntrustedRoots = allowUntrustedRoots
 	options.HTTPTimeout = httpTimeout
+	options.EnableAIAFetching = FetchAIA
+	options.DecryptionCertificate, options.DecryptionKey = decryptionKey()
+	options.Password = Password
+	options.Recover = Recover
 
-	resp, err := verify.VerifyFileWithOptions(inputFile, options)
+	policy := verify.DefaultPolicy()
+	if PolicyFile != "" {
+		policy, err = verify.LoadPolicy(PolicyFile)
+		if err != nil {
+			fmt.Println(err)
+			osExit(ExitError)
+			return
+		}
+	}
+
+	if RevocationDir != "" {
+		source, err := verify.LoadRevocationDirectory(RevocationDir)
+		if err != nil {
+			fmt.Println(err)
+			osExit(ExitError)
+			return
+		}
+		options.RevocationSource = source
+	}
+
+	// Standard input is spooled to a temporary file unless it is a file.
+	resp, err := verify.VerifyReader(inputFile, options)
 	if err != nil {
 		fmt.Println(err)
-		osExit(1)
+		// A document without signatures is a policy outcome, not a failure
+		// to verify.
+		if errors.Is(err, verify.ErrNoSignature) {
+			osExit(verdictExitCode(os.Stderr, policy.Evaluate(nil)))
+			return
+		}
+		osExit(ExitError)
+		return
 	}
 
-	jsonData, err := json.Marshal(resp)
-	if err != nil {
+	if err := writeVerifyOutput(os.Stdout, resp, OutputFormat, input); err != nil {
 		fmt.Println(err)
-		osExit(1)
+		osExit(ExitError)
+		return
+	}
+
+	osExit(verdictExitCode(os.Stderr, policy.Evaluate(resp)))
+}
+
+// verdictExitCode reports the verdict and its violations on w and returns the
+// matching exit code.
+func verdictExitCode(w io.Writer, verdict *verify.Verdict) int {
+	_, _ = fmt.Fprintf(w, "Verdict: %s\n", verdict.Status)
+	for _, v := range verdict.Violations {
+		if v.Signature < 0 {
+			_, _ = fmt.Fprintf(w, "  %s: %s\n", v.Rule, v.Message)
+		} else {
+			_, _ = fmt.Fprintf(w, "  signature %d: %s: %s\n", v.Signature+1, v.Rule, v.Message)
+		}
+	}
+
+	switch verdict.Status {
+	case verify.VerdictPassed:
+		return ExitOK
+	case verify.VerdictInvalid:
+		return ExitInvalid
+	default:
+		return ExitPolicyFailed
+	}
+}
+
+// writeVerifyOutput prints resp in the requested output format. source names
+// the verified file in the human-readable reports.
+func writeVerifyOutput(w io.Writer, resp *verify.Response, format, source string) error {
+	switch format {
+	case "", "json":
+		jsonData, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(jsonData))
+		return err
+	case "etsi-xml":
+		return report.ETSI(resp, time.Now()).WriteXML(w)
+	case "etsi-json":
+		return report.ETSIJSON(resp, time.Now()).WriteJSON(w)
+	case "text":
+		return report.WriteText(w, resp, report.TextOptions{
+			Color:  useColor(w),
+			Source: source,
+		})
+	case "pdf":
+		opts := report.PDFOptions{Source: source}
+		if ReportCert != "" || ReportKey != "" {
+			if ReportCert == "" || ReportKey == "" {
+				return fmt.Errorf("both -report-cert and -report-key are required to sign the report")
+			}
+			cert, pkey, chains := LoadCertificatesAndKey(ReportCert, ReportKey, ReportChain)
+			opts.Sign = &sign.SignData{
+				Signature: sign.SignDataSignature{
+					Info: sign.SignDataSignatureInfo{
+						Name:   cert.Subject.CommonName,
+						Reason: "Signature validation report",
+						Date:   time.Now().Local(),
+					},
+					CertType:   sign.CertificationSignature,
+					DocMDPPerm: sign.DoNotAllowAnyChangesPerms,
+				},
+				Signer:            pkey,
+				DigestAlgorithm:   crypto.SHA256,
+				Certificate:       cert,
+				CertificateChains: chains,
+			}
+		}
+		return report.WritePDF(w, resp, opts)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// useColor reports whether coloured output should be written to w: only for
+// terminals, and never when NO_COLOR is set.
+func useColor(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
 	}
-	fmt.Println(string(jsonData))
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }
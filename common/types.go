@@ -38,6 +38,39 @@ type SignatureInfo struct {
 	DocumentHash  string               `json:"document_hash"`
 	SignatureHash string               `json:"signature_hash"`
 	HashAlgorithm string               `json:"hash_algorithm"`
+	SubFilter     string               `json:"sub_filter,omitempty"`
+
+	// CoversWholeDocument reports whether the ByteRange spans the whole
+	// file apart from the signature value, i.e. nothing was appended after
+	// this signature.
+	CoversWholeDocument bool `json:"covers_whole_document"`
+	// SignedLength is the length of the revision the signature covers: the
+	// end of the second ByteRange segment.
+	SignedLength int64 `json:"signed_length,omitempty"`
+
+	// Field is the fully qualified name of the signature field and Filter
+	// the signature handler (e.g. Adobe.PPKLite).
+	Field  string `json:"field,omitempty"`
+	Filter string `json:"filter,omitempty"`
+	// Widgets are the widget annotations of the signature field. Visible
+	// reports whether one of them is shown on a page with a non-empty area.
+	Widgets []SignatureWidget `json:"widgets,omitempty"`
+	Visible bool              `json:"visible"`
+
+	// Repairs describes what was repaired to read the document before it
+	// was signed (see SignData.Recover), including the objects that could
+	// not be read and were left out when it was rewritten.
+	Repairs []string `json:"repairs,omitempty"`
+	// Warnings lists the changes made although the document's signatures
+	// do not permit them (see SignData.PermissionPolicy).
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// SignatureWidget is a widget annotation of a signature field.
+type SignatureWidget struct {
+	// Page is the 1-based page number, 0 when the widget is on no page.
+	Page int        `json:"page"`
+	Rect [4]float64 `json:"rect"`
 }
 
 // Certificate contains certificate information and validation results.
@@ -45,6 +78,7 @@ type SignatureInfo struct {
 type Certificate struct {
 	Certificate          *x509.Certificate `json:"certificate"`
 	VerifyError          string            `json:"verify_error"`
+	SigningCertificate   bool              `json:"signing_certificate"` // Whether this certificate created the signature
 	KeyUsageValid        bool              `json:"key_usage_valid"`
 	KeyUsageError        string            `json:"key_usage_error,omitempty"`
 	ExtKeyUsageValid     bool              `json:"ext_key_usage_valid"`
@@ -55,12 +89,14 @@ type Certificate struct {
 	OCSPExternalChecked  bool              `json:"ocsp_external_checked"`  // Whether external OCSP check was attempted
 	OCSPExternalValid    bool              `json:"ocsp_external_valid"`    // Whether external OCSP check succeeded
 	OCSPExternalWarning  string            `json:"ocsp_external_warning,omitempty"` // Warning if external OCSP check failed
+	OCSPSource           string            `json:"ocsp_source,omitempty"`           // Which source answered: "embedded", "external" or a supplied source origin
 	CRLRevoked           time.Time         `json:"crl_revoked"`
 	CRLEmbedded          bool              `json:"crl_embedded"`
 	CRLExternal          bool              `json:"crl_external"`
 	CRLExternalChecked   bool              `json:"crl_external_checked"`   // Whether external CRL check was attempted
 	CRLExternalValid     bool              `json:"crl_external_valid"`      // Whether external CRL check succeeded
 	CRLExternalWarning   string            `json:"crl_external_warning,omitempty"` // Warning if external CRL check failed
+	CRLSource            string            `json:"crl_source,omitempty"`            // Which source answered: "embedded", "external" or a supplied source origin
 	RevocationWarning    string            `json:"revocation_warning,omitempty"`
 	RevocationTime       *time.Time        `json:"revocation_time,omitempty"` // When the certificate was revoked (if applicable)
 	RevokedBeforeSigning bool              `json:"revoked_before_signing"`    // Whether revocation occurred before signing
@@ -0,0 +1,461 @@
+package report
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/subnoto/pdfsign/common"
+	"github.com/subnoto/pdfsign/verify"
+)
+
+// ETSI TS 119 102-2 namespaces and URIs.
+const (
+	ETSINamespace   = "http://uri.etsi.org/19102/v1.2.1#"
+	sha256AlgURI    = "http://www.w3.org/2001/04/xmlenc#sha256"
+	mainIndication  = "urn:etsi:019102:mainindication:"
+	subIndication   = "urn:etsi:019102:subindication:"
+	validationProc  = "urn:etsi:019102:validationprocess:Basic"
+	poeValidation   = "urn:etsi:019102:poetype:validation"
+	poeProvided     = "urn:etsi:019102:poetype:provided"
+	voCertificate   = "urn:etsi:019102:validationObject:certificate"
+	voOCSPResponse  = "urn:etsi:019102:validationObject:OCSPResponse"
+	voTimestamp     = "urn:etsi:019102:validationObject:timestamp"
+	findingDataType = "urn:pdfsign:finding:"
+)
+
+// ValidationReport is the root element of an ETSI TS 119 102-2 validation
+// report.
+type ValidationReport struct {
+	XMLName                    xml.Name                    `xml:"http://uri.etsi.org/19102/v1.2.1# ValidationReport"`
+	SignatureValidationReports []SignatureValidationReport `xml:"SignatureValidationReport"`
+	SignatureValidationObjects *ValidationObjectList       `xml:"SignatureValidationObjects,omitempty"`
+}
+
+// SignatureValidationReport reports on a single signature.
+type SignatureValidationReport struct {
+	SignatureIdentifier        SignatureIdentifier        `xml:"SignatureIdentifier"`
+	ValidationTimeInfo         ValidationTimeInfo         `xml:"ValidationTimeInfo"`
+	SignatureAttributes        *SignatureAttributes       `xml:"SignatureAttributes,omitempty"`
+	SignerInformation          *SignerInformation         `xml:"SignerInformation,omitempty"`
+	SignatureValidationProcess SignatureValidationProcess `xml:"SignatureValidationProcess"`
+	SignatureValidationStatus  ValidationStatus           `xml:"SignatureValidationStatus"`
+}
+
+// SignatureIdentifier identifies the signature by the digest of its value.
+type SignatureIdentifier struct {
+	ID                string             `xml:"id,attr"`
+	DigestAlgAndValue *DigestAlgAndValue `xml:"DigestAlgAndValue,omitempty"`
+	HashOnly          bool               `xml:"HashOnly"`
+	DocHashOnly       bool               `xml:"DocHashOnly"`
+}
+
+// DigestAlgAndValue holds a digest and the algorithm that produced it.
+type DigestAlgAndValue struct {
+	DigestMethod DigestMethod `xml:"http://www.w3.org/2000/09/xmldsig# DigestMethod"`
+	DigestValue  string       `xml:"http://www.w3.org/2000/09/xmldsig# DigestValue"` // base64
+}
+
+// DigestMethod is the xmldsig digest method element.
+type DigestMethod struct {
+	Algorithm string `xml:"Algorithm,attr"`
+}
+
+// ValidationTimeInfo states when the validation took place and the best
+// available proof of existence of the signature.
+type ValidationTimeInfo struct {
+	ValidationTime    time.Time `xml:"ValidationTime"`
+	BestSignatureTime *POE      `xml:"BestSignatureTime,omitempty"`
+}
+
+// POE is a proof of existence.
+type POE struct {
+	POETime     time.Time `xml:"POETime"`
+	TypeOfProof string    `xml:"TypeOfProof"`
+}
+
+// SignatureAttributes lists the PDF signature attributes.
+type SignatureAttributes struct {
+	SigningTime        *SigningTime        `xml:"SigningTime,omitempty"`
+	SignatureTimeStamp *SignatureTimeStamp `xml:"SignatureTimeStamp,omitempty"`
+	Name               string              `xml:"Name,omitempty"`
+	ContactInfo        string              `xml:"ContactInfo,omitempty"`
+	Reason             string              `xml:"Reason,omitempty"`
+	Location           string              `xml:"Location,omitempty"`
+}
+
+// SigningTime is the claimed signing time.
+type SigningTime struct {
+	Signed bool      `xml:"Signed,attr"`
+	Time   time.Time `xml:"Time"`
+}
+
+// SignatureTimeStamp refers to the signature timestamp validation object.
+type SignatureTimeStamp struct {
+	Signed          bool      `xml:"Signed,attr"`
+	TimeStampValue  time.Time `xml:"TimeStampValue"`
+	AttributeObject VORef     `xml:"AttributeObject"`
+}
+
+// VORef references a validation object by its id.
+type VORef struct {
+	VOReference string `xml:"VOReference,attr"`
+}
+
+// SignerInformation identifies the signer.
+type SignerInformation struct {
+	SignerCertificate VORef  `xml:"SignerCertificate"`
+	Signer            string `xml:"Signer,omitempty"`
+}
+
+// SignatureValidationProcess names the validation process applied.
+type SignatureValidationProcess struct {
+	SignatureValidationProcessID string `xml:"SignatureValidationProcessID"`
+}
+
+// ValidationStatus carries the main and sub indication.
+type ValidationStatus struct {
+	MainIndication                 string                 `xml:"MainIndication"`
+	SubIndication                  []string               `xml:"SubIndication,omitempty"`
+	AssociatedValidationReportData []ValidationReportData `xml:"AssociatedValidationReportData,omitempty"`
+}
+
+// ValidationReportData holds the certificate chain, revocation status and
+// additional data backing an indication.
+type ValidationReportData struct {
+	CertificateChain               *CertificateChain           `xml:"CertificateChain,omitempty"`
+	RevocationStatusInformation    []RevocationStatusInfo      `xml:"RevocationStatusInformation,omitempty"`
+	AdditionalValidationReportData *AdditionalValidationReport `xml:"AdditionalValidationReportData,omitempty"`
+}
+
+// CertificateChain lists the certificates used to validate the signature.
+type CertificateChain struct {
+	SigningCertificate      VORef   `xml:"SigningCertificate"`
+	IntermediateCertificate []VORef `xml:"IntermediateCertificate,omitempty"`
+	TrustAnchor             *VORef  `xml:"TrustAnchor,omitempty"`
+}
+
+// RevocationStatusInfo reports a revoked certificate.
+type RevocationStatusInfo struct {
+	ValidationObjectID VORef     `xml:"ValidationObjectId"`
+	RevocationTime     time.Time `xml:"RevocationTime"`
+	RevocationObject   *VORef    `xml:"RevocationObject,omitempty"`
+}
+
+// AdditionalValidationReport carries findings that have no dedicated element.
+type AdditionalValidationReport struct {
+	ReportData []ReportData `xml:"ReportData"`
+}
+
+// ReportData is a typed free-form value.
+type ReportData struct {
+	Type  string `xml:"Type"`
+	Value string `xml:"Value"`
+}
+
+// ValidationObjectList lists the objects referenced from the reports.
+type ValidationObjectList struct {
+	ValidationObjects []ValidationObject `xml:"ValidationObject"`
+}
+
+// ValidationObject is a certificate, revocation response or timestamp used
+// during validation.
+type ValidationObject struct {
+	ID                             string           `xml:"id,attr"`
+	ObjectType                     string           `xml:"ObjectType"`
+	ValidationObjectRepresentation VORepresentation `xml:"ValidationObjectRepresentation"`
+}
+
+// VORepresentation embeds the DER encoding of a validation object.
+type VORepresentation struct {
+	Base64 string `xml:"base64"`
+}
+
+// ETSI builds an ETSI TS 119 102-2 validation report for resp. validationTime
+// is the time the validation was performed.
+func ETSI(resp *verify.Response, validationTime time.Time) *ValidationReport {
+	validationTime = validationTime.Truncate(time.Second)
+	b := newETSIBuilder()
+	report := &ValidationReport{}
+
+	for i, sig := range resp.Signatures {
+		report.SignatureValidationReports = append(report.SignatureValidationReports,
+			b.signatureReport(i, sig.Info, sig.Validation, validationTime))
+	}
+
+	if len(b.objects) > 0 {
+		report.SignatureValidationObjects = &ValidationObjectList{ValidationObjects: b.objects}
+	}
+	return report
+}
+
+// WriteXML writes the report as an XML document.
+func (r *ValidationReport) WriteXML(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type etsiBuilder struct {
+	objects []ValidationObject
+	seen    map[string]bool
+}
+
+func newETSIBuilder() *etsiBuilder {
+	return &etsiBuilder{seen: make(map[string]bool)}
+}
+
+// addObject registers a validation object once and returns its id.
+func (b *etsiBuilder) addObject(prefix, objectType string, der []byte) string {
+	sum := sha256.Sum256(der)
+	id := prefix + "-" + strings.ToUpper(hex.EncodeToString(sum[:]))
+	if !b.seen[id] {
+		b.seen[id] = true
+		b.objects = append(b.objects, ValidationObject{
+			ID:                             id,
+			ObjectType:                     objectType,
+			ValidationObjectRepresentation: VORepresentation{Base64: base64.StdEncoding.EncodeToString(der)},
+		})
+	}
+	return id
+}
+
+func (b *etsiBuilder) signatureReport(index int, info common.SignatureInfo, validation verify.SignatureValidation, validationTime time.Time) SignatureValidationReport {
+	r := SignatureValidationReport{
+		SignatureIdentifier: SignatureIdentifier{ID: fmt.Sprintf("S-%d", index+1)},
+		ValidationTimeInfo:  ValidationTimeInfo{ValidationTime: validationTime.UTC()},
+		SignatureValidationProcess: SignatureValidationProcess{
+			SignatureValidationProcessID: validationProc,
+		},
+	}
+
+	if digest, err := hex.DecodeString(info.SignatureHash); err == nil && len(digest) > 0 {
+		r.SignatureIdentifier.DigestAlgAndValue = &DigestAlgAndValue{
+			DigestMethod: DigestMethod{Algorithm: sha256AlgURI},
+			DigestValue:  base64.StdEncoding.EncodeToString(digest),
+		}
+	}
+
+	attrs := &SignatureAttributes{
+		Name:        info.Name,
+		ContactInfo: info.ContactInfo,
+		Reason:      info.Reason,
+		Location:    info.Location,
+	}
+	if info.SignatureTime != nil {
+		attrs.SigningTime = &SigningTime{Signed: true, Time: info.SignatureTime.UTC()}
+	}
+	if info.TimeStamp != nil && len(info.TimeStamp.RawToken) > 0 {
+		id := b.addObject("T", voTimestamp, info.TimeStamp.RawToken)
+		attrs.SignatureTimeStamp = &SignatureTimeStamp{
+			Signed:          false,
+			TimeStampValue:  info.TimeStamp.Time.UTC(),
+			AttributeObject: VORef{VOReference: id},
+		}
+	}
+	if *attrs != (SignatureAttributes{}) {
+		r.SignatureAttributes = attrs
+	}
+
+	// A trusted timestamp is the best proof of existence; otherwise only
+	// the validation time itself is known.
+	if validation.TimeSource == "embedded_timestamp" && validation.VerificationTime != nil {
+		r.ValidationTimeInfo.BestSignatureTime = &POE{POETime: validation.VerificationTime.UTC(), TypeOfProof: poeProvided}
+	} else {
+		r.ValidationTimeInfo.BestSignatureTime = &POE{POETime: validationTime.UTC(), TypeOfProof: poeValidation}
+	}
+
+	var chain *CertificateChain
+	var revoked []RevocationStatusInfo
+	signer := signingCertificate(validation.Certificates)
+	for i, c := range validation.Certificates {
+		if c.Certificate == nil {
+			continue
+		}
+		id := b.addObject("C", voCertificate, c.Certificate.Raw)
+		ref := VORef{VOReference: id}
+
+		if chain == nil {
+			chain = &CertificateChain{}
+		}
+		switch {
+		case i == signer:
+			chain.SigningCertificate = ref
+			r.SignerInformation = &SignerInformation{SignerCertificate: ref, Signer: c.Certificate.Subject.String()}
+		case isSelfSigned(c.Certificate):
+			anchor := ref
+			chain.TrustAnchor = &anchor
+		default:
+			chain.IntermediateCertificate = append(chain.IntermediateCertificate, ref)
+		}
+
+		var revocationObject *VORef
+		if c.OCSPResponse != nil && len(c.OCSPResponse.Raw) > 0 {
+			revocationObject = &VORef{VOReference: b.addObject("R", voOCSPResponse, c.OCSPResponse.Raw)}
+		}
+		if c.RevocationTime != nil {
+			revoked = append(revoked, RevocationStatusInfo{
+				ValidationObjectID: ref,
+				RevocationTime:     c.RevocationTime.UTC(),
+				RevocationObject:   revocationObject,
+			})
+		}
+	}
+
+	indication, sub := Evaluate(validation)
+	status := ValidationStatus{MainIndication: mainIndication + strings.ToLower(string(indication))}
+	if sub != "" {
+		status.SubIndication = []string{subIndication + string(sub)}
+	}
+
+	data := ValidationReportData{CertificateChain: chain, RevocationStatusInformation: revoked}
+	if len(validation.Findings) > 0 {
+		additional := &AdditionalValidationReport{}
+		for _, f := range validation.Findings {
+			additional.ReportData = append(additional.ReportData, ReportData{
+				Type:  findingDataType + f.Code,
+				Value: findingText(f),
+			})
+		}
+		data.AdditionalValidationReportData = additional
+	}
+	if data.CertificateChain != nil || len(data.RevocationStatusInformation) > 0 || data.AdditionalValidationReportData != nil {
+		status.AssociatedValidationReportData = []ValidationReportData{data}
+	}
+	r.SignatureValidationStatus = status
+
+	return r
+}
+
+// findingText renders a finding as a single line of text.
+func findingText(f verify.Finding) string {
+	text := fmt.Sprintf("[%s] %s: %s", f.Severity, f.Subject, f.Message)
+	if f.Certificate != "" {
+		text += " (" + f.Certificate + ")"
+	}
+	return text
+}
+
+// isSelfSigned reports whether cert names itself as issuer. The signature is
+// not checked: legacy roots signed with SHA-1 are still trust anchors.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject)
+}
+
+// signingCertificate returns the index of the end-entity certificate: the
+// one that issued none of the others. Embedded certificates are not ordered.
+func signingCertificate(certs []common.Certificate) int {
+	for i, c := range certs {
+		if c.Certificate == nil {
+			continue
+		}
+		issuer := false
+		for j, other := range certs {
+			if i != j && other.Certificate != nil && bytes.Equal(other.Certificate.RawIssuer, c.Certificate.RawSubject) {
+				issuer = true
+				break
+			}
+		}
+		if !issuer {
+			return i
+		}
+	}
+	return 0
+}
+
+// SimpleReport is a simplified JSON equivalent of the ETSI validation report.
+type SimpleReport struct {
+	ValidationTime time.Time         `json:"validation_time"`
+	Signatures     []SimpleSignature `json:"signatures"`
+}
+
+// SimpleSignature is the validation result of one signature.
+type SimpleSignature struct {
+	ID                string              `json:"id"`
+	Indication        Indication          `json:"indication"`
+	SubIndication     SubIndication       `json:"sub_indication,omitempty"`
+	Signer            string              `json:"signer,omitempty"`
+	SigningTime       *time.Time          `json:"signing_time,omitempty"`
+	BestSignatureTime time.Time           `json:"best_signature_time"`
+	TimestampTime     *time.Time          `json:"timestamp_time,omitempty"`
+	Certificates      []SimpleCertificate `json:"certificates"`
+	Findings          []verify.Finding    `json:"findings,omitempty"`
+}
+
+// SimpleCertificate summarises one certificate of a signature's chain.
+type SimpleCertificate struct {
+	ID             string     `json:"id"`
+	Subject        string     `json:"subject"`
+	Issuer         string     `json:"issuer"`
+	SerialNumber   string     `json:"serial_number"`
+	NotBefore      time.Time  `json:"not_before"`
+	NotAfter       time.Time  `json:"not_after"`
+	RevocationTime *time.Time `json:"revocation_time,omitempty"`
+}
+
+// ETSIJSON builds the simplified JSON equivalent of the ETSI report.
+func ETSIJSON(resp *verify.Response, validationTime time.Time) *SimpleReport {
+	validationTime = validationTime.Truncate(time.Second)
+	full := ETSI(resp, validationTime)
+	report := &SimpleReport{ValidationTime: validationTime.UTC(), Signatures: []SimpleSignature{}}
+
+	for i, sig := range resp.Signatures {
+		svr := full.SignatureValidationReports[i]
+		indication, sub := Evaluate(sig.Validation)
+		s := SimpleSignature{
+			ID:                svr.SignatureIdentifier.ID,
+			Indication:        indication,
+			SubIndication:     sub,
+			SigningTime:       sig.Info.SignatureTime,
+			BestSignatureTime: svr.ValidationTimeInfo.BestSignatureTime.POETime,
+			Certificates:      []SimpleCertificate{},
+			Findings:          sig.Validation.Findings,
+		}
+		if svr.SignerInformation != nil {
+			s.Signer = svr.SignerInformation.Signer
+		}
+		if sig.Info.TimeStamp != nil {
+			t := sig.Info.TimeStamp.Time.UTC()
+			s.TimestampTime = &t
+		}
+		for _, c := range sig.Validation.Certificates {
+			if c.Certificate == nil {
+				continue
+			}
+			sum := sha256.Sum256(c.Certificate.Raw)
+			s.Certificates = append(s.Certificates, SimpleCertificate{
+				ID:             "C-" + strings.ToUpper(hex.EncodeToString(sum[:])),
+				Subject:        c.Certificate.Subject.String(),
+				Issuer:         c.Certificate.Issuer.String(),
+				SerialNumber:   c.Certificate.SerialNumber.String(),
+				NotBefore:      c.Certificate.NotBefore.UTC(),
+				NotAfter:       c.Certificate.NotAfter.UTC(),
+				RevocationTime: c.RevocationTime,
+			})
+		}
+		report.Signatures = append(report.Signatures, s)
+	}
+	return report
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *SimpleReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,164 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subnoto/pdfsign/verify"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		findings []verify.Finding
+		valid    bool
+		want     Indication
+		wantSub  SubIndication
+	}{
+		{
+			name:  "no findings",
+			valid: true,
+			want:  TotalPassed,
+		},
+		{
+			name:     "warnings only",
+			valid:    true,
+			findings: []verify.Finding{{Code: verify.CodeRevocationUnavailable, Severity: verify.SeverityWarning}},
+			want:     TotalPassed,
+		},
+		{
+			name:     "untrusted chain",
+			valid:    true,
+			findings: []verify.Finding{{Code: verify.CodeChainUntrusted, Severity: verify.SeverityError, Subject: verify.SubjectCertificate}},
+			want:     Indeterminate,
+			wantSub:  NoCertificateChainFound,
+		},
+		{
+			name: "failure takes precedence over indeterminate",
+			findings: []verify.Finding{
+				{Code: verify.CodeChainUntrusted, Severity: verify.SeverityError},
+				{Code: verify.CodeByteRangeMismatch, Severity: verify.SeverityError},
+			},
+			want:    TotalFailed,
+			wantSub: HashFailure,
+		},
+		{
+			name:     "invalid timestamp",
+			valid:    true,
+			findings: []verify.Finding{{Code: verify.CodeTimestampInvalid, Severity: verify.SeverityError, Subject: verify.SubjectTimestamp}},
+			want:     TotalFailed,
+			wantSub:  SigCryptoFailure,
+		},
+		{
+			name:     "revoked before signing",
+			valid:    true,
+			findings: []verify.Finding{{Code: verify.CodeCertificateRevoked, Severity: verify.SeverityError, Subject: verify.SubjectCertificate}},
+			want:     TotalFailed,
+			wantSub:  Revoked,
+		},
+		{
+			name:     "revoked without proof of existence",
+			valid:    true,
+			findings: []verify.Finding{{Code: verify.CodeCertificateRevoked, Severity: verify.SeverityError, Subject: verify.SubjectSignature}},
+			want:     Indeterminate,
+			wantSub:  RevokedNoPOE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotSub := Evaluate(verify.SignatureValidation{ValidSignature: tt.valid, Findings: tt.findings})
+			if got != tt.want || gotSub != tt.wantSub {
+				t.Errorf("Evaluate() = %s/%s, want %s/%s", got, gotSub, tt.want, tt.wantSub)
+			}
+		})
+	}
+}
+
+func verifyTestFile(t *testing.T, name string) *verify.Response {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testfiles", name))
+	if err != nil {
+		t.Skipf("test file %s not available: %v", name, err)
+	}
+	defer func() { _ = f.Close() }()
+	resp, err := verify.VerifyFile(f)
+	if err != nil {
+		t.Fatalf("VerifyFile() error = %v", err)
+	}
+	return resp
+}
+
+func TestETSIXML(t *testing.T) {
+	resp := verifyTestFile(t, "testfile30.pdf")
+	validationTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err := ETSI(resp, validationTime).WriteXML(&buf); err != nil {
+		t.Fatalf("WriteXML() error = %v", err)
+	}
+
+	var parsed ValidationReport
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("report is not well-formed XML: %v", err)
+	}
+	if parsed.XMLName.Space != ETSINamespace {
+		t.Errorf("namespace = %q, want %q", parsed.XMLName.Space, ETSINamespace)
+	}
+	if len(parsed.SignatureValidationReports) != len(resp.Signatures) {
+		t.Fatalf("got %d signature reports, want %d", len(parsed.SignatureValidationReports), len(resp.Signatures))
+	}
+
+	svr := parsed.SignatureValidationReports[0]
+	if got := svr.SignatureValidationStatus.MainIndication; got != "urn:etsi:019102:mainindication:indeterminate" {
+		t.Errorf("MainIndication = %q", got)
+	}
+	if got := svr.SignatureValidationStatus.SubIndication; len(got) != 1 || got[0] != "urn:etsi:019102:subindication:NO_CERTIFICATE_CHAIN_FOUND" {
+		t.Errorf("SubIndication = %v", got)
+	}
+	if !svr.ValidationTimeInfo.ValidationTime.Equal(validationTime) {
+		t.Errorf("ValidationTime = %v", svr.ValidationTimeInfo.ValidationTime)
+	}
+	if svr.SignerInformation == nil || !strings.Contains(svr.SignerInformation.Signer, "John B Harris") {
+		t.Errorf("SignerInformation = %+v", svr.SignerInformation)
+	}
+
+	// Every referenced certificate is present as a validation object.
+	ids := map[string]bool{}
+	for _, vo := range parsed.SignatureValidationObjects.ValidationObjects {
+		ids[vo.ID] = true
+	}
+	if !ids[svr.SignerInformation.SignerCertificate.VOReference] {
+		t.Errorf("signer certificate %s missing from validation objects", svr.SignerInformation.SignerCertificate.VOReference)
+	}
+}
+
+func TestETSIJSON(t *testing.T) {
+	resp := verifyTestFile(t, "testfile30.pdf")
+
+	var buf bytes.Buffer
+	if err := ETSIJSON(resp, time.Now()).WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	var parsed SimpleReport
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Signatures) != 1 {
+		t.Fatalf("got %d signatures, want 1", len(parsed.Signatures))
+	}
+	sig := parsed.Signatures[0]
+	if sig.Indication != Indeterminate || sig.SubIndication != NoCertificateChainFound {
+		t.Errorf("indication = %s/%s", sig.Indication, sig.SubIndication)
+	}
+	if len(sig.Certificates) != 3 {
+		t.Errorf("got %d certificates, want 3", len(sig.Certificates))
+	}
+}
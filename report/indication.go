@@ -0,0 +1,89 @@
+// Package report renders verify.Response results as validation reports for
+// archiving and for human readers.
+package report
+
+import (
+	"github.com/subnoto/pdfsign/verify"
+)
+
+// Indication is a main status indication as defined in ETSI EN 319 102-1.
+type Indication string
+
+const (
+	TotalPassed   Indication = "TOTAL-PASSED"
+	TotalFailed   Indication = "TOTAL-FAILED"
+	Indeterminate Indication = "INDETERMINATE"
+)
+
+// SubIndication qualifies a TOTAL-FAILED or INDETERMINATE indication
+// (ETSI EN 319 102-1, section 5.1.3).
+type SubIndication string
+
+const (
+	FormatFailure                  SubIndication = "FORMAT_FAILURE"
+	HashFailure                    SubIndication = "HASH_FAILURE"
+	SigCryptoFailure               SubIndication = "SIG_CRYPTO_FAILURE"
+	Revoked                        SubIndication = "REVOKED"
+	SigConstraintsFailure          SubIndication = "SIG_CONSTRAINTS_FAILURE"
+	ChainConstraintsFailure        SubIndication = "CHAIN_CONSTRAINTS_FAILURE"
+	CertificateChainGeneralFailure SubIndication = "CERTIFICATE_CHAIN_GENERAL_FAILURE"
+	NoCertificateChainFound        SubIndication = "NO_CERTIFICATE_CHAIN_FOUND"
+	RevokedNoPOE                   SubIndication = "REVOKED_NO_POE"
+	TimestampOrderFailure          SubIndication = "TIMESTAMP_ORDER_FAILURE"
+)
+
+// findingIndications maps error-severity finding codes to the indication they
+// cause. Findings are evaluated in order and TOTAL-FAILED takes precedence
+// over INDETERMINATE.
+var findingIndications = map[string]struct {
+	indication    Indication
+	subIndication SubIndication
+}{
+	verify.CodeMalformedSignature:    {TotalFailed, FormatFailure},
+	verify.CodeByteRangeInvalid:      {TotalFailed, FormatFailure},
+	verify.CodeByteRangeMismatch:     {TotalFailed, HashFailure},
+	verify.CodeSignatureInvalid:      {TotalFailed, SigCryptoFailure},
+	verify.CodeTimestampInvalid:      {TotalFailed, SigCryptoFailure},
+	verify.CodeChainUntrusted:        {Indeterminate, NoCertificateChainFound},
+	verify.CodeChainIncomplete:       {Indeterminate, NoCertificateChainFound},
+	verify.CodeKeyUsage:              {Indeterminate, SigConstraintsFailure},
+	verify.CodeRevocationDataInvalid: {Indeterminate, CertificateChainGeneralFailure},
+}
+
+// Evaluate returns the ETSI indication for a signature validation result.
+// A certificate revoked before the signing time yields TOTAL-FAILED/REVOKED;
+// a revocation that cannot be placed relative to the signing time yields
+// INDETERMINATE/REVOKED_NO_POE.
+func Evaluate(validation verify.SignatureValidation) (Indication, SubIndication) {
+	indication, subIndication := TotalPassed, SubIndication("")
+	for _, f := range validation.Findings {
+		if f.Severity != verify.SeverityError {
+			continue
+		}
+
+		var ind Indication
+		var sub SubIndication
+		switch {
+		case f.Code == verify.CodeCertificateRevoked && f.Subject == verify.SubjectCertificate:
+			ind, sub = TotalFailed, Revoked
+		case f.Code == verify.CodeCertificateRevoked:
+			ind, sub = Indeterminate, RevokedNoPOE
+		default:
+			mapped, ok := findingIndications[f.Code]
+			if !ok {
+				ind, sub = Indeterminate, CertificateChainGeneralFailure
+			} else {
+				ind, sub = mapped.indication, mapped.subIndication
+			}
+		}
+
+		if indication == TotalPassed || (indication == Indeterminate && ind == TotalFailed) {
+			indication, subIndication = ind, sub
+		}
+	}
+
+	if indication == TotalPassed && !validation.ValidSignature {
+		return TotalFailed, SigCryptoFailure
+	}
+	return indication, subIndication
+}
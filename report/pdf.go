@@ -0,0 +1,232 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"github.com/subnoto/pdfsign/sign"
+	"github.com/subnoto/pdfsign/verify"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Page layout of the PDF report (A4, in points).
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfFontSize   = 9
+	pdfLeading    = 12
+	pdfIndent     = 12
+	pdfTagWidth   = 34
+)
+
+// PDFOptions configures WritePDF.
+type PDFOptions struct {
+	// Source names the verified file in the report header.
+	Source string
+
+	// GeneratedAt is the time printed in the header and used as creation
+	// date. If zero, the current time is used.
+	GeneratedAt time.Time
+
+	// Sign, when set, signs the generated report with the given signing
+	// data (typically a service certificate) using the sign package.
+	Sign *sign.SignData
+}
+
+// pdfLine is one line of the rendered report.
+type pdfLine struct {
+	indent int
+	status status
+	text   string
+	bold   bool
+}
+
+// WritePDF writes a standalone PDF document summarising every signature,
+// certificate chain and revocation result in resp.
+func WritePDF(w io.Writer, resp *verify.Response, opts PDFOptions) error {
+	generated := opts.GeneratedAt
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+
+	lines := []pdfLine{{text: "Signature validation report", bold: true}}
+	if opts.Source != "" {
+		lines = append(lines, pdfLine{text: "File: " + opts.Source})
+	}
+	lines = append(lines, pdfLine{text: "Generated: " + formatTime(generated)}, pdfLine{})
+	for _, root := range buildTree(resp) {
+		lines = appendPDFLines(lines, root, 0)
+		lines = append(lines, pdfLine{})
+	}
+
+	doc := renderPDF(lines, generated)
+	if opts.Sign == nil {
+		_, err := w.Write(doc)
+		return err
+	}
+
+	rdr, err := pdf.NewReader(bytes.NewReader(doc), int64(len(doc)))
+	if err != nil {
+		return fmt.Errorf("failed to read generated report: %w", err)
+	}
+	if _, err := sign.Sign(bytes.NewReader(doc), w, rdr, int64(len(doc)), *opts.Sign); err != nil {
+		return fmt.Errorf("failed to sign report: %w", err)
+	}
+	return nil
+}
+
+func appendPDFLines(lines []pdfLine, n *node, depth int) []pdfLine {
+	// Wrap long labels; continuation lines are indented under the text.
+	maxChars := int(float64(pdfPageWidth-2*pdfMargin-depth*pdfIndent-pdfTagWidth) / (pdfFontSize * 0.55))
+	for i, part := range wrapText(n.label, maxChars) {
+		line := pdfLine{indent: depth, text: part, bold: depth == 0}
+		if i == 0 {
+			line.status = n.status
+		}
+		lines = append(lines, line)
+	}
+	for _, child := range n.children {
+		lines = appendPDFLines(lines, child, depth+1)
+	}
+	return lines
+}
+
+// wrapText splits s into lines of at most width characters, breaking at
+// spaces where possible.
+func wrapText(s string, width int) []string {
+	if width < 10 {
+		width = 10
+	}
+	var out []string
+	runes := []rune(s)
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		out = append(out, string(runes[:cut]))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	return append(out, string(runes))
+}
+
+// renderPDF lays out lines on A4 pages using the standard Helvetica fonts
+// and returns the complete PDF file.
+func renderPDF(lines []pdfLine, created time.Time) []byte {
+	perPage := (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	var pages [][]pdfLine
+	for len(lines) > 0 {
+		n := min(perPage, len(lines))
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; each page then adds a page and a content object.
+	const firstPage = 6
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (pdfsign) /CreationDate (D:%s) >>",
+		pdfString("Signature validation report"), created.UTC().Format("20060102150405Z")))
+
+	for i, page := range pages {
+		content := pageContent(page, i+1, len(pages))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func pageContent(lines []pdfLine, page, total int) string {
+	var b strings.Builder
+	y := pdfPageHeight - pdfMargin
+	for _, line := range lines {
+		x := pdfMargin + line.indent*pdfIndent
+		if line.status != statusNone {
+			r, g, bl := statusColor(line.status)
+			fmt.Fprintf(&b, "BT /F2 %d Tf %.2f %.2f %.2f rg %d %d Td %s Tj ET\n",
+				pdfFontSize, r, g, bl, x, y, pdfString("["+line.status.String()+"]"))
+		}
+		if line.text != "" {
+			font := "F1"
+			if line.bold {
+				font = "F2"
+			}
+			fmt.Fprintf(&b, "BT /%s %d Tf 0 0 0 rg %d %d Td %s Tj ET\n",
+				font, pdfFontSize, x+pdfTagWidth, y, pdfString(line.text))
+		}
+		y -= pdfLeading
+	}
+	fmt.Fprintf(&b, "BT /F1 8 Tf 0.4 0.4 0.4 rg %d %d Td %s Tj ET",
+		pdfPageWidth-pdfMargin-60, pdfMargin/2, pdfString(fmt.Sprintf("Page %d of %d", page, total)))
+	return b.String()
+}
+
+func statusColor(s status) (r, g, b float64) {
+	switch s {
+	case statusPass:
+		return 0, 0.5, 0
+	case statusWarn:
+		return 0.8, 0.5, 0
+	default:
+		return 0.8, 0, 0
+	}
+}
+
+// pdfString encodes s as a PDF literal string in WinAnsiEncoding, replacing
+// characters the encoding cannot represent.
+func pdfString(s string) string {
+	enc := charmap.Windows1252.NewEncoder()
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		encoded, err := enc.String(string(r))
+		if err != nil {
+			encoded = "?"
+		}
+		for i := 0; i < len(encoded); i++ {
+			c := encoded[i]
+			switch c {
+			case '(', ')', '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case '\n', '\r':
+				b.WriteByte(' ')
+			default:
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
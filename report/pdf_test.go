@@ -0,0 +1,90 @@
+package report
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"github.com/subnoto/pdfsign/sign"
+	"github.com/subnoto/pdfsign/verify"
+)
+
+func TestWritePDF(t *testing.T) {
+	resp := verifyTestFile(t, "testfile30.pdf")
+
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, resp, PDFOptions{Source: "testfile30.pdf (draft)"}); err != nil {
+		t.Fatalf("WritePDF() error = %v", err)
+	}
+
+	rdr, err := pdf.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("generated report does not parse: %v", err)
+	}
+	if rdr.NumPage() < 1 {
+		t.Fatal("generated report has no pages")
+	}
+	if !strings.Contains(buf.String(), `(File: testfile30.pdf \(draft\))`) {
+		t.Error("report does not contain the escaped source name")
+	}
+}
+
+func TestWritePDFSigned(t *testing.T) {
+	resp := verifyTestFile(t, "testfile30.pdf")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Validation Service"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = WritePDF(&buf, resp, PDFOptions{Sign: &sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info:       sign.SignDataSignatureInfo{Name: "Validation Service", Date: time.Now()},
+			CertType:   sign.CertificationSignature,
+			DocMDPPerm: sign.DoNotAllowAnyChangesPerms,
+		},
+		Signer:          key,
+		DigestAlgorithm: crypto.SHA256,
+		Certificate:     cert,
+	}})
+	if err != nil {
+		t.Fatalf("WritePDF() error = %v", err)
+	}
+
+	options := verify.DefaultVerifyOptions()
+	options.AllowUntrustedRoots = true
+	signed, err := verify.VerifyWithOptions(bytes.NewReader(buf.Bytes()), int64(buf.Len()), options)
+	if err != nil {
+		t.Fatalf("verify signed report: %v", err)
+	}
+	if len(signed.Signatures) != 1 || !signed.Signatures[0].Validation.ValidSignature {
+		t.Fatalf("signed report does not carry one valid signature: %+v", signed.Signatures)
+	}
+}
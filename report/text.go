@@ -0,0 +1,93 @@
+package report
+
+import (
+	"bufio"
+	"io"
+	"time"
+
+	"github.com/subnoto/pdfsign/verify"
+)
+
+// ANSI escape sequences used for coloured status tags.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiRed    = "\x1b[31m"
+)
+
+// TextOptions configures WriteText.
+type TextOptions struct {
+	// Color enables ANSI colours for the PASS/WARN/FAIL tags. Enable it
+	// only when writing to a terminal.
+	Color bool
+
+	// Source names the verified file in the report header.
+	Source string
+
+	// GeneratedAt is the time printed in the header. If zero, the current
+	// time is used.
+	GeneratedAt time.Time
+}
+
+// WriteText writes a human-readable tree of the verification results:
+// signatures, certificates, timestamps and revocation status, each tagged
+// PASS, WARN or FAIL.
+func WriteText(w io.Writer, resp *verify.Response, opts TextOptions) error {
+	bw := bufio.NewWriter(w)
+
+	generated := opts.GeneratedAt
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	header := "Signature validation report"
+	if opts.Color {
+		header = ansiBold + header + ansiReset
+	}
+	_, _ = bw.WriteString(header + "\n")
+	if opts.Source != "" {
+		_, _ = bw.WriteString("File: " + opts.Source + "\n")
+	}
+	_, _ = bw.WriteString("Generated: " + formatTime(generated) + "\n\n")
+
+	for _, root := range buildTree(resp) {
+		writeTextNode(bw, root, "", "", opts.Color)
+		_, _ = bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+func writeTextNode(w *bufio.Writer, n *node, prefix, childPrefix string, color bool) {
+	_, _ = w.WriteString(prefix)
+	if tag := statusTag(n.status, color); tag != "" {
+		_, _ = w.WriteString(tag + " ")
+	}
+	_, _ = w.WriteString(n.label + "\n")
+
+	for i, child := range n.children {
+		if i == len(n.children)-1 {
+			writeTextNode(w, child, childPrefix+"└─ ", childPrefix+"   ", color)
+		} else {
+			writeTextNode(w, child, childPrefix+"├─ ", childPrefix+"│  ", color)
+		}
+	}
+}
+
+func statusTag(s status, color bool) string {
+	if s == statusNone {
+		return ""
+	}
+	tag := "[" + s.String() + "]"
+	if !color {
+		return tag
+	}
+	switch s {
+	case statusPass:
+		return ansiGreen + tag + ansiReset
+	case statusWarn:
+		return ansiYellow + tag + ansiReset
+	default:
+		return ansiRed + tag + ansiReset
+	}
+}
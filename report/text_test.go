@@ -0,0 +1,65 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subnoto/pdfsign/verify"
+)
+
+func TestWriteText(t *testing.T) {
+	resp := verifyTestFile(t, "testfile30.pdf")
+	generated := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, resp, TextOptions{Source: "testfile30.pdf", GeneratedAt: generated}); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"File: testfile30.pdf",
+		"Generated: 2025-01-02 03:04:05 UTC",
+		"Signature 1:",
+		"Field: Signature2 (visible on page 1)",
+		"INDETERMINATE / NO_CERTIFICATE_CHAIN_FOUND",
+		"[PASS] Integrity",
+		"[FAIL] Certificate chain: not trusted",
+		"└─",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("text report does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Error("text report contains ANSI escapes with colour disabled")
+	}
+
+	buf.Reset()
+	if err := WriteText(&buf, resp, TextOptions{Color: true}); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), ansiGreen+"[PASS]"+ansiReset) {
+		t.Errorf("coloured report does not contain a green PASS tag:\n%s", buf.String())
+	}
+
+	resp.Repairs = []string{"ignored 19 bytes before the PDF header"}
+	buf.Reset()
+	if err := WriteText(&buf, resp, TextOptions{}); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if want := "[WARN] Repaired: ignored 19 bytes before the PDF header"; !strings.Contains(buf.String(), want) {
+		t.Errorf("text report does not contain %q:\n%s", want, buf.String())
+	}
+
+	resp.OrphanSignatures = []verify.OrphanSignature{{Object: 42, Filter: "Adobe.PPKLite"}}
+	buf.Reset()
+	if err := WriteText(&buf, resp, TextOptions{}); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if want := "[WARN] Orphan signature dictionary: object 42 (Adobe.PPKLite)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("text report does not contain %q:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,261 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/subnoto/pdfsign/common"
+	"github.com/subnoto/pdfsign/verify"
+)
+
+// status is the outcome shown next to a report line.
+type status int
+
+const (
+	statusNone status = iota
+	statusPass
+	statusWarn
+	statusFail
+)
+
+func (s status) String() string {
+	switch s {
+	case statusPass:
+		return "PASS"
+	case statusWarn:
+		return "WARN"
+	case statusFail:
+		return "FAIL"
+	default:
+		return ""
+	}
+}
+
+// node is a line of the human-readable report with its nested details.
+type node struct {
+	label    string
+	status   status
+	children []*node
+}
+
+func (n *node) add(status status, format string, args ...any) *node {
+	child := &node{label: fmt.Sprintf(format, args...), status: status}
+	n.children = append(n.children, child)
+	return child
+}
+
+// buildTree arranges the verification results as a tree shared by the text
+// and PDF reports.
+func buildTree(resp *verify.Response) []*node {
+	var roots []*node
+
+	doc := &node{label: "Document"}
+	if resp.DocumentInfo.Title != "" {
+		doc.add(statusNone, "Title: %s", resp.DocumentInfo.Title)
+	}
+	if resp.DocumentInfo.Author != "" {
+		doc.add(statusNone, "Author: %s", resp.DocumentInfo.Author)
+	}
+	doc.add(statusNone, "Pages: %d", resp.DocumentInfo.Pages)
+	doc.add(statusNone, "Signatures: %d", len(resp.Signatures))
+	for _, repair := range resp.Repairs {
+		doc.add(statusWarn, "Repaired: %s", repair)
+	}
+	for _, o := range resp.OrphanSignatures {
+		doc.add(statusWarn, "Orphan signature dictionary: object %d (%s) is not the value of any signature field", o.Object, o.Filter)
+	}
+	roots = append(roots, doc)
+
+	for i, sig := range resp.Signatures {
+		roots = append(roots, signatureNode(i, sig.Info, sig.Validation))
+	}
+	return roots
+}
+
+func signatureNode(index int, info common.SignatureInfo, validation verify.SignatureValidation) *node {
+	indication, sub := Evaluate(validation)
+	verdict := string(indication)
+	if sub != "" {
+		verdict += " / " + string(sub)
+	}
+
+	st := statusPass
+	switch indication {
+	case TotalFailed:
+		st = statusFail
+	case Indeterminate:
+		st = statusWarn
+	}
+
+	name := info.Name
+	if name == "" {
+		name = signerSubject(validation)
+	}
+	n := &node{label: fmt.Sprintf("Signature %d: %s (%s)", index+1, name, verdict), status: st}
+
+	if info.Field != "" {
+		n.add(statusNone, "Field: %s (%s)", info.Field, placement(info))
+	}
+	if info.Reason != "" {
+		n.add(statusNone, "Reason: %s", info.Reason)
+	}
+	if info.Location != "" {
+		n.add(statusNone, "Location: %s", info.Location)
+	}
+	if info.SignatureTime != nil {
+		n.add(statusNone, "Claimed signing time: %s", formatTime(*info.SignatureTime))
+	}
+
+	if validation.ValidSignature {
+		n.add(statusPass, "Integrity: signature matches the signed byte ranges")
+	} else {
+		n.add(statusFail, "Integrity: signature does not verify")
+	}
+
+	n.children = append(n.children, timestampNode(info, validation))
+	n.children = append(n.children, chainNode(validation))
+
+	if len(validation.Findings) > 0 {
+		findings := n.add(statusNone, "Findings")
+		for _, f := range validation.Findings {
+			fst := statusNone
+			switch f.Severity {
+			case verify.SeverityError:
+				fst = statusFail
+			case verify.SeverityWarning:
+				fst = statusWarn
+			}
+			label := fmt.Sprintf("%s: %s", f.Code, f.Message)
+			if f.Certificate != "" {
+				label += " (" + f.Certificate + ")"
+			}
+			findings.add(fst, "%s", label)
+		}
+	}
+	return n
+}
+
+// placement describes where the widgets of a signature field are shown.
+func placement(info common.SignatureInfo) string {
+	if !info.Visible {
+		return "invisible"
+	}
+	var pages []string
+	for _, w := range info.Widgets {
+		if p := fmt.Sprint(w.Page); w.Page != 0 && !slices.Contains(pages, p) {
+			pages = append(pages, p)
+		}
+	}
+	return "visible on page " + strings.Join(pages, ", ")
+}
+
+func timestampNode(info common.SignatureInfo, validation verify.SignatureValidation) *node {
+	if info.TimeStamp == nil {
+		return &node{label: "Timestamp: none, signing time is not proven", status: statusWarn}
+	}
+
+	n := &node{label: fmt.Sprintf("Timestamp: %s", formatTime(info.TimeStamp.Time))}
+	switch {
+	case validation.TimestampStatus != "valid":
+		n.status = statusFail
+		n.add(statusNone, "Status: %s", validation.TimestampStatus)
+	case validation.TimestampTrusted:
+		n.status = statusPass
+	default:
+		n.status = statusWarn
+		n.add(statusNone, "Timestamp authority certificate not validated")
+	}
+	for _, cert := range info.TimeStamp.Certificates {
+		n.add(statusNone, "Authority: %s", cert.Subject.String())
+	}
+	return n
+}
+
+func chainNode(validation verify.SignatureValidation) *node {
+	n := &node{label: "Certificate chain"}
+	switch {
+	case validation.TrustedIssuer:
+		n.status = statusPass
+		n.label += ": trusted"
+	case !hasErrorFinding(validation, verify.CodeChainUntrusted):
+		n.status = statusWarn
+		n.label += ": anchored in an embedded certificate"
+	default:
+		n.status = statusFail
+		n.label += ": not trusted"
+	}
+
+	for _, c := range validation.Certificates {
+		if c.Certificate == nil {
+			continue
+		}
+		cn := n.add(statusPass, "%s", c.Certificate.Subject.String())
+		if c.VerifyError != "" {
+			cn.status = statusFail
+			cn.add(statusNone, "Chain: %s", c.VerifyError)
+		}
+		cn.add(statusNone, "Issuer: %s", c.Certificate.Issuer.String())
+		cn.add(statusNone, "Serial: %s", c.Certificate.SerialNumber.String())
+		cn.add(statusNone, "Validity: %s to %s", formatTime(c.Certificate.NotBefore), formatTime(c.Certificate.NotAfter))
+		cn.children = append(cn.children, revocationNode(c))
+	}
+	return n
+}
+
+func revocationNode(c common.Certificate) *node {
+	switch {
+	case c.RevocationTime != nil && c.RevokedBeforeSigning:
+		return &node{label: fmt.Sprintf("Revocation: revoked at %s, before signing", formatTime(*c.RevocationTime)), status: statusFail}
+	case c.RevocationTime != nil:
+		return &node{label: fmt.Sprintf("Revocation: revoked at %s, after signing", formatTime(*c.RevocationTime)), status: statusWarn}
+	}
+
+	var sources []string
+	if c.OCSPSource != "" {
+		sources = append(sources, "OCSP ("+c.OCSPSource+")")
+	}
+	if c.CRLSource != "" {
+		sources = append(sources, "CRL ("+c.CRLSource+")")
+	}
+	if len(sources) > 0 {
+		n := &node{label: "Revocation: not revoked", status: statusPass}
+		for _, s := range sources {
+			n.add(statusNone, "Checked via %s", s)
+		}
+		if c.RevocationWarning != "" {
+			n.add(statusNone, "%s", c.RevocationWarning)
+		}
+		return n
+	}
+
+	if c.Certificate != nil && isSelfSigned(c.Certificate) {
+		return &node{label: "Revocation: not applicable to a root certificate", status: statusNone}
+	}
+	n := &node{label: "Revocation: status unknown", status: statusWarn}
+	if c.RevocationWarning != "" {
+		n.add(statusNone, "%s", c.RevocationWarning)
+	}
+	return n
+}
+
+func hasErrorFinding(validation verify.SignatureValidation, code string) bool {
+	for _, f := range validation.Findings {
+		if f.Code == code && f.Severity == verify.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func signerSubject(validation verify.SignatureValidation) string {
+	if i := signingCertificate(validation.Certificates); i < len(validation.Certificates) && validation.Certificates[i].Certificate != nil {
+		return validation.Certificates[i].Certificate.Subject.CommonName
+	}
+	return "unknown signer"
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05 MST")
+}
@@ -17,6 +17,14 @@ func main() {
 		cli.SignCommand()
 	case "verify":
 		cli.VerifyCommand()
+	case "inspect":
+		cli.InspectCommand()
+	case "revisions":
+		cli.RevisionsCommand()
+	case "diff":
+		cli.DiffCommand()
+	case "tsa-server":
+		cli.TSAServerCommand()
 	case "-h", "--help", "help":
 		cli.Usage()
 	default:
@@ -0,0 +1,56 @@
+package sign
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/subnoto/pdfsign/aia"
+)
+
+// AIAFetcher downloads missing issuer certificates from caIssuers URLs when a
+// certificate chain is completed during signing. It caches downloads across
+// signatures. While its Client is nil, the downloads use the
+// RevocationHTTPClient in effect at the time of the fetch.
+var AIAFetcher = aia.NewFetcher(nil)
+
+// aiaFetcher returns AIAFetcher, falling back to RevocationHTTPClient when it
+// has no client of its own.
+func aiaFetcher() *aia.Fetcher {
+	if AIAFetcher.Client != nil {
+		return AIAFetcher
+	}
+	f := *AIAFetcher
+	f.Client = RevocationHTTPClient
+	return &f
+}
+
+// completeCertificateChain extends CertificateChains[0] up to a self-signed
+// root, taking issuers from the supplied chains first and downloading the
+// remaining ones through AIAFetcher. The completed chain replaces the first
+// chain even when it is still partial; the error reports why.
+func completeCertificateChain(signData *SignData) error {
+	if signData.Certificate == nil {
+		return nil
+	}
+
+	var known []*x509.Certificate
+	for _, chain := range signData.CertificateChains {
+		known = append(known, chain...)
+	}
+
+	chain, err := aiaFetcher().CompleteChain(signData.Certificate, known)
+	if len(signData.CertificateChains) == 0 || len(chain) > len(signData.CertificateChains[0]) {
+		if len(signData.CertificateChains) == 0 {
+			signData.CertificateChains = [][]*x509.Certificate{chain}
+		} else {
+			chains := make([][]*x509.Certificate, len(signData.CertificateChains))
+			copy(chains, signData.CertificateChains)
+			chains[0] = chain
+			signData.CertificateChains = chains
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("complete certificate chain: %w", err)
+	}
+	return nil
+}
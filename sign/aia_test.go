@@ -0,0 +1,158 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"github.com/subnoto/pdfsign/aia"
+)
+
+// newChainCert returns a certificate named name and its key, issued by
+// parent or self-signed when parent is nil, with issuerURL as its caIssuers
+// URL when set.
+func newChainCert(t *testing.T, serial int64, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, issuerURL string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if issuerURL != "" {
+		template.IssuingCertificateURL = []string{issuerURL}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestCompleteCertificateChain(t *testing.T) {
+	var served []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(served)
+	}))
+	defer server.Close()
+
+	root, rootKey := newChainCert(t, 1, "Chain Root", true, nil, nil, "")
+	intermediate, intermediateKey := newChainCert(t, 2, "Chain Intermediate", true, root, rootKey, "")
+	leaf, _ := newChainCert(t, 3, "Chain Signer", false, intermediate, intermediateKey, server.URL)
+	served = intermediate.Raw
+
+	// The fetcher picks up the revocation client in effect at fetch time.
+	origFetcher, origClient := AIAFetcher, RevocationHTTPClient
+	AIAFetcher = aia.NewFetcher(nil)
+	RevocationHTTPClient = server.Client()
+	t.Cleanup(func() { AIAFetcher, RevocationHTTPClient = origFetcher, origClient })
+
+	// The root is supplied, the intermediate must be downloaded.
+	signData := SignData{
+		Certificate:       leaf,
+		CertificateChains: [][]*x509.Certificate{{leaf, root}},
+	}
+	if err := completeCertificateChain(&signData); err != nil {
+		t.Fatalf("completeCertificateChain() error = %v", err)
+	}
+	chain := signData.CertificateChains[0]
+	if len(chain) != 3 || !chain[1].Equal(intermediate) || !chain[2].Equal(root) {
+		t.Fatalf("completed chain has %d certificates, want leaf, intermediate, root", len(chain))
+	}
+
+	// Without the root and without a caIssuers URL on the intermediate the
+	// chain stays partial and an error is reported.
+	signData = SignData{Certificate: leaf}
+	if err := completeCertificateChain(&signData); err == nil {
+		t.Error("completeCertificateChain() expected error for a chain without root")
+	}
+	if got := len(signData.CertificateChains[0]); got != 2 {
+		t.Errorf("partial chain length = %d, want 2", got)
+	}
+}
+
+func TestSignLTVCompleteChain(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+	root, rootKey := newChainCert(t, 1, "LTV Root", true, nil, nil, "")
+	intermediate, intermediateKey := newChainCert(t, 2, "LTV Intermediate", true, root, rootKey, "")
+	leaf, leafKey := newChainCert(t, 3, "LTV Signer", false, intermediate, intermediateKey, server.URL)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(intermediate.Raw)
+	})
+
+	origFetcher, origClient := AIAFetcher, RevocationHTTPClient
+	AIAFetcher = aia.NewFetcher(nil)
+	RevocationHTTPClient = server.Client()
+	t.Cleanup(func() { AIAFetcher, RevocationHTTPClient = origFetcher, origClient })
+
+	input, err := os.ReadFile("../testfiles/testfile20.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdr, err := pdf.NewReader(bytes.NewReader(input), int64(len(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := SignLTV(bytes.NewReader(input), &out, rdr, int64(len(input)), SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "LTV", Date: time.Now()},
+			CertType: ApprovalSignature,
+		},
+		DigestAlgorithm:    crypto.SHA256,
+		Signer:             leafKey,
+		Certificate:        leaf,
+		CertificateChains:  [][]*x509.Certificate{{leaf, root}},
+		CompleteChain:      true,
+		RevocationFunction: mockRevocationFunction,
+	}); err != nil {
+		t.Fatalf("SignLTV() error = %v", err)
+	}
+
+	// The downloaded intermediate is listed in the DSS.
+	data := out.Bytes()
+	signed, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := signed.Trailer().Key("Root").Key("DSS").Key("Certs")
+	found := false
+	for i := range certs.Len() {
+		rc := certs.Index(i).Reader()
+		der, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err == nil && bytes.Equal(der, intermediate.Raw) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("DSS has %d certificates, none of them the downloaded intermediate", certs.Len())
+	}
+}
@@ -0,0 +1,176 @@
+package sign
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/digitorus/pdf"
+)
+
+var (
+	// ErrAnchorNotFound is returned when the anchor text of a visible
+	// signature does not occur on the searched pages.
+	ErrAnchorNotFound = errors.New("signature anchor text not found")
+	// ErrAnchorAmbiguous is returned when the anchor text occurs more than
+	// once on the searched pages.
+	ErrAnchorAmbiguous = errors.New("signature anchor text is ambiguous")
+)
+
+// Anchor positions a visible signature relative to text on a page instead
+// of fixed coordinates.
+//
+// The anchor is matched against the text drawn by the page content stream
+// in drawing order. Whitespace is ignored on both sides, because PDF files
+// rarely contain explicit space characters between words.
+type Anchor struct {
+	// Text is the text to search for, e.g. "Signature:" or a hidden marker
+	// such as "{{sig:alice}}".
+	Text string
+	// Page limits the search to one page (1-based); zero searches all pages.
+	Page uint32
+	// OffsetX and OffsetY move the lower-left corner of the signature
+	// rectangle relative to the start of the anchor's baseline, in points.
+	OffsetX float64
+	OffsetY float64
+	// Width and Height are the size of the signature rectangle in points.
+	Width  float64
+	Height float64
+}
+
+// anchorMatch is an occurrence of the anchor text on a page.
+type anchorMatch struct {
+	page       int
+	x, y, w, h float64 // bounding box of the anchor text, y is the baseline
+}
+
+// resolveAnchor sets the page and rectangle of the appearance from its
+// anchor. It does nothing when no anchor is configured.
+func (context *SignContext) resolveAnchor() error {
+	app := &context.SignData.Appearance
+	anchor := app.Anchor
+	if anchor == nil {
+		return nil
+	}
+	if anchor.Width <= 0 || anchor.Height <= 0 {
+		return fmt.Errorf("anchor width and height must be greater than 0")
+	}
+
+	match, err := findAnchor(context.PDFReader, anchor.Text, int(anchor.Page))
+	if err != nil {
+		return err
+	}
+	app.Page = uint32(match.page)
+	app.LowerLeftX = match.x + anchor.OffsetX
+	app.LowerLeftY = match.y + anchor.OffsetY
+	app.UpperRightX = app.LowerLeftX + anchor.Width
+	app.UpperRightY = app.LowerLeftY + anchor.Height
+	return nil
+}
+
+// findAnchor returns the only occurrence of text on page, or on any page
+// when page is zero.
+func findAnchor(r *pdf.Reader, text string, page int) (anchorMatch, error) {
+	needle := removeSpace(text)
+	if needle == "" {
+		return anchorMatch{}, fmt.Errorf("anchor text must not be empty")
+	}
+
+	first, last := 1, r.NumPage()
+	if page != 0 {
+		if page > last {
+			return anchorMatch{}, fmt.Errorf("anchor page %d does not exist, document has %d pages", page, last)
+		}
+		first, last = page, page
+	}
+
+	var matches []anchorMatch
+	for i := first; i <= last; i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		matches = append(matches, findAnchorOnPage(p.Content().Text, needle, i)...)
+	}
+
+	switch len(matches) {
+	case 0:
+		return anchorMatch{}, fmt.Errorf("%w: %q", ErrAnchorNotFound, text)
+	case 1:
+		return matches[0], nil
+	default:
+		pages := make([]string, len(matches))
+		for i, m := range matches {
+			pages[i] = fmt.Sprint(m.page)
+		}
+		return anchorMatch{}, fmt.Errorf("%w: %q occurs %d times (pages %s)", ErrAnchorAmbiguous, text, len(matches), strings.Join(pages, ", "))
+	}
+}
+
+// findAnchorOnPage returns the occurrences of needle, which contains no
+// whitespace, in the positioned characters of a page.
+func findAnchorOnPage(chars []pdf.Text, needle string, page int) []anchorMatch {
+	// Concatenate the characters, remembering which one each byte of the
+	// haystack comes from.
+	var haystack strings.Builder
+	var owner []int
+	for i, c := range chars {
+		s := removeSpace(c.S)
+		haystack.WriteString(s)
+		for range len(s) {
+			owner = append(owner, i)
+		}
+	}
+
+	var matches []anchorMatch
+	hs := haystack.String()
+	for offset := 0; ; {
+		idx := strings.Index(hs[offset:], needle)
+		if idx < 0 {
+			break
+		}
+		start := offset + idx
+		end := start + len(needle) - 1
+		span := chars[owner[start] : owner[end]+1]
+		m := anchorMatch{page: page, x: span[0].X, y: span[0].Y}
+		right := m.x
+		for _, c := range span {
+			if c.X < m.x {
+				m.x = c.X
+			}
+			if r := c.X + charWidth(c); r > right {
+				right = r
+			}
+			if c.FontSize > m.h {
+				m.h = c.FontSize
+			}
+		}
+		m.w = right - m.x
+		matches = append(matches, m)
+		offset = start + len(needle)
+	}
+	return matches
+}
+
+// charWidth returns the width of a positioned character. Standard fonts
+// often come without a /Widths array, in which case the built-in metrics
+// are used, or half an em for other fonts.
+func charWidth(c pdf.Text) float64 {
+	if c.W > 0 {
+		return c.W
+	}
+	if font := StandardFont(c.Font); font.valid() {
+		return font.textWidth(c.S, c.FontSize)
+	}
+	return c.FontSize / 2
+}
+
+func removeSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,122 @@
+package sign
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/digitorus/pdf"
+)
+
+func openTestReader(t *testing.T, path string) *pdf.Reader {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	st, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := pdf.NewReader(f, st.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestFindAnchor(t *testing.T) {
+	// Each page of the fixture reads "Page N of 3".
+	r := openTestReader(t, "../testfiles/gen_pdf16_xref_table_3pages.pdf")
+
+	m, err := findAnchor(r, "Page 2 of 3", 0)
+	if err != nil {
+		t.Fatalf("findAnchor() error = %v", err)
+	}
+	if m.page != 2 || m.x <= 0 || m.y <= 0 || m.w <= 0 || m.h <= 0 {
+		t.Errorf("findAnchor() = %+v", m)
+	}
+
+	if m, err := findAnchor(r, "Page", 3); err != nil || m.page != 3 {
+		t.Errorf("findAnchor() on page 3 = %+v, %v", m, err)
+	}
+	if _, err := findAnchor(r, "of 3", 0); !errors.Is(err, ErrAnchorAmbiguous) {
+		t.Errorf("findAnchor() ambiguous: error = %v, want %v", err, ErrAnchorAmbiguous)
+	}
+	if _, err := findAnchor(r, "{{sig:alice}}", 0); !errors.Is(err, ErrAnchorNotFound) {
+		t.Errorf("findAnchor() missing: error = %v, want %v", err, ErrAnchorNotFound)
+	}
+	if _, err := findAnchor(r, "Page", 4); err == nil {
+		t.Error("findAnchor() on a missing page: error = nil")
+	}
+}
+
+func TestFindAnchorOnPage(t *testing.T) {
+	chars := []pdf.Text{
+		{S: "S", X: 10, Y: 100, W: 6, FontSize: 12},
+		{S: "i", X: 16, Y: 100, W: 3, FontSize: 12},
+		{S: "g", X: 19, Y: 100, W: 6, FontSize: 12},
+		{S: "n", X: 30, Y: 100, W: 6, FontSize: 14},
+		{S: ":", X: 36, Y: 100, W: 3, FontSize: 12},
+	}
+	matches := findAnchorOnPage(chars, "Sign:", 1)
+	if len(matches) != 1 {
+		t.Fatalf("findAnchorOnPage() = %+v, want one match", matches)
+	}
+	if m := matches[0]; m.x != 10 || m.y != 100 || m.w != 29 || m.h != 14 {
+		t.Errorf("match = %+v", m)
+	}
+	if matches := findAnchorOnPage(chars, "gn", 1); len(matches) != 1 || matches[0].x != 19 {
+		t.Errorf("findAnchorOnPage(gn) = %+v", matches)
+	}
+}
+
+func TestSignPDFAnchor(t *testing.T) {
+	cert, pkey := loadCertificateAndKey(t)
+	input := "../testfiles/gen_pdf16_xref_table_3pages.pdf"
+	anchor := &Anchor{Text: "Page 2 of 3", OffsetY: -60, Width: 180, Height: 50}
+
+	m, err := findAnchor(openTestReader(t, input), anchor.Text, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpfile, err := os.CreateTemp("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(tmpfile.Name()) }()
+
+	signData := SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "John Doe"},
+			CertType: ApprovalSignature,
+		},
+		Appearance:      Appearance{Visible: true, Anchor: anchor},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	}
+	if _, err := SignFile(input, tmpfile.Name(), signData); err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+	verifyAllSignaturesValid(t, tmpfile, 1)
+
+	out, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rect := fmt.Sprintf("/Rect [%f %f %f %f]", m.x, m.y-60, m.x+180, m.y-10)
+	if !strings.Contains(string(out), rect) {
+		t.Errorf("signed file does not contain %s", rect)
+	}
+
+	signData.Appearance.Anchor = &Anchor{Text: "of 3", Width: 100, Height: 20}
+	if _, err := SignFile(input, tmpfile.Name(), signData); !errors.Is(err, ErrAnchorAmbiguous) {
+		t.Errorf("SignFile() with ambiguous anchor: error = %v, want %v", err, ErrAnchorAmbiguous)
+	}
+}
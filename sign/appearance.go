@@ -7,6 +7,7 @@ import (
 	"image"
 	_ "image/jpeg" // register JPEG format
 	_ "image/png"  // register PNG format
+	"strings"
 )
 
 // Helper functions for PDF resource components
@@ -14,34 +15,21 @@ import (
 // writeAppearanceHeader writes the header for the appearance stream.
 //
 // Should be closed by writeFormTypeAndLength.
-func writeAppearanceHeader(buffer *bytes.Buffer, rectWidth, rectHeight float64) {
+func writeAppearanceHeader(buffer *bytes.Buffer, rectWidth, rectHeight float64, rotate int) {
 	buffer.WriteString("<<\n")
 	buffer.WriteString("  /Type /XObject\n")
 	buffer.WriteString("  /Subtype /Form\n")
 	fmt.Fprintf(buffer, "  /BBox [0 0 %f %f]\n", rectWidth, rectHeight)
-	buffer.WriteString("  /Matrix [1 0 0 1 0 0]\n") // No scaling or translation
+	fmt.Fprintf(buffer, "  /Matrix %s\n", rotationMatrix(rotate)) // No scaling or translation, counter the page rotation
 }
 
-func createFontResource(buffer *bytes.Buffer) {
+func createFontResource(buffer *bytes.Buffer, font StandardFont) {
 	buffer.WriteString("   /Font <<\n")
 	buffer.WriteString("     /F1 <<\n")
 	buffer.WriteString("       /Type /Font\n")
 	buffer.WriteString("       /Subtype /Type1\n")
-	buffer.WriteString("       /BaseFont /Times-Roman\n")
-	buffer.WriteString("       /FirstChar 32\n") // Standard ASCII range start (space)
-	buffer.WriteString("       /LastChar 255\n") // Standard ASCII range end
-	buffer.WriteString("       /FontDescriptor <<\n")
-	buffer.WriteString("         /Type /FontDescriptor\n")
-	buffer.WriteString("         /FontName /Times-Roman\n")
-	buffer.WriteString("         /Flags 32\n")
-	buffer.WriteString("         /FontBBox [-168 -218 1000 898]\n")
-	buffer.WriteString("         /ItalicAngle 0\n")
-	buffer.WriteString("         /Ascent 683\n")
-	buffer.WriteString("         /Descent -217\n")
-	buffer.WriteString("         /CapHeight 662\n")
-	buffer.WriteString("         /StemV 84\n") // StemH is optionnal per ISO 32000-1:2008
-	buffer.WriteString("         /XHeight 450\n")
-	buffer.WriteString("       >>\n")
+	fmt.Fprintf(buffer, "       /BaseFont /%s\n", font)
+	buffer.WriteString("       /Encoding /WinAnsiEncoding\n")
 	buffer.WriteString("     >>\n")
 	buffer.WriteString("   >>\n")
 }
@@ -315,65 +303,103 @@ func hasAlpha(img image.Image) bool {
 	}
 }
 
-func computeTextSizeAndPosition(text string, rectWidth, rectHeight float64) (float64, float64, float64) {
-	// Calculate font size
-	fontSize := rectHeight * 0.8                     // Use most of the height for the font
-	textWidth := float64(len(text)) * fontSize * 0.5 // Approximate text width
-	if textWidth > rectWidth {
-		fontSize = rectWidth / (float64(len(text)) * 0.5) // Adjust font size to fit text within rect width
-	}
-
-	// Center text horizontally and vertically
-	textWidth = float64(len(text)) * fontSize * 0.5
-	textX := (rectWidth - textWidth) / 2
-	if textX < 0 {
-		textX = 0
-	}
-	textY := (rectHeight-fontSize)/2 + fontSize/3 // Approximate vertical centering
-
-	return fontSize, textX, textY
-}
-
-func drawText(buffer *bytes.Buffer, text string, fontSize float64, x, y float64) {
-	buffer.WriteString("q\n")                       // Save graphics state
-	buffer.WriteString("BT\n")                      // Begin text
-	fmt.Fprintf(buffer, "/F1 %.2f Tf\n", fontSize)  // Set font and size
-	fmt.Fprintf(buffer, "%.2f %.2f Td\n", x, y)     // Set text position
-	buffer.WriteString("0.2 0.2 0.6 rg\n")          // Set font color to ballpoint-like color (RGB)
-	fmt.Fprintf(buffer, "%s Tj\n", pdfString(text)) // Show text
-	buffer.WriteString("ET\n")                      // End text
-	buffer.WriteString("Q\n")                       // Restore graphics state
-}
-
-func drawImage(buffer *bytes.Buffer, rectWidth, rectHeight float64) {
+func drawImage(buffer *bytes.Buffer, b box) {
 	// We save state twice on purpose due to the cm operation
 	buffer.WriteString("q\n") // Save graphics state
 	buffer.WriteString("q\n") // Save before image transformation
-	fmt.Fprintf(buffer, "%.2f 0 0 %.2f 0 0 cm\n", rectWidth, rectHeight)
+	fmt.Fprintf(buffer, "%.2f 0 0 %.2f %.2f %.2f cm\n", b.w, b.h, b.x, b.y)
 	buffer.WriteString("/Im1 Do\n") // Draw image
 	buffer.WriteString("Q\n")       // Restore after transformation
 	buffer.WriteString("Q\n")       // Restore graphics state
 }
 
-func (context *SignContext) createAppearance(rect [4]float64) ([]byte, error) {
+// createAppearance creates the appearance XObject of a visible signature.
+// rotate is the clockwise display rotation of the page; the appearance is
+// laid out as displayed and rotated back into the rectangle.
+func (context *SignContext) createAppearance(rect [4]float64, rotate int) ([]byte, error) {
 	rectWidth := rect[2] - rect[0]
 	rectHeight := rect[3] - rect[1]
+	if rotate == 90 || rotate == 270 {
+		rectWidth, rectHeight = rectHeight, rectWidth
+	}
 
 	if rectWidth < 1 || rectHeight < 1 {
 		return nil, fmt.Errorf("invalid rectangle dimensions: width %.2f and height %.2f must be greater than 0", rectWidth, rectHeight)
 	}
 
-	hasImage := len(context.SignData.Appearance.Image) > 0
-	shouldDisplayText := context.SignData.Appearance.ImageAsWatermark || !hasImage
+	app := context.SignData.Appearance
+	if err := validateAppearanceLayout(app); err != nil {
+		return nil, err
+	}
+	standardFont := app.Font
+	if standardFont == "" {
+		standardFont = FontTimesRoman
+	}
+	var font appearanceFont = standardFont
+	spacing := app.LineSpacing
+	if spacing == 0 {
+		spacing = defaultLineSpacing
+	}
+
+	hasImage := len(app.Image) > 0
+	var imageWidth, imageHeight int
+	if hasImage {
+		var err error
+		if imageWidth, imageHeight, err = imageSize(app.Image); err != nil {
+			return nil, err
+		}
+	}
+	var qr *qrCode
+	var qrBox box
+	rest := box{w: rectWidth, h: rectHeight}
+	if app.QRCode != nil {
+		if err := validateQRCode(app.QRCode); err != nil {
+			return nil, err
+		}
+		content, err := context.qrCodeContent()
+		if err != nil {
+			return nil, err
+		}
+		level, _ := parseQRLevel(app.QRCode.ErrorCorrection)
+		if qr, err = encodeQR([]byte(content), level); err != nil {
+			return nil, err
+		}
+		qrBox, rest = layoutQRCode(app.QRCode, app.Padding, rectWidth, rectHeight)
+	}
+
+	imageBox, textBox := layoutBoxes(app, imageWidth, imageHeight, rest.w, rest.h)
+	imageBox.x += rest.x
+	textBox.x += rest.x
+	shouldDisplayText := textBox.w > 0 && textBox.h > 0
+
+	var paragraphs []string
+	if shouldDisplayText {
+		var err error
+		if paragraphs, err = context.appearanceText(); err != nil {
+			return nil, err
+		}
+	}
+
+	var subset *fontSubset
+	if shouldDisplayText && app.EmbeddedFont != nil {
+		var err error
+		if subset, err = app.EmbeddedFont.subset(strings.Join(paragraphs, "")); err != nil {
+			return nil, err
+		}
+		font = subset
+	}
 
 	// Create the appearance XObject
 	var appearance_buffer bytes.Buffer
-	writeAppearanceHeader(&appearance_buffer, rectWidth, rectHeight)
+	writeAppearanceHeader(&appearance_buffer, rectWidth, rectHeight, rotate)
 
 	// Resources dictionary with font
 	appearance_buffer.WriteString("  /Resources <<\n")
 
-	if hasImage {
+	if hasImage && context.VisualSignData.imageObjectId != 0 {
+		// Appearances on several pages share the image.
+		createImageResource(&appearance_buffer, context.VisualSignData.imageObjectId)
+	} else if hasImage {
 		// Create and add the image XObject
 		imageBytes, maskObjectBytes, err := context.createImageXObject()
 		if err != nil {
@@ -393,11 +419,18 @@ func (context *SignContext) createAppearance(rect [4]float64) ([]byte, error) {
 			}
 		}
 
+		context.VisualSignData.imageObjectId = imageObjectId
 		createImageResource(&appearance_buffer, imageObjectId)
 	}
 
-	if shouldDisplayText {
-		createFontResource(&appearance_buffer)
+	if subset != nil {
+		fontID, err := context.addFontSubset(subset)
+		if err != nil {
+			return nil, err
+		}
+		createEmbeddedFontResource(&appearance_buffer, fontID)
+	} else if shouldDisplayText {
+		createFontResource(&appearance_buffer, standardFont)
 	}
 
 	appearance_buffer.WriteString("  >>\n")
@@ -405,14 +438,25 @@ func (context *SignContext) createAppearance(rect [4]float64) ([]byte, error) {
 	// Create the appearance stream
 	var appearance_stream_buffer bytes.Buffer
 
+	if app.BackgroundColor != nil {
+		drawBackground(&appearance_stream_buffer, *app.BackgroundColor, rectWidth, rectHeight)
+	}
+
 	if hasImage {
-		drawImage(&appearance_stream_buffer, rectWidth, rectHeight)
+		drawImage(&appearance_stream_buffer, imageBox)
 	}
 
 	if shouldDisplayText {
-		text := context.SignData.Signature.Info.Name
-		fontSize, textX, textY := computeTextSizeAndPosition(text, rectWidth, rectHeight)
-		drawText(&appearance_stream_buffer, text, fontSize, textX, textY)
+		fontSize, lines := fitText(paragraphs, font, app.FontSize, spacing, textBox)
+		drawTextBlock(&appearance_stream_buffer, app, font, lines, fontSize, spacing, textBox)
+	}
+
+	if qr != nil {
+		drawQRCode(&appearance_stream_buffer, qr, app.QRCode.Color, qrBox)
+	}
+
+	if app.BorderColor != nil && app.BorderWidth > 0 {
+		drawBorder(&appearance_stream_buffer, *app.BorderColor, app.BorderWidth, rectWidth, rectHeight)
 	}
 
 	// Encrypt the appearance stream if the PDF is encrypted.
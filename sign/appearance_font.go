@@ -0,0 +1,165 @@
+package sign
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode/utf16"
+)
+
+// appearanceFont measures and encodes text drawn in an appearance stream.
+// It is implemented by StandardFont and by subsets of embedded fonts.
+type appearanceFont interface {
+	// textWidth returns the width of s in points when set at size.
+	textWidth(s string, size float64) float64
+	// encodeText returns s as a PDF string operand for Tj.
+	encodeText(s string) string
+}
+
+// encodeText returns s as a WinAnsiEncoding literal string.
+func (font StandardFont) encodeText(s string) string {
+	return winAnsiString(s)
+}
+
+// addFontSubset writes the objects of an embedded font subset (font
+// program, ToUnicode CMap, font descriptor, CIDFont and Type0 font) and
+// returns the object ID of the Type0 font. Appearances using the same
+// subset share its objects.
+func (context *SignContext) addFontSubset(subset *fontSubset) (uint32, error) {
+	name := subset.baseFont()
+	if id, ok := context.embeddedFonts[name]; ok {
+		return id, nil
+	}
+
+	program := subset.fontFile()
+	fontFile, err := context.encryptStreamForNextObject(compressData(program))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt font program: %w", err)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<<\n  /Length %d\n  /Length1 %d\n  /Filter /FlateDecode\n>>\nstream\n", len(fontFile), len(program))
+	buf.Write(fontFile)
+	buf.WriteString("\nendstream\n")
+	fontFileID, err := context.addObject(buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("failed to add font program: %w", err)
+	}
+
+	toUnicode, err := context.encryptStreamForNextObject(compressData(subset.toUnicodeCMap()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt ToUnicode CMap: %w", err)
+	}
+	buf.Reset()
+	fmt.Fprintf(&buf, "<<\n  /Length %d\n  /Filter /FlateDecode\n>>\nstream\n", len(toUnicode))
+	buf.Write(toUnicode)
+	buf.WriteString("\nendstream\n")
+	toUnicodeID, err := context.addObject(buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("failed to add ToUnicode CMap: %w", err)
+	}
+
+	f := subset.font
+	buf.Reset()
+	buf.WriteString("<<\n  /Type /FontDescriptor\n")
+	fmt.Fprintf(&buf, "  /FontName /%s\n", name)
+	buf.WriteString("  /Flags 4\n") // Symbolic: glyphs are selected by CID, not by a standard encoding
+	fmt.Fprintf(&buf, "  /FontBBox [%d %d %d %d]\n",
+		subset.scaled(int(f.bbox[0])), subset.scaled(int(f.bbox[1])), subset.scaled(int(f.bbox[2])), subset.scaled(int(f.bbox[3])))
+	fmt.Fprintf(&buf, "  /ItalicAngle %.2f\n", f.italicAngle)
+	fmt.Fprintf(&buf, "  /Ascent %d\n", subset.scaled(int(f.ascent)))
+	fmt.Fprintf(&buf, "  /Descent %d\n", subset.scaled(int(f.descent)))
+	fmt.Fprintf(&buf, "  /CapHeight %d\n", subset.scaled(int(f.capHeight)))
+	buf.WriteString("  /StemV 80\n")
+	fmt.Fprintf(&buf, "  /FontFile2 %d 0 R\n", fontFileID)
+	buf.WriteString(">>\n")
+	descriptorID, err := context.addObject(buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("failed to add font descriptor: %w", err)
+	}
+
+	cidFontID := context.getNextObjectID()
+	registry, err := context.encryptPdfString(cidFontID, "Adobe")
+	if err != nil {
+		return 0, err
+	}
+	ordering, err := context.encryptPdfString(cidFontID, "Identity")
+	if err != nil {
+		return 0, err
+	}
+	buf.Reset()
+	buf.WriteString("<<\n  /Type /Font\n  /Subtype /CIDFontType2\n")
+	fmt.Fprintf(&buf, "  /BaseFont /%s\n", name)
+	fmt.Fprintf(&buf, "  /CIDSystemInfo << /Registry %s /Ordering %s /Supplement 0 >>\n", registry, ordering)
+	fmt.Fprintf(&buf, "  /FontDescriptor %d 0 R\n", descriptorID)
+	fmt.Fprintf(&buf, "  /DW %d\n", subset.scaled(int(f.advances[0])))
+	buf.WriteString("  /W [")
+	for cid, gid := range subset.glyphs {
+		fmt.Fprintf(&buf, " %d [%d]", cid, subset.scaled(int(f.advances[gid])))
+	}
+	buf.WriteString(" ]\n")
+	buf.WriteString("  /CIDToGIDMap /Identity\n")
+	buf.WriteString(">>\n")
+	if _, err := context.addObject(buf.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to add CIDFont: %w", err)
+	}
+
+	buf.Reset()
+	buf.WriteString("<<\n  /Type /Font\n  /Subtype /Type0\n")
+	fmt.Fprintf(&buf, "  /BaseFont /%s\n", name)
+	buf.WriteString("  /Encoding /Identity-H\n")
+	fmt.Fprintf(&buf, "  /DescendantFonts [%d 0 R]\n", cidFontID)
+	fmt.Fprintf(&buf, "  /ToUnicode %d 0 R\n", toUnicodeID)
+	buf.WriteString(">>\n")
+	fontID, err := context.addObject(buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("failed to add Type0 font: %w", err)
+	}
+
+	if context.embeddedFonts == nil {
+		context.embeddedFonts = make(map[string]uint32)
+	}
+	context.embeddedFonts[name] = fontID
+	return fontID, nil
+}
+
+// toUnicodeCMap maps the subset glyph IDs back to the characters they were
+// selected for, so text can be extracted and searched.
+func (s *fontSubset) toUnicodeCMap() []byte {
+	cids := make([]int, 0, len(s.unicode))
+	for cid := range s.unicode {
+		cids = append(cids, int(cid))
+	}
+	sort.Ints(cids)
+
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// bfchar sections hold at most 100 entries.
+	for start := 0; start < len(cids); start += 100 {
+		end := start + 100
+		if end > len(cids) {
+			end = len(cids)
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, cid := range cids[start:end] {
+			fmt.Fprintf(&b, "<%04X> <", cid)
+			for _, u := range utf16.Encode([]rune{s.unicode[uint16(cid)]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// createEmbeddedFontResource writes a font resource dictionary referencing
+// an embedded font as /F1.
+func createEmbeddedFontResource(buffer *bytes.Buffer, fontID uint32) {
+	buffer.WriteString("   /Font <<\n")
+	fmt.Fprintf(buffer, "     /F1 %d 0 R\n", fontID)
+	buffer.WriteString("   >>\n")
+}
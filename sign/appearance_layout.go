@@ -0,0 +1,305 @@
+package sign
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+const (
+	defaultLineSpacing = 1.2
+	minAutoFontSize    = 2.0
+	maxAutoFontSize    = 72.0
+)
+
+// defaultTextColor is the ballpoint-like blue used for appearance text.
+var defaultTextColor = Color{R: 0.2, G: 0.2, B: 0.6}
+
+// AppearanceTextData holds the values available to Appearance.Text.
+type AppearanceTextData struct {
+	Name        string
+	Reason      string
+	Location    string
+	ContactInfo string
+	// Date is the signing time formatted like filled date fields (see
+	// Appearance.DateFormat, DateStyle, Locale and Timezone).
+	Date string
+
+	// Subject and Issuer are the common names of the signing certificate
+	// and its issuer; SubjectDN and IssuerDN are the full distinguished
+	// names. Serial is the certificate serial number in hexadecimal.
+	Subject   string
+	SubjectDN string
+	Issuer    string
+	IssuerDN  string
+	Serial    string
+}
+
+// box is a rectangle in appearance stream coordinates.
+type box struct {
+	x, y, w, h float64
+}
+
+// appearanceTextData collects the template values for the signature.
+func (context *SignContext) appearanceTextData() (AppearanceTextData, error) {
+	info := context.SignData.Signature.Info
+	data := AppearanceTextData{
+		Name:        info.Name,
+		Reason:      info.Reason,
+		Location:    info.Location,
+		ContactInfo: info.ContactInfo,
+	}
+
+	date, err := applyTimezone(info.Date, context.SignData.Appearance.Timezone)
+	if err != nil {
+		return data, err
+	}
+	if data.Date, err = formatFillableDate(date, context.SignData.Appearance); err != nil {
+		return data, err
+	}
+
+	if cert := context.SignData.Certificate; cert != nil {
+		data.Subject = cert.Subject.CommonName
+		data.SubjectDN = cert.Subject.String()
+		data.Issuer = cert.Issuer.CommonName
+		data.IssuerDN = cert.Issuer.String()
+		data.Serial = fmt.Sprintf("%X", cert.SerialNumber)
+	}
+	return data, nil
+}
+
+// appearanceText returns the paragraphs of the visible signature text.
+func (context *SignContext) appearanceText() ([]string, error) {
+	app := context.SignData.Appearance
+	if app.Text == "" {
+		return []string{context.SignData.Signature.Info.Name}, nil
+	}
+
+	tmpl, err := template.New("appearance").Option("missingkey=error").Parse(app.Text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid appearance text template: %w", err)
+	}
+	data, err := context.appearanceTextData()
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render appearance text: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// validateAppearanceLayout checks the layout options of app.
+func validateAppearanceLayout(app Appearance) error {
+	if app.Font != "" && !app.Font.valid() {
+		return fmt.Errorf("unsupported appearance font %q", app.Font)
+	}
+	switch app.TextAlign {
+	case "", AlignLeft, AlignCenter, AlignRight:
+	default:
+		return fmt.Errorf("invalid text alignment %q", app.TextAlign)
+	}
+	switch app.VerticalAlign {
+	case "", AlignTop, AlignMiddle, AlignBottom:
+	default:
+		return fmt.Errorf("invalid vertical alignment %q", app.VerticalAlign)
+	}
+	switch app.ImagePosition {
+	case "", ImageFill, ImageLeft, ImageRight, ImageBehind:
+	default:
+		return fmt.Errorf("invalid image position %q", app.ImagePosition)
+	}
+	if app.FontSize < 0 || app.Padding < 0 || app.BorderWidth < 0 || app.LineSpacing < 0 {
+		return fmt.Errorf("font size, padding, border width and line spacing must not be negative")
+	}
+	return nil
+}
+
+// layoutBoxes splits the rectangle into the image and text areas. An empty
+// box means the element is not drawn.
+func layoutBoxes(app Appearance, imageWidth, imageHeight int, rectWidth, rectHeight float64) (imageBox, textBox box) {
+	inner := box{x: app.Padding, y: app.Padding, w: rectWidth - 2*app.Padding, h: rectHeight - 2*app.Padding}
+	full := box{w: rectWidth, h: rectHeight}
+
+	if imageWidth == 0 || imageHeight == 0 {
+		return box{}, inner
+	}
+
+	switch app.ImagePosition {
+	case ImageLeft, ImageRight:
+		// Keep the aspect ratio, use at most half of the width.
+		h := inner.h
+		w := h * float64(imageWidth) / float64(imageHeight)
+		if w > inner.w/2 {
+			w = inner.w / 2
+			h = w * float64(imageHeight) / float64(imageWidth)
+		}
+		imageBox = box{y: inner.y + (inner.h-h)/2, w: w, h: h}
+		textBox = box{y: inner.y, w: inner.w - w - app.Padding, h: inner.h}
+		if app.ImagePosition == ImageLeft {
+			imageBox.x = inner.x
+			textBox.x = inner.x + w + app.Padding
+		} else {
+			imageBox.x = inner.x + inner.w - w
+			textBox.x = inner.x
+		}
+		return imageBox, textBox
+	case ImageBehind:
+		return full, inner
+	default:
+		if app.ImageAsWatermark {
+			return full, inner
+		}
+		return full, box{}
+	}
+}
+
+// wrapParagraphs breaks paragraphs into lines no wider than width. fits is
+// false when a single word had to be broken to fit.
+func wrapParagraphs(paragraphs []string, font appearanceFont, size, width float64) (lines []string, fits bool) {
+	fits = true
+	for _, p := range paragraphs {
+		var line string
+		for _, word := range strings.Fields(p) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if font.textWidth(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			for font.textWidth(word, size) > width && utf8.RuneCountInString(word) > 1 {
+				fits = false
+				runes := []rune(word)
+				n := len(runes) - 1
+				for n > 1 && font.textWidth(string(runes[:n]), size) > width {
+					n--
+				}
+				lines = append(lines, string(runes[:n]))
+				word = string(runes[n:])
+			}
+			line = word
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, fits
+}
+
+// fitText returns the font size and wrapped lines for the text box. A fixed
+// size is used as is; otherwise the largest size whose wrapped text fits the
+// box without breaking words is searched.
+func fitText(paragraphs []string, font appearanceFont, fixedSize, spacing float64, b box) (float64, []string) {
+	blockHeight := func(n int, size float64) float64 {
+		return size + float64(n-1)*size*spacing
+	}
+
+	if fixedSize > 0 {
+		lines, _ := wrapParagraphs(paragraphs, font, fixedSize, b.w)
+		return fixedSize, lines
+	}
+
+	lo, hi := minAutoFontSize, maxAutoFontSize
+	if b.h < hi {
+		hi = b.h
+	}
+	if hi < lo {
+		hi = lo
+	}
+	best, bestLines := lo, []string(nil)
+	for i := 0; i < 20; i++ {
+		size := (lo + hi) / 2
+		lines, fits := wrapParagraphs(paragraphs, font, size, b.w)
+		if fits && blockHeight(len(lines), size) <= b.h {
+			best, bestLines, lo = size, lines, size
+		} else {
+			hi = size
+		}
+	}
+	if bestLines == nil {
+		bestLines, _ = wrapParagraphs(paragraphs, font, best, b.w)
+	}
+	return best, bestLines
+}
+
+// drawTextBlock writes the lines aligned in b, clipped to the box.
+func drawTextBlock(buffer *bytes.Buffer, app Appearance, font appearanceFont, lines []string, size, spacing float64, b box) {
+	if len(lines) == 0 || b.w <= 0 || b.h <= 0 {
+		return
+	}
+	leading := size * spacing
+	blockHeight := size + float64(len(lines)-1)*leading
+	// The baseline sits about 0.8 em below the top of a line and the
+	// descenders take the remaining 0.2 em.
+	var top float64
+	switch app.VerticalAlign {
+	case AlignTop:
+		top = b.y + b.h
+	case AlignBottom:
+		top = b.y + blockHeight
+	default:
+		top = b.y + (b.h+blockHeight)/2
+	}
+
+	color := defaultTextColor
+	if app.TextColor != nil {
+		color = *app.TextColor
+	}
+
+	buffer.WriteString("q\n")
+	fmt.Fprintf(buffer, "%.2f %.2f %.2f %.2f re W n\n", b.x, b.y, b.w, b.h) // Clip to the text box
+	buffer.WriteString("BT\n")
+	fmt.Fprintf(buffer, "/F1 %.2f Tf\n", size)
+	fmt.Fprintf(buffer, "%.3f %.3f %.3f rg\n", color.R, color.G, color.B)
+	for i, line := range lines {
+		x := b.x
+		switch app.TextAlign {
+		case AlignLeft:
+		case AlignRight:
+			x = b.x + b.w - font.textWidth(line, size)
+		default:
+			x = b.x + (b.w-font.textWidth(line, size))/2
+		}
+		y := top - 0.8*size - float64(i)*leading
+		fmt.Fprintf(buffer, "1 0 0 1 %.2f %.2f Tm\n", x, y)
+		fmt.Fprintf(buffer, "%s Tj\n", font.encodeText(line))
+	}
+	buffer.WriteString("ET\n")
+	buffer.WriteString("Q\n")
+}
+
+func drawBackground(buffer *bytes.Buffer, color Color, rectWidth, rectHeight float64) {
+	fmt.Fprintf(buffer, "q %.3f %.3f %.3f rg 0 0 %.2f %.2f re f Q\n", color.R, color.G, color.B, rectWidth, rectHeight)
+}
+
+func drawBorder(buffer *bytes.Buffer, color Color, width, rectWidth, rectHeight float64) {
+	// Stroke inside the rectangle so the border is not clipped by the BBox.
+	fmt.Fprintf(buffer, "q %.3f %.3f %.3f RG %.2f w %.2f %.2f %.2f %.2f re S Q\n",
+		color.R, color.G, color.B, width, width/2, width/2, rectWidth-width, rectHeight-width)
+}
+
+// imageSize returns the pixel dimensions of the appearance image.
+func imageSize(data []byte) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
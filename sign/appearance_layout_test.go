@@ -0,0 +1,180 @@
+package sign
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStandardFontTextWidth(t *testing.T) {
+	tests := []struct {
+		font StandardFont
+		text string
+		want float64
+	}{
+		{FontHelvetica, "Hello", (722 + 556 + 222 + 222 + 556) * 12.0 / 1000},
+		{FontHelveticaBold, "Hello", (722 + 556 + 278 + 278 + 611) * 12.0 / 1000},
+		{FontTimesRoman, "Hello", (722 + 444 + 278 + 278 + 500) * 12.0 / 1000},
+		{FontCourier, "Hello", 5 * 600 * 12.0 / 1000},
+		{FontHelvetica, "é", 556 * 12.0 / 1000}, // base letter width
+	}
+	for _, tt := range tests {
+		if got := tt.font.textWidth(tt.text, 12); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s.textWidth(%q) = %v, want %v", tt.font, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWinAnsiString(t *testing.T) {
+	if got, want := winAnsiString(`Café (a\b) ☃`), "(Caf\xe9 \\(a\\\\b\\) ?)"; got != want {
+		t.Errorf("winAnsiString() = %q, want %q", got, want)
+	}
+}
+
+func TestWrapParagraphs(t *testing.T) {
+	width := FontHelvetica.textWidth("Signed by Jane", 10)
+	lines, fits := wrapParagraphs([]string{"Signed by Jane Doe", "Reason: ok"}, FontHelvetica, 10, width)
+	want := []string{"Signed by Jane", "Doe", "Reason: ok"}
+	if !fits || strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapParagraphs() = %q, %v, want %q, true", lines, fits, want)
+	}
+
+	lines, fits = wrapParagraphs([]string{"Supercalifragilistic"}, FontHelvetica, 10, 30)
+	if fits || len(lines) < 2 {
+		t.Errorf("long word: wrapParagraphs() = %q, %v, want broken word", lines, fits)
+	}
+	for _, line := range lines {
+		if FontHelvetica.textWidth(line, 10) > 30 {
+			t.Errorf("line %q is wider than the box", line)
+		}
+	}
+}
+
+func TestFitText(t *testing.T) {
+	b := box{w: 150, h: 40}
+	paragraphs := []string{"Jane Doe", "Reason: I approve this document", "2024-01-15 14:30 CET"}
+	size, lines := fitText(paragraphs, FontHelvetica, 0, defaultLineSpacing, b)
+
+	if size < minAutoFontSize || size > b.h {
+		t.Fatalf("font size %v out of range", size)
+	}
+	if height := size + float64(len(lines)-1)*size*defaultLineSpacing; height > b.h+1e-6 {
+		t.Errorf("text block height %v exceeds box height %v", height, b.h)
+	}
+	for _, line := range lines {
+		if w := FontHelvetica.textWidth(line, size); w > b.w+1e-6 {
+			t.Errorf("line %q width %v exceeds box width %v", line, w, b.w)
+		}
+	}
+
+	// A slightly larger size must not fit, otherwise the search stopped early.
+	bigger := size * 1.05
+	biggerLines, fits := wrapParagraphs(paragraphs, FontHelvetica, bigger, b.w)
+	if fits && bigger+float64(len(biggerLines)-1)*bigger*defaultLineSpacing <= b.h {
+		t.Errorf("font size %v is not the largest fitting size", size)
+	}
+
+	if size, _ := fitText(paragraphs, FontHelvetica, 9, defaultLineSpacing, b); size != 9 {
+		t.Errorf("fixed font size = %v, want 9", size)
+	}
+}
+
+func TestLayoutBoxes(t *testing.T) {
+	app := Appearance{ImagePosition: ImageLeft, Padding: 4}
+	img, text := layoutBoxes(app, 100, 50, 200, 60)
+	// The image may use at most half of the inner width (192/2), keeping
+	// its 2:1 aspect ratio, and is centred vertically.
+	if img.x != 4 || img.w != 96 || img.h != 48 || img.y != 6 {
+		t.Errorf("image box = %+v", img)
+	}
+	if text.x != 4+96+4 || text.x+text.w != 196 {
+		t.Errorf("text box = %+v", text)
+	}
+
+	app.ImagePosition = ImageRight
+	img, text = layoutBoxes(app, 100, 50, 200, 60)
+	if img.x+img.w != 196 || text.x != 4 || text.x+text.w > img.x {
+		t.Errorf("right: image box = %+v, text box = %+v", img, text)
+	}
+
+	_, text = layoutBoxes(Appearance{}, 100, 50, 200, 60)
+	if text.w != 0 {
+		t.Errorf("image fill without watermark should draw no text, got %+v", text)
+	}
+	_, text = layoutBoxes(Appearance{ImageAsWatermark: true}, 100, 50, 200, 60)
+	if text.w != 200 {
+		t.Errorf("watermark text box = %+v", text)
+	}
+}
+
+func TestValidateAppearanceLayout(t *testing.T) {
+	for _, app := range []Appearance{
+		{Font: "Symbol"},
+		{TextAlign: "justify"},
+		{VerticalAlign: "baseline"},
+		{ImagePosition: "above"},
+		{Padding: -1},
+	} {
+		if err := validateAppearanceLayout(app); err == nil {
+			t.Errorf("validateAppearanceLayout(%+v) = nil, want error", app)
+		}
+	}
+}
+
+func TestCreateAppearanceTemplate(t *testing.T) {
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(0xABCD),
+		Subject:      pkix.Name{CommonName: "Jane Doe", Organization: []string{"ACME"}},
+		Issuer:       pkix.Name{CommonName: "ACME CA"},
+	}
+	context := SignContext{SignData: SignData{
+		Certificate: cert,
+		Signature: SignDataSignature{Info: SignDataSignatureInfo{
+			Name:   "Jane Doe",
+			Reason: "Approval",
+			Date:   time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC),
+		}},
+		Appearance: Appearance{
+			Text:            "Signed by {{.Name}}\n{{if .Location}}Location: {{.Location}}{{end}}\nReason: {{.Reason}}\nIssuer: {{.Issuer}} ({{.Serial}})\n{{.Date}}",
+			Font:            FontHelveticaBold,
+			TextAlign:       AlignLeft,
+			Padding:         3,
+			BackgroundColor: &Color{R: 1, G: 1, B: 0.9},
+			BorderColor:     &Color{},
+			BorderWidth:     1,
+		},
+	}}
+
+	paragraphs, err := context.appearanceText()
+	if err != nil {
+		t.Fatalf("appearanceText() error = %v", err)
+	}
+	want := []string{"Signed by Jane Doe", "Reason: Approval", "Issuer: ACME CA (ABCD)"}
+	if len(paragraphs) != 4 || strings.Join(paragraphs[:3], "|") != strings.Join(want, "|") || !strings.HasPrefix(paragraphs[3], "01/15/2024") {
+		t.Errorf("appearanceText() = %q", paragraphs)
+	}
+
+	appearance, err := context.createAppearance([4]float64{0, 0, 200, 80}, 0)
+	if err != nil {
+		t.Fatalf("createAppearance() error = %v", err)
+	}
+	for _, want := range []string{
+		"/BaseFont /Helvetica-Bold", "/Encoding /WinAnsiEncoding",
+		"1.000 1.000 0.900 rg 0 0 200.00 80.00 re f", // background
+		"RG 1.00 w 0.50 0.50 199.00 79.00 re S",      // border
+		"(Signed by Jane Doe) Tj", "1 0 0 1 3.00 ",
+	} {
+		if !strings.Contains(string(appearance), want) {
+			t.Errorf("appearance does not contain %q:\n%s", want, appearance)
+		}
+	}
+
+	context.SignData.Appearance.Text = "{{.Missing}}"
+	if _, err := context.createAppearance([4]float64{0, 0, 200, 80}, 0); err == nil {
+		t.Error("createAppearance() with unknown template field: error = nil")
+	}
+}
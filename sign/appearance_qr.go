@@ -0,0 +1,182 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+)
+
+// Positions of a QR code in the signature appearance.
+const (
+	// QRRight and QRLeft reserve a square on that side of the rectangle for
+	// the code; the image and text are laid out in the remaining space.
+	QRRight = "right"
+	QRLeft  = "left"
+	// QRCorner draws the code in the bottom-right corner over the rest of
+	// the appearance without reserving space.
+	QRCorner = "corner"
+)
+
+// DefaultQRContent is the compact payload used when QRCode.Content is empty.
+const DefaultQRContent = "{{.Name}};{{.Time}}"
+
+// qrQuietZone is the light margin around the symbol, in modules.
+const qrQuietZone = 4
+
+// QRCode adds a QR code, typically encoding a verification URL, to the
+// visible signature. The code is drawn as vector paths in the appearance.
+type QRCode struct {
+	// Content is a text/template for the encoded text, rendered with
+	// QRCodeData, e.g.
+	// "https://verify.example.com/?doc={{.InputHash}}&sig={{urlquery .SignatureID}}".
+	// When empty, DefaultQRContent is used.
+	Content string
+	// Position is QRRight (default), QRLeft or QRCorner.
+	Position string
+	// Size is the side of the code in points, including its quiet zone.
+	// When zero, the code is as large as the rectangle allows, or half its
+	// height for QRCorner.
+	Size float64
+	// ErrorCorrection is QRLevelL, QRLevelM (default), QRLevelQ or QRLevelH.
+	ErrorCorrection string
+	// Color of the dark modules, black by default. The quiet zone is
+	// always white.
+	Color *Color
+}
+
+// QRCodeData holds the values available to QRCode.Content.
+type QRCodeData struct {
+	AppearanceTextData
+	// Time is the signing time in UTC formatted as RFC 3339.
+	Time string
+	// SignatureID is the name of the signature field, e.g. "Signature 1".
+	SignatureID string
+	// InputHash is the hex SHA-256 digest of the document before signing.
+	// The digest of the signed document cannot be used, because the
+	// appearance is part of it.
+	InputHash string
+}
+
+// qrCodeData collects the template values for the QR code.
+func (context *SignContext) qrCodeData() (QRCodeData, error) {
+	text, err := context.appearanceTextData()
+	if err != nil {
+		return QRCodeData{}, err
+	}
+	data := QRCodeData{
+		AppearanceTextData: text,
+		SignatureID:        context.signatureFieldName(),
+	}
+	if date := context.SignData.Signature.Info.Date; !date.IsZero() {
+		data.Time = date.UTC().Format(time.RFC3339)
+	}
+
+	if _, err := context.InputFile.Seek(0, io.SeekStart); err != nil {
+		return data, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, context.InputFile); err != nil {
+		return data, fmt.Errorf("failed to hash input document: %w", err)
+	}
+	data.InputHash = hex.EncodeToString(h.Sum(nil))
+	return data, nil
+}
+
+// qrCodeContent renders the content template of the QR code.
+func (context *SignContext) qrCodeContent() (string, error) {
+	content := context.SignData.Appearance.QRCode.Content
+	if content == "" {
+		content = DefaultQRContent
+	}
+	tmpl, err := template.New("qrcode").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("invalid QR code content template: %w", err)
+	}
+	data, err := context.qrCodeData()
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render QR code content: %w", err)
+	}
+	return out.String(), nil
+}
+
+// validateQRCode checks the options of q.
+func validateQRCode(q *QRCode) error {
+	switch q.Position {
+	case "", QRRight, QRLeft, QRCorner:
+	default:
+		return fmt.Errorf("invalid QR code position %q", q.Position)
+	}
+	if q.Size < 0 {
+		return fmt.Errorf("QR code size must not be negative")
+	}
+	_, err := parseQRLevel(q.ErrorCorrection)
+	return err
+}
+
+// layoutQRCode returns the square of the QR code and the part of the
+// rectangle left for the image and text, as an offset and size.
+func layoutQRCode(q *QRCode, padding, rectWidth, rectHeight float64) (qrBox, rest box) {
+	rest = box{w: rectWidth, h: rectHeight}
+	innerW, innerH := rectWidth-2*padding, rectHeight-2*padding
+
+	if q.Position == QRCorner {
+		side := q.Size
+		if side == 0 {
+			side = innerH / 2
+		}
+		side = minFloat(side, minFloat(innerW, innerH))
+		return box{x: rectWidth - padding - side, y: padding, w: side, h: side}, rest
+	}
+
+	side := minFloat(innerH, innerW/2)
+	if q.Size > 0 {
+		side = minFloat(side, q.Size)
+	}
+	qrBox = box{y: padding + (innerH-side)/2, w: side, h: side}
+	rest.w = rectWidth - side - padding
+	if q.Position == QRLeft {
+		qrBox.x = padding
+		rest.x = padding + side
+	} else {
+		qrBox.x = rectWidth - padding - side
+	}
+	return qrBox, rest
+}
+
+// drawQRCode draws the modules of qr as filled rectangles on a white
+// square covering b. Horizontal runs of dark modules are merged.
+func drawQRCode(buffer *bytes.Buffer, qr *qrCode, color *Color, b box) {
+	module := b.w / float64(qr.size+2*qrQuietZone)
+	originX := b.x + qrQuietZone*module
+	originY := b.y + b.h - qrQuietZone*module // modules are numbered from the top
+
+	buffer.WriteString("q\n")
+	fmt.Fprintf(buffer, "1 1 1 rg %.2f %.2f %.2f %.2f re f\n", b.x, b.y, b.w, b.h)
+	c := Color{}
+	if color != nil {
+		c = *color
+	}
+	fmt.Fprintf(buffer, "%.3f %.3f %.3f rg\n", c.R, c.G, c.B)
+	for y, row := range qr.modules {
+		for x := 0; x < qr.size; x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x+1 < qr.size && row[x+1] {
+				x++
+			}
+			fmt.Fprintf(buffer, "%.3f %.3f %.3f %.3f re\n",
+				originX+float64(start)*module, originY-float64(y+1)*module, float64(x-start+1)*module, module)
+		}
+	}
+	buffer.WriteString("f\nQ\n")
+}
@@ -0,0 +1,120 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutQRCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        QRCode
+		wantQR   box
+		wantRest box
+	}{
+		{"right", QRCode{}, box{x: 135, y: 5, w: 60, h: 60}, box{w: 135, h: 70}},
+		{"left sized", QRCode{Position: QRLeft, Size: 40}, box{x: 5, y: 15, w: 40, h: 40}, box{x: 45, w: 155, h: 70}},
+		{"corner", QRCode{Position: QRCorner}, box{x: 165, y: 5, w: 30, h: 30}, box{w: 200, h: 70}},
+	}
+	for _, tt := range tests {
+		qr, rest := layoutQRCode(&tt.q, 5, 200, 70)
+		if qr != tt.wantQR || rest != tt.wantRest {
+			t.Errorf("%s: layoutQRCode() = %+v, %+v, want %+v, %+v", tt.name, qr, rest, tt.wantQR, tt.wantRest)
+		}
+	}
+}
+
+func TestDrawQRCode(t *testing.T) {
+	qr, err := encodeQR([]byte("hello"), qrM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	drawQRCode(&buf, qr, nil, box{x: 10, y: 10, w: 29, h: 29})
+	out := buf.String()
+	if !strings.HasPrefix(out, "q\n1 1 1 rg 10.00 10.00 29.00 29.00 re f\n0.000 0.000 0.000 rg\n") {
+		t.Errorf("drawQRCode() starts with %q", out[:min(len(out), 80)])
+	}
+	// Version 1 is 21 modules plus the quiet zone, one point each; the top
+	// row of the finder patterns starts 4 modules in from the top-left.
+	if !strings.Contains(out, "\n14.000 34.000 7.000 1.000 re\n") {
+		t.Errorf("drawQRCode() does not draw the top row of the finder pattern:\n%s", out)
+	}
+}
+
+func TestSignPDFQRCode(t *testing.T) {
+	cert, pkey := loadCertificateAndKey(t)
+	input := "../testfiles/testfile20.pdf"
+	inputData, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(inputData)
+
+	signData := SignData{
+		Signature: SignDataSignature{
+			Info: SignDataSignatureInfo{
+				Name: "John Doe",
+				Date: time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC),
+			},
+			CertType: ApprovalSignature,
+		},
+		Appearance: Appearance{
+			Visible:     true,
+			LowerLeftX:  350,
+			LowerLeftY:  75,
+			UpperRightX: 590,
+			UpperRightY: 145,
+			QRCode: &QRCode{
+				Content: "https://verify.example.com/?doc={{.InputHash}}&sig={{urlquery .SignatureID}}",
+			},
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	}
+
+	context := &SignContext{SignData: signData, InputFile: bytes.NewReader(inputData)}
+	content, err := context.qrCodeContent()
+	if err != nil {
+		t.Fatalf("qrCodeContent() error = %v", err)
+	}
+	if want := "https://verify.example.com/?doc=" + hex.EncodeToString(sum[:]) + "&sig=Signature+1"; content != want {
+		t.Errorf("qrCodeContent() = %q, want %q", content, want)
+	}
+	context.SignData.Appearance.QRCode = &QRCode{}
+	if content, _ := context.qrCodeContent(); content != "John Doe;2024-01-15T14:30:00Z" {
+		t.Errorf("default qrCodeContent() = %q", content)
+	}
+
+	output := filepath.Join(t.TempDir(), "signed.pdf")
+	if _, err := SignFile(input, output, signData); err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	verifyAllSignaturesValid(t, f, 1)
+
+	out, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out, []byte("1 1 1 rg")) || bytes.Count(out, []byte(" re\n")) < 50 {
+		t.Error("signed file does not contain the QR code paths")
+	}
+
+	signData.Appearance.QRCode = &QRCode{Position: "top"}
+	if _, err := SignFile(input, output, signData); err == nil {
+		t.Error("SignFile() with invalid QR code position: error = nil")
+	}
+}
@@ -0,0 +1,281 @@
+package sign
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/digitorus/pdf"
+)
+
+// ErrChangeNotPermitted is returned when an existing certification
+// signature or a signature field lock does not permit an update.
+var ErrChangeNotPermitted = errors.New("change not permitted by the document's signatures")
+
+// PermissionPolicy decides what Sign does when the update it makes is not
+// permitted by the document.
+type PermissionPolicy int
+
+const (
+	// PermissionRefuse fails with an error wrapping ErrChangeNotPermitted.
+	PermissionRefuse PermissionPolicy = iota
+	// PermissionWarn signs anyway, which invalidates the certification or
+	// locking signature, and lists the violations in the Warnings of the
+	// returned SignatureInfo.
+	PermissionWarn
+	// PermissionIgnore signs without checking the permissions.
+	PermissionIgnore
+)
+
+// Change is a kind of modification made by an incremental update.
+type Change int
+
+const (
+	// ChangeDocumentTimestamp adds a document timestamp. Document
+	// timestamps and validation data are not considered changes.
+	ChangeDocumentTimestamp Change = iota + 1
+	// ChangeSignature adds a signature other than a document timestamp.
+	ChangeSignature
+	// ChangeSignatureWidget shows a new signature field on a page. Sign
+	// always creates a new field, so its widget is a new annotation rather
+	// than the widget of an existing field.
+	ChangeSignatureWidget
+	// ChangeFormFill changes the values of form fields.
+	ChangeFormFill
+	// ChangeAnnotation creates, modifies or deletes annotations.
+	ChangeAnnotation
+	// ChangeValidationData adds a document security store (DSS).
+	ChangeValidationData
+	// ChangeCertification adds a certification signature, which must be
+	// the first signature of a document.
+	ChangeCertification
+)
+
+func (c Change) String() string {
+	switch c {
+	case ChangeDocumentTimestamp:
+		return "document timestamp"
+	case ChangeSignature:
+		return "new signature"
+	case ChangeSignatureWidget:
+		return "visible signature widget"
+	case ChangeFormFill:
+		return "form filling"
+	case ChangeAnnotation:
+		return "annotation change"
+	case ChangeValidationData:
+		return "validation data"
+	case ChangeCertification:
+		return "certification signature"
+	default:
+		return fmt.Sprintf("Change(%d)", int(c))
+	}
+}
+
+// minDocMDPPerm is the DocMDP permission level from which a change is
+// permitted (ISO 32000-2, 12.8.2.2).
+var minDocMDPPerm = map[Change]DocMDPPerm{
+	ChangeDocumentTimestamp: DoNotAllowAnyChangesPerms,
+	ChangeSignature:         AllowFillingExistingFormFieldsAndSignaturesPerms,
+	ChangeSignatureWidget:   AllowFillingExistingFormFieldsAndSignaturesAndCRUDAnnotationsPerms,
+	ChangeFormFill:          AllowFillingExistingFormFieldsAndSignaturesPerms,
+	ChangeAnnotation:        AllowFillingExistingFormFieldsAndSignaturesAndCRUDAnnotationsPerms,
+	ChangeValidationData:    DoNotAllowAnyChangesPerms,
+	ChangeCertification:     AllowFillingExistingFormFieldsAndSignaturesPerms,
+}
+
+// DocumentPermissions are the modification restrictions set by the
+// signatures of a document.
+type DocumentPermissions struct {
+	// Signed reports whether the document has at least one signature.
+	Signed bool
+	// Certified reports whether the document has a certification
+	// signature; CertificationField names its field.
+	Certified          bool
+	CertificationField string
+	// DocMDP is the most restrictive of the certification permission and
+	// the /P entries of the /Lock dictionaries of signed fields (PDF 2.0).
+	// It is zero when no signature restricts changes.
+	DocMDP DocMDPPerm
+	// Access holds the access permissions (/P) of an encrypted document
+	// opened without the owner password. It is zero when access is not
+	// restricted.
+	Access uint32
+	// fieldLocks are the /Lock dictionaries of signed fields and the
+	// FieldMDP references of their signatures.
+	fieldLocks []fieldLock
+}
+
+// fieldLock locks form fields: all of them, those in fields (include) or
+// all but those in fields.
+type fieldLock struct {
+	signature string
+	action    string
+	fields    []string
+}
+
+// ReadPermissions returns the modification restrictions of the document
+// read by rdr.
+func ReadPermissions(rdr *pdf.Reader) DocumentPermissions {
+	var perms DocumentPermissions
+	if p := rdr.Permissions(); p != 0xffffffff {
+		perms.Access = p
+	}
+	root := rdr.Trailer().Key("Root")
+	certification := root.Key("Perms").Key("DocMDP")
+	if certification.Kind() == pdf.Dict {
+		p, _ := docMDPPermission(certification)
+		perms.Certified = true
+		perms.restrict(DocMDPPerm(p))
+	}
+
+	for name, field := range collectFormFields(root.Key("AcroForm").Key("Fields")) {
+		if inheritedKey(field.value, "FT").Name() != "Sig" {
+			continue
+		}
+		sig := inheritedKey(field.value, "V")
+		if sig.Kind() != pdf.Dict {
+			continue
+		}
+		perms.Signed = true
+
+		if perms.Certified {
+			if id := sig.GetPtr().GetID(); id != 0 && id == certification.GetPtr().GetID() {
+				perms.CertificationField = name
+			}
+		} else if p, ok := docMDPPermission(sig); ok {
+			// Certified before /Perms was written.
+			perms.Certified = true
+			perms.CertificationField = name
+			perms.restrict(DocMDPPerm(p))
+		}
+
+		lock := field.value.Key("Lock")
+		if p := lock.Key("P").Int64(); p >= 1 && p <= 3 {
+			perms.restrict(DocMDPPerm(p))
+		}
+		if action := lock.Key("Action").Name(); action != "" {
+			perms.fieldLocks = append(perms.fieldLocks, newFieldLock(name, action, lock.Key("Fields")))
+		}
+		refs := sig.Key("Reference")
+		for i := 0; i < refs.Len(); i++ {
+			ref := refs.Index(i)
+			if ref.Key("TransformMethod").Name() != "FieldMDP" {
+				continue
+			}
+			params := ref.Key("TransformParams")
+			perms.fieldLocks = append(perms.fieldLocks, newFieldLock(name, params.Key("Action").Name(), params.Key("Fields")))
+		}
+	}
+	return perms
+}
+
+func newFieldLock(signature, action string, fields pdf.Value) fieldLock {
+	l := fieldLock{signature: signature, action: action}
+	for i := 0; i < fields.Len(); i++ {
+		l.fields = append(l.fields, fields.Index(i).Text())
+	}
+	return l
+}
+
+// restrict lowers the permission level to p.
+func (perms *DocumentPermissions) restrict(p DocMDPPerm) {
+	if perms.DocMDP == 0 || p < perms.DocMDP {
+		perms.DocMDP = p
+	}
+}
+
+// locks reports whether l locks the field named name; a lock on a field
+// also locks its descendants.
+func (l fieldLock) locks(name string) bool {
+	listed := false
+	for _, f := range l.fields {
+		if name == f || strings.HasPrefix(name, f+".") {
+			listed = true
+			break
+		}
+	}
+	switch l.action {
+	case "All":
+		return true
+	case "Include":
+		return listed
+	case "Exclude":
+		return !listed
+	}
+	return false
+}
+
+// Check returns an error wrapping ErrChangeNotPermitted when the change is
+// not permitted. For ChangeFormFill, fields are the fully qualified names of
+// the fields changed.
+func (perms DocumentPermissions) Check(change Change, fields ...string) error {
+	access := AllowAnnotations | AllowFillingForms
+	if change == ChangeAnnotation {
+		access = AllowAnnotations
+	}
+	if perms.Access != 0 && perms.Access&uint32(access) == 0 {
+		return fmt.Errorf("%w: the access permissions of the document do not allow %s without the owner password", ErrChangeNotPermitted, change)
+	}
+	if change == ChangeCertification && (perms.Signed || perms.Certified) {
+		return fmt.Errorf("%w: the document is already signed, a certification signature must be the first signature", ErrChangeNotPermitted)
+	}
+	if perms.DocMDP != 0 && perms.DocMDP < minDocMDPPerm[change] {
+		return fmt.Errorf("%w: %s is not allowed by permission level %d", ErrChangeNotPermitted, change, perms.DocMDP)
+	}
+	if change == ChangeFormFill {
+		for _, name := range fields {
+			for _, l := range perms.fieldLocks {
+				if l.locks(name) {
+					return fmt.Errorf("%w: field %q is locked by signature %q", ErrChangeNotPermitted, name, l.signature)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// changes lists the modifications the signing update makes.
+func (context *SignContext) changes() []Change {
+	var changes []Change
+	switch context.SignData.Signature.CertType {
+	case TimeStampSignature:
+		changes = append(changes, ChangeDocumentTimestamp)
+	case CertificationSignature:
+		changes = append(changes, ChangeCertification)
+	default:
+		changes = append(changes, ChangeSignature)
+	}
+	if context.SignData.Appearance.Visible {
+		changes = append(changes, ChangeSignatureWidget)
+	}
+	if len(context.SignData.FormFields) > 0 || context.SignData.Appearance.SignerUID != "" {
+		changes = append(changes, ChangeFormFill)
+	}
+	return changes
+}
+
+// checkPermissions applies SignData.PermissionPolicy to the changes made by
+// signing. With PermissionWarn, it returns the violations as warnings.
+func (context *SignContext) checkPermissions() ([]string, error) {
+	if context.SignData.PermissionPolicy == PermissionIgnore {
+		return nil, nil
+	}
+	perms := ReadPermissions(context.PDFReader)
+	var fields []string
+	for _, v := range context.SignData.FormFields {
+		fields = append(fields, v.Name)
+	}
+	var warnings []string
+	for _, change := range context.changes() {
+		err := perms.Check(change, fields...)
+		if err == nil {
+			continue
+		}
+		if context.SignData.PermissionPolicy != PermissionWarn {
+			return nil, err
+		}
+		warnings = append(warnings, err.Error())
+	}
+	return warnings, nil
+}
@@ -0,0 +1,155 @@
+package sign
+
+import (
+	"crypto"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// certifyTestPDF certifies the form fixture with permission level perm.
+func certifyTestPDF(t *testing.T, perm DocMDPPerm) string {
+	t.Helper()
+	cert, pkey := loadCertificateAndKey(t)
+	output := filepath.Join(t.TempDir(), "certified.pdf")
+	_, err := SignFile(writeFormPDF(t), output, SignData{
+		Signature: SignDataSignature{
+			Info:       SignDataSignatureInfo{Name: "Author"},
+			CertType:   CertificationSignature,
+			DocMDPPerm: perm,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	})
+	if err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+	return output
+}
+
+func TestReadPermissions(t *testing.T) {
+	path := certifyTestPDF(t, AllowFillingExistingFormFieldsAndSignaturesPerms)
+	perms := ReadPermissions(openTestReader(t, path))
+	if !perms.Signed || !perms.Certified || perms.CertificationField != "Signature 1" || perms.DocMDP != AllowFillingExistingFormFieldsAndSignaturesPerms {
+		t.Errorf("ReadPermissions() = %+v", perms)
+	}
+
+	// The catalog /Perms refers to the certification signature.
+	r := openTestReader(t, path)
+	sig := r.Trailer().Key("Root").Key("Perms").Key("DocMDP")
+	if sig.Key("Type").Name() != "Sig" {
+		t.Errorf("catalog /Perms /DocMDP = %v, want the signature dictionary", sig)
+	}
+
+	if perms := ReadPermissions(openTestReader(t, writeFormPDF(t))); perms.Signed || perms.DocMDP != 0 {
+		t.Errorf("ReadPermissions() of an unsigned document = %+v", perms)
+	}
+}
+
+func TestDocumentPermissionsCheck(t *testing.T) {
+	locked := DocumentPermissions{
+		Signed: true,
+		DocMDP: AllowFillingExistingFormFieldsAndSignaturesPerms,
+		fieldLocks: []fieldLock{
+			{signature: "Signature 1", action: "Include", fields: []string{"applicant"}},
+			{signature: "Signature 2", action: "Exclude", fields: []string{"zip", "applicant"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		perms   DocumentPermissions
+		change  Change
+		fields  []string
+		allowed bool
+	}{
+		{"unsigned annotation", DocumentPermissions{}, ChangeAnnotation, nil, true},
+		{"no changes timestamp", DocumentPermissions{DocMDP: DoNotAllowAnyChangesPerms}, ChangeDocumentTimestamp, nil, true},
+		{"no changes signature", DocumentPermissions{DocMDP: DoNotAllowAnyChangesPerms}, ChangeSignature, nil, false},
+		{"form filling widget", locked, ChangeSignatureWidget, nil, false},
+		{"annotations widget", DocumentPermissions{DocMDP: AllowFillingExistingFormFieldsAndSignaturesAndCRUDAnnotationsPerms}, ChangeSignatureWidget, nil, true},
+		{"form filling annotation", locked, ChangeAnnotation, nil, false},
+		{"unlocked field", locked, ChangeFormFill, []string{"zip"}, true},
+		{"included field", locked, ChangeFormFill, []string{"applicant.name"}, false},
+		{"excluded field", locked, ChangeFormFill, []string{"country"}, false},
+		{"first certification", DocumentPermissions{}, ChangeCertification, nil, true},
+		{"certification of a signed document", DocumentPermissions{Signed: true}, ChangeCertification, nil, false},
+	}
+	for _, tt := range tests {
+		err := tt.perms.Check(tt.change, tt.fields...)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: Check() error = %v", tt.name, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrChangeNotPermitted) {
+			t.Errorf("%s: Check() error = %v, want %v", tt.name, err, ErrChangeNotPermitted)
+		}
+	}
+}
+
+func TestSignCertifiedDocument(t *testing.T) {
+	cert, pkey := loadCertificateAndKey(t)
+	approval := SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "Reviewer"},
+			CertType: ApprovalSignature,
+		},
+		Appearance: Appearance{
+			Visible:     true,
+			LowerLeftX:  300,
+			LowerLeftY:  50,
+			UpperRightX: 500,
+			UpperRightY: 100,
+		},
+		FormFields:      []FormFieldValue{CheckBoxValue("agree", true)},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	}
+	output := filepath.Join(t.TempDir(), "approved.pdf")
+
+	noChanges := certifyTestPDF(t, DoNotAllowAnyChangesPerms)
+	if _, err := SignFile(noChanges, output, approval); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("SignFile() of a document certified with /P 1: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+	if err := FillFormFile(noChanges, output, approval.FormFields); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("FillFormFile() of a document certified with /P 1: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+	warn := approval
+	warn.PermissionPolicy = PermissionWarn
+	info, err := SignFile(noChanges, output, warn)
+	if err != nil {
+		t.Errorf("SignFile() with PermissionWarn: error = %v", err)
+	} else if len(info.Warnings) != 3 {
+		// The signature, its widget and the form filling.
+		t.Errorf("SignFile() with PermissionWarn: warnings = %q, want 3", info.Warnings)
+	}
+
+	// Permission level 2 allows signing but not a new visible widget.
+	formFilling := certifyTestPDF(t, AllowFillingExistingFormFieldsAndSignaturesPerms)
+	if _, err := SignFile(formFilling, output, approval); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("SignFile() of a visible signature in a document certified with /P 2: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+	invisible := approval
+	invisible.Appearance = Appearance{}
+	info, err = SignFile(formFilling, output, invisible)
+	if err != nil {
+		t.Fatalf("SignFile() of a document certified with /P 2: error = %v", err)
+	}
+	if len(info.Warnings) != 0 {
+		t.Errorf("SignFile() of a permitted change: warnings = %q", info.Warnings)
+	}
+	// The second signature leaves the certification in place.
+	perms := ReadPermissions(openTestReader(t, output))
+	if perms.CertificationField != "Signature 1" || perms.DocMDP != AllowFillingExistingFormFieldsAndSignaturesPerms {
+		t.Errorf("ReadPermissions() after approval = %+v", perms)
+	}
+
+	// A document that is already signed cannot be certified again.
+	recertify := approval
+	recertify.Signature.CertType = CertificationSignature
+	recertify.Signature.DocMDPPerm = AllowFillingExistingFormFieldsAndSignaturesPerms
+	recertify.FormFields = nil
+	if _, err := SignFile(output, filepath.Join(t.TempDir(), "recertified.pdf"), recertify); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("SignFile() certifying a signed document: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+}
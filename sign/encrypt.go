@@ -0,0 +1,312 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/digitorus/pdf"
+)
+
+// AccessPermission is a set of access permission bits (/P) of an encrypted
+// document (ISO 32000-2, table 22). They apply to users who open the
+// document with the user password.
+type AccessPermission uint32
+
+const (
+	// AllowPrinting permits printing, at low quality unless
+	// AllowHighQualityPrinting is also set.
+	AllowPrinting AccessPermission = 1 << 2
+	// AllowModifying permits changes other than those controlled by
+	// AllowAnnotations, AllowFillingForms and AllowAssembling.
+	AllowModifying AccessPermission = 1 << 3
+	// AllowCopying permits copying and extracting text and graphics.
+	AllowCopying AccessPermission = 1 << 4
+	// AllowAnnotations permits adding and modifying annotations and, with
+	// AllowModifying, creating form fields.
+	AllowAnnotations AccessPermission = 1 << 5
+	// AllowFillingForms permits filling form fields, including signing.
+	AllowFillingForms AccessPermission = 1 << 8
+	// AllowExtracting permits extracting text and graphics for
+	// accessibility.
+	AllowExtracting AccessPermission = 1 << 9
+	// AllowAssembling permits inserting, rotating and deleting pages.
+	AllowAssembling AccessPermission = 1 << 10
+	// AllowHighQualityPrinting permits printing at full quality.
+	AllowHighQualityPrinting AccessPermission = 1 << 11
+)
+
+// p returns the /P value granting the permissions; the reserved bits are
+// set as required for revision 6.
+func (a AccessPermission) p() uint32 {
+	return 0xfffff0c0 | uint32(a)
+}
+
+// Encryption describes the AES-256 encryption (standard security handler,
+// revision 6) of a document.
+type Encryption struct {
+	// UserPassword opens the document with the access permissions; it may
+	// be empty so that the document opens without a password.
+	UserPassword string
+	// OwnerPassword opens the document without restrictions. It is
+	// required.
+	OwnerPassword string
+	// Permissions are the access permissions of users who open the
+	// document with the user password.
+	Permissions AccessPermission
+}
+
+// Encrypt writes the document read by rdr to output as a new file (a full
+// save, not an incremental update) encrypted with enc. Object streams and
+// cross-reference streams are not preserved; their objects are written as
+// plain objects. An encrypted document must have been opened with its owner
+// password. Signed documents are refused, as a full save invalidates their
+// signatures.
+func Encrypt(output io.Writer, rdr *pdf.Reader, enc Encryption) error {
+	if enc.OwnerPassword == "" {
+		return errors.New("encrypt: an owner password is required")
+	}
+	if rdr.EncryptionKey() != nil && !rdr.OwnerAuthenticated() {
+		return fmt.Errorf("%w: re-encrypting the document requires its owner password", ErrChangeNotPermitted)
+	}
+	if ReadPermissions(rdr).Signed {
+		return errors.New("encrypt: the document is signed and rewriting it would invalidate its signatures")
+	}
+
+	sec, err := pdf.NewStandardSecurityV5(enc.UserPassword, enc.OwnerPassword, enc.Permissions.p(), true)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	context := &SignContext{encryption: &EncryptionContext{Key: sec.Key, UseAES: true, EncVersion: 5}}
+
+	// AES-256 is part of PDF 2.0 and of Adobe extension level 8 to PDF 1.7.
+	version := "1.7"
+	if rdr.PDFVersion == "2.0" {
+		version = "2.0"
+	}
+	encrypt := fmt.Sprintf("<< /Filter /Standard /V 5 /R 6 /Length 256 /P %d"+
+		" /O <%x> /U <%x> /OE <%x> /UE <%x> /Perms <%x>"+
+		" /CF << /StdCF << /CFM /AESV3 /AuthEvent /DocOpen /Length 32 >> >> /StmF /StdCF /StrF /StdCF >>",
+		int32(sec.P), sec.O, sec.U, sec.OE, sec.UE, sec.Perms)
+	dropped, err := context.writeFullSave(output, rdr, version, encrypt)
+	if err != nil {
+		return err
+	}
+	if len(dropped) > 0 {
+		return fmt.Errorf("encrypt: objects %v cannot be read", dropped)
+	}
+	return nil
+}
+
+// writeFullSave writes the document read by rdr to output as a new file.
+// Object streams and cross-reference streams are not preserved; their
+// objects are written as plain objects. Objects in use that cannot be read
+// are left out and returned in dropped, so the caller can fail or report
+// them. With an encryption dictionary encrypt, strings and streams are
+// encrypted with the key of context and the old encryption dictionary is
+// replaced.
+func (context *SignContext) writeFullSave(output io.Writer, rdr *pdf.Reader, version, encrypt string) (dropped []uint32, err error) {
+	trailer := rdr.Trailer()
+	root := trailer.Key("Root").GetPtr().GetID()
+	oldEncrypt := trailer.Key("Encrypt").GetPtr().GetID()
+	table := rdr.Xref()
+	size := uint32(len(table))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-" + version + "\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, size)
+	gens := make([]int, size)
+	for id := uint32(1); id < size; id++ {
+		if x := &table[id]; id == oldEncrypt || x.Offset() == 0 && !x.InStream() {
+			continue
+		}
+		obj, err := rdr.GetObject(id)
+		if err != nil {
+			dropped = append(dropped, id)
+			continue
+		}
+		if typ := obj.Key("Type").Name(); obj.Kind() == pdf.Stream && (typ == "XRef" || typ == "ObjStm") {
+			continue
+		}
+
+		offsets[id], gens[id] = buf.Len(), int(obj.GetPtr().GetGen())
+		_, _ = fmt.Fprintf(&buf, "%d %d obj\n", id, gens[id])
+		if id == root && encrypt != "" && version != "2.0" {
+			err = context.writeEncryptedCatalog(&buf, id, obj)
+		} else {
+			err = context.writeEncryptedObject(&buf, id, obj)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("write object %d: %w", id, err)
+		}
+		buf.WriteString("\nendobj\n")
+	}
+
+	var encryptRef string
+	if encrypt != "" {
+		offsets, gens = append(offsets, buf.Len()), append(gens, 0)
+		_, _ = fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", size, encrypt)
+		encryptRef = fmt.Sprintf(" /Encrypt %d 0 R", size)
+	}
+
+	// The first identifier is kept; the second one marks the new file.
+	fileID := make([]byte, 32)
+	if _, err := rand.Read(fileID); err != nil {
+		return nil, fmt.Errorf("full save: %w", err)
+	}
+	if id := trailer.Key("ID").Index(0).RawString(); id != "" {
+		fileID = append([]byte(id), fileID[16:]...)
+	}
+	firstID := fileID[:len(fileID)-16]
+
+	xrefStart := buf.Len()
+	_, _ = fmt.Fprintf(&buf, "xref\n0 %d\n", len(offsets))
+	for id, off := range offsets {
+		if off == 0 {
+			buf.WriteString("0000000000 65535 f \n")
+		} else {
+			_, _ = fmt.Fprintf(&buf, "%010d %05d n \n", off, gens[id])
+		}
+	}
+	_, _ = fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d %d R", len(offsets), root, trailer.Key("Root").GetPtr().GetGen())
+	if info := trailer.Key("Info").GetPtr(); info.GetID() != 0 {
+		_, _ = fmt.Fprintf(&buf, " /Info %d %d R", info.GetID(), info.GetGen())
+	}
+	_, _ = fmt.Fprintf(&buf, "%s /ID [<%x> <%x>] >>\nstartxref\n%d\n%%%%EOF\n",
+		encryptRef, firstID, fileID[len(firstID):], xrefStart)
+
+	if _, err := output.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return dropped, nil
+}
+
+// writeEncryptedObject writes the object id, encrypting its strings and
+// stream data.
+func (context *SignContext) writeEncryptedObject(w *bytes.Buffer, id uint32, obj pdf.Value) error {
+	if obj.Kind() != pdf.Stream {
+		return context.writeEncryptedValue(w, id, obj)
+	}
+	data, err := obj.RawData()
+	if err != nil {
+		return err
+	}
+	if data, err = context.encryptStreamData(id, data); err != nil {
+		return err
+	}
+	w.WriteString("<<")
+	for _, key := range obj.Keys() {
+		if key == "Length" {
+			continue
+		}
+		w.WriteString(" " + pdfName(key) + " ")
+		if err := context.writeEncryptedValue(w, id, obj.Key(key)); err != nil {
+			return err
+		}
+	}
+	_, _ = fmt.Fprintf(w, " /Length %d >>\nstream\n", len(data))
+	w.Write(data)
+	w.WriteString("\nendstream")
+	return nil
+}
+
+// writeEncryptedCatalog writes the catalog id declaring the Adobe extension
+// level of AES-256 encryption for PDF 1.7.
+func (context *SignContext) writeEncryptedCatalog(w *bytes.Buffer, id uint32, catalog pdf.Value) error {
+	w.WriteString("<<")
+	for _, key := range catalog.Keys() {
+		if key == "Extensions" {
+			continue
+		}
+		w.WriteString(" " + pdfName(key) + " ")
+		if err := context.writeEncryptedValue(w, id, catalog.Key(key)); err != nil {
+			return err
+		}
+	}
+	w.WriteString(" /Extensions <<")
+	extensions := catalog.Key("Extensions")
+	for _, key := range extensions.Keys() {
+		if key == "ADBE" {
+			continue
+		}
+		w.WriteString(" " + pdfName(key) + " ")
+		if err := context.writeEncryptedValue(w, extensions.GetPtr().GetID(), extensions.Key(key)); err != nil {
+			return err
+		}
+	}
+	w.WriteString(" /ADBE << /BaseVersion /1.7 /ExtensionLevel 8 >> >> >>")
+	return nil
+}
+
+// writeEncryptedValue writes v, a value of the object id: values of other
+// objects are written as references, and strings are encrypted.
+func (context *SignContext) writeEncryptedValue(w *bytes.Buffer, id uint32, v pdf.Value) error {
+	if ptr := v.GetPtr(); ptr.GetID() != id {
+		if ptr.GetID() == 0 {
+			w.WriteString("null") // reference to a missing object
+		} else {
+			_, _ = fmt.Fprintf(w, "%d %d R", ptr.GetID(), ptr.GetGen())
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case pdf.String:
+		s, err := context.encryptPdfString(id, v.RawString())
+		if err != nil {
+			return err
+		}
+		w.WriteString(s)
+	case pdf.Bool:
+		w.WriteString(strconv.FormatBool(v.Bool()))
+	case pdf.Integer:
+		w.WriteString(strconv.FormatInt(v.Int64(), 10))
+	case pdf.Real:
+		w.WriteString(strconv.FormatFloat(v.Float64(), 'f', -1, 64))
+	case pdf.Name:
+		w.WriteString(pdfName(v.Name()))
+	case pdf.Dict:
+		w.WriteString("<<")
+		for _, key := range v.Keys() {
+			w.WriteString(" " + pdfName(key) + " ")
+			if err := context.writeEncryptedValue(w, id, v.Key(key)); err != nil {
+				return err
+			}
+		}
+		w.WriteString(" >>")
+	case pdf.Array:
+		w.WriteString("[")
+		for i := range v.Len() {
+			if i > 0 {
+				w.WriteString(" ")
+			}
+			if err := context.writeEncryptedValue(w, id, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		w.WriteString("]")
+	case pdf.Stream:
+		return errors.New("stream cannot be a direct object")
+	default:
+		w.WriteString("null")
+	}
+	return nil
+}
+
+// encryptInput rewrites the document read by rdr encrypted with enc and
+// opens the result with the owner password, for signing.
+func encryptInput(rdr *pdf.Reader, enc Encryption) (*bytes.Reader, *pdf.Reader, int64, error) {
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, rdr, enc); err != nil {
+		return nil, nil, 0, err
+	}
+	input := bytes.NewReader(buf.Bytes())
+	encrypted, err := pdf.NewReaderPassword(input, input.Size(), enc.OwnerPassword)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("encrypt: re-open encrypted document: %w", err)
+	}
+	return input, encrypted, input.Size(), nil
+}
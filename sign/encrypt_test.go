@@ -0,0 +1,198 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pdf"
+)
+
+func TestEncrypt(t *testing.T) {
+	// testfile30.pdf uses object and cross-reference streams.
+	for _, input := range []string{"../testfiles/testfile20.pdf", "../testfiles/testfile30.pdf", "../testfiles/gen_pdf20_xref_stream.pdf"} {
+		original := openTestReader(t, input)
+		var out bytes.Buffer
+		err := Encrypt(&out, original, Encryption{UserPassword: "user", OwnerPassword: "owner", Permissions: AllowPrinting})
+		if ReadPermissions(original).Signed {
+			if err == nil {
+				t.Errorf("%s: Encrypt() of a signed document: error = nil", input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Encrypt() error = %v", input, err)
+		}
+		if bytes.Contains(out.Bytes(), []byte("/ObjStm")) {
+			t.Errorf("%s: encrypted file still has object streams", input)
+		}
+
+		data := out.Bytes()
+		if _, err := pdf.NewReaderPassword(bytes.NewReader(data), int64(len(data)), ""); !errors.Is(err, pdf.ErrInvalidPassword) {
+			t.Errorf("%s: open without password: error = %v, want %v", input, err, pdf.ErrInvalidPassword)
+		}
+		r, err := pdf.NewReaderPassword(bytes.NewReader(data), int64(len(data)), "user")
+		if err != nil {
+			t.Fatalf("%s: open with the user password: %v", input, err)
+		}
+		if r.EncVersion() != 5 || r.Permissions() != AllowPrinting.p() {
+			t.Errorf("%s: V = %d, permissions = %#x", input, r.EncVersion(), r.Permissions())
+		}
+		if got, want := r.NumPage(), original.NumPage(); got != want {
+			t.Errorf("%s: %d pages, want %d", input, got, want)
+		}
+		got, want := pageContents(r.Page(1)), pageContents(original.Page(1))
+		if len(want) == 0 || !bytes.Equal(got, want) {
+			t.Errorf("%s: page content differs after encryption (%d bytes, want %d)", input, len(got), len(want))
+		}
+		if v := r.PDFVersion; v == "1.7" && r.Trailer().Key("Root").Key("Extensions").Key("ADBE").Key("ExtensionLevel").Int64() != 8 {
+			t.Errorf("%s: PDF 1.7 catalog does not declare extension level 8", input)
+		}
+	}
+}
+
+// pageContents returns the decoded content streams of p.
+func pageContents(p pdf.Page) []byte {
+	contents := p.V.Key("Contents")
+	if contents.Kind() == pdf.Stream {
+		return contents.Data()
+	}
+	var data []byte
+	for i := range contents.Len() {
+		data = append(data, contents.Index(i).Data()...)
+	}
+	return data
+}
+
+func TestEncryptErrors(t *testing.T) {
+	var out bytes.Buffer
+	if err := Encrypt(&out, openTestReader(t, "../testfiles/testfile20.pdf"), Encryption{UserPassword: "user"}); err == nil {
+		t.Error("Encrypt() without owner password: error = nil")
+	}
+
+	data, err := os.ReadFile("../testfiles/testfile_password.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := pdf.NewReaderPassword(bytes.NewReader(data), int64(len(data)), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Encrypt(&out, r, Encryption{OwnerPassword: "new"}); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("Encrypt() opened with the user password: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+
+	// An object that cannot be read fails the encryption instead of being
+	// left out of the encrypted file.
+	path := writeTestPDF(t, []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources 4 0 R >>",
+		"<< /Font << >> >>",
+	})
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("4 0 obj"), []byte("9 0 obj"), 1)
+	r, err = pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Encrypt(&out, r, Encryption{OwnerPassword: "owner"}); err == nil || !strings.Contains(err.Error(), "cannot be read") {
+		t.Errorf("Encrypt() with an unreadable object: error = %v, want objects that cannot be read", err)
+	}
+}
+
+func TestEncryptGeneration(t *testing.T) {
+	// Object 4 has generation 1.
+	path := writeTestPDF(t, []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources 4 1 R >>",
+		"<< /Font << >> >>",
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("4 0 obj"), []byte("4 1 obj"), 1)
+	entry := bytes.Index(data, []byte("xref\n")) + len("xref\n0 5\n") + 4*20
+	copy(data[entry+11:], "00001")
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Encrypt(&out, r, Encryption{OwnerPassword: "owner"}); err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	encrypted, err := pdf.NewReaderPassword(bytes.NewReader(out.Bytes()), int64(out.Len()), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x := encrypted.Xref()[4]; x.Offset() == 0 {
+		t.Fatal("object 4 is missing from the cross-reference table")
+	}
+	if _, err := encrypted.GetObject(4); err != nil {
+		t.Errorf("GetObject(4) of the encrypted file: error = %v", err)
+	}
+}
+
+func TestSignEncryptOutput(t *testing.T) {
+	cert, key := loadCertificateAndKey(t)
+	output := filepath.Join(t.TempDir(), "signed.pdf")
+	_, err := SignFile("../testfiles/testfile20.pdf", output, SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "Contract Signer", Date: time.Now()},
+			CertType: ApprovalSignature,
+		},
+		Appearance: Appearance{
+			Visible:     true,
+			LowerLeftX:  350,
+			LowerLeftY:  75,
+			UpperRightX: 590,
+			UpperRightY: 145,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          key,
+		Certificate:     cert,
+		Encryption:      &Encryption{OwnerPassword: "owner", Permissions: AllowPrinting | AllowCopying},
+	})
+	if err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+
+	signed, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(signed, []byte("(Contract Signer)")) {
+		t.Error("signature /Name is not encrypted")
+	}
+	// Documents encrypted with an empty user password open without one.
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	verifyAllSignaturesValid(t, f, 1)
+
+	r, err := pdf.NewReaderPassword(bytes.NewReader(signed), int64(len(signed)), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	perms := ReadPermissions(r)
+	if !perms.Signed || perms.Access != uint32((AllowPrinting|AllowCopying).p()) {
+		t.Errorf("signed PDF permissions = %+v", perms)
+	}
+	if err := perms.Check(ChangeSignature); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("signing without the owner password: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+}
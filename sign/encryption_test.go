@@ -7,13 +7,16 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/digitorus/pdf"
+	"github.com/subnoto/pdfsign/verify"
 )
 
 func generate4096Cert(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
@@ -258,3 +261,130 @@ func signatureContentsFromReader(r *pdf.Reader) ([]byte, bool) {
 	}
 	return nil, false
 }
+
+func TestSignPasswordProtectedPDF(t *testing.T) {
+	// testfile_password.pdf is encrypted with 128-bit RC4, user password
+	// "user" and owner password "owner", and permits neither changes nor
+	// form filling.
+	const input = "../testfiles/testfile_password.pdf"
+	cert, key := loadCertificateAndKey(t)
+	signData := SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "Protected Signer", Date: time.Now()},
+			CertType: ApprovalSignature,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          key,
+		Certificate:     cert,
+	}
+	output := filepath.Join(t.TempDir(), "signed.pdf")
+
+	if _, err := SignFile(input, output, signData); !errors.Is(err, pdf.ErrInvalidPassword) {
+		t.Errorf("SignFile() without password: error = %v, want %v", err, pdf.ErrInvalidPassword)
+	}
+	signData.Password = "user"
+	if _, err := SignFile(input, output, signData); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("SignFile() with the user password: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+
+	signData.Password = "owner"
+	if _, err := SignFile(input, output, signData); err != nil {
+		t.Fatalf("SignFile() with the owner password: error = %v", err)
+	}
+	signed, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(signed, []byte("(Protected Signer)")) {
+		t.Error("signature /Name is not encrypted")
+	}
+	rdr, err := pdf.NewReaderPassword(bytes.NewReader(signed), int64(len(signed)), "user")
+	if err != nil {
+		t.Fatalf("re-open signed PDF: %v", err)
+	}
+	if perms := ReadPermissions(rdr); !perms.Signed || perms.Access != 0xfffff0c0 {
+		t.Errorf("signed PDF permissions = %+v", perms)
+	}
+	if contents, ok := signatureContentsFromReader(rdr); !ok || contents[0] != 0x30 {
+		t.Error("signed PDF has no readable signature contents")
+	}
+
+	if _, err := verify.VerifyBytes(signed, nil); !errors.Is(err, pdf.ErrInvalidPassword) {
+		t.Errorf("VerifyBytes() without password: error = %v, want %v", err, pdf.ErrInvalidPassword)
+	}
+	options := verify.DefaultVerifyOptions()
+	options.Password = "user"
+	resp, err := verify.VerifyBytes(signed, options)
+	if err != nil {
+		t.Fatalf("VerifyBytes() with the user password: error = %v", err)
+	}
+	if len(resp.Signatures) != 1 || !resp.Signatures[0].Validation.ValidSignature || resp.Signatures[0].Info.Name != "Protected Signer" {
+		t.Errorf("signature of the password-protected PDF = %+v", resp.Signatures)
+	}
+
+	// Validation data follows the same rules.
+	if _, err := AddValidationDataWithPassword(signed, []*x509.Certificate{cert}, nil, nil, "user"); !errors.Is(err, ErrChangeNotPermitted) {
+		t.Errorf("AddValidationDataWithPassword() with the user password: error = %v, want %v", err, ErrChangeNotPermitted)
+	}
+	withDSS, err := AddValidationDataWithPassword(signed, []*x509.Certificate{cert}, nil, nil, "owner")
+	if err != nil {
+		t.Fatalf("AddValidationDataWithPassword() with the owner password: error = %v", err)
+	}
+	if rdr, err := pdf.NewReaderPassword(bytes.NewReader(withDSS), int64(len(withDSS)), "owner"); err != nil || rdr.Trailer().Key("Root").Key("DSS").Key("Certs").Len() != 1 {
+		t.Errorf("re-open PDF with validation data: %v", err)
+	}
+}
+
+func TestSignPublicKeyEncryptedPDF(t *testing.T) {
+	// testfile_pubsec.pdf is encrypted with AES-256 for two recipients: the
+	// test signing certificate, allowed to print and fill forms, and
+	// another certificate.
+	const input = "../testfiles/testfile_pubsec.pdf"
+	cert, key := loadCertificateAndKey(t)
+	signData := SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "Recipient Signer", Date: time.Now()},
+			CertType: ApprovalSignature,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          key,
+		Certificate:     cert,
+	}
+	output := filepath.Join(t.TempDir(), "signed.pdf")
+
+	if _, err := SignFile(input, output, signData); !errors.Is(err, pdf.ErrCertificateRequired) {
+		t.Errorf("SignFile() without certificate: error = %v, want %v", err, pdf.ErrCertificateRequired)
+	}
+	signData.DecryptionCertificate, signData.DecryptionKey = cert, key
+	if _, err := SignFile(input, output, signData); err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+
+	signed, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(signed, []byte("(Recipient Signer)")) {
+		t.Error("signature /Name is not encrypted")
+	}
+	rdr, err := pdf.NewReaderCertificate(bytes.NewReader(signed), int64(len(signed)), cert, key)
+	if err != nil {
+		t.Fatalf("re-open signed PDF: %v", err)
+	}
+	if perms := ReadPermissions(rdr); !perms.Signed || perms.Access != 0xfffff1c4 {
+		t.Errorf("signed PDF permissions = %+v", perms)
+	}
+	if title := rdr.Trailer().Key("Info").Key("Title").Text(); title != "Recipients only" {
+		t.Errorf("signed PDF /Title = %q", title)
+	}
+
+	options := verify.DefaultVerifyOptions()
+	options.DecryptionCertificate, options.DecryptionKey = cert, key
+	resp, err := verify.VerifyWithOptions(bytes.NewReader(signed), int64(len(signed)), options)
+	if err != nil {
+		t.Fatalf("VerifyWithOptions() error = %v", err)
+	}
+	if len(resp.Signatures) != 1 || !resp.Signatures[0].Validation.ValidSignature {
+		t.Errorf("signature of the encrypted PDF is not valid: %+v", resp.Signatures)
+	}
+}
@@ -40,9 +40,18 @@ func normalizeDA(raw string) string {
 	return fmt.Sprintf("/F1 %s Tf 0 0 0 rg", size)
 }
 
+// textFieldLayout describes how the value of a text field is laid out. The
+// zero value centres a single line, as used for initials and date fields.
+type textFieldLayout struct {
+	align     string // AlignLeft, AlignCenter or AlignRight; empty centres
+	multiline bool   // wrap the text at word boundaries and line breaks
+	comb      int    // number of comb cells, 0 when the field is not a comb field
+}
+
 // createTextFieldAppearance creates an appearance stream for a text field.
 // fontScale is an optional multiplier for fontSize (e.g. 1.2 for date fields); 0 means no scaling.
-func (context *SignContext) createTextFieldAppearance(text string, rect [4]float64, da string, fontScale float64) ([]byte, error) {
+// A font size of 0 in the DA string sizes the text to the field.
+func (context *SignContext) createTextFieldAppearance(text string, rect [4]float64, da string, fontScale float64, layout textFieldLayout) ([]byte, error) {
 	width := rect[2] - rect[0]
 	height := rect[3] - rect[1]
 
@@ -51,17 +60,11 @@ func (context *SignContext) createTextFieldAppearance(text string, rect [4]float
 	}
 
 	// Extract font size from DA string
-	fontSize := 10.0
-	re := regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?)\s*Tf`)
-	if m := re.FindStringSubmatch(da); len(m) >= 2 {
-		if size, err := strconv.ParseFloat(m[1], 64); err == nil {
-			fontSize = size
-		}
-	}
+	fontSize := daFontSize(da)
 
 	// Adjust font size to fit the field height with some padding
 	maxFontSize := height * 0.7
-	if fontSize > maxFontSize {
+	if fontSize > maxFontSize || (fontSize == 0 && !layout.multiline) {
 		fontSize = maxFontSize
 	}
 	if fontScale > 0 {
@@ -71,19 +74,23 @@ func (context *SignContext) createTextFieldAppearance(text string, rect [4]float
 		}
 	}
 
-	// Better text width calculation (rough approximation for Helvetica)
-	textWidth := float64(len(text)) * fontSize * 0.6
-
-	// Center text horizontally and vertically
-	textX := (width - textWidth) / 2
-	if textX < 1 {
-		textX = 1 // small left margin
+	// With an embedded font the text is measured exactly and drawn with a
+	// subset written before this appearance object.
+	var font appearanceFont = FontHelvetica
+	var fontID uint32
+	if embedded := context.SignData.Appearance.EmbeddedFont; embedded != nil {
+		subset, err := embedded.subset(text)
+		if err != nil {
+			return nil, err
+		}
+		id, err := context.addFontSubset(subset)
+		if err != nil {
+			return nil, err
+		}
+		fontID = id
+		font = subset
 	}
 
-	// Center vertically: baseline should be positioned so text appears centered
-	// For Helvetica, descender is about 0.2 * fontSize, ascender is about 0.7 * fontSize
-	textY := (height-fontSize)/2 + fontSize*0.2
-
 	// Create appearance stream
 	var stream bytes.Buffer
 	stream.WriteString("q\n") // Save graphics state
@@ -93,26 +100,88 @@ func (context *SignContext) createTextFieldAppearance(text string, rect [4]float
 	stream.WriteString(fmt.Sprintf("0 0 %.1f %.1f re\n", width, height)) // Rectangle covering entire field
 	stream.WriteString("f\n")                                            // Fill rectangle
 
-	// Draw text
-	stream.WriteString("BT\n")                                      // Begin text
-	stream.WriteString("/F1 ")                                      // Use font F1 (must be in Resources)
-	stream.WriteString(fmt.Sprintf("%.1f", fontSize))               // Font size
-	stream.WriteString(" Tf\n")                                     // Set font
-	stream.WriteString("0 0 0 rg\n")                                // Black text color
-	stream.WriteString(fmt.Sprintf("%.1f %.1f Td\n", textX, textY)) // Position
-	stream.WriteString(pdfString(text))                             // Text content
-	stream.WriteString(" Tj\n")                                     // Show text
-	stream.WriteString("ET\n")                                      // End text
-	stream.WriteString("Q\n")                                       // Restore graphics state
-
-	// Create XObject dictionary
-	streamBytes := stream.Bytes()
-	if context.encryption != nil {
-		encrypted, err := context.encryptStreamForNextObject(streamBytes)
-		if err != nil {
-			return nil, fmt.Errorf("encrypt field appearance stream: %w", err)
+	switch {
+	case layout.multiline:
+		// Lines start at the top of the field, 2 points inside its edges.
+		align := layout.align
+		if align == "" {
+			align = AlignLeft
+		}
+		inner := box{x: 2, y: 2, w: width - 4, h: height - 4}
+		size, lines := fitText(strings.Split(text, "\n"), font, fontSize, defaultLineSpacing, inner)
+		app := Appearance{TextAlign: align, VerticalAlign: AlignTop, TextColor: &Color{}}
+		drawTextBlock(&stream, app, font, lines, size, defaultLineSpacing, inner)
+	case layout.comb > 0:
+		// One character centred in each of the equally wide cells.
+		cell := width / float64(layout.comb)
+		textY := (height-fontSize)/2 + fontSize*0.2
+		stream.WriteString("BT\n")
+		stream.WriteString(fmt.Sprintf("/F1 %.1f Tf\n", fontSize))
+		stream.WriteString("0 0 0 rg\n")
+		for i, r := range []rune(text) {
+			if i >= layout.comb {
+				break
+			}
+			c := string(r)
+			x := float64(i)*cell + (cell-font.textWidth(c, fontSize))/2
+			stream.WriteString(fmt.Sprintf("1 0 0 1 %.2f %.2f Tm\n", x, textY))
+			stream.WriteString(font.encodeText(c) + " Tj\n")
+		}
+		stream.WriteString("ET\n")
+	default:
+		textWidth := font.textWidth(text, fontSize)
+		var textX float64
+		switch layout.align {
+		case AlignLeft:
+			textX = 2
+		case AlignRight:
+			textX = width - textWidth - 2
+		default:
+			// Center text horizontally
+			textX = (width - textWidth) / 2
 		}
-		streamBytes = encrypted
+		if textX < 1 {
+			textX = 1 // small left margin
+		}
+
+		// Center vertically: baseline should be positioned so text appears centered
+		// For Helvetica, descender is about 0.2 * fontSize, ascender is about 0.7 * fontSize
+		textY := (height-fontSize)/2 + fontSize*0.2
+
+		// Draw text
+		stream.WriteString("BT\n")                                      // Begin text
+		stream.WriteString("/F1 ")                                      // Use font F1 (must be in Resources)
+		stream.WriteString(fmt.Sprintf("%.1f", fontSize))               // Font size
+		stream.WriteString(" Tf\n")                                     // Set font
+		stream.WriteString("0 0 0 rg\n")                                // Black text color
+		stream.WriteString(fmt.Sprintf("%.1f %.1f Td\n", textX, textY)) // Position
+		stream.WriteString(font.encodeText(text))                       // Text content
+		stream.WriteString(" Tj\n")                                     // Show text
+		stream.WriteString("ET\n")                                      // End text
+	}
+	stream.WriteString("Q\n") // Restore graphics state
+
+	return context.fieldAppearanceXObject(stream.Bytes(), width, height, fontID)
+}
+
+// daFontSize returns the font size of a default appearance string, 10 when
+// it has none. Zero means the text is sized to the field.
+func daFontSize(da string) float64 {
+	re := regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?)\s*Tf`)
+	if m := re.FindStringSubmatch(da); len(m) >= 2 {
+		if size, err := strconv.ParseFloat(m[1], 64); err == nil {
+			return size
+		}
+	}
+	return 10
+}
+
+// fieldAppearanceXObject wraps a field appearance stream drawn with font F1
+// in a form XObject. fontID is the embedded font, or 0 for Helvetica.
+func (context *SignContext) fieldAppearanceXObject(stream []byte, width, height float64, fontID uint32) ([]byte, error) {
+	streamBytes, err := context.encryptStreamForNextObject(stream)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt field appearance stream: %w", err)
 	}
 
 	var xobj bytes.Buffer
@@ -121,13 +190,11 @@ func (context *SignContext) createTextFieldAppearance(text string, rect [4]float
 	xobj.WriteString("  /Subtype /Form\n")
 	xobj.WriteString(fmt.Sprintf("  /BBox [0 0 %.1f %.1f]\n", width, height))
 	xobj.WriteString("  /Resources <<\n")
-	xobj.WriteString("    /Font <<\n")
-	xobj.WriteString("      /F1 <<\n")
-	xobj.WriteString("        /Type /Font\n")
-	xobj.WriteString("        /Subtype /Type1\n")
-	xobj.WriteString("        /BaseFont /Helvetica\n")
-	xobj.WriteString("      >>\n")
-	xobj.WriteString("    >>\n")
+	if fontID != 0 {
+		createEmbeddedFontResource(&xobj, fontID)
+	} else {
+		createFontResource(&xobj, FontHelvetica)
+	}
 	xobj.WriteString("  >>\n")
 	xobj.WriteString(fmt.Sprintf("  /Length %d\n", len(streamBytes)))
 	xobj.WriteString(">>\n")
@@ -279,7 +346,7 @@ func (context *SignContext) updateFieldObject(field pdf.Value, value string, mak
 			// Generate new appearance stream for this field
 			rect := getFieldRect(field)
 			da := normalizeDA(field.Key("DA").RawString())
-			appearance, err := context.createTextFieldAppearance(value, rect, da, appearanceFontScale)
+			appearance, err := context.createTextFieldAppearance(value, rect, da, appearanceFontScale, textFieldLayout{})
 			if err == nil {
 				apObjectId, err := context.addObject(appearance)
 				if err == nil {
@@ -387,7 +454,7 @@ func (context *SignContext) updateFieldWidget(kid pdf.Value, value string, makeR
 				rect = [4]float64{0, 0, 100, 20} // default size
 			}
 			da := normalizeDA(kid.Key("DA").RawString())
-			appearance, err := context.createTextFieldAppearance(value, rect, da, appearanceFontScale)
+			appearance, err := context.createTextFieldAppearance(value, rect, da, appearanceFontScale, textFieldLayout{})
 			if err == nil {
 				apObjectId, err := context.addObject(appearance)
 				if err == nil {
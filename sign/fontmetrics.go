@@ -0,0 +1,171 @@
+package sign
+
+import (
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// StandardFont names one of the standard 14 PDF fonts that can be used for
+// appearance text without embedding. Symbol and ZapfDingbats are not
+// supported because they do not use WinAnsiEncoding.
+type StandardFont string
+
+const (
+	FontHelvetica            StandardFont = "Helvetica"
+	FontHelveticaBold        StandardFont = "Helvetica-Bold"
+	FontHelveticaOblique     StandardFont = "Helvetica-Oblique"
+	FontHelveticaBoldOblique StandardFont = "Helvetica-BoldOblique"
+	FontTimesRoman           StandardFont = "Times-Roman"
+	FontTimesBold            StandardFont = "Times-Bold"
+	FontTimesItalic          StandardFont = "Times-Italic"
+	FontTimesBoldItalic      StandardFont = "Times-BoldItalic"
+	FontCourier              StandardFont = "Courier"
+	FontCourierBold          StandardFont = "Courier-Bold"
+	FontCourierOblique       StandardFont = "Courier-Oblique"
+	FontCourierBoldOblique   StandardFont = "Courier-BoldOblique"
+)
+
+// Glyph widths of the printable ASCII characters (32-126) in 1/1000 em,
+// taken from the Adobe Font Metrics of the standard 14 fonts. Oblique
+// variants share the widths of their upright counterparts and all Courier
+// glyphs are 600 units wide.
+var (
+	helveticaWidths = [95]uint16{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]uint16{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+	timesRomanWidths = [95]uint16{
+		250, 333, 408, 500, 500, 833, 778, 180, 333, 333, 500, 564, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 564, 564, 564, 444,
+		921, 722, 667, 667, 722, 611, 556, 722, 722, 333, 389, 722, 611, 889, 722, 722,
+		556, 722, 667, 556, 611, 722, 722, 944, 722, 722, 611, 333, 278, 333, 469, 500,
+		333, 444, 500, 444, 500, 444, 333, 500, 500, 278, 278, 500, 278, 778, 500, 500,
+		500, 500, 333, 389, 278, 500, 500, 722, 500, 500, 444, 480, 200, 480, 541,
+	}
+	timesBoldWidths = [95]uint16{
+		250, 333, 555, 500, 500, 1000, 833, 278, 333, 333, 500, 570, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 570, 570, 570, 500,
+		930, 722, 667, 722, 722, 667, 611, 778, 778, 389, 500, 778, 667, 944, 722, 778,
+		611, 778, 722, 556, 667, 722, 722, 1000, 722, 722, 667, 333, 278, 333, 581, 500,
+		333, 500, 556, 444, 556, 444, 333, 500, 556, 278, 333, 556, 278, 833, 556, 500,
+		556, 556, 444, 389, 333, 556, 500, 722, 500, 500, 444, 394, 220, 394, 520,
+	}
+	timesItalicWidths = [95]uint16{
+		250, 333, 420, 500, 500, 833, 778, 214, 333, 333, 500, 675, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 675, 675, 675, 500,
+		920, 611, 611, 667, 722, 611, 611, 722, 722, 333, 444, 667, 556, 833, 667, 722,
+		611, 722, 611, 500, 556, 722, 611, 833, 611, 556, 556, 389, 278, 389, 422, 500,
+		333, 500, 500, 444, 500, 444, 278, 500, 500, 278, 278, 444, 278, 722, 500, 500,
+		500, 500, 389, 389, 278, 500, 444, 667, 444, 444, 389, 400, 275, 400, 541,
+	}
+	timesBoldItalicWidths = [95]uint16{
+		250, 389, 555, 500, 500, 833, 778, 278, 333, 333, 500, 570, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 570, 570, 570, 500,
+		832, 667, 667, 667, 722, 667, 667, 722, 778, 389, 500, 667, 611, 889, 722, 722,
+		611, 722, 667, 556, 611, 722, 667, 889, 667, 611, 611, 333, 278, 333, 570, 500,
+		333, 500, 500, 444, 500, 444, 333, 500, 556, 278, 278, 500, 278, 778, 556, 500,
+		500, 500, 389, 389, 278, 556, 444, 667, 500, 444, 389, 348, 220, 348, 570,
+	}
+)
+
+// fontMetrics returns the ASCII width table of font; it is nil for the
+// monospaced Courier fonts. ok is false for unsupported fonts.
+func fontMetrics(font StandardFont) (widths *[95]uint16, ok bool) {
+	switch font {
+	case FontHelvetica, FontHelveticaOblique:
+		return &helveticaWidths, true
+	case FontHelveticaBold, FontHelveticaBoldOblique:
+		return &helveticaBoldWidths, true
+	case FontTimesRoman:
+		return &timesRomanWidths, true
+	case FontTimesBold:
+		return &timesBoldWidths, true
+	case FontTimesItalic:
+		return &timesItalicWidths, true
+	case FontTimesBoldItalic:
+		return &timesBoldItalicWidths, true
+	case FontCourier, FontCourierBold, FontCourierOblique, FontCourierBoldOblique:
+		return nil, true
+	default:
+		return nil, false
+	}
+}
+
+// valid reports whether font is a supported standard font.
+func (font StandardFont) valid() bool {
+	_, ok := fontMetrics(font)
+	return ok
+}
+
+// glyphWidth returns the advance width of r in 1/1000 em. Accented Latin
+// letters use the width of their base letter; other characters outside
+// ASCII are approximated by the width of "o".
+func (font StandardFont) glyphWidth(r rune) float64 {
+	widths, _ := fontMetrics(font)
+	if widths == nil {
+		return 600
+	}
+	if r >= 32 && r <= 126 {
+		return float64(widths[r-32])
+	}
+	if base := []rune(norm.NFD.String(string(r))); len(base) > 0 && base[0] >= 32 && base[0] <= 126 {
+		return float64(widths[base[0]-32])
+	}
+	if r == '\u00a0' { // no-break space
+		return float64(widths[0])
+	}
+	return float64(widths['o'-32])
+}
+
+// textWidth returns the width of s in points when set in font at size.
+func (font StandardFont) textWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		units += font.glyphWidth(r)
+	}
+	return units * size / 1000
+}
+
+// winAnsiString encodes s as a PDF literal string in WinAnsiEncoding, the
+// encoding declared for standard fonts in appearance streams. Characters the
+// encoding cannot represent are replaced by "?".
+func winAnsiString(s string) string {
+	enc := charmap.Windows1252.NewEncoder()
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		encoded, err := enc.String(string(r))
+		if err != nil {
+			encoded = "?"
+		}
+		for i := 0; i < len(encoded); i++ {
+			switch c := encoded[i]; c {
+			case '(', ')', '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case '\r':
+				b.WriteString("\\r")
+			case '\n':
+				b.WriteString("\\n")
+			default:
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,693 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/digitorus/pdf"
+	"github.com/mattetti/filebuffer"
+)
+
+// ErrFormFieldNotFound is returned when a value is given for a field that
+// does not exist in the document's AcroForm.
+var ErrFormFieldNotFound = errors.New("form field not found")
+
+// Form field types of a FormFieldValue.
+const (
+	FormFieldText     = "text"
+	FormFieldCheckBox = "checkbox"
+	FormFieldRadio    = "radio"
+	FormFieldChoice   = "choice"
+
+	// Push buttons and signature fields are reported by Inspect but
+	// cannot be filled.
+	FormFieldPushButton = "pushbutton"
+	FormFieldSignature  = "signature"
+)
+
+// Field flags (ISO 32000-1, tables 221, 226, 228 and 230).
+const (
+	fieldFlagReadOnly    = 1 << 0
+	fieldFlagMultiline   = 1 << 12
+	fieldFlagPassword    = 1 << 13
+	fieldFlagRadio       = 1 << 15
+	fieldFlagPushbutton  = 1 << 16
+	fieldFlagCombo       = 1 << 17
+	fieldFlagEdit        = 1 << 18
+	fieldFlagMultiSelect = 1 << 21
+	fieldFlagComb        = 1 << 24
+)
+
+// FormFieldValue is a value to fill into an AcroForm field.
+type FormFieldValue struct {
+	// Name is the fully qualified field name: the partial names of the
+	// field and its ancestors joined by periods, e.g. "applicant.name".
+	Name string
+	// Type is the expected field type (FormFieldText, FormFieldCheckBox,
+	// FormFieldRadio or FormFieldChoice); filling fails when the field has
+	// another type. When empty, the type of the field in the document is
+	// used.
+	Type string
+	// Text is the value of a text field.
+	Text string
+	// Checked checks or clears a check box.
+	Checked bool
+	// Selected is the export value of the chosen radio button, or the
+	// chosen items of a list or combo box, matched against the export
+	// values and the displayed texts of the options. An editable combo box
+	// also accepts other text. Empty clears the selection.
+	Selected []string
+	// ReadOnly makes the field read-only after filling it.
+	ReadOnly bool
+}
+
+// TextFieldValue returns a value for a text field.
+func TextFieldValue(name, text string) FormFieldValue {
+	return FormFieldValue{Name: name, Type: FormFieldText, Text: text}
+}
+
+// CheckBoxValue returns a value for a check box.
+func CheckBoxValue(name string, checked bool) FormFieldValue {
+	return FormFieldValue{Name: name, Type: FormFieldCheckBox, Checked: checked}
+}
+
+// RadioValue returns a value choosing the radio button with the given
+// export value.
+func RadioValue(name, option string) FormFieldValue {
+	return FormFieldValue{Name: name, Type: FormFieldRadio, Selected: []string{option}}
+}
+
+// ChoiceValue returns a value choosing items of a list or combo box.
+func ChoiceValue(name string, selected ...string) FormFieldValue {
+	return FormFieldValue{Name: name, Type: FormFieldChoice, Selected: selected}
+}
+
+// FillFormFile fills form fields of the input file and writes the result,
+// an incremental update of the input, to output.
+func FillFormFile(input, output string, values []FormFieldValue) error {
+	inputFile, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = inputFile.Close()
+	}()
+
+	finfo, err := inputFile.Stat()
+	if err != nil {
+		return err
+	}
+	rdr, err := pdf.NewReader(inputFile, finfo.Size())
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := FillForm(inputFile, &out, rdr, finfo.Size(), values); err != nil {
+		return err
+	}
+	return os.WriteFile(output, out.Bytes(), 0o644)
+}
+
+// FillForm fills form fields without signing. The input is copied to output
+// followed by an incremental update with the new field values and
+// appearances, so existing signatures stay valid. Filling fails with
+// ErrChangeNotPermitted when a certification signature or field lock of the
+// document does not permit changing the fields.
+func FillForm(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64, values []FormFieldValue) error {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = v.Name
+	}
+	if err := ReadPermissions(rdr).Check(ChangeFormFill, names...); err != nil {
+		return err
+	}
+
+	context := SignContext{
+		PDFReader:  rdr,
+		InputFile:  input,
+		OutputFile: output,
+		encryption: encryptionContext(rdr),
+	}
+
+	context.OutputBuffer = filebuffer.New([]byte{})
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(context.OutputBuffer, input); err != nil {
+		return err
+	}
+	// File always needs an empty line after %%EOF.
+	if _, err := context.OutputBuffer.Write([]byte("\n")); err != nil {
+		return err
+	}
+
+	if err := context.fillFormValues(values); err != nil {
+		return err
+	}
+
+	// The catalog is unchanged; the trailer keeps pointing at it.
+	rootPtr := rdr.Trailer().Key("Root").GetPtr()
+	context.CatalogData.ObjectId = rootPtr.GetID()
+	context.CatalogData.RootString = fmt.Sprintf("%d %d R", rootPtr.GetID(), rootPtr.GetGen())
+
+	if err := context.writeXref(); err != nil {
+		return fmt.Errorf("failed to write xref: %w", err)
+	}
+	if err := context.writeTrailer(); err != nil {
+		return fmt.Errorf("failed to write trailer: %w", err)
+	}
+
+	if _, err := context.OutputBuffer.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := output.Write(context.OutputBuffer.Buff.Bytes())
+	return err
+}
+
+// formField is a terminal field of the AcroForm field tree.
+type formField struct {
+	name    string      // fully qualified name
+	value   pdf.Value   // field dictionary
+	widgets []pdf.Value // widget annotations; the field itself when merged
+}
+
+// fieldType returns the FormField* type of the field, or an empty string
+// for push buttons, signature fields and unknown types.
+func (f formField) fieldType() string {
+	flags := inheritedKey(f.value, "Ff").Int64()
+	switch inheritedKey(f.value, "FT").Name() {
+	case "Tx":
+		return FormFieldText
+	case "Btn":
+		switch {
+		case flags&fieldFlagPushbutton != 0:
+			return ""
+		case flags&fieldFlagRadio != 0:
+			return FormFieldRadio
+		default:
+			return FormFieldCheckBox
+		}
+	case "Ch":
+		return FormFieldChoice
+	default:
+		return ""
+	}
+}
+
+// collectFormFields returns the terminal fields below the given field
+// array, keyed by fully qualified name.
+func collectFormFields(fields pdf.Value) map[string]formField {
+	result := make(map[string]formField)
+	var walk func(node pdf.Value, parent string, depth int)
+	walk = func(node pdf.Value, parent string, depth int) {
+		if depth > 32 {
+			return
+		}
+		name := partialFieldName(node)
+		if parent != "" {
+			name = parent + "." + name
+		}
+
+		var children, widgets []pdf.Value
+		kids := node.Key("Kids")
+		for i := 0; i < kids.Len(); i++ {
+			// Kids without a partial name are the widgets of this field.
+			if kid := kids.Index(i); kid.Key("T").IsNull() {
+				widgets = append(widgets, kid)
+			} else {
+				children = append(children, kid)
+			}
+		}
+		for _, child := range children {
+			walk(child, name, depth+1)
+		}
+		if len(children) > 0 {
+			return
+		}
+		if kids.Len() == 0 {
+			widgets = []pdf.Value{node}
+		}
+		result[name] = formField{name: name, value: node, widgets: widgets}
+	}
+	for i := 0; i < fields.Len(); i++ {
+		walk(fields.Index(i), "", 0)
+	}
+	return result
+}
+
+// dictEdits collects replaced entries of existing dictionaries, so a field
+// that is also its own widget annotation is rewritten once.
+type dictEdits struct {
+	order []uint32
+	dicts map[uint32]*dictEdit
+}
+
+type dictEdit struct {
+	value   pdf.Value
+	keys    []string
+	entries map[string]string // serialized values; empty removes the key
+}
+
+// set replaces key of the dictionary v with an already serialized value.
+func (e *dictEdits) set(v pdf.Value, key, serialized string) {
+	id := v.GetPtr().GetID()
+	if e.dicts == nil {
+		e.dicts = make(map[uint32]*dictEdit)
+	}
+	d, ok := e.dicts[id]
+	if !ok {
+		d = &dictEdit{value: v, entries: make(map[string]string)}
+		e.dicts[id] = d
+		e.order = append(e.order, id)
+	}
+	if _, ok := d.entries[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.entries[key] = serialized
+}
+
+// write rewrites the edited dictionaries in an incremental update.
+func (e *dictEdits) write(context *SignContext) error {
+	for _, id := range e.order {
+		d := e.dicts[id]
+		var buf bytes.Buffer
+		buf.WriteString("<<\n")
+		for _, key := range d.value.Keys() {
+			if _, ok := d.entries[key]; ok {
+				continue
+			}
+			fmt.Fprintf(&buf, "  /%s ", key)
+			if err := context.serializeCatalogEntry(&buf, id, d.value.Key(key), id); err != nil {
+				return err
+			}
+			buf.WriteString("\n")
+		}
+		for _, key := range d.keys {
+			if v := d.entries[key]; v != "" {
+				fmt.Fprintf(&buf, "  /%s %s\n", key, v)
+			}
+		}
+		buf.WriteString(">>\n")
+		if err := context.updateObject(id, buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to update form field object %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// fillFormValues fills the given values into the AcroForm fields of the
+// document.
+func (context *SignContext) fillFormValues(values []FormFieldValue) error {
+	if len(values) == 0 {
+		return nil
+	}
+	acroForm := context.PDFReader.Trailer().Key("Root").Key("AcroForm")
+	fields := collectFormFields(acroForm.Key("Fields"))
+
+	var edits dictEdits
+	filled := make(map[string]bool)
+	for _, v := range values {
+		field, ok := fields[v.Name]
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrFormFieldNotFound, v.Name)
+		}
+		if filled[v.Name] {
+			return fmt.Errorf("form field %q is filled more than once", v.Name)
+		}
+		filled[v.Name] = true
+		if field.value.GetPtr().GetID() == 0 {
+			return fmt.Errorf("form field %q is not an indirect object", v.Name)
+		}
+
+		fieldType := field.fieldType()
+		if fieldType == "" {
+			return fmt.Errorf("form field %q cannot be filled", v.Name)
+		}
+		if v.Type != "" && v.Type != fieldType {
+			return fmt.Errorf("form field %q is a %s field, not %s", v.Name, fieldType, v.Type)
+		}
+
+		var err error
+		switch fieldType {
+		case FormFieldText:
+			err = context.fillTextField(&edits, field, acroForm, v.Text)
+		case FormFieldCheckBox:
+			err = context.fillButtonField(&edits, field, v.Checked, "")
+		case FormFieldRadio:
+			option := ""
+			if len(v.Selected) > 1 {
+				return fmt.Errorf("radio button group %q takes one option, got %d", v.Name, len(v.Selected))
+			} else if len(v.Selected) == 1 {
+				option = v.Selected[0]
+			}
+			err = context.fillButtonField(&edits, field, option != "" && option != "Off", option)
+		case FormFieldChoice:
+			err = context.fillChoiceField(&edits, field, acroForm, v.Selected)
+		}
+		if err != nil {
+			return fmt.Errorf("form field %q: %w", v.Name, err)
+		}
+
+		if v.ReadOnly {
+			flags := inheritedKey(field.value, "Ff").Int64() | fieldFlagReadOnly
+			edits.set(field.value, "Ff", fmt.Sprint(flags))
+		}
+	}
+	return edits.write(context)
+}
+
+// fieldDA returns the default appearance string of a widget, inherited from
+// its field or the AcroForm.
+func fieldDA(widget, acroForm pdf.Value) string {
+	if da := inheritedKey(widget, "DA"); !da.IsNull() {
+		return da.RawString()
+	}
+	return acroForm.Key("DA").RawString()
+}
+
+// fieldAlign returns the alignment of the quadding (/Q) of a widget.
+func fieldAlign(widget, acroForm pdf.Value) string {
+	q := inheritedKey(widget, "Q")
+	if q.IsNull() {
+		q = acroForm.Key("Q")
+	}
+	switch q.Int64() {
+	case 1:
+		return AlignCenter
+	case 2:
+		return AlignRight
+	default:
+		return AlignLeft
+	}
+}
+
+// setWidgetAppearance adds a normal appearance XObject to each widget of
+// field that has a rectangle; draw creates the XObject for a rectangle.
+func (context *SignContext) setWidgetAppearance(edits *dictEdits, field formField, draw func(widget pdf.Value, rect [4]float64) ([]byte, error)) error {
+	for _, widget := range field.widgets {
+		rect, ok := readPageBox(widget.Key("Rect"))
+		if !ok || widget.GetPtr().GetID() == 0 {
+			continue
+		}
+		appearance, err := draw(widget, rect)
+		if err != nil {
+			return err
+		}
+		id, err := context.addObject(appearance)
+		if err != nil {
+			return fmt.Errorf("failed to add field appearance: %w", err)
+		}
+		edits.set(widget, "AP", fmt.Sprintf("<< /N %d 0 R >>", id))
+	}
+	return nil
+}
+
+func (context *SignContext) fillTextField(edits *dictEdits, field formField, acroForm pdf.Value, text string) error {
+	flags := inheritedKey(field.value, "Ff").Int64()
+	maxLen := int(inheritedKey(field.value, "MaxLen").Int64())
+	if maxLen > 0 && len([]rune(text)) > maxLen {
+		return fmt.Errorf("value is longer than the maximum of %d characters", maxLen)
+	}
+	if flags&fieldFlagMultiline == 0 {
+		text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+	}
+
+	fieldID := field.value.GetPtr().GetID()
+	value, err := context.encryptPdfString(fieldID, text)
+	if err != nil {
+		return err
+	}
+	edits.set(field.value, "V", value)
+
+	shown := text
+	if flags&fieldFlagPassword != 0 {
+		// Password fields never show their value.
+		shown = strings.Repeat("*", len([]rune(text)))
+	}
+	return context.setWidgetAppearance(edits, field, func(widget pdf.Value, rect [4]float64) ([]byte, error) {
+		layout := textFieldLayout{
+			align:     fieldAlign(widget, acroForm),
+			multiline: flags&fieldFlagMultiline != 0,
+		}
+		if flags&fieldFlagComb != 0 && maxLen > 0 {
+			layout.comb = maxLen
+		}
+		return context.createTextFieldAppearance(shown, rect, fieldDA(widget, acroForm), 0, layout)
+	})
+}
+
+// onStates returns the names of the "on" appearance states of a widget.
+func onStates(widget pdf.Value) []string {
+	var states []string
+	for _, ap := range []pdf.Value{widget.Key("AP").Key("N"), widget.Key("AP").Key("D")} {
+		for _, key := range ap.Keys() {
+			if key != "Off" && !containsString(states, key) {
+				states = append(states, key)
+			}
+		}
+	}
+	return states
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fillButtonField sets a check box or radio button group. For radio buttons
+// option is the export value of the chosen button: an appearance state name,
+// or an entry of /Opt naming the widget at the same index.
+func (context *SignContext) fillButtonField(edits *dictEdits, field formField, on bool, option string) error {
+	state := "Off"
+	if on {
+		opts := inheritedKey(field.value, "Opt")
+		var available []string
+		for i, widget := range field.widgets {
+			states := onStates(widget)
+			if len(states) == 0 {
+				continue
+			}
+			export := states[0]
+			if i < opts.Len() {
+				export = opts.Index(i).Text()
+			}
+			if containsString(states, option) {
+				state = option
+				break
+			}
+			if option == "" || option == export {
+				state = states[0]
+				break
+			}
+			available = append(available, export)
+		}
+		if state == "Off" {
+			if option == "" {
+				return fmt.Errorf("check box has no on appearance state")
+			}
+			return fmt.Errorf("no radio button with export value %q (available: %s)", option, strings.Join(available, ", "))
+		}
+	}
+
+	edits.set(field.value, "V", pdfName(state))
+	for _, widget := range field.widgets {
+		if widget.GetPtr().GetID() == 0 {
+			continue
+		}
+		as := "Off"
+		if containsString(onStates(widget), state) {
+			as = state
+		}
+		edits.set(widget, "AS", pdfName(as))
+	}
+	return nil
+}
+
+// choiceOption is an item of a list or combo box.
+type choiceOption struct {
+	export, display string
+}
+
+func choiceOptions(field pdf.Value) []choiceOption {
+	opts := inheritedKey(field, "Opt")
+	options := make([]choiceOption, opts.Len())
+	for i := range options {
+		if item := opts.Index(i); item.Kind() == pdf.Array {
+			options[i] = choiceOption{export: item.Index(0).Text(), display: item.Index(1).Text()}
+		} else {
+			options[i] = choiceOption{export: item.Text(), display: item.Text()}
+		}
+	}
+	return options
+}
+
+func (context *SignContext) fillChoiceField(edits *dictEdits, field formField, acroForm pdf.Value, selected []string) error {
+	flags := inheritedKey(field.value, "Ff").Int64()
+	combo := flags&fieldFlagCombo != 0
+	if len(selected) > 1 && (combo || flags&fieldFlagMultiSelect == 0) {
+		return fmt.Errorf("only one item can be selected, got %d", len(selected))
+	}
+
+	options := choiceOptions(field.value)
+	var indices []int
+	var exports []string
+	custom := ""
+	for _, s := range selected {
+		index := -1
+		for i, o := range options {
+			if o.export == s || o.display == s {
+				index = i
+				break
+			}
+		}
+		switch {
+		case index >= 0:
+			indices = append(indices, index)
+			exports = append(exports, options[index].export)
+		case combo && flags&fieldFlagEdit != 0:
+			custom = s
+			exports = append(exports, s)
+		default:
+			return fmt.Errorf("%q is not one of the options", s)
+		}
+	}
+	sort.Ints(indices)
+
+	fieldID := field.value.GetPtr().GetID()
+	var value string
+	switch len(exports) {
+	case 0:
+	case 1:
+		v, err := context.encryptPdfString(fieldID, exports[0])
+		if err != nil {
+			return err
+		}
+		value = v
+	default:
+		parts := make([]string, len(exports))
+		for i, e := range exports {
+			v, err := context.encryptPdfString(fieldID, e)
+			if err != nil {
+				return err
+			}
+			parts[i] = v
+		}
+		value = "[" + strings.Join(parts, " ") + "]"
+	}
+	edits.set(field.value, "V", value)
+	if !combo && len(indices) > 0 {
+		parts := make([]string, len(indices))
+		for i, index := range indices {
+			parts[i] = fmt.Sprint(index)
+		}
+		edits.set(field.value, "I", "["+strings.Join(parts, " ")+"]")
+	} else {
+		edits.set(field.value, "I", "")
+	}
+
+	return context.setWidgetAppearance(edits, field, func(widget pdf.Value, rect [4]float64) ([]byte, error) {
+		da := fieldDA(widget, acroForm)
+		if combo {
+			shown := custom
+			if len(indices) == 1 {
+				shown = options[indices[0]].display
+			}
+			return context.createTextFieldAppearance(shown, rect, da, 0, textFieldLayout{align: fieldAlign(widget, acroForm)})
+		}
+		items := make([]string, len(options))
+		for i, o := range options {
+			items[i] = o.display
+		}
+		top := int(field.value.Key("TI").Int64())
+		return context.createListBoxAppearance(items, indices, top, rect, da)
+	})
+}
+
+// createListBoxAppearance creates the appearance of a list box showing the
+// items from index top, with the selected items highlighted.
+func (context *SignContext) createListBoxAppearance(items []string, selected []int, top int, rect [4]float64, da string) ([]byte, error) {
+	width := rect[2] - rect[0]
+	height := rect[3] - rect[1]
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid rectangle dimensions")
+	}
+	fontSize := daFontSize(da)
+	if fontSize == 0 {
+		fontSize = 12
+	}
+	lineHeight := fontSize * defaultLineSpacing
+
+	var font appearanceFont = FontHelvetica
+	var fontID uint32
+	if embedded := context.SignData.Appearance.EmbeddedFont; embedded != nil {
+		subset, err := embedded.subset(strings.Join(items, ""))
+		if err != nil {
+			return nil, err
+		}
+		id, err := context.addFontSubset(subset)
+		if err != nil {
+			return nil, err
+		}
+		fontID = id
+		font = subset
+	}
+
+	var stream bytes.Buffer
+	stream.WriteString("q\n")
+	fmt.Fprintf(&stream, "1 1 1 rg 0 0 %.1f %.1f re f\n", width, height)
+	fmt.Fprintf(&stream, "0 0 %.1f %.1f re W n\n", width, height)
+	for row, i := 0, max(top, 0); i < len(items); row, i = row+1, i+1 {
+		y := height - float64(row+1)*lineHeight
+		if y+lineHeight < 0 {
+			break
+		}
+		if containsInt(selected, i) {
+			// The highlight colour used by common viewers.
+			fmt.Fprintf(&stream, "0.600 0.757 0.855 rg 0 %.2f %.1f %.2f re f\n", y, width, lineHeight)
+		}
+		stream.WriteString("BT\n")
+		fmt.Fprintf(&stream, "/F1 %.1f Tf\n", fontSize)
+		stream.WriteString("0 0 0 rg\n")
+		fmt.Fprintf(&stream, "1 0 0 1 2 %.2f Tm\n", y+(lineHeight-fontSize)/2+fontSize*0.2)
+		stream.WriteString(font.encodeText(items[i]) + " Tj\n")
+		stream.WriteString("ET\n")
+	}
+	stream.WriteString("Q\n")
+
+	return context.fieldAppearanceXObject(stream.Bytes(), width, height, fontID)
+}
+
+func containsInt(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+// pdfName returns s as a PDF name object, escaping delimiters, whitespace
+// and non-ASCII bytes as #xx.
+func pdfName(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x21 || c > 0x7e || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package sign
+
+import (
+	"crypto"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/digitorus/pdf"
+)
+
+// writeFormPDF writes a one page PDF with text, check box, radio button and
+// choice fields.
+func writeFormPDF(t *testing.T) string {
+	t.Helper()
+	widget := "/Type /Annot /Subtype /Widget /P 3 0 R"
+	onOff := func(on string) string {
+		return "/AP << /N << /" + on + " 16 0 R /Off 16 0 R >> >> /AS /Off"
+	}
+	return writeTestPDF(t, []string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [5 0 R 8 0 R 9 0 R 10 0 R 13 0 R 14 0 R] /DA (/Helv 0 Tf 0 g) >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Annots [6 0 R 7 0 R 8 0 R 9 0 R 11 0 R 12 0 R 13 0 R 14 0 R] >>",
+		"<< /Length 0 >>\nstream\n\nendstream",
+		"<< /T (applicant) /FT /Tx /Kids [6 0 R 7 0 R] >>",
+		"<< " + widget + " /Parent 5 0 R /T (name) /Rect [50 700 250 720] /DA (/Helv 12 Tf 0 g) /Q 1 >>",
+		"<< " + widget + " /Parent 5 0 R /T (notes) /Ff 4096 /Rect [50 600 250 680] >>",
+		"<< " + widget + " /FT /Tx /T (zip) /Ff 16777216 /MaxLen 5 /Rect [50 560 150 580] >>",
+		"<< " + widget + " /FT /Btn /T (agree) /Rect [50 520 62 532] /V /Off " + onOff("Yes") + " >>",
+		"<< /FT /Btn /Ff 49152 /T (color) /Kids [11 0 R 12 0 R] /Opt [(red) (blue)] >>",
+		"<< " + widget + " /Parent 10 0 R /Rect [50 480 62 492] " + onOff("0") + " >>",
+		"<< " + widget + " /Parent 10 0 R /Rect [80 480 92 492] " + onOff("1") + " >>",
+		"<< " + widget + " /FT /Ch /Ff 393216 /T (country) /Opt [[(FR) (France)] [(DE) (Germany)]] /Rect [50 440 200 460] >>",
+		"<< " + widget + " /FT /Ch /Ff 2097152 /T (langs) /Opt [(Go) (Rust) (C)] /Rect [50 360 200 420] >>",
+		"<< /Type /XObject /Subtype /Form /BBox [0 0 12 12] /Length 0 >>\nstream\n\nendstream",
+	})
+}
+
+func TestCollectFormFields(t *testing.T) {
+	r := openTestReader(t, writeFormPDF(t))
+	fields := collectFormFields(r.Trailer().Key("Root").Key("AcroForm").Key("Fields"))
+	want := map[string]string{
+		"applicant.name":  FormFieldText,
+		"applicant.notes": FormFieldText,
+		"zip":             FormFieldText,
+		"agree":           FormFieldCheckBox,
+		"color":           FormFieldRadio,
+		"country":         FormFieldChoice,
+		"langs":           FormFieldChoice,
+	}
+	if len(fields) != len(want) {
+		t.Errorf("collectFormFields() found %d fields, want %d", len(fields), len(want))
+	}
+	for name, typ := range want {
+		if got := fields[name].fieldType(); got != typ {
+			t.Errorf("field %q type = %q, want %q", name, got, typ)
+		}
+	}
+	if n := len(fields["color"].widgets); n != 2 {
+		t.Errorf("radio group has %d widgets, want 2", n)
+	}
+}
+
+func TestFillFormFile(t *testing.T) {
+	input := writeFormPDF(t)
+	output := filepath.Join(t.TempDir(), "filled.pdf")
+	values := []FormFieldValue{
+		TextFieldValue("applicant.name", "Jane (Doe)"),
+		TextFieldValue("applicant.notes", "First line\nsecond line"),
+		TextFieldValue("zip", "75001"),
+		CheckBoxValue("agree", true),
+		RadioValue("color", "blue"),
+		ChoiceValue("country", "Germany"),
+		{Name: "langs", Selected: []string{"C", "Go"}, ReadOnly: true},
+	}
+	if err := FillFormFile(input, output, values); err != nil {
+		t.Fatalf("FillFormFile() error = %v", err)
+	}
+
+	r := openTestReader(t, output)
+	fields := collectFormFields(r.Trailer().Key("Root").Key("AcroForm").Key("Fields"))
+	field := func(name string) pdf.Value { return fields[name].value }
+
+	for name, want := range map[string]string{
+		"applicant.name":  "Jane (Doe)",
+		"applicant.notes": "First line\nsecond line",
+		"zip":             "75001",
+		"country":         "DE",
+	} {
+		if got := field(name).Key("V").Text(); got != want {
+			t.Errorf("%s /V = %q, want %q", name, got, want)
+		}
+		if field(name).Key("AP").Key("N").Kind() != pdf.Stream {
+			t.Errorf("%s has no appearance stream", name)
+		}
+	}
+
+	if v, as := field("agree").Key("V").Name(), field("agree").Key("AS").Name(); v != "Yes" || as != "Yes" {
+		t.Errorf("check box /V = %q /AS = %q, want Yes", v, as)
+	}
+	color := fields["color"]
+	if v := color.value.Key("V").Name(); v != "1" {
+		t.Errorf("radio /V = %q, want 1", v)
+	}
+	if a, b := color.widgets[0].Key("AS").Name(), color.widgets[1].Key("AS").Name(); a != "Off" || b != "1" {
+		t.Errorf("radio /AS = %q, %q, want Off, 1", a, b)
+	}
+
+	langs := field("langs")
+	if v := langs.Key("V"); v.Len() != 2 || v.Index(0).Text() != "C" || v.Index(1).Text() != "Go" {
+		t.Errorf("list box /V = %v", v)
+	}
+	if i := langs.Key("I"); i.Len() != 2 || i.Index(0).Int64() != 0 || i.Index(1).Int64() != 2 {
+		t.Errorf("list box /I = %v, want [0 2]", i)
+	}
+	if ff := langs.Key("Ff").Int64(); ff&fieldFlagReadOnly == 0 || ff&fieldFlagMultiSelect == 0 {
+		t.Errorf("list box /Ff = %d, want read-only and multi-select", ff)
+	}
+	if ff := field("zip").Key("Ff").Int64(); ff&fieldFlagReadOnly != 0 {
+		t.Errorf("zip /Ff = %d, not requested read-only", ff)
+	}
+
+	// The comb field shows one character per cell of 20 points, the digits
+	// at 14 points (the auto size) centred in their cells.
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"1 0 0 1 6.11 ", "1 0 0 1 86.11 "} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("filled file does not contain comb cell position %q", s)
+		}
+	}
+}
+
+func TestFillFormErrors(t *testing.T) {
+	input := writeFormPDF(t)
+	output := filepath.Join(t.TempDir(), "filled.pdf")
+	tests := []struct {
+		name  string
+		value FormFieldValue
+	}{
+		{"wrong type", CheckBoxValue("applicant.name", true)},
+		{"too long", TextFieldValue("zip", "123456")},
+		{"unknown radio option", RadioValue("color", "green")},
+		{"unknown choice", ChoiceValue("langs", "Java")},
+		{"several items in a combo box", ChoiceValue("country", "FR", "DE")},
+	}
+	for _, tt := range tests {
+		if err := FillFormFile(input, output, []FormFieldValue{tt.value}); err == nil {
+			t.Errorf("%s: FillFormFile() error = nil", tt.name)
+		}
+	}
+
+	err := FillFormFile(input, output, []FormFieldValue{TextFieldValue("applicant", "x")})
+	if !errors.Is(err, ErrFormFieldNotFound) {
+		t.Errorf("FillFormFile() with a non-terminal field: error = %v, want %v", err, ErrFormFieldNotFound)
+	}
+	// An editable combo box accepts other text.
+	if err := FillFormFile(input, output, []FormFieldValue{ChoiceValue("country", "Italy")}); err != nil {
+		t.Errorf("FillFormFile() with custom combo text: error = %v", err)
+	}
+}
+
+func TestSignPDFFormFields(t *testing.T) {
+	cert, pkey := loadCertificateAndKey(t)
+	input := writeFormPDF(t)
+	output := filepath.Join(t.TempDir(), "signed.pdf")
+	_, err := SignFile(input, output, SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "John Doe"},
+			CertType: ApprovalSignature,
+		},
+		FormFields: []FormFieldValue{
+			TextFieldValue("applicant.name", "John Doe"),
+			CheckBoxValue("agree", true),
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	})
+	if err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	verifyAllSignaturesValid(t, f, 1)
+
+	r := openTestReader(t, output)
+	fields := collectFormFields(r.Trailer().Key("Root").Key("AcroForm").Key("Fields"))
+	if got := fields["applicant.name"].value.Key("V").Text(); got != "John Doe" {
+		t.Errorf("signed file: applicant.name = %q", got)
+	}
+	if got := fields["agree"].value.Key("AS").Name(); got != "Yes" {
+		t.Errorf("signed file: agree /AS = %q", got)
+	}
+}
@@ -0,0 +1,469 @@
+package sign
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/digitorus/pdf"
+)
+
+// DocumentInfo describes the structure of a PDF document as reported by
+// Inspect.
+type DocumentInfo struct {
+	// Version is the PDF version of the header, or of the catalog /Version
+	// entry when that is later.
+	Version string `json:"version"`
+	// XrefType is "table" or "stream".
+	XrefType   string          `json:"xref_type"`
+	Encryption *EncryptionInfo `json:"encryption,omitempty"`
+	Pages      int             `json:"pages"`
+	// Revisions is the number of revisions: the original document plus one
+	// per incremental update, counted by their end-of-file markers.
+	Revisions int `json:"revisions"`
+	// DocMDP is set for certified documents.
+	DocMDP *DocMDPInfo `json:"docmdp,omitempty"`
+	// Fields is the AcroForm field tree.
+	Fields []FieldInfo `json:"fields"`
+}
+
+// EncryptionInfo describes the security handler of an encrypted document.
+type EncryptionInfo struct {
+	Filter    string `json:"filter"`
+	SubFilter string `json:"sub_filter,omitempty"`
+	V         int64  `json:"v"`
+	R         int64  `json:"r,omitempty"`
+	KeyLength int64  `json:"key_length,omitempty"` // in bits
+	// Method is the crypt filter method for streams and strings, e.g.
+	// "RC4", "AESV2" or "AESV3".
+	Method string `json:"method"`
+}
+
+// DocMDPInfo describes the modification permissions of a certified
+// document.
+type DocMDPInfo struct {
+	// Permission is 1 (no changes), 2 (form filling and signing) or 3 (also
+	// annotations).
+	Permission int64 `json:"permission"`
+	// Field is the name of the certification signature field.
+	Field string `json:"field,omitempty"`
+}
+
+// FieldInfo describes a node of the AcroForm field tree.
+type FieldInfo struct {
+	// Name is the fully qualified field name.
+	Name string `json:"name"`
+	// Type is FormFieldText, FormFieldCheckBox, FormFieldRadio,
+	// FormFieldChoice, FormFieldPushButton or FormFieldSignature; it is
+	// empty for nodes whose kids have different types.
+	Type      string   `json:"type,omitempty"`
+	Flags     int64    `json:"flags"`
+	FlagNames []string `json:"flag_names,omitempty"`
+	// Value is the current value of a single valued field; Values holds
+	// the items chosen in a multiple selection list box.
+	Value  string   `json:"value,omitempty"`
+	Values []string `json:"values,omitempty"`
+	// Options are the values accepted by FormFieldValue.Selected: the
+	// export values of list and combo box items or of radio buttons, and
+	// the on state of check boxes.
+	Options   []string            `json:"options,omitempty"`
+	MaxLen    int64               `json:"max_len,omitempty"`
+	Widgets   []WidgetInfo        `json:"widgets,omitempty"`
+	Signature *SignatureFieldInfo `json:"signature,omitempty"`
+	Kids      []FieldInfo         `json:"kids,omitempty"`
+}
+
+// WidgetInfo describes a widget annotation of a field.
+type WidgetInfo struct {
+	// Page is the 1-based page number, 0 when the widget is on no page.
+	Page int        `json:"page"`
+	Rect [4]float64 `json:"rect"`
+	// State is the appearance state (/AS) of check boxes and radio buttons.
+	State string `json:"state,omitempty"`
+}
+
+// SignatureFieldInfo describes a signature field.
+type SignatureFieldInfo struct {
+	Signed    bool    `json:"signed"`
+	Filter    string  `json:"filter,omitempty"`
+	SubFilter string  `json:"sub_filter,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	Reason    string  `json:"reason,omitempty"`
+	Location  string  `json:"location,omitempty"`
+	Time      string  `json:"time,omitempty"` // the /M entry as written
+	ByteRange []int64 `json:"byte_range,omitempty"`
+	// Lock is the field lock dictionary (/Lock) and SeedValue the seed
+	// value dictionary (/SV), converted to JSON values.
+	Lock      map[string]any `json:"lock,omitempty"`
+	SeedValue map[string]any `json:"seed_value,omitempty"`
+}
+
+// fieldFlagNames names the field flags by field type (ISO 32000-1, tables
+// 221, 226, 228 and 230), keyed by bit position starting at 1.
+var fieldFlagNames = map[string]map[int]string{
+	"": {1: "ReadOnly", 2: "Required", 3: "NoExport"},
+	FormFieldText: {13: "Multiline", 14: "Password", 21: "FileSelect",
+		23: "DoNotSpellCheck", 24: "DoNotScroll", 25: "Comb", 26: "RichText"},
+	FormFieldCheckBox: {15: "NoToggleToOff", 26: "RadiosInUnison"},
+	FormFieldRadio:    {15: "NoToggleToOff", 16: "Radio", 26: "RadiosInUnison"},
+	FormFieldChoice: {18: "Combo", 19: "Edit", 20: "Sort", 22: "MultiSelect",
+		23: "DoNotSpellCheck", 27: "CommitOnSelChange"},
+	FormFieldPushButton: {17: "Pushbutton"},
+}
+
+// InspectFile describes the structure of the PDF file at path.
+func InspectFile(path string) (*DocumentInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Inspect(f, st.Size())
+}
+
+// Inspect describes the structure of a PDF document: its version, cross
+// reference and encryption, revisions, certification permissions and the
+// AcroForm field tree with values, widgets and signature status.
+//
+// InspectWithPassword opens password-protected documents.
+func Inspect(input io.ReaderAt, size int64) (*DocumentInfo, error) {
+	return InspectWithPassword(input, size, "")
+}
+
+// InspectWithPassword is Inspect for an encrypted document, opened with the
+// user or owner password. It fails with pdf.ErrInvalidPassword when the
+// password is wrong.
+func InspectWithPassword(input io.ReaderAt, size int64, password string) (*DocumentInfo, error) {
+	r, err := pdf.NewReaderPassword(input, size, password)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := countRevisions(io.NewSectionReader(input, 0, size))
+	if err != nil {
+		return nil, err
+	}
+
+	root := r.Trailer().Key("Root")
+	info := &DocumentInfo{
+		Version:   r.PDFVersion,
+		XrefType:  r.XrefInformation.Type,
+		Pages:     r.NumPage(),
+		Revisions: revisions,
+		Fields:    []FieldInfo{},
+	}
+	if v := root.Key("Version").Name(); v != "" {
+		if a, err := strconv.ParseFloat(v, 64); err == nil {
+			if b, err := strconv.ParseFloat(info.Version, 64); err != nil || a > b {
+				info.Version = v
+			}
+		}
+	}
+	if enc := r.Trailer().Key("Encrypt"); !enc.IsNull() {
+		info.Encryption = inspectEncryption(enc)
+	}
+
+	in := inspector{
+		info:   info,
+		docMDP: root.Key("Perms").Key("DocMDP"),
+		pageOf: make(map[uint32]int),
+	}
+	if !in.docMDP.IsNull() {
+		p, _ := docMDPPermission(in.docMDP)
+		info.DocMDP = &DocMDPInfo{Permission: p}
+	}
+
+	// Widgets refer to their page with /P, which is optional; the page
+	// /Annots arrays are authoritative.
+	for i := 1; i <= info.Pages; i++ {
+		annots := r.Page(i).V.Key("Annots")
+		for j := 0; j < annots.Len(); j++ {
+			if id := annots.Index(j).GetPtr().GetID(); id != 0 {
+				in.pageOf[id] = i
+			}
+		}
+	}
+
+	fields := root.Key("AcroForm").Key("Fields")
+	for i := 0; i < fields.Len(); i++ {
+		info.Fields = append(info.Fields, in.field(fields.Index(i), "", 0))
+	}
+	return info, nil
+}
+
+// inspector holds the document wide state of Inspect.
+type inspector struct {
+	info *DocumentInfo
+	// docMDP is the signature dictionary referenced by the catalog /Perms.
+	docMDP pdf.Value
+	// pageOf maps widget object numbers to page numbers.
+	pageOf map[uint32]int
+}
+
+// countRevisions counts the revisions of the document: the end-of-file
+// markers that follow a startxref offset.
+func countRevisions(r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	return max(len(revisionEnds(data)), 1), nil
+}
+
+func inspectEncryption(enc pdf.Value) *EncryptionInfo {
+	info := &EncryptionInfo{
+		Filter:    enc.Key("Filter").Name(),
+		SubFilter: enc.Key("SubFilter").Name(),
+		V:         enc.Key("V").Int64(),
+		R:         enc.Key("R").Int64(),
+		KeyLength: enc.Key("Length").Int64(),
+		Method:    "RC4",
+	}
+	if info.V >= 4 {
+		cf := enc.Key("StmF").Name()
+		if method := enc.Key("CF").Key(cf).Key("CFM").Name(); method != "" {
+			info.Method = method
+		} else if cf == "Identity" {
+			info.Method = "None"
+		}
+	}
+	if info.KeyLength == 0 {
+		switch {
+		case info.V == 5:
+			info.KeyLength = 256
+		case info.V == 1:
+			info.KeyLength = 40
+		}
+	}
+	return info
+}
+
+// docMDPPermission returns the DocMDP permission of a signature dictionary
+// and whether it has a DocMDP signature reference.
+func docMDPPermission(sig pdf.Value) (int64, bool) {
+	refs := sig.Key("Reference")
+	for i := 0; i < refs.Len(); i++ {
+		ref := refs.Index(i)
+		if ref.Key("TransformMethod").Name() != "DocMDP" {
+			continue
+		}
+		if p := ref.Key("TransformParams").Key("P").Int64(); p != 0 {
+			return p, true
+		}
+		return 2, true // the default when /P is absent
+	}
+	return 2, false
+}
+
+// partialFieldName returns the decoded /T entry of a field.
+func partialFieldName(field pdf.Value) string {
+	raw := field.Key("T").RawString()
+	if decoded := decodeFieldName(raw); decoded != raw {
+		return decoded
+	}
+	return field.Key("T").Text()
+}
+
+// inspectFieldType returns the type of a field, including the types that
+// cannot be filled.
+func inspectFieldType(field pdf.Value) string {
+	switch inheritedKey(field, "FT").Name() {
+	case "Sig":
+		return FormFieldSignature
+	case "Btn":
+		if inheritedKey(field, "Ff").Int64()&fieldFlagPushbutton != 0 {
+			return FormFieldPushButton
+		}
+	}
+	return formField{value: field}.fieldType()
+}
+
+func (in *inspector) field(node pdf.Value, parent string, depth int) FieldInfo {
+	name := partialFieldName(node)
+	if parent != "" {
+		name = parent + "." + name
+	}
+	fi := FieldInfo{Name: name}
+	if depth > 32 {
+		return fi
+	}
+
+	var widgets []pdf.Value
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		kid := kids.Index(i)
+		if kid.Key("T").IsNull() {
+			widgets = append(widgets, kid)
+		} else {
+			fi.Kids = append(fi.Kids, in.field(kid, name, depth+1))
+		}
+	}
+	if len(fi.Kids) > 0 {
+		// Intermediate node: the type applies when all kids share it.
+		for i, kid := range fi.Kids {
+			if i == 0 {
+				fi.Type = kid.Type
+			} else if kid.Type != fi.Type {
+				fi.Type = ""
+			}
+		}
+		return fi
+	}
+	if kids.Len() == 0 {
+		widgets = []pdf.Value{node}
+	}
+
+	fi.Type = inspectFieldType(node)
+	fi.Flags = inheritedKey(node, "Ff").Int64()
+	for bit := 1; bit <= 32; bit++ {
+		if fi.Flags&(1<<(bit-1)) == 0 {
+			continue
+		}
+		if n, ok := fieldFlagNames[""][bit]; ok {
+			fi.FlagNames = append(fi.FlagNames, n)
+		} else if n, ok := fieldFlagNames[fi.Type][bit]; ok {
+			fi.FlagNames = append(fi.FlagNames, n)
+		}
+	}
+	fi.MaxLen = inheritedKey(node, "MaxLen").Int64()
+
+	for _, w := range widgets {
+		wi := WidgetInfo{Page: in.pageOf[w.GetPtr().GetID()], State: w.Key("AS").Name()}
+		if rect, ok := readPageBox(w.Key("Rect")); ok {
+			wi.Rect = rect
+		}
+		fi.Widgets = append(fi.Widgets, wi)
+	}
+
+	v := inheritedKey(node, "V")
+	switch fi.Type {
+	case FormFieldSignature:
+		fi.Signature = inspectSignature(node, v)
+		in.certification(v, name)
+	case FormFieldCheckBox, FormFieldRadio:
+		fi.Value = v.Name()
+		opts := inheritedKey(node, "Opt")
+		for i, w := range widgets {
+			states := onStates(w)
+			if len(states) == 0 {
+				continue
+			}
+			option := states[0]
+			if i < opts.Len() {
+				option = opts.Index(i).Text()
+			}
+			if !containsString(fi.Options, option) {
+				fi.Options = append(fi.Options, option)
+			}
+		}
+	case FormFieldChoice:
+		for _, o := range choiceOptions(node) {
+			fi.Options = append(fi.Options, o.export)
+		}
+		fallthrough
+	default:
+		if v.Kind() == pdf.Array {
+			for i := 0; i < v.Len(); i++ {
+				fi.Values = append(fi.Values, v.Index(i).Text())
+			}
+		} else if v.Kind() == pdf.String {
+			fi.Value = v.Text()
+		} else if v.Kind() == pdf.Name {
+			fi.Value = v.Name()
+		}
+	}
+	return fi
+}
+
+// certification records the field name of the certification signature sig.
+// Documents certified without a catalog /Perms entry are recognised by the
+// DocMDP reference of the signature.
+func (in *inspector) certification(sig pdf.Value, name string) {
+	if sig.Kind() != pdf.Dict {
+		return
+	}
+	if in.info.DocMDP != nil {
+		if id := sig.GetPtr().GetID(); id != 0 && id == in.docMDP.GetPtr().GetID() {
+			in.info.DocMDP.Field = name
+		}
+		return
+	}
+	if p, ok := docMDPPermission(sig); ok {
+		in.info.DocMDP = &DocMDPInfo{Permission: p, Field: name}
+	}
+}
+
+func inspectSignature(field, v pdf.Value) *SignatureFieldInfo {
+	si := &SignatureFieldInfo{
+		Signed:    v.Kind() == pdf.Dict,
+		Lock:      jsonDict(field.Key("Lock")),
+		SeedValue: jsonDict(field.Key("SV")),
+	}
+	if !si.Signed {
+		return si
+	}
+	si.Filter = v.Key("Filter").Name()
+	si.SubFilter = v.Key("SubFilter").Name()
+	si.Name = v.Key("Name").Text()
+	si.Reason = v.Key("Reason").Text()
+	si.Location = v.Key("Location").Text()
+	si.Time = v.Key("M").Text()
+	br := v.Key("ByteRange")
+	for i := 0; i < br.Len(); i++ {
+		si.ByteRange = append(si.ByteRange, br.Index(i).Int64())
+	}
+	return si
+}
+
+// jsonDict converts a PDF dictionary to JSON values, or returns nil when v
+// is not a dictionary.
+func jsonDict(v pdf.Value) map[string]any {
+	if v.Kind() != pdf.Dict {
+		return nil
+	}
+	m, _ := jsonValue(v, 0).(map[string]any)
+	return m
+}
+
+// jsonValue converts a PDF object to a JSON value. Names keep their slash
+// to tell them from strings; streams are summarized.
+func jsonValue(v pdf.Value, depth int) any {
+	if depth > 8 {
+		return nil
+	}
+	switch v.Kind() {
+	case pdf.Bool:
+		return v.Bool()
+	case pdf.Integer:
+		return v.Int64()
+	case pdf.Real:
+		return v.Float64()
+	case pdf.String:
+		return v.Text()
+	case pdf.Name:
+		return "/" + v.Name()
+	case pdf.Array:
+		a := make([]any, v.Len())
+		for i := range a {
+			a[i] = jsonValue(v.Index(i), depth+1)
+		}
+		return a
+	case pdf.Dict:
+		m := make(map[string]any)
+		for _, key := range v.Keys() {
+			m[key] = jsonValue(v.Key(key), depth+1)
+		}
+		return m
+	case pdf.Stream:
+		return fmt.Sprintf("stream (%d 0 R)", v.GetPtr().GetID())
+	default:
+		return nil
+	}
+}
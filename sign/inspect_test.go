@@ -0,0 +1,176 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/digitorus/pdf"
+)
+
+// findField returns the field named name in the tree fields.
+func findField(fields []FieldInfo, name string) *FieldInfo {
+	for i := range fields {
+		if fields[i].Name == name {
+			return &fields[i]
+		}
+		if f := findField(fields[i].Kids, name); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+func TestInspectFile(t *testing.T) {
+	info, err := InspectFile(writeFormPDF(t))
+	if err != nil {
+		t.Fatalf("InspectFile() error = %v", err)
+	}
+	if info.Version != "1.7" || info.XrefType != "table" || info.Pages != 1 || info.Revisions != 1 {
+		t.Errorf("InspectFile() = version %q, xref %q, %d pages, %d revisions", info.Version, info.XrefType, info.Pages, info.Revisions)
+	}
+	if info.Encryption != nil || info.DocMDP != nil {
+		t.Errorf("InspectFile() reports encryption %+v, DocMDP %+v", info.Encryption, info.DocMDP)
+	}
+	if len(info.Fields) != 6 {
+		t.Fatalf("InspectFile() found %d top-level fields, want 6", len(info.Fields))
+	}
+
+	applicant := findField(info.Fields, "applicant")
+	if applicant.Type != FormFieldText || len(applicant.Kids) != 2 || len(applicant.Widgets) != 0 {
+		t.Errorf("applicant = %+v, want a text node with two kids", applicant)
+	}
+	notes := findField(info.Fields, "applicant.notes")
+	if notes == nil || !reflect.DeepEqual(notes.FlagNames, []string{"Multiline"}) {
+		t.Errorf("applicant.notes = %+v, want the Multiline flag", notes)
+	}
+	if w := notes.Widgets; len(w) != 1 || w[0].Page != 1 || w[0].Rect != [4]float64{50, 600, 250, 680} {
+		t.Errorf("applicant.notes widgets = %+v", w)
+	}
+	if zip := findField(info.Fields, "zip"); zip.MaxLen != 5 || !reflect.DeepEqual(zip.FlagNames, []string{"Comb"}) {
+		t.Errorf("zip = %+v", zip)
+	}
+
+	color := findField(info.Fields, "color")
+	if color.Type != FormFieldRadio || !reflect.DeepEqual(color.Options, []string{"red", "blue"}) || len(color.Widgets) != 2 {
+		t.Errorf("color = %+v, want a radio group with options red and blue", color)
+	}
+	if agree := findField(info.Fields, "agree"); agree.Value != "Off" || !reflect.DeepEqual(agree.Options, []string{"Yes"}) {
+		t.Errorf("agree = %+v", agree)
+	}
+	country := findField(info.Fields, "country")
+	if !reflect.DeepEqual(country.Options, []string{"FR", "DE"}) || !reflect.DeepEqual(country.FlagNames, []string{"Combo", "Edit"}) {
+		t.Errorf("country = %+v", country)
+	}
+}
+
+func TestInspectWithPassword(t *testing.T) {
+	// testfile_password.pdf has the user password "user".
+	data, err := os.ReadFile("../testfiles/testfile_password.pdf")
+	if err != nil {
+		t.Skipf("test file not available: %v", err)
+	}
+	if _, err := Inspect(bytes.NewReader(data), int64(len(data))); !errors.Is(err, pdf.ErrInvalidPassword) {
+		t.Errorf("Inspect() without password: error = %v, want %v", err, pdf.ErrInvalidPassword)
+	}
+	info, err := InspectWithPassword(bytes.NewReader(data), int64(len(data)), "user")
+	if err != nil {
+		t.Fatalf("InspectWithPassword() error = %v", err)
+	}
+	if info.Encryption == nil || info.Pages == 0 {
+		t.Errorf("InspectWithPassword() = encryption %+v, %d pages", info.Encryption, info.Pages)
+	}
+}
+
+func TestCountRevisions(t *testing.T) {
+	// Only markers following a startxref offset end a revision.
+	data := "%PDF-1.7\n1 0 obj\n(%%EOF)\nendobj\nstartxref\n9\n%%EOF\n% comment %%EOF\n"
+	if n, err := countRevisions(strings.NewReader(data)); err != nil || n != 1 {
+		t.Errorf("countRevisions() = %d, %v; want 1", n, err)
+	}
+}
+
+func TestInspectFilledAndCertified(t *testing.T) {
+	cert, pkey := loadCertificateAndKey(t)
+	output := filepath.Join(t.TempDir(), "certified.pdf")
+	_, err := SignFile(writeFormPDF(t), output, SignData{
+		Signature: SignDataSignature{
+			Info:       SignDataSignatureInfo{Name: "John Doe", Reason: "Approved"},
+			CertType:   CertificationSignature,
+			DocMDPPerm: AllowFillingExistingFormFieldsAndSignaturesPerms,
+		},
+		FormFields: []FormFieldValue{
+			{Name: "langs", Selected: []string{"Rust", "C"}},
+			RadioValue("color", "red"),
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	})
+	if err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+
+	info, err := InspectFile(output)
+	if err != nil {
+		t.Fatalf("InspectFile() error = %v", err)
+	}
+	if info.Revisions != 2 {
+		t.Errorf("Revisions = %d, want 2", info.Revisions)
+	}
+	if info.DocMDP == nil || info.DocMDP.Permission != 2 || info.DocMDP.Field != "Signature 1" {
+		t.Errorf("DocMDP = %+v, want permission 2 by Signature 1", info.DocMDP)
+	}
+
+	sig := findField(info.Fields, "Signature 1")
+	if sig == nil || sig.Type != FormFieldSignature || sig.Signature == nil {
+		t.Fatalf("signature field = %+v", sig)
+	}
+	if s := sig.Signature; !s.Signed || s.Filter != "Adobe.PPKLite" || s.Name != "John Doe" || s.Reason != "Approved" || len(s.ByteRange) != 4 {
+		t.Errorf("signature = %+v", s)
+	}
+	if langs := findField(info.Fields, "langs"); !reflect.DeepEqual(langs.Values, []string{"Rust", "C"}) {
+		t.Errorf("langs values = %v", langs.Values)
+	}
+	if color := findField(info.Fields, "color"); color.Value != "0" {
+		t.Errorf("color value = %q, want 0", color.Value)
+	}
+}
+
+func TestInspectSignatureFieldDictionaries(t *testing.T) {
+	// The field name is "Signé" in UTF-16BE.
+	path := writeTestPDF(t, []string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R] /SigFlags 1 >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [4 0 R] >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Sig /T <FEFF005300690067006E00E9> /Rect [0 0 0 0]" +
+			" /Lock << /Type /SigFieldLock /Action /Include /Fields [(name)] >>" +
+			" /SV << /Type /SV /Ff 1 /SubFilter [/adbe.pkcs7.detached] >> >>",
+	})
+	info, err := InspectFile(path)
+	if err != nil {
+		t.Fatalf("InspectFile() error = %v", err)
+	}
+	if len(info.Fields) != 1 || info.Fields[0].Name != "Signé" {
+		t.Fatalf("fields = %+v, want one field named Signé", info.Fields)
+	}
+	s := info.Fields[0].Signature
+	if s == nil || s.Signed {
+		t.Fatalf("signature = %+v, want an unsigned field", s)
+	}
+	got, err := json.Marshal(map[string]any{"lock": s.Lock, "sv": s.SeedValue})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"lock":{"Action":"/Include","Fields":["name"],"Type":"/SigFieldLock"},` +
+		`"sv":{"Ff":1,"SubFilter":["/adbe.pkcs7.detached"],"Type":"/SV"}}`
+	if string(got) != want {
+		t.Errorf("lock and seed value = %s, want %s", got, want)
+	}
+}
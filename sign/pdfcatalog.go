@@ -43,6 +43,9 @@ func (context *SignContext) createCatalog() ([]byte, error) {
 
 	// Copy over existing catalog entries except for type and AcroForum
 	for _, key := range root.Keys() {
+		if key == "Perms" && context.permsEntry() != "" {
+			continue // written below
+		}
 		if key != "Type" && key != "AcroForm" {
 			_, _ = fmt.Fprintf(&catalog_buffer, "  /%s ", key)
 			if err := context.serializeCatalogEntry(&catalog_buffer, rootPtr.GetID(), root.Key(key), catalogObjID); err != nil {
@@ -52,6 +55,23 @@ func (context *SignContext) createCatalog() ([]byte, error) {
 		}
 	}
 
+	// The permissions dictionary (Table 263) refers viewers to the
+	// signature dictionary of a certification or usage rights signature.
+	if entry := context.permsEntry(); entry != "" {
+		catalog_buffer.WriteString("  /Perms <<")
+		perms := root.Key("Perms")
+		for _, key := range perms.Keys() {
+			if key == entry {
+				continue
+			}
+			_, _ = fmt.Fprintf(&catalog_buffer, " /%s ", key)
+			if err := context.serializeCatalogEntry(&catalog_buffer, rootPtr.GetID(), perms.Key(key), catalogObjID); err != nil {
+				return nil, err
+			}
+		}
+		_, _ = fmt.Fprintf(&catalog_buffer, " /%s %d 0 R >>\n", entry, context.SignData.objectId)
+	}
+
 	// Start the AcroForm dictionary. If the input PDF already has an AcroForm
 	// preserve its entries (including existing form fields) and append the new
 	// visual signature field instead of overwriting the whole dictionary.
@@ -282,3 +302,19 @@ func (context *SignContext) serializeCatalogEntry(w io.Writer, rootObjId uint32,
 	}
 	return nil
 }
+
+// permsEntry returns the key of the catalog permissions dictionary that
+// refers to the new signature, or "" when it is not listed there. A
+// document is certified by its first certification signature only.
+func (context *SignContext) permsEntry() string {
+	switch context.SignData.Signature.CertType {
+	case CertificationSignature:
+		if !context.PDFReader.Trailer().Key("Root").Key("Perms").Key("DocMDP").IsNull() {
+			return ""
+		}
+		return "DocMDP"
+	case UsageRightsSignature:
+		return "UR3"
+	}
+	return ""
+}
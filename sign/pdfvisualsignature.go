@@ -22,6 +22,11 @@ const (
 	AnnotationFlagLockedContents = 1 << 9
 )
 
+// signatureFieldName returns the name of the new signature field.
+func (context *SignContext) signatureFieldName() string {
+	return "Signature " + strconv.Itoa(len(context.existingSignatures)+1)
+}
+
 // createVisualSignature creates a visual signature field in a PDF document.
 // visible: determines if the signature field should be visible or not.
 // pageNumber: the page number where the signature should be placed.
@@ -37,29 +42,6 @@ func (context *SignContext) createVisualSignature(visible bool, pageNumber uint3
 	// Specify the annotation subtype as a widget.
 	visual_signature.WriteString("  /Subtype /Widget\n")
 
-	if visible {
-		// Set the position and size of the signature field if visible.
-		visual_signature.WriteString(fmt.Sprintf("  /Rect [%f %f %f %f]\n", rect[0], rect[1], rect[2], rect[3]))
-
-		appearance, err := context.createAppearance(rect)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create appearance: %w", err)
-		}
-
-		appearanceObjectId, err := context.addObject(appearance)
-		if err != nil {
-			return nil, fmt.Errorf("failed to add appearance object: %w", err)
-		}
-
-		// An appearance dictionary specifying how the annotation
-		// shall be presented visually on the page (see 12.5.5, "Appearance streams").
-		visual_signature.WriteString(fmt.Sprintf("  /AP << /N %d 0 R >>\n", appearanceObjectId))
-
-	} else {
-		// Set the rectangle to zero if the signature is invisible.
-		visual_signature.WriteString("  /Rect [0 0 0 0]\n")
-	}
-
 	// Retrieve the root object from the PDF trailer.
 	root := context.PDFReader.Trailer().Key("Root")
 	// Get all keys from the root object.
@@ -78,13 +60,54 @@ func (context *SignContext) createVisualSignature(visible bool, pageNumber uint3
 	// Store the root object reference in the catalog data.
 	context.CatalogData.RootString = strconv.Itoa(int(rootPtr.GetID())) + " " + strconv.Itoa(int(rootPtr.GetGen())) + " R"
 
+	var page pdf.Value
 	if found_pages {
 		// Find the page object by its number.
-		page, err := findPageByNumber(root.Key("Pages"), pageNumber)
+		var err error
+		page, err = findPageByNumber(root.Key("Pages"), pageNumber)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	if visible {
+		// Keep the rectangle on the visible part of the page and note the
+		// page rotation so the appearance can be drawn upright.
+		rotate := 0
+		if found_pages {
+			geometry, err := getPageGeometry(page)
+			if err != nil {
+				return nil, err
+			}
+			if rect, err = geometry.clamp(rect); err != nil {
+				return nil, err
+			}
+			rotate = geometry.rotate
+		}
+
+		// Set the position and size of the signature field if visible.
+		visual_signature.WriteString(fmt.Sprintf("  /Rect [%f %f %f %f]\n", rect[0], rect[1], rect[2], rect[3]))
+
+		appearance, err := context.createAppearance(rect, rotate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create appearance: %w", err)
+		}
+
+		appearanceObjectId, err := context.addObject(appearance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add appearance object: %w", err)
+		}
+
+		// An appearance dictionary specifying how the annotation
+		// shall be presented visually on the page (see 12.5.5, "Appearance streams").
+		visual_signature.WriteString(fmt.Sprintf("  /AP << /N %d 0 R >>\n", appearanceObjectId))
+
+	} else {
+		// Set the rectangle to zero if the signature is invisible.
+		visual_signature.WriteString("  /Rect [0 0 0 0]\n")
+	}
 
+	if found_pages {
 		// Get the pointer to the page object.
 		page_ptr := page.GetPtr()
 
@@ -105,7 +128,7 @@ func (context *SignContext) createVisualSignature(visible bool, pageNumber uint3
 	// The visual signature object will be the next object added by the caller.
 	// Use getNextObjectID() to predict its ID for string encryption.
 	vsObjID := context.getNextObjectID()
-	titleStr, err := context.encryptPdfString(vsObjID, "Signature "+strconv.Itoa(len(context.existingSignatures)+1))
+	titleStr, err := context.encryptPdfString(vsObjID, context.signatureFieldName())
 	if err != nil {
 		return nil, err
 	}
@@ -229,3 +252,125 @@ func findPageByNumberRec(pages pdf.Value, pageNumber uint32) (pdf.Value, uint32,
 	}
 	return pdf.Value{}, pageNumber, fmt.Errorf("page number %d not found", pageNumber)
 }
+
+// signaturePages returns the pages a visible signature is shown on when it
+// spans several pages, or nil for a single widget on Appearance.Page.
+func (context *SignContext) signaturePages() ([]uint32, error) {
+	app := context.SignData.Appearance
+	if !app.AllPages && len(app.Pages) == 0 {
+		return nil, nil
+	}
+	if app.Anchor != nil {
+		return nil, fmt.Errorf("an anchored signature cannot be shown on several pages")
+	}
+
+	numPages := uint32(context.PDFReader.NumPage())
+	if app.AllPages {
+		pages := make([]uint32, numPages)
+		for i := range pages {
+			pages[i] = uint32(i + 1)
+		}
+		return pages, nil
+	}
+
+	seen := make(map[uint32]bool, len(app.Pages))
+	var pages []uint32
+	for _, p := range app.Pages {
+		if p < 1 || p > numPages {
+			return nil, fmt.Errorf("page number %d not found, document has %d pages", p, numPages)
+		}
+		if !seen[p] {
+			seen[p] = true
+			pages = append(pages, p)
+		}
+	}
+	return pages, nil
+}
+
+// createMultiPageVisualSignature creates a signature field whose widget
+// annotations show the appearance on each of pages. The appearances are
+// written immediately; the field is returned and the widgets are kept in
+// VisualSignData.widgets, to be written right after the field so their IDs
+// follow it.
+func (context *SignContext) createMultiPageVisualSignature(pages []uint32) ([]byte, error) {
+	root := context.PDFReader.Trailer().Key("Root")
+	rootPtr := root.GetPtr()
+	context.CatalogData.RootString = strconv.Itoa(int(rootPtr.GetID())) + " " + strconv.Itoa(int(rootPtr.GetGen())) + " R"
+
+	app := context.SignData.Appearance
+	widgets := make([]signatureWidget, 0, len(pages))
+	appearanceIds := make([]uint32, 0, len(pages))
+	rects := make([][4]float64, 0, len(pages))
+	for _, pageNumber := range pages {
+		page, err := findPageByNumber(root.Key("Pages"), pageNumber)
+		if err != nil {
+			return nil, err
+		}
+		geometry, err := getPageGeometry(page)
+		if err != nil {
+			return nil, err
+		}
+
+		rect := [4]float64{app.LowerLeftX, app.LowerLeftY, app.UpperRightX, app.UpperRightY}
+		if app.Placement != nil {
+			if rect, err = app.Placement.rect(geometry); err != nil {
+				return nil, err
+			}
+		}
+		if rect, err = geometry.clamp(rect); err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNumber, err)
+		}
+
+		appearance, err := context.createAppearance(rect, geometry.rotate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create appearance: %w", err)
+		}
+		appearanceObjectId, err := context.addObject(appearance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add appearance object: %w", err)
+		}
+
+		widgets = append(widgets, signatureWidget{pageNumber: pageNumber, pageObjectId: page.GetPtr().GetID()})
+		appearanceIds = append(appearanceIds, appearanceObjectId)
+		rects = append(rects, rect)
+	}
+
+	// The field is the next object and its widgets follow it.
+	fieldObjID := context.getNextObjectID()
+	for i := range widgets {
+		w := &widgets[i]
+		w.objectId = fieldObjID + uint32(i) + 1
+		rect := rects[i]
+
+		var widget bytes.Buffer
+		widget.WriteString("<<\n")
+		widget.WriteString("  /Type /Annot\n")
+		widget.WriteString("  /Subtype /Widget\n")
+		widget.WriteString(fmt.Sprintf("  /Rect [%f %f %f %f]\n", rect[0], rect[1], rect[2], rect[3]))
+		widget.WriteString(fmt.Sprintf("  /AP << /N %d 0 R >>\n", appearanceIds[i]))
+		widget.WriteString(fmt.Sprintf("  /P %d 0 R\n", w.pageObjectId))
+		widget.WriteString(fmt.Sprintf("  /F %d\n", AnnotationFlagPrint|AnnotationFlagLocked))
+		widget.WriteString(fmt.Sprintf("  /Parent %d 0 R\n", fieldObjID))
+		widget.WriteString(">>\n")
+		w.object = widget.Bytes()
+	}
+	context.VisualSignData.widgets = widgets
+
+	var field bytes.Buffer
+	field.WriteString("<<\n")
+	field.WriteString("  /FT /Sig\n")
+	titleStr, err := context.encryptPdfString(fieldObjID, context.signatureFieldName())
+	if err != nil {
+		return nil, err
+	}
+	field.WriteString(fmt.Sprintf("  /T %s\n", titleStr))
+	field.WriteString(fmt.Sprintf("  /V %d 0 R\n", context.SignData.objectId))
+	field.WriteString("  /Kids [")
+	for _, w := range widgets {
+		field.WriteString(fmt.Sprintf(" %d 0 R", w.objectId))
+	}
+	field.WriteString(" ]\n")
+	field.WriteString(">>\n")
+
+	return field.Bytes(), nil
+}
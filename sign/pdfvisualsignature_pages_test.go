@@ -0,0 +1,91 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignPDFMultiPageAppearance(t *testing.T) {
+	cert, pkey := loadCertificateAndKey(t)
+	input := "../testfiles/gen_pdf16_xref_table_3pages.pdf"
+	image, err := os.ReadFile("../testfiles/pdfsign-signature.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signData := SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "John Doe"},
+			CertType: ApprovalSignature,
+		},
+		Appearance: Appearance{
+			Visible:   true,
+			Pages:     []uint32{1, 3, 1},
+			Image:     image,
+			Placement: &Placement{Corner: CornerBottomRight, MarginX: Mm(10), MarginY: Mm(10), Width: Mm(40), Height: Mm(15)},
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	}
+	first := filepath.Join(t.TempDir(), "first.pdf")
+	if _, err := SignFile(input, first, signData); err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+
+	out, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(out, []byte("/Subtype /Image")); n != 1 {
+		t.Errorf("found %d image XObjects, want one shared by both appearances", n)
+	}
+
+	r := openTestReader(t, first)
+	var field uint32
+	for i, want := range []int{1, 0, 1} {
+		annots := r.Page(i + 1).V.Key("Annots")
+		if annots.Len() != want {
+			t.Fatalf("page %d has %d annotations, want %d", i+1, annots.Len(), want)
+		}
+		if want == 0 {
+			continue
+		}
+		widget := annots.Index(0)
+		parent := widget.Key("Parent")
+		if widget.Key("Subtype").Name() != "Widget" || widget.Key("AP").Key("N").IsNull() || parent.Key("FT").Name() != "Sig" {
+			t.Errorf("page %d: unexpected widget %v", i+1, widget)
+		}
+		if field != 0 && parent.GetPtr().GetID() != field {
+			t.Errorf("page %d: widget belongs to field %d, want %d", i+1, parent.GetPtr().GetID(), field)
+		}
+		field = parent.GetPtr().GetID()
+		if parent.Key("Kids").Len() != 2 || parent.Key("V").IsNull() {
+			t.Errorf("field %v should have two kids and a value", parent)
+		}
+	}
+
+	// A second signature on every page must leave the first one intact.
+	signData.Appearance.Pages = nil
+	signData.Appearance.AllPages = true
+	signData.Appearance.Placement.Corner = CornerBottomLeft
+	second := filepath.Join(t.TempDir(), "second.pdf")
+	if _, err := SignFile(first, second, signData); err != nil {
+		t.Fatalf("SignFile() second signature error = %v", err)
+	}
+	f, err := os.Open(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	verifyAllSignaturesValid(t, f, 2)
+
+	signData.Appearance.Pages = []uint32{4}
+	signData.Appearance.AllPages = false
+	if _, err := SignFile(input, filepath.Join(t.TempDir(), "bad.pdf"), signData); err == nil {
+		t.Error("SignFile() with a missing page: error = nil")
+	}
+}
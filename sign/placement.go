@@ -0,0 +1,283 @@
+package sign
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/digitorus/pdf"
+)
+
+// Units of a Length.
+const (
+	UnitPoint      = "pt" // 1/72 inch; the zero Unit also means points
+	UnitMillimetre = "mm"
+	UnitInch       = "in"
+	UnitPercent    = "%"
+)
+
+// Corners a Placement is measured from, as the page is displayed.
+const (
+	CornerBottomLeft  = "bottom-left"
+	CornerBottomRight = "bottom-right"
+	CornerTopLeft     = "top-left"
+	CornerTopRight    = "top-right"
+)
+
+// Length is a distance on the page. Percentages are relative to the width
+// of the visible page area for horizontal lengths and to its height for
+// vertical lengths.
+type Length struct {
+	Value float64
+	Unit  string
+}
+
+// Pt returns a length in points.
+func Pt(v float64) Length { return Length{Value: v, Unit: UnitPoint} }
+
+// Mm returns a length in millimetres.
+func Mm(v float64) Length { return Length{Value: v, Unit: UnitMillimetre} }
+
+// Percent returns a length relative to the visible page size.
+func Percent(v float64) Length { return Length{Value: v, Unit: UnitPercent} }
+
+// ParseLength parses a length such as "72", "72pt", "20mm", "1in" or "25%".
+func ParseLength(s string) (Length, error) {
+	s = strings.TrimSpace(s)
+	unit := UnitPoint
+	for _, u := range []string{UnitPoint, UnitMillimetre, UnitInch, UnitPercent} {
+		if strings.HasSuffix(s, u) {
+			unit = u
+			s = strings.TrimSpace(strings.TrimSuffix(s, u))
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Length{}, fmt.Errorf("invalid length %q", s)
+	}
+	return Length{Value: v, Unit: unit}, nil
+}
+
+// points converts l to points; reference is the page size percentages are
+// relative to.
+func (l Length) points(reference float64) (float64, error) {
+	switch l.Unit {
+	case "", UnitPoint:
+		return l.Value, nil
+	case UnitMillimetre:
+		return l.Value * 72 / 25.4, nil
+	case UnitInch:
+		return l.Value * 72, nil
+	case UnitPercent:
+		return l.Value * reference / 100, nil
+	default:
+		return 0, fmt.Errorf("unknown length unit %q", l.Unit)
+	}
+}
+
+// Placement positions a visible signature relative to the visible area of
+// the page (its CropBox) as the page is displayed, taking /Rotate into
+// account.
+type Placement struct {
+	// Corner is the page corner the margins are measured from
+	// (default CornerBottomLeft).
+	Corner string
+	// MarginX and MarginY are the distances between the corner and the
+	// nearest edges of the signature.
+	MarginX Length
+	MarginY Length
+	// Width and Height are the size of the signature.
+	Width  Length
+	Height Length
+}
+
+// pageGeometry describes how a page is displayed.
+type pageGeometry struct {
+	box    [4]float64 // visible area (CropBox within MediaBox) in default user space
+	rotate int        // clockwise display rotation: 0, 90, 180 or 270
+}
+
+// getPageGeometry reads the inheritable MediaBox, CropBox and Rotate
+// entries of page.
+func getPageGeometry(page pdf.Value) (pageGeometry, error) {
+	media, ok := readPageBox(inheritedKey(page, "MediaBox"))
+	if !ok {
+		// Letter size is the conventional default for a missing MediaBox.
+		media = [4]float64{0, 0, 612, 792}
+	}
+	g := pageGeometry{box: media}
+	if crop, ok := readPageBox(inheritedKey(page, "CropBox")); ok {
+		g.box = [4]float64{
+			maxFloat(crop[0], media[0]), maxFloat(crop[1], media[1]),
+			minFloat(crop[2], media[2]), minFloat(crop[3], media[3]),
+		}
+		if g.box[0] >= g.box[2] || g.box[1] >= g.box[3] {
+			return g, fmt.Errorf("page CropBox lies outside its MediaBox")
+		}
+	}
+
+	rotate := int(inheritedKey(page, "Rotate").Int64())
+	if rotate%90 != 0 {
+		return g, fmt.Errorf("invalid page rotation %d", rotate)
+	}
+	g.rotate = ((rotate % 360) + 360) % 360
+	return g, nil
+}
+
+// inheritedKey returns key from node or the nearest ancestor in its page
+// or field tree that defines it.
+func inheritedKey(node pdf.Value, key string) pdf.Value {
+	for depth := 0; !node.IsNull() && depth < 64; node, depth = node.Key("Parent"), depth+1 {
+		if v := node.Key(key); !v.IsNull() {
+			return v
+		}
+	}
+	return pdf.Value{}
+}
+
+// readPageBox reads a rectangle array, normalising its corners.
+func readPageBox(v pdf.Value) ([4]float64, bool) {
+	if v.Kind() != pdf.Array || v.Len() != 4 {
+		return [4]float64{}, false
+	}
+	var r [4]float64
+	for i := range r {
+		r[i] = v.Index(i).Float64()
+	}
+	return [4]float64{minFloat(r[0], r[2]), minFloat(r[1], r[3]), maxFloat(r[0], r[2]), maxFloat(r[1], r[3])}, true
+}
+
+// displaySize returns the width and height of the visible area as displayed.
+func (g pageGeometry) displaySize() (float64, float64) {
+	w, h := g.box[2]-g.box[0], g.box[3]-g.box[1]
+	if g.rotate == 90 || g.rotate == 270 {
+		return h, w
+	}
+	return w, h
+}
+
+// toUserSpace converts a rectangle in display coordinates (origin at the
+// bottom-left corner of the displayed visible area) to default user space.
+func (g pageGeometry) toUserSpace(r [4]float64) [4]float64 {
+	x0, y0, x1, y1 := g.box[0], g.box[1], g.box[2], g.box[3]
+	point := func(dx, dy float64) (float64, float64) {
+		switch g.rotate {
+		case 90:
+			return x1 - dy, y0 + dx
+		case 180:
+			return x1 - dx, y1 - dy
+		case 270:
+			return x0 + dy, y1 - dx
+		default:
+			return x0 + dx, y0 + dy
+		}
+	}
+	ax, ay := point(r[0], r[1])
+	bx, by := point(r[2], r[3])
+	return [4]float64{minFloat(ax, bx), minFloat(ay, by), maxFloat(ax, bx), maxFloat(ay, by)}
+}
+
+// clamp restricts rect to the visible area of the page.
+func (g pageGeometry) clamp(rect [4]float64) ([4]float64, error) {
+	clamped := [4]float64{
+		maxFloat(rect[0], g.box[0]), maxFloat(rect[1], g.box[1]),
+		minFloat(rect[2], g.box[2]), minFloat(rect[3], g.box[3]),
+	}
+	if clamped[0] >= clamped[2] || clamped[1] >= clamped[3] {
+		return rect, fmt.Errorf("signature rectangle [%.2f %.2f %.2f %.2f] lies outside the visible page area [%.2f %.2f %.2f %.2f]",
+			rect[0], rect[1], rect[2], rect[3], g.box[0], g.box[1], g.box[2], g.box[3])
+	}
+	return clamped, nil
+}
+
+// rect returns the signature rectangle in default user space for p.
+func (p Placement) rect(g pageGeometry) ([4]float64, error) {
+	pageW, pageH := g.displaySize()
+	var v [4]float64
+	for i, l := range []struct {
+		length    Length
+		reference float64
+	}{{p.MarginX, pageW}, {p.MarginY, pageH}, {p.Width, pageW}, {p.Height, pageH}} {
+		points, err := l.length.points(l.reference)
+		if err != nil {
+			return [4]float64{}, err
+		}
+		v[i] = points
+	}
+	marginX, marginY, w, h := v[0], v[1], v[2], v[3]
+	if w <= 0 || h <= 0 {
+		return [4]float64{}, fmt.Errorf("placement width and height must be greater than 0")
+	}
+
+	x, y := marginX, marginY
+	switch p.Corner {
+	case "", CornerBottomLeft:
+	case CornerBottomRight:
+		x = pageW - marginX - w
+	case CornerTopLeft:
+		y = pageH - marginY - h
+	case CornerTopRight:
+		x, y = pageW-marginX-w, pageH-marginY-h
+	default:
+		return [4]float64{}, fmt.Errorf("invalid placement corner %q", p.Corner)
+	}
+	return g.toUserSpace([4]float64{x, y, x + w, y + h}), nil
+}
+
+// resolvePlacement sets the rectangle of the appearance from its
+// placement. It does nothing when no placement is configured or when the
+// signature is shown on several pages, where each page is placed
+// separately.
+func (context *SignContext) resolvePlacement() error {
+	app := &context.SignData.Appearance
+	if app.Placement == nil || app.AllPages || len(app.Pages) > 0 {
+		return nil
+	}
+	if app.Anchor != nil {
+		return fmt.Errorf("appearance placement and anchor cannot be combined")
+	}
+	page, err := findPageByNumber(context.PDFReader.Trailer().Key("Root").Key("Pages"), app.Page)
+	if err != nil {
+		return err
+	}
+	g, err := getPageGeometry(page)
+	if err != nil {
+		return err
+	}
+	rect, err := app.Placement.rect(g)
+	if err != nil {
+		return err
+	}
+	app.LowerLeftX, app.LowerLeftY, app.UpperRightX, app.UpperRightY = rect[0], rect[1], rect[2], rect[3]
+	return nil
+}
+
+// rotationMatrix returns the appearance /Matrix that keeps the appearance
+// upright on a page displayed with the given clockwise rotation.
+func rotationMatrix(rotate int) string {
+	switch rotate {
+	case 90:
+		return "[0 1 -1 0 0 0]"
+	case 180:
+		return "[-1 0 0 -1 0 0]"
+	case 270:
+		return "[0 -1 1 0 0 0]"
+	default:
+		return "[1 0 0 1 0 0]"
+	}
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,221 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeOnePagePDF writes a single page PDF whose page tree node and page
+// dictionaries contain the given extra entries.
+func writeOnePagePDF(t *testing.T, pagesExtra, pageExtra string) string {
+	t.Helper()
+	content := "BT /F1 12 Tf 72 72 Td (Hello) Tj ET"
+	return writeTestPDF(t, []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [3 0 R] /Count 1 %s >>", pagesExtra),
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> %s >>", pageExtra),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	})
+}
+
+// writeTestPDF writes a PDF with the given objects, numbered from 1, and
+// object 1 as the catalog.
+func writeTestPDF(t *testing.T, objects []string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(t.TempDir(), "test.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseLength(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Length
+	}{
+		{"72", Pt(72)},
+		{"12.5pt", Pt(12.5)},
+		{"20mm", Mm(20)},
+		{" 1 in", Length{Value: 1, Unit: UnitInch}},
+		{"25%", Percent(25)},
+	}
+	for _, tt := range tests {
+		got, err := ParseLength(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLength(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "mm", "ten pt", "5px"} {
+		if _, err := ParseLength(in); err == nil {
+			t.Errorf("ParseLength(%q) error = nil", in)
+		}
+	}
+	if got, _ := Mm(25.4).points(0); math.Abs(got-72) > 1e-9 {
+		t.Errorf("25.4mm = %vpt, want 72", got)
+	}
+}
+
+func TestPlacementRect(t *testing.T) {
+	portrait := pageGeometry{box: [4]float64{0, 0, 600, 800}}
+	tests := []struct {
+		name      string
+		geometry  pageGeometry
+		placement Placement
+		want      [4]float64
+	}{
+		{
+			name:      "bottom-left",
+			geometry:  portrait,
+			placement: Placement{MarginX: Pt(10), MarginY: Pt(20), Width: Pt(100), Height: Pt(50)},
+			want:      [4]float64{10, 20, 110, 70},
+		},
+		{
+			name:      "top-right percent",
+			geometry:  portrait,
+			placement: Placement{Corner: CornerTopRight, MarginX: Pt(10), MarginY: Percent(5), Width: Percent(20), Height: Pt(40)},
+			want:      [4]float64{470, 720, 590, 760},
+		},
+		{
+			name:      "cropbox offset",
+			geometry:  pageGeometry{box: [4]float64{50, 100, 550, 700}},
+			placement: Placement{Corner: CornerBottomRight, Width: Pt(100), Height: Pt(50)},
+			want:      [4]float64{450, 100, 550, 150},
+		},
+		{
+			// Displayed as 800x600; the displayed bottom-left corner is the
+			// bottom-right corner of user space.
+			name:      "rotate 90",
+			geometry:  pageGeometry{box: [4]float64{0, 0, 600, 800}, rotate: 90},
+			placement: Placement{MarginX: Pt(10), MarginY: Pt(10), Width: Pt(100), Height: Pt(50)},
+			want:      [4]float64{540, 10, 590, 110},
+		},
+		{
+			name:      "rotate 180",
+			geometry:  pageGeometry{box: [4]float64{0, 0, 600, 800}, rotate: 180},
+			placement: Placement{MarginX: Pt(10), MarginY: Pt(10), Width: Pt(100), Height: Pt(50)},
+			want:      [4]float64{490, 740, 590, 790},
+		},
+		{
+			name:      "rotate 270",
+			geometry:  pageGeometry{box: [4]float64{0, 0, 600, 800}, rotate: 270},
+			placement: Placement{MarginX: Pt(10), MarginY: Pt(10), Width: Pt(100), Height: Pt(50)},
+			want:      [4]float64{10, 690, 60, 790},
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.placement.rect(tt.geometry)
+		if err != nil {
+			t.Errorf("%s: rect() error = %v", tt.name, err)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("%s: rect() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := (Placement{Corner: "middle", Width: Pt(1), Height: Pt(1)}).rect(portrait); err == nil {
+		t.Error("rect() with invalid corner: error = nil")
+	}
+	if _, err := (Placement{Width: Pt(100)}).rect(portrait); err == nil {
+		t.Error("rect() without height: error = nil")
+	}
+}
+
+func TestPageGeometryClamp(t *testing.T) {
+	g := pageGeometry{box: [4]float64{0, 0, 600, 800}}
+	if got, err := g.clamp([4]float64{500, -20, 700, 40}); err != nil || got != [4]float64{500, 0, 600, 40} {
+		t.Errorf("clamp() = %v, %v", got, err)
+	}
+	if _, err := g.clamp([4]float64{700, 0, 800, 40}); err == nil {
+		t.Error("clamp() outside the page: error = nil")
+	}
+}
+
+func TestSignPDFRotatedPage(t *testing.T) {
+	cert, pkey := loadCertificateAndKey(t)
+	// MediaBox and Rotate are inherited from the page tree node.
+	input := writeOnePagePDF(t, "/MediaBox [0 0 600 800] /Rotate 90", "/CropBox [0 50 600 800]")
+
+	r := openTestReader(t, input)
+	page, err := findPageByNumber(r.Trailer().Key("Root").Key("Pages"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := getPageGeometry(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.rotate != 90 || g.box != [4]float64{0, 50, 600, 800} {
+		t.Fatalf("getPageGeometry() = %+v", g)
+	}
+
+	output := filepath.Join(t.TempDir(), "signed.pdf")
+	_, err = SignFile(input, output, SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "John Doe"},
+			CertType: ApprovalSignature,
+		},
+		Appearance: Appearance{
+			Visible: true,
+			Placement: &Placement{
+				Corner:  CornerTopRight,
+				MarginX: Mm(10),
+				MarginY: Mm(10),
+				Width:   Pt(200),
+				Height:  Pt(60),
+			},
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	})
+	if err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	verifyAllSignaturesValid(t, f, 1)
+
+	out, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rotated 90° clockwise, the displayed top-right corner is the top-left
+	// corner of user space and the displayed width runs along user y.
+	margin := 10 * 72 / 25.4
+	want := fmt.Sprintf("/Rect [%f %f %f %f]", margin, 800-margin-200, margin+60, 800-margin)
+	for _, s := range []string{want, "/Matrix [0 1 -1 0 0 0]", "/BBox [0 0 200.000000 60.000000]"} {
+		if !strings.Contains(string(out), s) {
+			t.Errorf("signed file does not contain %s", s)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package sign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Version 1-M "HELLO WORLD" from ISO/IEC 18004, annex I.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestQRByteCapacity(t *testing.T) {
+	tests := []struct {
+		version int
+		level   qrLevel
+		want    int
+	}{
+		{1, qrL, 17}, {1, qrM, 14}, {1, qrQ, 11}, {1, qrH, 7},
+		{10, qrM, 213},
+		{40, qrL, 2953}, {40, qrM, 2331}, {40, qrQ, 1663}, {40, qrH, 1273},
+	}
+	for _, tt := range tests {
+		if got := qrByteCapacity(tt.version, tt.level); got != tt.want {
+			t.Errorf("qrByteCapacity(%d, %d) = %d, want %d", tt.version, tt.level, got, tt.want)
+		}
+	}
+}
+
+// readQRCodewords reads the codewords back from a symbol, undoing the mask
+// and the interleaving, and returns the data codewords.
+func readQRCodewords(qr *qrCode) []byte {
+	var raw []byte
+	var cur byte
+	n := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range qr.size {
+			for j := range 2 {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if qr.function[y][x] {
+					continue
+				}
+				cur <<= 1
+				if qr.modules[y][x] != qrMask(qr.mask, x, y) {
+					cur |= 1
+				}
+				if n++; n%8 == 0 {
+					raw = append(raw, cur)
+				}
+			}
+		}
+	}
+
+	numBlocks := qrNumBlocks[qr.level][qr.version]
+	eccLen := qrECCCodewordsPerBlock[qr.level][qr.version]
+	total := qrRawDataModules(qr.version) / 8
+	numShort := numBlocks - total%numBlocks
+	shortData := total/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range shortData + 1 {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, b...)
+	}
+	return data
+}
+
+func TestEncodeQR(t *testing.T) {
+	for _, content := range []string{
+		"hello",
+		"https://verify.example.com/check?doc=3a7bd3e2360a3d29eea436fcfb7e44c735d117c42d1c1835420b6b9942dd4f1b&sig=Signature%201",
+		strings.Repeat("0123456789", 120),
+	} {
+		for level := qrL; level <= qrH; level++ {
+			qr, err := encodeQR([]byte(content), level)
+			if err != nil {
+				t.Fatalf("encodeQR(%d bytes, %d) error = %v", len(content), level, err)
+			}
+			if qr.version > 1 && len(content) <= qrByteCapacity(qr.version-1, level) {
+				t.Errorf("encodeQR(%d bytes, %d) chose version %d, a smaller one fits", len(content), level, qr.version)
+			}
+
+			// Finder patterns: dark outer ring and centre, light inner ring.
+			for _, c := range [][2]int{{3, 3}, {qr.size - 4, 3}, {3, qr.size - 4}} {
+				if !qr.modules[c[1]][c[0]] || !qr.modules[c[1]-3][c[0]] || qr.modules[c[1]-2][c[0]] {
+					t.Errorf("version %d: no finder pattern at %v", qr.version, c)
+				}
+			}
+
+			// Format information next to the top-left finder pattern.
+			format := 0
+			for i := 14; i >= 9; i-- {
+				format = format<<1 | b2i(qr.modules[8][14-i])
+			}
+			format = format<<1 | b2i(qr.modules[8][7])
+			format = format<<1 | b2i(qr.modules[8][8])
+			format = format<<1 | b2i(qr.modules[7][8])
+			for i := 5; i >= 0; i-- {
+				format = format<<1 | b2i(qr.modules[i][8])
+			}
+			if want := qrFormatBits(level, qr.mask); format != want {
+				t.Errorf("version %d: format bits %015b, want %015b", qr.version, format, want)
+			}
+
+			want := qrDataCodewords([]byte(content), qr.version, level)
+			if got := readQRCodewords(qr); !bytes.Equal(got[:len(want)], want) {
+				t.Errorf("version %d level %d: data codewords do not round-trip", qr.version, level)
+			}
+		}
+	}
+
+	if _, err := encodeQR(make([]byte, 2954), qrL); err == nil {
+		t.Error("encodeQR() with too much data: error = nil")
+	}
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/digitorus/pdf"
+)
+
+// repairInput rewrites a document that was repaired when it was opened (see
+// SignData.Recover) as a new file, and opens the result for signing. The
+// objects that cannot be read are left out and returned in dropped.
+func repairInput(rdr *pdf.Reader) (input *bytes.Reader, repaired *pdf.Reader, size int64, dropped []uint32, err error) {
+	if ReadPermissions(rdr).Signed {
+		return nil, nil, 0, nil, errors.New("repair: the document is signed and rewriting it would invalidate its signatures")
+	}
+	if rdr.EncryptionKey() != nil {
+		return nil, nil, 0, nil, errors.New("repair: rewriting a repaired encrypted document is not supported; set SignData.Encryption to encrypt it anew")
+	}
+	var buf bytes.Buffer
+	dropped, err = (&SignContext{}).writeFullSave(&buf, rdr, rdr.PDFVersion, "")
+	if err != nil {
+		return nil, nil, 0, nil, fmt.Errorf("repair: %w", err)
+	}
+	input = bytes.NewReader(buf.Bytes())
+	repaired, err = pdf.NewReader(input, input.Size())
+	if err != nil {
+		return nil, nil, 0, nil, fmt.Errorf("repair: re-open rewritten document: %w", err)
+	}
+	return input, repaired, input.Size(), dropped, nil
+}
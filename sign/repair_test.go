@@ -0,0 +1,103 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pdf"
+)
+
+// breakStartxref writes input with its final startxref pointing past the end
+// of the file.
+func breakStartxref(t *testing.T, input string) string {
+	t.Helper()
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.LastIndex(data, []byte("startxref"))
+	broken := filepath.Join(t.TempDir(), "broken.pdf")
+	if err := os.WriteFile(broken, append(data[:i:i], "startxref\n99999999\n%%EOF\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return broken
+}
+
+func TestSignRecover(t *testing.T) {
+	cert, key := loadCertificateAndKey(t)
+	signData := SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "Recovered", Date: time.Now()},
+			CertType: ApprovalSignature,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          key,
+		Certificate:     cert,
+	}
+
+	broken := breakStartxref(t, "../testfiles/testfile20.pdf")
+	output := filepath.Join(t.TempDir(), "signed.pdf")
+	if _, err := SignFile(broken, output, signData); err == nil {
+		t.Fatal("SignFile() of a broken PDF without recovery: error = nil")
+	}
+
+	signData.Recover = true
+	info, err := SignFile(broken, output, signData)
+	if err != nil {
+		t.Fatalf("SignFile() with recovery: error = %v", err)
+	}
+	if len(info.Repairs) == 0 {
+		t.Error("SignFile() with recovery: no repairs reported")
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	verifyAllSignaturesValid(t, f, 1)
+
+	// Rewriting a signed document would invalidate its signatures.
+	if _, err := SignFile(breakStartxref(t, "../testfiles/testfile30.pdf"), output, signData); err == nil {
+		t.Error("SignFile() of a repaired signed PDF: error = nil")
+	}
+}
+
+func TestSignLTVRecoverEncrypt(t *testing.T) {
+	cert, key := loadCertificateAndKey(t)
+	broken := breakStartxref(t, "../testfiles/testfile20.pdf")
+	data, err := os.ReadFile(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdr, err := pdf.NewReaderOptions(bytes.NewReader(data), int64(len(data)), pdf.ReaderOptions{Recover: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The document is encrypted before it is signed; the repairs made to
+	// read it are still reported.
+	var out bytes.Buffer
+	info, err := SignLTV(bytes.NewReader(data), &out, rdr, int64(len(data)), SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "Recovered", Date: time.Now()},
+			CertType: ApprovalSignature,
+		},
+		DigestAlgorithm:    crypto.SHA256,
+		Signer:             key,
+		Certificate:        cert,
+		CertificateChains:  [][]*x509.Certificate{{cert}},
+		RevocationFunction: mockRevocationFunction,
+		Encryption:         &Encryption{OwnerPassword: "owner"},
+	})
+	if err != nil {
+		t.Fatalf("SignLTV() error = %v", err)
+	}
+	if len(info.Repairs) == 0 {
+		t.Error("SignLTV() of an encrypted repaired document: no repairs reported")
+	}
+}
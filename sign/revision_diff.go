@@ -0,0 +1,526 @@
+package sign
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/digitorus/pdf"
+)
+
+// Kinds of change of a RevisionDiff entry.
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// maxDiffLines limits the number of text lines of a page compared line by
+// line; longer pages are reported as wholly replaced.
+const maxDiffLines = 4000
+
+// RevisionDiff describes the changes from one revision of a document to a
+// later one, as reported by DiffRevisions and DiffSignedRevision.
+type RevisionDiff struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Signature is the signature field whose signed revision is From.
+	Signature string `json:"signature,omitempty"`
+
+	Added    []uint32 `json:"added_objects"`
+	Removed  []uint32 `json:"removed_objects"`
+	Modified []uint32 `json:"modified_objects"`
+
+	Catalog     []EntryChange      `json:"catalog,omitempty"`
+	Pages       []PageChange       `json:"pages,omitempty"`
+	Annotations []AnnotationChange `json:"annotations,omitempty"`
+	Fields      []FieldChange      `json:"fields,omitempty"`
+}
+
+// EntryChange is a changed entry of the document catalog. Old and New are
+// the entries as written, with indirect objects as references.
+type EntryChange struct {
+	Key    string `json:"key"`
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// PageChange is an added, removed or modified page. A page is modified
+// when its dictionary or its content streams changed.
+type PageChange struct {
+	Page   int    `json:"page"`
+	Change string `json:"change"`
+	// ContentChanged reports whether the content streams changed;
+	// RemovedText and AddedText are the extracted text lines that differ.
+	ContentChanged bool     `json:"content_changed,omitempty"`
+	RemovedText    []string `json:"removed_text,omitempty"`
+	AddedText      []string `json:"added_text,omitempty"`
+}
+
+// AnnotationChange is an annotation added to, removed from or modified on
+// a page.
+type AnnotationChange struct {
+	Page    int        `json:"page"`
+	Change  string     `json:"change"`
+	Object  uint32     `json:"object"`
+	Subtype string     `json:"subtype"`
+	Rect    [4]float64 `json:"rect"`
+	// Field is the fully qualified name of the field of a widget.
+	Field string `json:"field,omitempty"`
+}
+
+// FieldChange is a form field that was added, removed or whose value
+// changed.
+type FieldChange struct {
+	Name   string `json:"name"`
+	Type   string `json:"type,omitempty"`
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// DiffSignedRevision describes the changes made to a document after the
+// signature of the field named field: from the revision the signature
+// covers to the last revision.
+func DiffSignedRevision(input io.ReaderAt, size int64, field string) (*RevisionDiff, error) {
+	history, err := Revisions(input, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, sr := range history.Signatures {
+		if sr.Field != field {
+			continue
+		}
+		if sr.Revision == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrRangeNotAtRevisionEnd, sr.Problem)
+		}
+		diff, err := history.diff(input, sr.Revision, len(history.Revisions))
+		if err != nil {
+			return nil, err
+		}
+		diff.Signature = field
+		return diff, nil
+	}
+	return nil, fmt.Errorf("%w: no signed signature field %q", ErrFormFieldNotFound, field)
+}
+
+// DiffRevisions describes the changes from revision from to revision to,
+// numbered as by Revisions, or to the last revision when to is 0.
+func DiffRevisions(input io.ReaderAt, size int64, from, to int) (*RevisionDiff, error) {
+	history, err := Revisions(input, size)
+	if err != nil {
+		return nil, err
+	}
+	return history.diff(input, from, to)
+}
+
+func (history *RevisionHistory) diff(input io.ReaderAt, from, to int) (*RevisionDiff, error) {
+	n := len(history.Revisions)
+	if to == 0 {
+		to = n
+	}
+	if from < 1 || to > n || from > to {
+		return nil, fmt.Errorf("invalid revisions %d to %d: the document has %d revisions", from, to, n)
+	}
+	open := func(number int) (*pdf.Reader, error) {
+		end := history.Revisions[number-1].End
+		return pdf.NewReader(io.NewSectionReader(input, 0, end), end)
+	}
+	old, err := open(from)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := open(to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &RevisionDiff{From: from, To: to, Added: []uint32{}, Removed: []uint32{}, Modified: []uint32{}}
+	modified := d.diffObjects(old, cur)
+	d.diffCatalog(old.Trailer().Key("Root"), cur.Trailer().Key("Root"))
+	d.diffPages(old, cur, modified)
+	d.diffFields(old, cur)
+	return d, nil
+}
+
+// diffObjects fills the added, removed and modified objects and returns
+// the changed ones. Objects with a new cross-reference entry are compared
+// by value, so rewritten but identical objects are not reported.
+func (d *RevisionDiff) diffObjects(old, cur *pdf.Reader) map[uint32]bool {
+	before := make(map[uint32]xrefState)
+	changedObjects(before, old)
+	after := make(map[uint32]xrefState)
+	changed, _ := changedObjects(after, cur)
+
+	result := make(map[uint32]bool)
+	for _, id := range changed {
+		prev, ok := before[id]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, id)
+		case prev == after[id]:
+			continue
+		default:
+			a, errA := old.GetObject(id)
+			b, errB := cur.GetObject(id)
+			if errA == nil && errB == nil && objectString(a) == objectString(b) {
+				continue
+			}
+			d.Modified = append(d.Modified, id)
+		}
+		result[id] = true
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			d.Removed = append(d.Removed, id)
+			result[id] = true
+		}
+	}
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i] < d.Removed[j] })
+	return result
+}
+
+// objectString serializes an object for comparison: dictionaries and
+// arrays with references to indirect objects, and streams with a digest of
+// their data.
+func objectString(v pdf.Value) string {
+	if v.Kind() != pdf.Stream {
+		return v.String()
+	}
+	data, _ := v.RawData()
+	return fmt.Sprintf("%s stream %x", v.Header().String(), sha256.Sum256(data))
+}
+
+// entryString returns the entry v of the dictionary parent as written: a
+// reference when v is an indirect object.
+func entryString(parent, v pdf.Value) string {
+	if p := v.GetPtr(); p.GetID() != 0 && p != parent.GetPtr() {
+		return fmt.Sprintf("%d %d R", p.GetID(), p.GetGen())
+	}
+	return objectString(v)
+}
+
+func (d *RevisionDiff) diffCatalog(old, cur pdf.Value) {
+	keys := old.Keys()
+	for _, key := range cur.Keys() {
+		if !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c := EntryChange{Key: key}
+		if v := old.Key(key); !v.IsNull() {
+			c.Old = entryString(old, v)
+		}
+		if v := cur.Key(key); !v.IsNull() {
+			c.New = entryString(cur, v)
+		}
+		switch {
+		case c.Old == c.New:
+			continue
+		case c.Old == "":
+			c.Change = ChangeAdded
+		case c.New == "":
+			c.Change = ChangeRemoved
+		default:
+			c.Change = ChangeModified
+		}
+		d.Catalog = append(d.Catalog, c)
+	}
+}
+
+func (d *RevisionDiff) diffPages(old, cur *pdf.Reader, modified map[uint32]bool) {
+	oldPages, curPages := old.NumPage(), cur.NumPage()
+	curFields := widgetFields(cur)
+	oldFields := widgetFields(old)
+	for i := 1; i <= max(oldPages, curPages); i++ {
+		switch {
+		case i > curPages:
+			d.Pages = append(d.Pages, PageChange{Page: i, Change: ChangeRemoved})
+			continue
+		case i > oldPages:
+			p := cur.Page(i)
+			d.Pages = append(d.Pages, PageChange{Page: i, Change: ChangeAdded, ContentChanged: true, AddedText: textLines(p)})
+			d.diffAnnotations(i, pdf.Page{}, p, modified, oldFields, curFields)
+			continue
+		}
+
+		a, b := old.Page(i), cur.Page(i)
+		pc := PageChange{Page: i, Change: ChangeModified}
+		pc.ContentChanged = contentDigest(a) != contentDigest(b)
+		if pc.ContentChanged {
+			pc.RemovedText, pc.AddedText = diffLines(textLines(a), textLines(b))
+		}
+		id := b.V.GetPtr().GetID()
+		if pc.ContentChanged || id != a.V.GetPtr().GetID() || modified[id] {
+			d.Pages = append(d.Pages, pc)
+		}
+		d.diffAnnotations(i, a, b, modified, oldFields, curFields)
+	}
+}
+
+// contentDigest returns a digest of the decoded content streams of a page.
+func contentDigest(p pdf.Page) [sha256.Size]byte {
+	h := sha256.New()
+	contents := p.V.Key("Contents")
+	if contents.Kind() == pdf.Array {
+		for i := 0; i < contents.Len(); i++ {
+			_, _ = h.Write(contents.Index(i).Data())
+		}
+	} else {
+		_, _ = h.Write(contents.Data())
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// textLines returns the text of a page, one string per line in content
+// stream order. Characters are on a new line when the baseline moves by
+// more than half their size, and separated by a space when the gap
+// between them is wider than a fifth of it.
+func textLines(p pdf.Page) []string {
+	var lines []string
+	var line strings.Builder
+	var prev pdf.Text
+	flush := func() {
+		if s := strings.TrimSpace(line.String()); s != "" {
+			lines = append(lines, s)
+		}
+		line.Reset()
+	}
+	for i, c := range p.Content().Text {
+		size := math.Max(c.FontSize, 1)
+		switch {
+		case i == 0:
+		case math.Abs(c.Y-prev.Y) > size/2:
+			flush()
+		case c.X-(prev.X+charWidth(prev)) > size/5:
+			line.WriteByte(' ')
+		}
+		line.WriteString(c.S)
+		prev = c
+	}
+	flush()
+	return lines
+}
+
+// diffLines returns the lines of a that are not in b and those of b that
+// are not in a, by their longest common subsequence.
+func diffLines(a, b []string) (removed, added []string) {
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return a, b
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	return append(removed, a[i:]...), append(added, b[j:]...)
+}
+
+// widgetFields maps the object numbers of widget annotations to the fully
+// qualified names of their fields.
+func widgetFields(r *pdf.Reader) map[uint32]string {
+	names := make(map[uint32]string)
+	for name, field := range collectFormFields(r.Trailer().Key("Root").Key("AcroForm").Key("Fields")) {
+		for _, w := range field.widgets {
+			names[w.GetPtr().GetID()] = name
+		}
+	}
+	return names
+}
+
+func (d *RevisionDiff) diffAnnotations(page int, old, cur pdf.Page, modified map[uint32]bool, oldFields, curFields map[uint32]string) {
+	oldAnnots := make(map[uint32]pdf.Value)
+	annots := old.V.Key("Annots")
+	for i := 0; i < annots.Len(); i++ {
+		if a := annots.Index(i); a.Kind() == pdf.Dict {
+			oldAnnots[a.GetPtr().GetID()] = a
+		}
+	}
+	annotation := func(a pdf.Value, change string, fields map[uint32]string) AnnotationChange {
+		ac := AnnotationChange{Page: page, Change: change, Object: a.GetPtr().GetID(), Subtype: a.Key("Subtype").Name()}
+		ac.Rect, _ = readPageBox(a.Key("Rect"))
+		ac.Field = fields[ac.Object]
+		return ac
+	}
+
+	annots = cur.V.Key("Annots")
+	for i := 0; i < annots.Len(); i++ {
+		a := annots.Index(i)
+		if a.Kind() != pdf.Dict {
+			continue
+		}
+		id := a.GetPtr().GetID()
+		if _, ok := oldAnnots[id]; !ok {
+			d.Annotations = append(d.Annotations, annotation(a, ChangeAdded, curFields))
+			continue
+		}
+		delete(oldAnnots, id)
+		if modified[id] {
+			d.Annotations = append(d.Annotations, annotation(a, ChangeModified, curFields))
+		}
+	}
+	ids := make([]uint32, 0, len(oldAnnots))
+	for id := range oldAnnots {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		d.Annotations = append(d.Annotations, annotation(oldAnnots[id], ChangeRemoved, oldFields))
+	}
+}
+
+func (d *RevisionDiff) diffFields(old, cur *pdf.Reader) {
+	before := collectFormFields(old.Trailer().Key("Root").Key("AcroForm").Key("Fields"))
+	after := collectFormFields(cur.Trailer().Key("Root").Key("AcroForm").Key("Fields"))
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		a, inOld := before[name]
+		b, inCur := after[name]
+		fc := FieldChange{Name: name}
+		switch {
+		case !inOld:
+			fc.Change, fc.Type, fc.New = ChangeAdded, inspectFieldType(b.value), fieldValueText(b.value)
+		case !inCur:
+			fc.Change, fc.Type, fc.Old = ChangeRemoved, inspectFieldType(a.value), fieldValueText(a.value)
+		default:
+			fc.Change, fc.Type = ChangeModified, inspectFieldType(b.value)
+			fc.Old, fc.New = fieldValueText(a.value), fieldValueText(b.value)
+			if fc.Old == fc.New {
+				continue
+			}
+		}
+		d.Fields = append(d.Fields, fc)
+	}
+}
+
+// fieldValueText returns the value of a field as text: signature fields
+// name their signature dictionary and signer.
+func fieldValueText(field pdf.Value) string {
+	v := inheritedKey(field, "V")
+	switch v.Kind() {
+	case pdf.String:
+		return v.Text()
+	case pdf.Name:
+		return v.Name()
+	case pdf.Array:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).Text()
+		}
+		return strings.Join(items, ", ")
+	case pdf.Dict:
+		p := v.GetPtr()
+		if name := v.Key("Name").Text(); name != "" {
+			return fmt.Sprintf("signature %d %d R by %s", p.GetID(), p.GetGen(), name)
+		}
+		return fmt.Sprintf("signature %d %d R", p.GetID(), p.GetGen())
+	}
+	return ""
+}
+
+// WriteText writes the changes as a readable summary.
+func (d *RevisionDiff) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if d.Signature != "" {
+		fmt.Fprintf(bw, "Changes after signature %s, from revision %d to %d\n", d.Signature, d.From, d.To)
+	} else {
+		fmt.Fprintf(bw, "Changes from revision %d to %d\n", d.From, d.To)
+	}
+	fmt.Fprintf(bw, "Objects: %d added, %d removed, %d modified\n", len(d.Added), len(d.Removed), len(d.Modified))
+
+	if len(d.Catalog) > 0 {
+		fmt.Fprintln(bw, "\nCatalog:")
+		for _, c := range d.Catalog {
+			fmt.Fprintf(bw, "  %s /%s%s\n", c.Change, c.Key, formatChange(c.Old, c.New))
+		}
+	}
+	if len(d.Pages) > 0 {
+		fmt.Fprintln(bw, "\nPages:")
+		for _, p := range d.Pages {
+			detail := ""
+			if p.ContentChanged {
+				detail = ", content changed"
+			}
+			fmt.Fprintf(bw, "  page %d %s%s\n", p.Page, p.Change, detail)
+			for _, line := range p.RemovedText {
+				fmt.Fprintf(bw, "    - %s\n", line)
+			}
+			for _, line := range p.AddedText {
+				fmt.Fprintf(bw, "    + %s\n", line)
+			}
+		}
+	}
+	if len(d.Annotations) > 0 {
+		fmt.Fprintln(bw, "\nAnnotations:")
+		for _, a := range d.Annotations {
+			fmt.Fprintf(bw, "  %s %s on page %d at [%g %g %g %g]", a.Change, a.Subtype, a.Page, a.Rect[0], a.Rect[1], a.Rect[2], a.Rect[3])
+			if a.Field != "" {
+				fmt.Fprintf(bw, " (field %s)", a.Field)
+			}
+			fmt.Fprintln(bw)
+		}
+	}
+	if len(d.Fields) > 0 {
+		fmt.Fprintln(bw, "\nForm fields:")
+		for _, f := range d.Fields {
+			fmt.Fprintf(bw, "  %s %s%s\n", f.Change, f.Name, formatChange(f.Old, f.New))
+		}
+	}
+	return bw.Flush()
+}
+
+// formatChange formats an old and a new value.
+func formatChange(old, cur string) string {
+	switch {
+	case old == "" && cur == "":
+		return ""
+	case old == "":
+		return fmt.Sprintf(": %q", cur)
+	case cur == "":
+		return fmt.Sprintf(": was %q", old)
+	}
+	return fmt.Sprintf(": %q -> %q", old, cur)
+}
@@ -0,0 +1,142 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// appendRevision appends the objects as a revision with a cross-reference
+// table to data, which is empty for the original document.
+func appendRevision(data []byte, objects map[int]string, size int) []byte {
+	var buf bytes.Buffer
+	buf.Write(data)
+	if len(data) == 0 {
+		buf.WriteString("%PDF-1.7\n")
+	}
+	ids := make([]int, 0, len(objects))
+	for id := range objects {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	offsets := make(map[int]int)
+	for _, id := range ids {
+		offsets[id] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", id, objects[id])
+	}
+	xref := buf.Len()
+	buf.WriteString("xref\n")
+	if len(data) == 0 {
+		buf.WriteString("0 1\n0000000000 65535 f \n")
+	}
+	for _, id := range ids {
+		fmt.Fprintf(&buf, "%d 1\n%010d 00000 n \n", id, offsets[id])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R", size)
+	if len(data) > 0 {
+		i := bytes.LastIndex(data, []byte("startxref"))
+		var prev int
+		_, _ = fmt.Sscanf(string(data[i:]), "startxref\n%d", &prev)
+		fmt.Fprintf(&buf, " /Prev %d", prev)
+	}
+	fmt.Fprintf(&buf, " >>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// helvetica is a font dictionary with widths, without which the text
+// extraction cannot tell where words end.
+var helvetica = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /FirstChar 32 /LastChar 122 /Widths [" + strings.Repeat("500 ", 91) + "] >>"
+
+func contentStream(s string) string {
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(s), s)
+}
+
+func TestDiffRevisions(t *testing.T) {
+	original := appendRevision(nil, map[int]string{
+		1: "<< /Type /Catalog /Pages 2 0 R >>",
+		2: "<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		3: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		4: contentStream("BT /F1 12 Tf 72 700 Td (Hello world) Tj 0 -20 Td (Unchanged line) Tj ET"),
+		5: helvetica,
+	}, 6)
+	updated := appendRevision(original, map[int]string{
+		1: "<< /Type /Catalog /Pages 2 0 R /Lang (en) >>",
+		3: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> /Annots [6 0 R] >>",
+		4: contentStream("BT /F1 12 Tf 72 700 Td (Goodbye world) Tj 0 -20 Td (Unchanged line) Tj ET"),
+		5: helvetica,
+		6: "<< /Type /Annot /Subtype /Text /Rect [10 20 30 40] /Contents (Note) >>",
+	}, 7)
+
+	d, err := DiffRevisions(bytes.NewReader(updated), int64(len(updated)), 1, 2)
+	if err != nil {
+		t.Fatalf("DiffRevisions() error = %v", err)
+	}
+	if fmt.Sprint(d.Added, d.Removed, d.Modified) != "[6] [] [1 3 4]" {
+		t.Errorf("objects added %v, removed %v, modified %v; want [6], [] and [1 3 4] (5 is rewritten unchanged)", d.Added, d.Removed, d.Modified)
+	}
+	if len(d.Catalog) != 1 || d.Catalog[0] != (EntryChange{Key: "Lang", Change: ChangeAdded, New: "(en)"}) {
+		t.Errorf("Catalog = %+v", d.Catalog)
+	}
+	if len(d.Pages) != 1 {
+		t.Fatalf("Pages = %+v, want page 1", d.Pages)
+	}
+	if p := d.Pages[0]; !p.ContentChanged || fmt.Sprint(p.RemovedText) != "[Hello world]" || fmt.Sprint(p.AddedText) != "[Goodbye world]" {
+		t.Errorf("page change = %+v", p)
+	}
+	if len(d.Annotations) != 1 || d.Annotations[0] != (AnnotationChange{Page: 1, Change: ChangeAdded, Object: 6, Subtype: "Text", Rect: [4]float64{10, 20, 30, 40}}) {
+		t.Errorf("Annotations = %+v", d.Annotations)
+	}
+
+	var buf bytes.Buffer
+	if err := d.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Changes from revision 1 to 2", "Objects: 1 added, 0 removed, 3 modified", "added /Lang: \"(en)\"", "- Hello world", "+ Goodbye world", "added Text on page 1 at [10 20 30 40]"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteText() = %s, want it to contain %q", buf.String(), want)
+		}
+	}
+
+	if _, err := DiffRevisions(bytes.NewReader(updated), int64(len(updated)), 2, 1); err == nil {
+		t.Error("DiffRevisions(2, 1) error = nil")
+	}
+}
+
+func TestDiffSignedRevision(t *testing.T) {
+	signed := signToTemp(t, writeFormPDF(t), "diff_signed_", approvalSignData(t, "Diff", nil))
+	filled := filepath.Join(t.TempDir(), "filled.pdf")
+	if err := FillFormFile(signed.Name(), filled, []FormFieldValue{TextFieldValue("zip", "75001")}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := Revisions(bytes.NewReader(data), int64(len(data)))
+	if err != nil || len(history.Signatures) != 1 {
+		t.Fatalf("Revisions() = %+v, %v", history, err)
+	}
+
+	d, err := DiffSignedRevision(bytes.NewReader(data), int64(len(data)), history.Signatures[0].Field)
+	if err != nil {
+		t.Fatalf("DiffSignedRevision() error = %v", err)
+	}
+	if d.From != 2 || d.To != 3 || d.Signature != history.Signatures[0].Field {
+		t.Errorf("DiffSignedRevision() compares revisions %d to %d for %q", d.From, d.To, d.Signature)
+	}
+	if len(d.Fields) != 1 || d.Fields[0] != (FieldChange{Name: "zip", Type: FormFieldText, Change: ChangeModified, New: "75001"}) {
+		t.Errorf("Fields = %+v", d.Fields)
+	}
+	if len(d.Modified) == 0 || len(d.Pages) != 0 {
+		t.Errorf("modified objects %v, pages %+v; want modified objects and no page change", d.Modified, d.Pages)
+	}
+
+	if _, err := DiffSignedRevision(bytes.NewReader(data), int64(len(data)), "missing"); !errors.Is(err, ErrFormFieldNotFound) {
+		t.Errorf("DiffSignedRevision() error = %v, want %v", err, ErrFormFieldNotFound)
+	}
+}
@@ -0,0 +1,322 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/digitorus/pdf"
+)
+
+// ErrRangeNotAtRevisionEnd is returned when the byte range of a signature
+// does not end at the end of a revision, right after its %%EOF marker, so
+// that the signed bytes are not a complete PDF.
+var ErrRangeNotAtRevisionEnd = errors.New("signed range does not end at the end of a revision")
+
+// RevisionHistory lists the revisions of a document and the revision each
+// signature covers, as reported by Revisions.
+type RevisionHistory struct {
+	Revisions []Revision `json:"revisions"`
+	// Signatures are the signed signature fields in signing order, by the
+	// end of their byte range.
+	Signatures []SignedRevision `json:"signatures"`
+	// TrailingBytes is the number of bytes after the end of the last
+	// revision.
+	TrailingBytes int64 `json:"trailing_bytes,omitempty"`
+}
+
+// Revision is the original document or one of its incremental updates.
+type Revision struct {
+	// Number is 1 for the original document.
+	Number int `json:"number"`
+	// Offset is the first byte appended by the revision and End the byte
+	// after its %%EOF marker and end-of-line; the first End bytes of the
+	// file are the document as of this revision.
+	Offset    int64  `json:"offset"`
+	End       int64  `json:"end"`
+	StartXref int64  `json:"startxref"`
+	XrefType  string `json:"xref_type"`
+	// Changed lists the objects added or replaced by the revision, Freed
+	// the objects it deleted.
+	Changed []uint32 `json:"changed_objects"`
+	Freed   []uint32 `json:"freed_objects,omitempty"`
+	// Signatures names the signature fields whose signature was added by
+	// the revision.
+	Signatures []string `json:"signatures,omitempty"`
+
+	eof int64 // the byte after %%EOF
+}
+
+// SignedRevision relates a signature to the revision it covers.
+type SignedRevision struct {
+	// Field is the fully qualified name of the signature field.
+	Field     string  `json:"field"`
+	ByteRange []int64 `json:"byte_range"`
+	// Revision is the number of the revision whose end the byte range
+	// ends at, or 0 with a Problem.
+	Revision int    `json:"revision,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+
+	sig uint32 // object number of the signature dictionary
+}
+
+// startxrefBeforeEOF matches the end of a revision before its %%EOF marker
+// and captures the offset of its last cross-reference section.
+var startxrefBeforeEOF = regexp.MustCompile(`startxref\s+(\d+)\s*$`)
+
+// RevisionsFile lists the revisions of the PDF file at path.
+func RevisionsFile(path string) (*RevisionHistory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Revisions(f, st.Size())
+}
+
+// Revisions lists the revisions of a document: where each one ends, the
+// objects it changed and the signatures it added, and for each signature
+// the revision its byte range covers.
+//
+// A revision ends at a %%EOF marker preceded by startxref. Markers whose
+// cross-reference sections cannot be read within the revision, such as the
+// end of the first page section of a linearized file, belong to the next
+// revision.
+func Revisions(input io.ReaderAt, size int64) (*RevisionHistory, error) {
+	return RevisionsOptions(input, size, pdf.ReaderOptions{})
+}
+
+// RevisionsOptions lists the revisions of a document like Revisions,
+// opening it with opts, such as the password of an encrypted document. The
+// cross-reference of each revision is derived from the /Prev chain of the
+// document, reading each cross-reference section once.
+func RevisionsOptions(input io.ReaderAt, size int64, opts pdf.ReaderOptions) (*RevisionHistory, error) {
+	data, err := io.ReadAll(io.NewSectionReader(input, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	rdr, err := pdf.NewReaderOptions(bytes.NewReader(data), size, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &RevisionHistory{Revisions: []Revision{}, Signatures: []SignedRevision{}}
+	objects := make(map[uint32]xrefState)
+	var offset int64
+	for _, end := range revisionEnds(data) {
+		r, err := rdr.Revision(end.startxref, end.end)
+		if err != nil {
+			continue
+		}
+		rev := Revision{
+			Number:    len(history.Revisions) + 1,
+			Offset:    offset,
+			End:       end.end,
+			StartXref: r.XrefInformation.StartPos,
+			XrefType:  r.XrefInformation.Type,
+			eof:       end.eof,
+		}
+		rev.Changed, rev.Freed = changedObjects(objects, r)
+		history.Revisions = append(history.Revisions, rev)
+		offset = end.end
+	}
+	if len(history.Revisions) == 0 {
+		return nil, errors.New("no revision found: the file has no readable %%EOF marker")
+	}
+	history.TrailingBytes = size - offset
+
+	fields := collectFormFields(rdr.Trailer().Key("Root").Key("AcroForm").Key("Fields"))
+	for name, field := range fields {
+		v := inheritedKey(field.value, "V")
+		if inheritedKey(field.value, "FT").Name() != "Sig" || v.Kind() != pdf.Dict {
+			continue
+		}
+		sr := SignedRevision{Field: name, ByteRange: []int64{}, sig: v.GetPtr().GetID()}
+		br := v.Key("ByteRange")
+		for i := 0; i < br.Len(); i++ {
+			sr.ByteRange = append(sr.ByteRange, br.Index(i).Int64())
+		}
+		history.coveredRevision(&sr)
+		history.Signatures = append(history.Signatures, sr)
+	}
+	sort.Slice(history.Signatures, func(i, j int) bool {
+		a, b := history.Signatures[i], history.Signatures[j]
+		if ea, eb := signedEnd(a.ByteRange), signedEnd(b.ByteRange); ea != eb {
+			return ea < eb
+		}
+		return a.Field < b.Field
+	})
+
+	for _, sr := range history.Signatures {
+		for i := range history.Revisions {
+			if containsObject(history.Revisions[i].Changed, sr.sig) {
+				history.Revisions[i].Signatures = append(history.Revisions[i].Signatures, sr.Field)
+				break
+			}
+		}
+	}
+	return history, nil
+}
+
+// revisionEnd is the end of a candidate revision.
+type revisionEnd struct {
+	startxref int64 // the offset of its last cross-reference section
+	eof       int64 // the byte after %%EOF
+	end       int64 // the byte after the end-of-line following %%EOF
+}
+
+// revisionEnds returns the %%EOF markers of data that follow a startxref
+// offset.
+func revisionEnds(data []byte) []revisionEnd {
+	var ends []revisionEnd
+	marker := []byte("%%EOF")
+	for i := 0; ; {
+		j := bytes.Index(data[i:], marker)
+		if j < 0 {
+			return ends
+		}
+		start := i + j
+		i = start + len(marker)
+		m := startxrefBeforeEOF.FindSubmatch(data[max(0, start-64):start])
+		if m == nil {
+			continue
+		}
+		startxref, err := strconv.ParseInt(string(m[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		end := i
+		if end < len(data) && data[end] == '\r' {
+			end++
+		}
+		if end < len(data) && data[end] == '\n' {
+			end++
+		}
+		ends = append(ends, revisionEnd{startxref: startxref, eof: int64(i), end: int64(end)})
+	}
+}
+
+// xrefState is the cross-reference entry of an object in use.
+type xrefState struct {
+	gen    uint16
+	stream uint32 // the object stream, 0 for objects stored directly
+	offset int64
+}
+
+// changedObjects compares the cross-reference table of the revision read
+// by r to the entries of the previous revision in objects, which it
+// updates.
+func changedObjects(objects map[uint32]xrefState, r *pdf.Reader) (changed, freed []uint32) {
+	changed = []uint32{}
+	table := r.Xref()
+	inUse := make(map[uint32]bool, len(table))
+	for id := range table {
+		x := &table[id]
+		if id == 0 || x.Offset() == 0 && !x.InStream() {
+			continue
+		}
+		state := xrefState{gen: x.Ptr().GetGen(), stream: x.StreamID(), offset: x.Offset()}
+		inUse[uint32(id)] = true
+		if prev, ok := objects[uint32(id)]; !ok || prev != state {
+			objects[uint32(id)] = state
+			changed = append(changed, uint32(id))
+		}
+	}
+	for id := range objects {
+		if !inUse[id] {
+			delete(objects, id)
+			freed = append(freed, id)
+		}
+	}
+	sort.Slice(freed, func(i, j int) bool { return freed[i] < freed[j] })
+	return changed, freed
+}
+
+// coveredRevision sets the revision whose end the byte range of sr ends
+// at, or the problem that prevents it.
+func (history *RevisionHistory) coveredRevision(sr *SignedRevision) {
+	br := sr.ByteRange
+	switch {
+	case len(br) != 4:
+		sr.Problem = fmt.Sprintf("the byte range has %d entries instead of 4", len(br))
+		return
+	case br[0] != 0:
+		sr.Problem = "the byte range does not start at the beginning of the file"
+		return
+	}
+	end := signedEnd(br)
+	for _, rev := range history.Revisions {
+		if end >= rev.eof && end <= rev.End {
+			sr.Revision = rev.Number
+			return
+		}
+	}
+	sr.Problem = fmt.Sprintf("the byte range ends at byte %d, not at the end of a revision", end)
+}
+
+// signedEnd returns the byte after the last byte covered by a byte range.
+func signedEnd(br []int64) int64 {
+	if len(br) != 4 {
+		return 0
+	}
+	return br[2] + br[3]
+}
+
+func containsObject(list []uint32, id uint32) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteRevision writes the document as of revision number (1 for the
+// original document) as a standalone PDF.
+func WriteRevision(w io.Writer, input io.ReaderAt, size int64, number int) error {
+	history, err := Revisions(input, size)
+	if err != nil {
+		return err
+	}
+	if number < 1 || number > len(history.Revisions) {
+		return fmt.Errorf("revision %d not found: the document has %d revisions", number, len(history.Revisions))
+	}
+	_, err = io.Copy(w, io.NewSectionReader(input, 0, history.Revisions[number-1].End))
+	return err
+}
+
+// WriteSignedRevision writes the revision covered by the signature of the
+// signature field named field as a standalone PDF: the document as the
+// signer saw it, the bytes up to the end of its byte range. It fails with
+// ErrRangeNotAtRevisionEnd when the byte range does not end at the end of
+// a revision, and with ErrFormFieldNotFound when the document has no
+// signed field of that name.
+func WriteSignedRevision(w io.Writer, input io.ReaderAt, size int64, field string) (*SignedRevision, error) {
+	history, err := Revisions(input, size)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history.Signatures {
+		sr := &history.Signatures[i]
+		if sr.Field != field {
+			continue
+		}
+		if sr.Revision == 0 {
+			return sr, fmt.Errorf("%w: %s", ErrRangeNotAtRevisionEnd, sr.Problem)
+		}
+		_, err := io.Copy(w, io.NewSectionReader(input, 0, signedEnd(sr.ByteRange)))
+		return sr, err
+	}
+	return nil, fmt.Errorf("%w: no signed signature field %q", ErrFormFieldNotFound, field)
+}
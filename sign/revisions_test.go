@@ -0,0 +1,167 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/digitorus/pdf"
+)
+
+func TestRevisions(t *testing.T) {
+	first := signToTemp(t, "../testfiles/testfile20.pdf", "revisions_1_", approvalSignData(t, "First", nil))
+	second := signToTemp(t, first.Name(), "revisions_2_", approvalSignData(t, "Second", nil))
+	data, err := os.ReadFile(second.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstSize := fileSize(t, first.Name())
+
+	history, err := Revisions(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Revisions() error = %v", err)
+	}
+	if len(history.Revisions) != 3 || len(history.Signatures) != 2 {
+		t.Fatalf("Revisions() = %d revisions and %d signatures, want 3 and 2", len(history.Revisions), len(history.Signatures))
+	}
+	if history.TrailingBytes != 0 {
+		t.Errorf("TrailingBytes = %d, want 0", history.TrailingBytes)
+	}
+	if rev := history.Revisions[1]; rev.End != firstSize || len(rev.Signatures) != 1 || len(rev.Changed) == 0 {
+		t.Errorf("revision 2 = %+v, want it to end at %d with one signature", rev, firstSize)
+	}
+	if rev := history.Revisions[2]; rev.Offset != firstSize || rev.End != int64(len(data)) {
+		t.Errorf("revision 3 spans %d-%d, want %d-%d", rev.Offset, rev.End, firstSize, len(data))
+	}
+	for i, sr := range history.Signatures {
+		if sr.Revision != i+2 || sr.Problem != "" {
+			t.Errorf("signature %q covers revision %d (%s), want %d", sr.Field, sr.Revision, sr.Problem, i+2)
+		}
+		if got := history.Revisions[i+1].Signatures; len(got) != 1 || got[0] != sr.Field {
+			t.Errorf("revision %d added signatures %q, want %q", i+2, got, sr.Field)
+		}
+	}
+
+	var buf bytes.Buffer
+	sr, err := WriteSignedRevision(&buf, bytes.NewReader(data), int64(len(data)), history.Signatures[0].Field)
+	if err != nil {
+		t.Fatalf("WriteSignedRevision() error = %v", err)
+	}
+	if sr.Revision != 2 || !bytes.Equal(buf.Bytes(), data[:firstSize]) {
+		t.Errorf("WriteSignedRevision() wrote %d bytes of revision %d, want the %d bytes of the first signed file", buf.Len(), sr.Revision, firstSize)
+	}
+	if _, err := pdf.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Errorf("the extracted revision cannot be opened: %v", err)
+	}
+
+	buf.Reset()
+	if err := WriteRevision(&buf, bytes.NewReader(data), int64(len(data)), 1); err != nil {
+		t.Fatalf("WriteRevision() error = %v", err)
+	}
+	original, _ := os.ReadFile("../testfiles/testfile20.pdf")
+	if !bytes.Equal(buf.Bytes(), original) {
+		t.Errorf("WriteRevision(1) wrote %d bytes, want the %d bytes of the original", buf.Len(), len(original))
+	}
+	if err := WriteRevision(&buf, bytes.NewReader(data), int64(len(data)), 4); err == nil {
+		t.Error("WriteRevision(4) error = nil")
+	}
+	if _, err := WriteSignedRevision(&buf, bytes.NewReader(data), int64(len(data)), "missing"); !errors.Is(err, ErrFormFieldNotFound) {
+		t.Errorf("WriteSignedRevision() error = %v, want %v", err, ErrFormFieldNotFound)
+	}
+}
+
+func TestSignedRangeNotAtRevisionEnd(t *testing.T) {
+	signed := signToTemp(t, "../testfiles/testfile20.pdf", "revisions_range_", approvalSignData(t, "Range", nil))
+	data, err := os.ReadFile(signed.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shorten the second range by 10 bytes, keeping the file offsets.
+	byteRange := regexp.MustCompile(`/ByteRange\s*\[\s*0 (\d+) (\d+) (\d+)`)
+	m := byteRange.FindSubmatchIndex(data)
+	if m == nil {
+		t.Fatal("no ByteRange found")
+	}
+	length, _ := strconv.Atoi(string(data[m[6]:m[7]]))
+	shorter := []byte(strconv.Itoa(length - 10))
+	shorter = append(shorter, bytes.Repeat([]byte(" "), m[7]-m[6]-len(shorter))...)
+	copy(data[m[6]:m[7]], shorter)
+
+	history, err := Revisions(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Revisions() error = %v", err)
+	}
+	if len(history.Signatures) != 1 || history.Signatures[0].Revision != 0 || history.Signatures[0].Problem == "" {
+		t.Fatalf("Signatures = %+v, want one signature with a problem", history.Signatures)
+	}
+	var buf bytes.Buffer
+	if _, err := WriteSignedRevision(&buf, bytes.NewReader(data), int64(len(data)), history.Signatures[0].Field); !errors.Is(err, ErrRangeNotAtRevisionEnd) {
+		t.Errorf("WriteSignedRevision() error = %v, want %v", err, ErrRangeNotAtRevisionEnd)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("WriteSignedRevision() wrote %d bytes on error", buf.Len())
+	}
+}
+
+func TestRevisionsPassword(t *testing.T) {
+	encrypted := approvalSignData(t, "First", nil)
+	encrypted.Encryption = &Encryption{UserPassword: "user", OwnerPassword: "owner"}
+	first := signToTemp(t, "../testfiles/testfile20.pdf", "revisions_encrypted_1_", encrypted)
+	owner := approvalSignData(t, "Second", nil)
+	owner.Password = "owner"
+	second := signToTemp(t, first.Name(), "revisions_encrypted_2_", owner)
+	data, err := os.ReadFile(second.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Revisions(bytes.NewReader(data), int64(len(data))); !errors.Is(err, pdf.ErrInvalidPassword) {
+		t.Errorf("Revisions() without password: error = %v, want %v", err, pdf.ErrInvalidPassword)
+	}
+	history, err := RevisionsOptions(bytes.NewReader(data), int64(len(data)), pdf.ReaderOptions{Password: "user"})
+	if err != nil {
+		t.Fatalf("RevisionsOptions() error = %v", err)
+	}
+	// The encrypted copy of the original document and one revision per
+	// signature; the field names are encrypted strings.
+	if len(history.Revisions) != 3 || len(history.Signatures) != 2 {
+		t.Fatalf("RevisionsOptions() = %d revisions and %d signatures, want 3 and 2", len(history.Revisions), len(history.Signatures))
+	}
+	for i, sr := range history.Signatures {
+		if sr.Revision != i+2 || sr.Field != "Signature "+strconv.Itoa(i+1) {
+			t.Errorf("signature %d = %+v, want field %q covering revision %d", i, sr, "Signature "+strconv.Itoa(i+1), i+2)
+		}
+	}
+	if got := history.Revisions[2].Signatures; len(got) != 1 || got[0] != "Signature 2" {
+		t.Errorf("revision 3 signatures = %q, want [Signature 2]", got)
+	}
+}
+
+func TestRevisionsObjectStreams(t *testing.T) {
+	// testfile30.pdf is an object stream document with one incremental
+	// update.
+	history, err := RevisionsFile("../testfiles/testfile30.pdf")
+	if err != nil {
+		t.Fatalf("RevisionsFile() error = %v", err)
+	}
+	for _, rev := range history.Revisions {
+		if len(rev.Changed) == 0 {
+			t.Errorf("revision %d changed no objects", rev.Number)
+		}
+	}
+	if n := len(history.Revisions); n < 1 || history.Revisions[n-1].XrefType == "" {
+		t.Errorf("Revisions = %+v", history.Revisions)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Size()
+}
@@ -155,8 +155,19 @@ func BestEffortEmbedRevocationStatusFunction(cert, issuer *x509.Certificate, i *
 // Security Store) dictionary built from the revocation data gathered during
 // signing, producing an LTV-enabled signature. When SignData.RevocationFunction
 // is nil it defaults to BestEffortEmbedRevocationStatusFunction so a missing or
-// unreachable responder never fails signing.
+// unreachable responder never fails signing. Revocation data and DSS
+// certificates are only gathered for the supplied chain; set
+// SignData.CompleteChain to download missing issuers first.
 func SignLTV(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64, signData SignData) (*common.SignatureInfo, error) {
+	// The chain is completed here rather than in Sign, so that the
+	// downloaded issuers reach the DSS as well.
+	if signData.CompleteChain {
+		if err := completeCertificateChain(&signData); err != nil {
+			return nil, err
+		}
+		signData.CompleteChain = false
+	}
+
 	var ocsps, crls [][]byte
 	inner := signData.RevocationFunction
 	if inner == nil {
@@ -174,21 +185,32 @@ func SignLTV(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64,
 		return err
 	}
 
+	// Sign reads the encrypted copy, which needs no repairs; the repairs
+	// made to read the input are reported with its own.
+	var repairs []string
+	if signData.Encryption != nil {
+		// Encrypt first so that the DSS streams use the new key.
+		repairs = rdr.Repairs()
+		var err error
+		if input, rdr, size, err = encryptInput(rdr, *signData.Encryption); err != nil {
+			return nil, err
+		}
+		signData.Encryption = nil
+	}
+
 	var buf bytes.Buffer
 	info, err := Sign(input, &buf, rdr, size, signData)
 	if err != nil {
 		return nil, err
 	}
+	info.Repairs = append(repairs, info.Repairs...)
 	signed := buf.Bytes()
 
 	var certs []*x509.Certificate
 	if len(signData.CertificateChains) > 0 {
 		certs = signData.CertificateChains[0]
 	}
-	var enc *EncryptionContext
-	if key := rdr.EncryptionKey(); key != nil {
-		enc = &EncryptionContext{Key: key, UseAES: rdr.UseAES(), EncVersion: rdr.EncVersion()}
-	}
+	enc := encryptionContext(rdr)
 	if (len(ocsps) > 0 || len(crls) > 0) && len(certs) > 0 {
 		augmented, dssErr := AddValidationData(signed, certs, ocsps, crls, enc)
 		if dssErr != nil {
@@ -220,20 +242,42 @@ func SignLTA(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64,
 		return nil, err
 	}
 	ltBytes := ltBuf.Bytes()
-	ltReader, err := pdf.NewReader(bytes.NewReader(ltBytes), int64(len(ltBytes)))
+	var ltReader *pdf.Reader
+	if signData.Encryption != nil {
+		ltReader, err = pdf.NewReaderPassword(bytes.NewReader(ltBytes), int64(len(ltBytes)), signData.Encryption.OwnerPassword)
+	} else {
+		ltReader, err = openReader(bytes.NewReader(ltBytes), int64(len(ltBytes)), signData)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("lta: re-open LT pdf: %w", err)
 	}
 	if _, err = Sign(bytes.NewReader(ltBytes), output, ltReader, int64(len(ltBytes)), SignData{
-		Signature:       SignDataSignature{CertType: TimeStampSignature},
-		DigestAlgorithm: signData.DigestAlgorithm,
-		TSA:             signData.TSA,
+		Signature:        SignDataSignature{CertType: TimeStampSignature},
+		DigestAlgorithm:  signData.DigestAlgorithm,
+		TSA:              signData.TSA,
+		PermissionPolicy: signData.PermissionPolicy,
 	}); err != nil {
 		return nil, fmt.Errorf("lta: archive timestamp: %w", err)
 	}
 	return info, nil
 }
 
+// AddValidationDataWithPassword is AddValidationData for an encrypted
+// document: it is opened with the user or owner password, which supplies
+// the key for the new streams, and the update fails with
+// ErrChangeNotPermitted when the access permissions of the document do not
+// allow it without the owner password.
+func AddValidationDataWithPassword(data []byte, certs []*x509.Certificate, ocsps, crls [][]byte, password string) ([]byte, error) {
+	rdr, err := pdf.NewReaderPassword(bytes.NewReader(data), int64(len(data)), password)
+	if err != nil {
+		return nil, err
+	}
+	if err := ReadPermissions(rdr).Check(ChangeValidationData); err != nil {
+		return nil, err
+	}
+	return AddValidationData(data, certs, ocsps, crls, encryptionContext(rdr))
+}
+
 func dssLastSubmatch(re *regexp.Regexp, b []byte) [][]byte {
 	ms := re.FindAllSubmatch(b, -1)
 	if len(ms) == 0 {
@@ -325,6 +369,8 @@ func dssEncryptStream(enc *EncryptionContext, objID int, data []byte) ([]byte, e
 //
 // The cross-reference of the incremental update matches the document's existing
 // type (classic table or cross-reference stream).
+//
+// AddValidationDataWithPassword opens password-protected documents.
 func AddValidationData(pdf []byte, certs []*x509.Certificate, ocsps, crls [][]byte, enc *EncryptionContext) ([]byte, error) {
 	rootM := dssLastSubmatch(regexp.MustCompile(`/Root\s+(\d+)\s+(\d+)\s+R`), pdf)
 	if rootM == nil {
@@ -3,13 +3,7 @@ package sign
 import (
 	"bytes"
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/asn1"
-	"io"
-	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -18,8 +12,8 @@ import (
 	"time"
 
 	"github.com/digitorus/pdf"
-	"github.com/digitorus/timestamp"
 	"github.com/subnoto/pdfsign/revocation"
+	"github.com/subnoto/pdfsign/tsa"
 )
 
 func signTestPDF(t *testing.T) []byte {
@@ -237,59 +231,15 @@ func TestEmbedOCSPUsesPOST(t *testing.T) {
 	}
 }
 
+// newMockTSAServer serves an RFC 3161 time-stamp authority with a
+// self-signed certificate, so that timestamped signatures need no network.
 func newMockTSAServer(t *testing.T) *httptest.Server {
 	t.Helper()
-	tsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	tsaCert, tsaKey, err := tsa.GenerateCertificate("Mock TSA", 24*time.Hour)
 	if err != nil {
 		t.Fatal(err)
 	}
-	template := x509.Certificate{
-		SerialNumber:          big.NewInt(1),
-		Subject:               pkix.Name{CommonName: "Mock TSA"},
-		NotBefore:             time.Now().Add(-time.Hour),
-		NotAfter:              time.Now().Add(24 * time.Hour),
-		KeyUsage:              x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
-		BasicConstraintsValid: true,
-	}
-	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &tsaKey.PublicKey, tsaKey)
-	if err != nil {
-		t.Fatal(err)
-	}
-	tsaCert, err := x509.ParseCertificate(der)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
-		body, err := io.ReadAll(r.Body)
-		if err != nil || len(body) == 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		req, err := timestamp.ParseRequest(body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		ts := timestamp.Timestamp{
-			HashAlgorithm: crypto.SHA256,
-			HashedMessage: req.HashedMessage,
-			Time:          time.Now().UTC(),
-			Policy:        asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 1},
-		}
-		resp, err := ts.CreateResponseWithOpts(tsaCert, tsaKey, crypto.SHA256)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/timestamp-reply")
-		_, _ = w.Write(resp)
-	}))
+	return httptest.NewServer(&tsa.Authority{Certificate: tsaCert, Signer: tsaKey, Accuracy: time.Second})
 }
 
 func TestSignLTA(t *testing.T) {
@@ -42,7 +42,7 @@ func SignFile(input string, output string, sign_data SignData) (*common.Signatur
 	}
 	size := finfo.Size()
 
-	rdr, err := pdf.NewReader(input_file, size)
+	rdr, err := openReader(input_file, size, sign_data)
 	if err != nil {
 		return nil, err
 	}
@@ -50,9 +50,45 @@ func SignFile(input string, output string, sign_data SignData) (*common.Signatur
 	return Sign(input_file, output_file, rdr, size, sign_data)
 }
 
+// openReader opens an input document with the password or the recipient
+// certificate of sign_data, in recovery mode when sign_data.Recover is set.
+func openReader(input io.ReaderAt, size int64, sign_data SignData) (*pdf.Reader, error) {
+	return pdf.NewReaderOptions(input, size, pdf.ReaderOptions{
+		Password:    sign_data.Password,
+		Certificate: sign_data.DecryptionCertificate,
+		Key:         sign_data.DecryptionKey,
+		Recover:     sign_data.Recover,
+	})
+}
+
 func Sign(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64, sign_data SignData) (*common.SignatureInfo, error) {
+	repairs := rdr.Repairs()
+	// Encryption rewrites the document as well.
+	if len(repairs) > 0 && sign_data.Encryption == nil {
+		var dropped []uint32
+		var err error
+		if input, rdr, size, dropped, err = repairInput(rdr); err != nil {
+			return nil, err
+		}
+		for _, id := range dropped {
+			repairs = append(repairs, fmt.Sprintf("object %d cannot be read and was left out", id))
+		}
+	}
+	if sign_data.Encryption != nil {
+		var err error
+		if input, rdr, size, err = encryptInput(rdr, *sign_data.Encryption); err != nil {
+			return nil, err
+		}
+		sign_data.Encryption = nil
+	}
 	sign_data.objectId = uint32(rdr.XrefInformation.ItemCount) + 2
 
+	if sign_data.CompleteChain {
+		if err := completeCertificateChain(&sign_data); err != nil {
+			return nil, err
+		}
+	}
+
 	context := SignContext{
 		PDFReader:              rdr,
 		InputFile:              input,
@@ -63,13 +99,7 @@ func Sign(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64, si
 
 	// Initialize encryption context if the PDF is encrypted.
 	// New stream objects must be encrypted with the same key.
-	if key := rdr.EncryptionKey(); key != nil {
-		context.encryption = &EncryptionContext{
-			Key:        key,
-			UseAES:     rdr.UseAES(),
-			EncVersion: rdr.EncVersion(),
-		}
-	}
+	context.encryption = encryptionContext(rdr)
 
 	// Fetch existing signatures
 	existingSignatures, err := context.fetchExistingSignatures()
@@ -78,14 +108,35 @@ func Sign(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64, si
 	}
 	context.existingSignatures = existingSignatures
 
+	warnings, err := context.checkPermissions()
+	if err != nil {
+		return nil, err
+	}
+
 	signatureInfo, err := context.SignPDF()
 	if err != nil {
 		return nil, err
 	}
+	signatureInfo.Repairs = repairs
+	signatureInfo.Warnings = warnings
 
 	return signatureInfo, nil
 }
 
+// encryptionContext returns the key material to encrypt new objects with, or
+// nil when the document is not encrypted.
+func encryptionContext(rdr *pdf.Reader) *EncryptionContext {
+	key := rdr.EncryptionKey()
+	if key == nil {
+		return nil
+	}
+	return &EncryptionContext{
+		Key:        key,
+		UseAES:     rdr.UseAES(),
+		EncVersion: rdr.EncVersion(),
+	}
+}
+
 func (context *SignContext) SignPDF() (*common.SignatureInfo, error) {
 	for attempt := 0; attempt < maxSignatureBufferRetries; attempt++ {
 		info, err := context.signPDFOnce()
@@ -106,6 +157,7 @@ func (context *SignContext) resetIncrementalState() {
 	context.lastXrefID = 0
 	context.newXrefEntries = nil
 	context.updatedXrefEntries = nil
+	context.embeddedFonts = nil
 	context.NewXrefStart = 0
 	context.ByteRangeValues = nil
 	context.CatalogData = CatalogData{}
@@ -131,6 +183,14 @@ func (context *SignContext) signPDFOnce() (*common.SignatureInfo, error) {
 	if context.SignData.Appearance.Page == 0 {
 		context.SignData.Appearance.Page = 1
 	}
+	if context.SignData.Appearance.Visible {
+		if err := context.resolveAnchor(); err != nil {
+			return nil, err
+		}
+		if err := context.resolvePlacement(); err != nil {
+			return nil, err
+		}
+	}
 
 	context.OutputBuffer = filebuffer.New([]byte{})
 
@@ -253,8 +313,20 @@ func (context *SignContext) signPDFOnce() (*common.SignatureInfo, error) {
 		}
 	}
 
+	var pages []uint32
+	if visible {
+		if pages, err = context.signaturePages(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Example usage: passing page number and default rect values
-	visual_signature, err := context.createVisualSignature(visible, context.SignData.Appearance.Page, rectangle)
+	var visual_signature []byte
+	if len(pages) > 0 {
+		visual_signature, err = context.createMultiPageVisualSignature(pages)
+	} else {
+		visual_signature, err = context.createVisualSignature(visible, context.SignData.Appearance.Page, rectangle)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create visual signature: %w", err)
 	}
@@ -265,6 +337,18 @@ func (context *SignContext) signPDFOnce() (*common.SignatureInfo, error) {
 		return nil, fmt.Errorf("failed to add visual signature object: %w", err)
 	}
 
+	// Write the widgets of a signature shown on several pages; their IDs
+	// were assigned to follow the field.
+	for _, w := range context.VisualSignData.widgets {
+		id, err := context.addObject(w.object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add signature widget: %w", err)
+		}
+		if id != w.objectId {
+			return nil, fmt.Errorf("signature widget written as object %d, expected %d", id, w.objectId)
+		}
+	}
+
 	// If configured, fill initials and date into matching AcroForm fields
 	if context.SignData.Appearance.SignerUID != "" {
 		if err := context.fillInitialsFields(); err != nil {
@@ -275,7 +359,20 @@ func (context *SignContext) signPDFOnce() (*common.SignatureInfo, error) {
 		}
 	}
 
-	if context.SignData.Appearance.Visible {
+	if err := context.fillFormValues(context.SignData.FormFields); err != nil {
+		return nil, fmt.Errorf("failed to fill form fields: %w", err)
+	}
+
+	for _, w := range context.VisualSignData.widgets {
+		inc_page_update, err := context.createIncPageUpdate(w.pageNumber, w.objectId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create incremental page update: %w", err)
+		}
+		if err := context.updateObject(w.pageObjectId, inc_page_update); err != nil {
+			return nil, fmt.Errorf("failed to add incremental page update object: %w", err)
+		}
+	}
+	if context.SignData.Appearance.Visible && len(context.VisualSignData.widgets) == 0 {
 		inc_page_update, err := context.createIncPageUpdate(context.SignData.Appearance.Page, context.VisualSignData.objectId)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create incremental page update: %w", err)
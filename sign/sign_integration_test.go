@@ -61,7 +61,7 @@ func TestSignPDFPlainSignerUIDInitials(t *testing.T) {
 				Name: "Newt Totoo",
 				Date: time.Now(),
 			},
-			CertType:   CertificationSignature,
+			CertType:   ApprovalSignature,
 			DocMDPPerm: AllowFillingExistingFormFieldsAndSignaturesPerms,
 		},
 		Signer:      key,
@@ -188,7 +188,7 @@ func TestSignPDFFilledFieldReadOnlyAndAppearance(t *testing.T) {
 				Name: "Newt Totoo",
 				Date: time.Now(),
 			},
-			CertType:   CertificationSignature,
+			CertType:   ApprovalSignature,
 			DocMDPPerm: AllowFillingExistingFormFieldsAndSignaturesPerms,
 		},
 		Signer:      key,
@@ -5,6 +5,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -155,6 +156,11 @@ func TestReaderCanReadPDF(t *testing.T) {
 
 			if f.Name() == "testfile_encrypted.pdf" || f.Name() == "testfile_encrypted_signed.pdf" {
 				_, err = pdf.NewReaderEncrypted(input_file, size, func() string { return "" })
+			} else if f.Name() == "testfile_password.pdf" {
+				_, err = pdf.NewReaderPassword(input_file, size, "user")
+			} else if f.Name() == "testfile_pubsec.pdf" {
+				cert, key := loadCertificateAndKey(st)
+				_, err = pdf.NewReaderCertificate(input_file, size, cert, key)
 			} else {
 				_, err = pdf.NewReader(input_file, size)
 			}
@@ -187,7 +193,7 @@ func testSignAllFiles(t *testing.T, baseSignData SignData) {
 			continue
 		}
 		switch f.Name() {
-		case "signed-with-transparent-watermark.pdf", "testfile_encrypted.pdf", "testfile_encrypted_signed.pdf":
+		case "signed-with-transparent-watermark.pdf", "testfile_encrypted.pdf", "testfile_encrypted_signed.pdf", "testfile_password.pdf", "testfile_pubsec.pdf":
 			continue
 		}
 
@@ -218,6 +224,23 @@ func testSignAllFiles(t *testing.T, baseSignData SignData) {
 			signData.Certificate = cert
 
 			_, err = SignFile("../testfiles/"+f.Name(), outputFile.Name(), signData)
+			switch {
+			case f.Name() == "testfile30.pdf":
+				// Locked with /P 1 by its signature field: signing is refused
+				// unless the policy allows breaking the lock.
+				if !errors.Is(err, ErrChangeNotPermitted) {
+					st.Fatalf("%s: error = %v, want %v", f.Name(), err, ErrChangeNotPermitted)
+				}
+				signData.PermissionPolicy = PermissionWarn
+				_, err = SignFile("../testfiles/"+f.Name(), outputFile.Name(), signData)
+			case (f.Name() == "testfile50.pdf" || f.Name() == "testfile51.pdf") && signData.Signature.CertType == CertificationSignature:
+				// Already signed: only approval signatures can be added.
+				if !errors.Is(err, ErrChangeNotPermitted) {
+					st.Fatalf("%s: error = %v, want %v", f.Name(), err, ErrChangeNotPermitted)
+				}
+				signData.Signature.CertType = ApprovalSignature
+				_, err = SignFile("../testfiles/"+f.Name(), outputFile.Name(), signData)
+			}
 			if err != nil {
 				st.Fatalf("%s: %s", f.Name(), err.Error())
 			}
@@ -227,6 +250,9 @@ func testSignAllFiles(t *testing.T, baseSignData SignData) {
 }
 
 func TestSignPDF(t *testing.T) {
+	tsaServer := newMockTSAServer(t)
+	defer tsaServer.Close()
+
 	testSignAllFiles(t, SignData{
 		Signature: SignDataSignature{
 			Info: SignDataSignatureInfo{
@@ -240,7 +266,7 @@ func TestSignPDF(t *testing.T) {
 			DocMDPPerm: AllowFillingExistingFormFieldsAndSignaturesPerms,
 		},
 		TSA: TSA{
-			URL: "http://timestamp.digicert.com",
+			URL: tsaServer.URL,
 		},
 		RevocationData:     revocation.InfoArchival{},
 		RevocationFunction: DefaultEmbedRevocationStatusFunction,
@@ -352,7 +378,7 @@ func TestSignPDFInitials(t *testing.T) {
 					Name: tc.signerName,
 					Date: time.Now().Local(),
 				},
-				CertType:   CertificationSignature,
+				CertType:   ApprovalSignature,
 				DocMDPPerm: AllowFillingExistingFormFieldsAndSignaturesPerms,
 			},
 			Signer:      pkey,
@@ -1058,6 +1084,9 @@ func TestSignPDFWithTwoApproval(t *testing.T) {
 }
 
 func TestSignPDFWithCertificationApprovalAndTimeStamp(t *testing.T) {
+	tsaServer := newMockTSAServer(t)
+	defer tsaServer.Close()
+
 	cert, pkey := loadCertificateAndKey(t)
 	tbsFile := "../testfiles/testfile20.pdf"
 
@@ -1145,7 +1174,7 @@ func TestSignPDFWithCertificationApprovalAndTimeStamp(t *testing.T) {
 		},
 		DigestAlgorithm: crypto.SHA512,
 		TSA: TSA{
-			URL: "http://timestamp.entrust.net/TSS/RFC3161sha2TS",
+			URL: tsaServer.URL,
 		},
 	})
 	if err != nil {
@@ -1155,6 +1184,9 @@ func TestSignPDFWithCertificationApprovalAndTimeStamp(t *testing.T) {
 }
 
 func TestTimestampPDFFile(t *testing.T) {
+	tsaServer := newMockTSAServer(t)
+	defer tsaServer.Close()
+
 	tmpfile, err := os.CreateTemp("", t.Name())
 	if err != nil {
 		t.Fatalf("%s", err.Error())
@@ -1171,7 +1203,7 @@ func TestTimestampPDFFile(t *testing.T) {
 		},
 		DigestAlgorithm: crypto.SHA512,
 		TSA: TSA{
-			URL: "http://timestamp.entrust.net/TSS/RFC3161sha2TS",
+			URL: tsaServer.URL,
 		},
 	})
 	if err != nil {
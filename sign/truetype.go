@@ -0,0 +1,653 @@
+package sign
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// ErrTextNeedsShaping is returned when text drawn with an embedded font
+// uses a script that cannot be displayed correctly without complex shaping
+// or bidirectional reordering, such as Arabic, Hebrew, the Indic scripts or
+// Thai, or contains combining marks.
+var ErrTextNeedsShaping = errors.New("text needs complex script shaping, which embedded fonts do not support")
+
+// shapedScripts lists the scripts whose text is rejected with
+// ErrTextNeedsShaping: right-to-left scripts, scripts with contextual glyph
+// forms or reordering, and scripts relying on mark positioning.
+var shapedScripts = []*unicode.RangeTable{
+	unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko,
+	unicode.Devanagari, unicode.Bengali, unicode.Gurmukhi, unicode.Gujarati,
+	unicode.Oriya, unicode.Tamil, unicode.Telugu, unicode.Kannada,
+	unicode.Malayalam, unicode.Sinhala, unicode.Thai, unicode.Lao,
+	unicode.Tibetan, unicode.Myanmar, unicode.Khmer, unicode.Mongolian,
+}
+
+// TrueTypeFont is a parsed TrueType font (or OpenType font with TrueType
+// outlines) that can be embedded in appearance streams. Only the glyphs
+// used by an appearance are embedded.
+//
+// Text is mapped to glyphs one character at a time through the font's cmap;
+// ligatures, kerning, complex script shaping and bidirectional reordering
+// are not applied, so text in scripts that need them, and text with
+// combining marks, is rejected with ErrTextNeedsShaping. Characters missing
+// from the font are drawn with the .notdef glyph.
+type TrueTypeFont struct {
+	tables map[string][]byte
+
+	// PostScriptName is the font's PostScript name from the name table.
+	PostScriptName string
+
+	unitsPerEm  uint16
+	numGlyphs   int
+	longLoca    bool
+	bbox        [4]int16
+	ascent      int16
+	descent     int16
+	capHeight   int16
+	italicAngle float64
+	advances    []uint16
+	cmap        map[rune]uint16
+}
+
+// LoadTrueTypeFont reads and parses a TrueType or OpenType font file.
+func LoadTrueTypeFont(path string) (*TrueTypeFont, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %w", err)
+	}
+	return ParseTrueTypeFont(data)
+}
+
+// ParseTrueTypeFont parses a TrueType (.ttf) or OpenType (.otf) font with
+// TrueType outlines (glyf table). OpenType fonts with CFF outlines ("OTTO")
+// and font collections are not supported, nor are fonts whose license does
+// not permit embedding.
+func ParseTrueTypeFont(data []byte) (*TrueTypeFont, error) {
+	if len(data) < 4 {
+		return nil, errors.New("font data is too short")
+	}
+	switch string(data[:4]) {
+	case "\x00\x01\x00\x00", "true":
+	case "OTTO":
+		return nil, errors.New("OpenType fonts with CFF outlines are not supported, use a font with TrueType outlines")
+	case "ttcf":
+		return nil, errors.New("font collections are not supported")
+	default:
+		return nil, errors.New("not a TrueType or OpenType font")
+	}
+
+	tables, err := readSFNTTables(data)
+	if err != nil {
+		return nil, err
+	}
+	f := &TrueTypeFont{tables: tables}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap", "loca", "glyf"} {
+		if f.tables[tag] == nil {
+			return nil, fmt.Errorf("font has no %q table", tag)
+		}
+	}
+
+	if err := f.parseHead(); err != nil {
+		return nil, err
+	}
+	if err := f.parseMetrics(); err != nil {
+		return nil, err
+	}
+	if err := f.parseCmap(); err != nil {
+		return nil, err
+	}
+	if err := f.parseOS2(); err != nil {
+		return nil, err
+	}
+	if post := f.tables["post"]; len(post) >= 8 {
+		f.italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+	}
+	f.PostScriptName = f.parseName()
+	if f.PostScriptName == "" {
+		f.PostScriptName = "EmbeddedFont"
+	}
+	return f, nil
+}
+
+// readSFNTTables returns the tables of a TrueType font file by tag.
+func readSFNTTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("font data is too short")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, errors.New("truncated font table directory")
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := data[12+16*i:]
+		tag := string(rec[:4])
+		offset := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("font table %q is out of bounds", tag)
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+func (f *TrueTypeFont) parseHead() error {
+	head := f.tables["head"]
+	if len(head) < 54 {
+		return errors.New("invalid head table")
+	}
+	f.unitsPerEm = binary.BigEndian.Uint16(head[18:])
+	if f.unitsPerEm == 0 {
+		return errors.New("invalid unitsPerEm in head table")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int16(binary.BigEndian.Uint16(head[36+2*i:]))
+	}
+	f.longLoca = binary.BigEndian.Uint16(head[50:]) != 0
+	return nil
+}
+
+func (f *TrueTypeFont) parseMetrics() error {
+	maxp, hhea, hmtx := f.tables["maxp"], f.tables["hhea"], f.tables["hmtx"]
+	if len(maxp) < 6 || len(hhea) < 36 {
+		return errors.New("invalid maxp or hhea table")
+	}
+	f.numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+	f.ascent = int16(binary.BigEndian.Uint16(hhea[4:]))
+	f.descent = int16(binary.BigEndian.Uint16(hhea[6:]))
+
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if numMetrics == 0 || numMetrics > f.numGlyphs || len(hmtx) < 4*numMetrics {
+		return errors.New("invalid hmtx table")
+	}
+	f.advances = make([]uint16, f.numGlyphs)
+	for gid := range f.advances {
+		if gid < numMetrics {
+			f.advances[gid] = binary.BigEndian.Uint16(hmtx[4*gid:])
+		} else {
+			// Trailing glyphs share the last advance width.
+			f.advances[gid] = f.advances[numMetrics-1]
+		}
+	}
+
+	entry := 2
+	if f.longLoca {
+		entry = 4
+	}
+	if len(f.tables["loca"]) < entry*(f.numGlyphs+1) {
+		return errors.New("invalid loca table")
+	}
+	return nil
+}
+
+// parseCmap reads the Unicode mapping, preferring a full-repertoire format
+// 12 subtable over a BMP format 4 subtable.
+func (f *TrueTypeFont) parseCmap() error {
+	cmap := f.tables["cmap"]
+	if len(cmap) < 4 {
+		return errors.New("invalid cmap table")
+	}
+	var format4, format12 []byte
+	for i := 0; i < int(binary.BigEndian.Uint16(cmap[2:])); i++ {
+		rec := cmap[4+8*i:]
+		if len(rec) < 8 {
+			return errors.New("invalid cmap table")
+		}
+		platform, encoding := binary.BigEndian.Uint16(rec), binary.BigEndian.Uint16(rec[2:])
+		offset := binary.BigEndian.Uint32(rec[4:])
+		if !(platform == 0 || platform == 3 && (encoding == 0 || encoding == 1 || encoding == 10)) || int(offset)+2 > len(cmap) {
+			continue
+		}
+		sub := cmap[offset:]
+		switch binary.BigEndian.Uint16(sub) {
+		case 4:
+			format4 = sub
+		case 12:
+			format12 = sub
+		}
+	}
+
+	f.cmap = make(map[rune]uint16)
+	switch {
+	case format12 != nil:
+		return f.parseCmap12(format12)
+	case format4 != nil:
+		return f.parseCmap4(format4)
+	default:
+		return errors.New("font has no Unicode cmap subtable")
+	}
+}
+
+func (f *TrueTypeFont) parseCmap4(sub []byte) error {
+	if len(sub) < 14 {
+		return errors.New("invalid cmap format 4 subtable")
+	}
+	segCount := int(binary.BigEndian.Uint16(sub[6:])) / 2
+	endCodes := 14
+	startCodes := endCodes + 2*segCount + 2
+	deltas := startCodes + 2*segCount
+	rangeOffsets := deltas + 2*segCount
+	if len(sub) < rangeOffsets+2*segCount {
+		return errors.New("invalid cmap format 4 subtable")
+	}
+	for i := 0; i < segCount; i++ {
+		end := binary.BigEndian.Uint16(sub[endCodes+2*i:])
+		start := binary.BigEndian.Uint16(sub[startCodes+2*i:])
+		delta := binary.BigEndian.Uint16(sub[deltas+2*i:])
+		rangeOffset := int(binary.BigEndian.Uint16(sub[rangeOffsets+2*i:]))
+		for c := uint32(start); c <= uint32(end) && c != 0xFFFF; c++ {
+			var gid uint16
+			if rangeOffset == 0 {
+				gid = uint16(c) + delta
+			} else {
+				pos := rangeOffsets + 2*i + rangeOffset + 2*int(c-uint32(start))
+				if pos+2 > len(sub) {
+					continue
+				}
+				if gid = binary.BigEndian.Uint16(sub[pos:]); gid != 0 {
+					gid += delta
+				}
+			}
+			if gid != 0 && int(gid) < f.numGlyphs {
+				f.cmap[rune(c)] = gid
+			}
+		}
+	}
+	return nil
+}
+
+func (f *TrueTypeFont) parseCmap12(sub []byte) error {
+	if len(sub) < 16 {
+		return errors.New("invalid cmap format 12 subtable")
+	}
+	numGroups := int(binary.BigEndian.Uint32(sub[12:]))
+	if len(sub) < 16+12*numGroups {
+		return errors.New("invalid cmap format 12 subtable")
+	}
+	for i := 0; i < numGroups; i++ {
+		group := sub[16+12*i:]
+		start, end := binary.BigEndian.Uint32(group), binary.BigEndian.Uint32(group[4:])
+		gid := binary.BigEndian.Uint32(group[8:])
+		if end > 0x10FFFF || start > end {
+			continue
+		}
+		for c := start; c <= end; c++ {
+			if g := gid + c - start; g != 0 && int(g) < f.numGlyphs {
+				f.cmap[rune(c)] = uint16(g)
+			}
+		}
+	}
+	return nil
+}
+
+// parseOS2 reads the vertical metrics and the embedding permissions.
+func (f *TrueTypeFont) parseOS2() error {
+	f.capHeight = f.ascent
+	os2 := f.tables["OS/2"]
+	if len(os2) < 72 {
+		return nil
+	}
+	// fsType 0x0002 is "restricted license embedding".
+	if binary.BigEndian.Uint16(os2[8:])&0x000F == 0x0002 {
+		return errors.New("font license does not permit embedding")
+	}
+	f.ascent = int16(binary.BigEndian.Uint16(os2[68:]))
+	f.descent = int16(binary.BigEndian.Uint16(os2[70:]))
+	if len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = int16(binary.BigEndian.Uint16(os2[88:]))
+	} else {
+		f.capHeight = f.ascent
+	}
+	return nil
+}
+
+// parseName returns the PostScript name (name ID 6), reduced to the
+// characters allowed in a PDF name.
+func (f *TrueTypeFont) parseName() string {
+	name := f.tables["name"]
+	if len(name) < 6 {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint16(name[2:]))
+	storage := int(binary.BigEndian.Uint16(name[4:]))
+	for i := 0; i < count; i++ {
+		rec := name[6+12*i:]
+		if len(rec) < 12 || binary.BigEndian.Uint16(rec[6:]) != 6 {
+			continue
+		}
+		platform := binary.BigEndian.Uint16(rec)
+		length := int(binary.BigEndian.Uint16(rec[8:]))
+		offset := storage + int(binary.BigEndian.Uint16(rec[10:]))
+		if offset+length > len(name) {
+			continue
+		}
+		raw := name[offset : offset+length]
+		var s string
+		switch platform {
+		case 0, 3:
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(raw[2*j:])
+			}
+			s = string(utf16.Decode(units))
+		case 1:
+			s = string(raw)
+		default:
+			continue
+		}
+		s = strings.Map(func(r rune) rune {
+			if r > 32 && r < 127 && !strings.ContainsRune("()<>[]{}/%#", r) {
+				return r
+			}
+			return -1
+		}, s)
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// glyphIndex returns the glyph for r, or 0 (.notdef) when the font has none.
+func (f *TrueTypeFont) glyphIndex(r rune) uint16 {
+	return f.cmap[r]
+}
+
+// HasGlyph reports whether the font has a glyph for r.
+func (f *TrueTypeFont) HasGlyph(r rune) bool {
+	_, ok := f.cmap[r]
+	return ok
+}
+
+// textWidth returns the width of s in points when set at size.
+func (f *TrueTypeFont) textWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		units += float64(f.advances[f.glyphIndex(r)])
+	}
+	return units * size / float64(f.unitsPerEm)
+}
+
+// glyphData returns the glyf entry of gid.
+func (f *TrueTypeFont) glyphData(gid uint16) []byte {
+	loca, glyf := f.tables["loca"], f.tables["glyf"]
+	var start, end uint32
+	if f.longLoca {
+		start = binary.BigEndian.Uint32(loca[4*int(gid):])
+		end = binary.BigEndian.Uint32(loca[4*int(gid)+4:])
+	} else {
+		start = 2 * uint32(binary.BigEndian.Uint16(loca[2*int(gid):]))
+		end = 2 * uint32(binary.BigEndian.Uint16(loca[2*int(gid)+2:]))
+	}
+	if start >= end || end > uint32(len(glyf)) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// Composite glyph component flags.
+const (
+	glyfArgsAreWords    = 0x0001
+	glyfHaveScale       = 0x0008
+	glyfMoreComponents  = 0x0020
+	glyfHaveXYScale     = 0x0040
+	glyfHaveTwoByTwo    = 0x0080
+	compositeHeaderSize = 10
+)
+
+// glyphComponents returns the offsets of the glyph index fields of a
+// composite glyph; simple glyphs have none.
+func glyphComponents(data []byte) []int {
+	if len(data) < compositeHeaderSize || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil
+	}
+	var offsets []int
+	pos := compositeHeaderSize
+	for pos+4 <= len(data) {
+		flags := binary.BigEndian.Uint16(data[pos:])
+		offsets = append(offsets, pos+2)
+		pos += 4
+		if flags&glyfArgsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&glyfHaveScale != 0:
+			pos += 2
+		case flags&glyfHaveXYScale != 0:
+			pos += 4
+		case flags&glyfHaveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&glyfMoreComponents == 0 {
+			break
+		}
+	}
+	return offsets
+}
+
+// fontSubset is the part of a TrueTypeFont used by one appearance. Glyphs
+// are renumbered densely, .notdef first, and the new glyph IDs are used as
+// CIDs (CIDToGIDMap /Identity).
+type fontSubset struct {
+	font    *TrueTypeFont
+	glyphs  []uint16          // original glyph ID of each subset glyph
+	newGID  map[uint16]uint16 // original to subset glyph ID
+	unicode map[uint16]rune   // subset glyph ID to character
+}
+
+// CheckText returns an error wrapping ErrTextNeedsShaping when text cannot
+// be drawn correctly by mapping each character to one glyph.
+func (f *TrueTypeFont) CheckText(text string) error {
+	for _, r := range text {
+		if unicode.Is(unicode.Mn, r) || unicode.IsOneOf(shapedScripts, r) {
+			return fmt.Errorf("%w: %q (U+%04X)", ErrTextNeedsShaping, r, r)
+		}
+	}
+	return nil
+}
+
+// subset returns the subset of f needed to draw text, including the
+// components of composite glyphs. Text that needs shaping is rejected.
+func (f *TrueTypeFont) subset(text string) (*fontSubset, error) {
+	if err := f.CheckText(text); err != nil {
+		return nil, err
+	}
+	s := &fontSubset{font: f, newGID: make(map[uint16]uint16), unicode: make(map[uint16]rune)}
+	used := map[uint16]bool{0: true}
+	var visit func(gid uint16)
+	visit = func(gid uint16) {
+		if used[gid] {
+			return
+		}
+		used[gid] = true
+		data := f.glyphData(gid)
+		for _, off := range glyphComponents(data) {
+			if c := binary.BigEndian.Uint16(data[off:]); int(c) < f.numGlyphs {
+				visit(c)
+			}
+		}
+	}
+	for _, r := range text {
+		if gid := f.glyphIndex(r); gid != 0 {
+			visit(gid)
+		}
+	}
+
+	for gid := range used {
+		s.glyphs = append(s.glyphs, gid)
+	}
+	sort.Slice(s.glyphs, func(i, j int) bool { return s.glyphs[i] < s.glyphs[j] })
+	for i, gid := range s.glyphs {
+		s.newGID[gid] = uint16(i)
+	}
+	for _, r := range text {
+		if gid := f.glyphIndex(r); gid != 0 {
+			if _, ok := s.unicode[s.newGID[gid]]; !ok {
+				s.unicode[s.newGID[gid]] = r
+			}
+		}
+	}
+	return s, nil
+}
+
+// textWidth returns the width of s in points when set at size.
+func (s *fontSubset) textWidth(text string, size float64) float64 {
+	return s.font.textWidth(text, size)
+}
+
+// encodeText returns text as a hexadecimal string of subset glyph IDs for
+// an Identity-H encoded font.
+func (s *fontSubset) encodeText(text string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range text {
+		fmt.Fprintf(&b, "%04X", s.newGID[s.font.glyphIndex(r)])
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// tag returns the six letter subset tag prefixed to the font name. It is
+// derived from the glyph set so identical subsets share a name.
+func (s *fontSubset) tag() string {
+	var h uint32 = 2166136261
+	for _, gid := range s.glyphs {
+		h = (h ^ uint32(gid)) * 16777619
+	}
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = byte('A' + h%26)
+		h /= 26
+	}
+	return string(tag)
+}
+
+// baseFont returns the font name with the subset tag.
+func (s *fontSubset) baseFont() string {
+	return s.tag() + "+" + s.font.PostScriptName
+}
+
+// scaled converts font units to PDF glyph space (1/1000 em).
+func (s *fontSubset) scaled(v int) int {
+	return int(math.Round(float64(v) * 1000 / float64(s.font.unitsPerEm)))
+}
+
+// fontFile builds the subset font program.
+func (s *fontSubset) fontFile() []byte {
+	f := s.font
+	var glyf []byte
+	loca := make([]byte, 4*(len(s.glyphs)+1))
+	for i, gid := range s.glyphs {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(len(glyf)))
+		data := append([]byte(nil), f.glyphData(gid)...)
+		for _, off := range glyphComponents(data) {
+			binary.BigEndian.PutUint16(data[off:], s.newGID[binary.BigEndian.Uint16(data[off:])])
+		}
+		glyf = append(glyf, data...)
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*len(s.glyphs):], uint32(len(glyf)))
+
+	hmtx := make([]byte, 4*len(s.glyphs))
+	for i, gid := range s.glyphs {
+		binary.BigEndian.PutUint16(hmtx[4*i:], f.advances[gid])
+		binary.BigEndian.PutUint16(hmtx[4*i+2:], uint16(glyphLSB(f.glyphData(gid))))
+	}
+
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)  // checkSumAdjustment
+	binary.BigEndian.PutUint16(head[50:], 1) // long loca offsets
+	hhea := append([]byte(nil), f.tables["hhea"]...)
+	binary.BigEndian.PutUint16(hhea[34:], uint16(len(s.glyphs)))
+	maxp := append([]byte(nil), f.tables["maxp"]...)
+	binary.BigEndian.PutUint16(maxp[4:], uint16(len(s.glyphs)))
+
+	tables := map[string][]byte{"glyf": glyf, "loca": loca, "hmtx": hmtx, "head": head, "hhea": hhea, "maxp": maxp}
+	// The hinting tables do not depend on glyph IDs and are kept as is.
+	for _, tag := range []string{"cvt ", "fpgm", "prep"} {
+		if t := f.tables[tag]; t != nil {
+			tables[tag] = t
+		}
+	}
+	return writeSFNT(tables)
+}
+
+// glyphLSB returns the left side bearing of a glyph, which TrueType fonts
+// normally set to the glyph's xMin.
+func glyphLSB(data []byte) int16 {
+	if len(data) < 4 {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(data[2:]))
+}
+
+// writeSFNT assembles a TrueType font file from its tables.
+func writeSFNT(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	numTables := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	header := make([]byte, 12+16*numTables)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(16*numTables-searchRange))
+
+	var body []byte
+	headOffset := 0
+	for i, tag := range tags {
+		data := tables[tag]
+		offset := len(header) + len(body)
+		rec := header[12+16*i:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[4:], sfntChecksum(data))
+		binary.BigEndian.PutUint32(rec[8:], uint32(offset))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+		if tag == "head" {
+			headOffset = offset
+		}
+		body = append(body, data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+	out := append(header, body...)
+	if headOffset > 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-sfntChecksum(out))
+	}
+	return out
+}
+
+// sfntChecksum is the TrueType table checksum: the sum of big-endian
+// uint32 words, zero padded.
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,319 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattetti/filebuffer"
+)
+
+// testFontGlyphs maps characters to the glyphs of the font built by
+// buildTestFont. Glyph 3 (Ä) is a composite of glyphs 1 (A) and 4
+// (dieresis); glyph 5 is never used.
+var testFontGlyphs = map[rune]uint16{'A': 1, 'B': 2, 'Ä': 3, 'C': 5, '你': 6}
+
+// buildTestFont returns a minimal TrueType font with 1000 units per em.
+func buildTestFont(t *testing.T, fsType uint16) []byte {
+	t.Helper()
+	const numGlyphs = 7
+	be16 := func(b []byte, vs ...int) []byte {
+		for _, v := range vs {
+			b = binary.BigEndian.AppendUint16(b, uint16(v))
+		}
+		return b
+	}
+
+	// A triangle with on-curve points and 16-bit coordinates.
+	simple := func(xMin, xMax int) []byte {
+		g := be16(nil, 1, xMin, 0, xMax, 700, 2, 0)
+		g = append(g, 1, 1, 1)
+		g = be16(g, xMin, xMax-xMin, -(xMax - xMin))
+		return be16(g, 0, 700, -700)
+	}
+	composite := be16(nil, -1, 10, 0, 600, 900)
+	composite = be16(composite, 0x0023, 1, 0, 0) // words, xy values, more components
+	composite = be16(composite, 0x0003, 4, 100, 750)
+	glyphs := [numGlyphs][]byte{simple(50, 450), simple(10, 600), simple(60, 550), composite, simple(0, 300), simple(40, 500), simple(30, 950)}
+	advances := [numGlyphs]int{500, 620, 580, 620, 320, 540, 1000}
+
+	var glyf, loca []byte
+	for _, g := range glyphs {
+		loca = binary.BigEndian.AppendUint32(loca, uint32(len(glyf)))
+		glyf = append(glyf, g...)
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+	}
+	loca = binary.BigEndian.AppendUint32(loca, uint32(len(glyf)))
+
+	var hmtx []byte
+	for i, adv := range advances {
+		hmtx = be16(hmtx, adv, int(glyphLSB(glyphs[i])))
+	}
+
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint32(head, 0x00010000)
+	binary.BigEndian.PutUint32(head[12:], 0x5F0F3CF5)
+	binary.BigEndian.PutUint16(head[18:], 1000)
+	be16(head[:36], 0, -200, 1000, 900)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	hhea := make([]byte, 36)
+	binary.BigEndian.PutUint32(hhea, 0x00010000)
+	be16(hhea[:4], 800, -200)
+	binary.BigEndian.PutUint16(hhea[34:], numGlyphs)
+
+	maxp := be16([]byte{0, 0, 0x50, 0}, numGlyphs)
+
+	// cmap format 4 with one segment per character.
+	chars := make([]int, 0, len(testFontGlyphs))
+	for r := range testFontGlyphs {
+		chars = append(chars, int(r))
+	}
+	sort.Ints(chars)
+	segCount := len(chars) + 1
+	var ends, starts, deltas, offsets []byte
+	for _, c := range chars {
+		ends, starts = be16(ends, c), be16(starts, c)
+		deltas = be16(deltas, int(testFontGlyphs[rune(c)])-c)
+		offsets = be16(offsets, 0)
+	}
+	ends, starts, deltas, offsets = be16(ends, 0xFFFF), be16(starts, 0xFFFF), be16(deltas, 1), be16(offsets, 0)
+	sub := be16(nil, 4, 0, 0, 2*segCount, 0, 0, 0)
+	sub = be16(append(sub, ends...), 0) // reservedPad
+	sub = append(append(append(sub, starts...), deltas...), offsets...)
+	binary.BigEndian.PutUint16(sub[2:], uint16(len(sub)))
+	cmap := append(be16(nil, 0, 1, 3, 1, 0, 12), sub...)
+
+	psName := "Test-Regular"
+	var nameUTF16 []byte
+	for _, r := range psName {
+		nameUTF16 = be16(nameUTF16, int(r))
+	}
+	name := append(be16(nil, 0, 1, 18, 3, 1, 0x409, 6, len(nameUTF16), 0), nameUTF16...)
+
+	os2 := make([]byte, 96)
+	be16(os2[:0], 4)
+	binary.BigEndian.PutUint16(os2[8:], fsType)
+	be16(os2[:68], 750, -250)
+	binary.BigEndian.PutUint16(os2[88:], 700)
+
+	post := make([]byte, 32)
+	binary.BigEndian.PutUint32(post, 0x00030000)
+
+	return writeSFNT(map[string][]byte{
+		"head": head, "hhea": hhea, "maxp": maxp, "hmtx": hmtx, "cmap": cmap,
+		"loca": loca, "glyf": glyf, "name": name, "OS/2": os2, "post": post,
+	})
+}
+
+func TestParseTrueTypeFont(t *testing.T) {
+	f, err := ParseTrueTypeFont(buildTestFont(t, 0))
+	if err != nil {
+		t.Fatalf("ParseTrueTypeFont() error = %v", err)
+	}
+	if f.PostScriptName != "Test-Regular" || f.unitsPerEm != 1000 || f.numGlyphs != 7 {
+		t.Errorf("name %q, unitsPerEm %d, numGlyphs %d", f.PostScriptName, f.unitsPerEm, f.numGlyphs)
+	}
+	for r, gid := range testFontGlyphs {
+		if got := f.glyphIndex(r); got != gid {
+			t.Errorf("glyphIndex(%q) = %d, want %d", r, got, gid)
+		}
+	}
+	if f.HasGlyph('Z') {
+		t.Error("HasGlyph('Z') = true")
+	}
+	if f.ascent != 750 || f.descent != -250 || f.capHeight != 700 {
+		t.Errorf("ascent %d, descent %d, capHeight %d", f.ascent, f.descent, f.capHeight)
+	}
+	if got, want := f.textWidth("AB你", 10), (620+580+1000)*10.0/1000; got != want {
+		t.Errorf("textWidth() = %v, want %v", got, want)
+	}
+}
+
+func TestParseTrueTypeFontErrors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"restricted": buildTestFont(t, 0x0002),
+		"cff header": append([]byte("OTTO"), make([]byte, 8)...),
+		"collection": append([]byte("ttcf"), make([]byte, 8)...),
+		"garbage":    []byte("not a font at all"),
+		"truncated":  buildTestFont(t, 0)[:40],
+	} {
+		if _, err := ParseTrueTypeFont(data); err == nil {
+			t.Errorf("%s: ParseTrueTypeFont() error = nil", name)
+		}
+	}
+
+	// A font with valid tables but CFF outlines is rejected by its tag.
+	cff := append([]byte("OTTO"), buildTestFont(t, 0)[4:]...)
+	if _, err := ParseTrueTypeFont(cff); err == nil || !strings.Contains(err.Error(), "CFF outlines") {
+		t.Errorf("ParseTrueTypeFont() of a CFF font: error = %v, want CFF outlines not supported", err)
+	}
+}
+
+func TestFontSubset(t *testing.T) {
+	f, err := ParseTrueTypeFont(buildTestFont(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := f.subset("AÄ A")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// .notdef, A, Ä and the dieresis used by Ä.
+	if got := s.glyphs; len(got) != 4 || got[0] != 0 || got[1] != 1 || got[2] != 3 || got[3] != 4 {
+		t.Fatalf("subset glyphs = %v, want [0 1 3 4]", got)
+	}
+	if got := s.encodeText("AÄZ"); got != "<000100020000>" {
+		t.Errorf("encodeText() = %s", got)
+	}
+	if tag := s.tag(); len(tag) != 6 || strings.Trim(tag, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		t.Errorf("tag() = %q", tag)
+	}
+
+	program := s.fontFile()
+	if sum := sfntChecksum(program); sum != 0xB1B0AFBA {
+		t.Errorf("font checksum = %#x, want 0xB1B0AFBA", sum)
+	}
+	tables, err := readSFNTTables(program)
+	if err != nil {
+		t.Fatalf("readSFNTTables() error = %v", err)
+	}
+	if _, ok := tables["cmap"]; ok {
+		t.Error("subset keeps the cmap table")
+	}
+	if n := binary.BigEndian.Uint16(tables["maxp"][4:]); n != 4 {
+		t.Errorf("subset numGlyphs = %d, want 4", n)
+	}
+	sub := &TrueTypeFont{tables: tables, longLoca: true}
+	var components []uint16
+	data := sub.glyphData(2)
+	for _, off := range glyphComponents(data) {
+		components = append(components, binary.BigEndian.Uint16(data[off:]))
+	}
+	if len(components) != 2 || components[0] != 1 || components[1] != 3 {
+		t.Errorf("composite components = %v, want renumbered [1 3]", components)
+	}
+
+	cmap := string(s.toUnicodeCMap())
+	for _, want := range []string{"2 beginbfchar", "<0001> <0041>", "<0002> <00C4>"} {
+		if !strings.Contains(cmap, want) {
+			t.Errorf("ToUnicode CMap does not contain %q:\n%s", want, cmap)
+		}
+	}
+}
+
+func TestFontCheckText(t *testing.T) {
+	f, err := ParseTrueTypeFont(buildTestFont(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"Jane Doe", "Łódź", "你好", "Ελληνικά", "Кириллица"} {
+		if err := f.CheckText(text); err != nil {
+			t.Errorf("CheckText(%q) error = %v", text, err)
+		}
+	}
+	for name, text := range map[string]string{
+		"arabic":         "مرحبا",
+		"hebrew":         "שלום",
+		"devanagari":     "नमस्ते",
+		"tamil":          "வணக்கம்",
+		"thai":           "ภาษาไทย",
+		"combining mark": "e\u0301",
+	} {
+		if err := f.CheckText(text); !errors.Is(err, ErrTextNeedsShaping) {
+			t.Errorf("%s: CheckText(%q) error = %v, want ErrTextNeedsShaping", name, text, err)
+		}
+	}
+	if _, err := f.subset("שלום"); !errors.Is(err, ErrTextNeedsShaping) {
+		t.Errorf("subset() error = %v, want ErrTextNeedsShaping", err)
+	}
+}
+
+func TestCreateAppearanceEmbeddedFont(t *testing.T) {
+	f, err := ParseTrueTypeFont(buildTestFont(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := SignContext{SignData: SignData{
+		Signature:  SignDataSignature{Info: SignDataSignatureInfo{Name: "ABC 你"}},
+		Appearance: Appearance{EmbeddedFont: f, FontSize: 10},
+	}}
+	context.OutputBuffer = filebuffer.New(nil)
+	context.lastXrefID = 10
+
+	appearance, err := context.createAppearance([4]float64{0, 0, 200, 50}, 0)
+	if err != nil {
+		t.Fatalf("createAppearance() error = %v", err)
+	}
+	if !strings.Contains(string(appearance), "/F1 15 0 R") || !strings.Contains(string(appearance), "<00010002000300000004> Tj") {
+		t.Errorf("appearance does not use the embedded font:\n%s", appearance)
+	}
+	all := context.OutputBuffer.Buff.String()
+	for _, want := range []string{"/Subtype /CIDFontType2", "/CIDToGIDMap /Identity", "/Encoding /Identity-H", "/FontFile2 11 0 R", "+Test-Regular"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("font objects do not contain %q", want)
+		}
+	}
+}
+
+func TestSignPDFEmbeddedFont(t *testing.T) {
+	font, err := ParseTrueTypeFont(buildTestFont(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, pkey := loadCertificateAndKey(t)
+
+	tmpfile, err := os.CreateTemp("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(tmpfile.Name()) }()
+
+	signData := SignData{
+		Signature: SignDataSignature{
+			Info:     SignDataSignatureInfo{Name: "AB C", Date: time.Now()},
+			CertType: ApprovalSignature,
+		},
+		Appearance: Appearance{
+			Visible:      true,
+			LowerLeftX:   350,
+			LowerLeftY:   75,
+			UpperRightX:  550,
+			UpperRightY:  125,
+			EmbeddedFont: font,
+			SignerUID:    "6a616e652e736d697468406578616d706c652e636f6d",
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+	}
+	if _, err := SignFile("../testfiles/testfile50.pdf", tmpfile.Name(), signData); err != nil {
+		t.Fatalf("SignFile() error = %v", err)
+	}
+	verifyAllSignaturesValid(t, tmpfile, 1)
+
+	out, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(out, []byte("/Subtype /CIDFontType2")); n < 2 {
+		t.Errorf("found %d embedded font subsets, want separate subsets for the signature and the initials", n)
+	}
+
+	// Text that needs shaping fails the signature instead of being drawn
+	// with disconnected or misordered glyphs.
+	signData.Signature.Info.Name = "مرحبا"
+	signData.Appearance.SignerUID = ""
+	if _, err := SignFile("../testfiles/testfile50.pdf", tmpfile.Name(), signData); !errors.Is(err, ErrTextNeedsShaping) {
+		t.Errorf("SignFile() with Arabic text: error = %v, want %v", err, ErrTextNeedsShaping)
+	}
+}
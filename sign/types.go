@@ -36,6 +36,42 @@ type SignData struct {
 	RevocationFunction RevocationFunction
 	Appearance         Appearance
 
+	// CompleteChain downloads issuers missing from CertificateChains[0] via
+	// the caIssuers URLs of the certificates (see AIAFetcher) before signing.
+	// Signing fails when no chain up to a self-signed root can be built.
+	CompleteChain bool
+	// FormFields are filled into the AcroForm fields of the document in the
+	// same incremental update as the signature (see FillForm to fill them
+	// without signing).
+	FormFields []FormFieldValue
+	// PermissionPolicy decides what happens when an existing certification
+	// signature or signature field lock does not permit the update
+	// (default PermissionRefuse).
+	PermissionPolicy PermissionPolicy
+	// Password opens an encrypted document in SignFile and when SignLTA
+	// re-reads the signed document. It may be the user or the owner
+	// password; only the owner password lifts the access permissions (/P)
+	// of the document. Sign and SignLTV use the reader passed to them,
+	// which should be opened with pdf.NewReaderPassword.
+	Password string
+	// DecryptionCertificate and DecryptionKey open in SignFile, and when
+	// SignLTA re-reads the signed document, a document encrypted for
+	// recipients with the public-key security handler (/Adobe.PubSec). The
+	// access permissions granted to the recipient apply.
+	DecryptionCertificate *x509.Certificate
+	DecryptionKey         crypto.Decrypter
+	// Recover opens a document with missing or broken cross-reference data
+	// in SignFile by rebuilding it (see pdf.ReaderOptions). Sign rewrites a
+	// document that was repaired when it was opened as a new file (a full
+	// save) before signing it, since an incremental update cannot build on
+	// its cross-reference data; repaired documents that are already signed
+	// or encrypted are refused.
+	Recover bool
+	// Encryption, when set, rewrites the document encrypted with AES-256
+	// (a full save) and signs the encrypted revision. The document must not
+	// be signed yet; SignLTA opens its result with the owner password.
+	Encryption *Encryption
+
 	objectId uint32
 }
 
@@ -43,14 +79,72 @@ type SignData struct {
 type Appearance struct {
 	Visible bool
 
+	// Page and the rectangle place a visible signature; the rectangle is
+	// given in default user space. On pages with /Rotate the appearance is
+	// rotated to read upright, and the rectangle is clipped to the CropBox.
 	Page        uint32
 	LowerLeftX  float64
 	LowerLeftY  float64
 	UpperRightX float64
 	UpperRightY float64
+	// Anchor, when set, places the visible signature relative to text found
+	// in the document; Page and the rectangle coordinates are then computed.
+	Anchor *Anchor
+	// Placement, when set, places the visible signature relative to a corner
+	// of the visible page area on Page, with lengths in points, millimetres
+	// or percentages; the rectangle coordinates are then computed.
+	Placement *Placement
+	// Pages, when set, shows the signature on each listed page instead of
+	// Page, and AllPages shows it on every page. Each page gets its own
+	// widget annotation and appearance, placed by the rectangle or
+	// Placement, all belonging to the same signature field.
+	Pages    []uint32
+	AllPages bool
 
 	Image            []byte // Image data to use as signature appearance
 	ImageAsWatermark bool   // If true, the text will be drawn over the image
+	// ImagePosition places the image relative to the text: ImageFill (default,
+	// the image covers the rectangle and text is only drawn with
+	// ImageAsWatermark), ImageLeft, ImageRight or ImageBehind.
+	ImagePosition string
+
+	// Text is a text/template for the visible text, rendered with
+	// AppearanceTextData; each line of the result is a paragraph, blank lines
+	// are dropped. When empty, Signature.Info.Name is drawn.
+	Text string
+	// Font is the standard font used for the text (default FontTimesRoman).
+	Font StandardFont
+	// EmbeddedFont, when set, is used instead of Font for the signature text
+	// and for filled initials and date fields. The glyphs used are embedded
+	// as a font subset, so any script covered by the font that needs no
+	// shaping can be drawn. Text in Arabic, Hebrew, the Indic scripts, Thai
+	// and other scripts that need shaping, and text with combining marks,
+	// fails with ErrTextNeedsShaping. OpenType fonts with CFF outlines
+	// ("OTTO") cannot be loaded (see ParseTrueTypeFont).
+	EmbeddedFont *TrueTypeFont
+	// FontSize is the font size in points. When zero, the largest size at
+	// which the wrapped text fits the rectangle is used.
+	FontSize float64
+	// LineSpacing is the distance between baselines as a multiple of the
+	// font size (default 1.2).
+	LineSpacing float64
+	// TextAlign is AlignLeft, AlignCenter (default) or AlignRight.
+	TextAlign string
+	// VerticalAlign is AlignTop, AlignMiddle (default) or AlignBottom.
+	VerticalAlign string
+	// Padding is the space in points kept free inside the border.
+	Padding float64
+	// TextColor defaults to a ballpoint-like dark blue.
+	TextColor *Color
+	// BackgroundColor fills the rectangle when set.
+	BackgroundColor *Color
+	// BorderColor and BorderWidth draw a border when both are set.
+	BorderColor *Color
+	BorderWidth float64
+	// QRCode, when set, adds a QR code such as a verification link to the
+	// appearance, beside or over the image and text.
+	QRCode *QRCode
+
 	// SignerUID, when set, will cause the signer initials to be filled into
 	// AcroForm fields matching the pattern:
 	//   initials_page_${pageIndex}_signer_${signer_uid}
@@ -77,6 +171,28 @@ type Appearance struct {
 	Locale string
 }
 
+// Color is an RGB colour with components between 0 and 1.
+type Color struct {
+	R, G, B float64
+}
+
+const (
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+
+	AlignTop    = "top"
+	AlignMiddle = "middle"
+	AlignBottom = "bottom"
+)
+
+const (
+	ImageFill   = "fill"
+	ImageLeft   = "left"
+	ImageRight  = "right"
+	ImageBehind = "behind"
+)
+
 const (
 	DateStyleNumeric  = "numeric"
 	DateStyleDateOnly = "date-only"
@@ -87,6 +203,20 @@ const (
 type VisualSignData struct {
 	pageObjectId uint32
 	objectId     uint32
+
+	// widgets are the widget annotations of a signature shown on several
+	// pages; they are written right after the signature field object.
+	widgets []signatureWidget
+	// imageObjectId is the image XObject shared by the appearances.
+	imageObjectId uint32
+}
+
+// signatureWidget is one widget annotation of a multi-page signature field.
+type signatureWidget struct {
+	pageNumber   uint32
+	pageObjectId uint32
+	objectId     uint32
+	object       []byte
 }
 
 // existingSignatureField identifies a prior signature widget annotation listed
@@ -163,6 +293,7 @@ type SignContext struct {
 	newXrefEntries     []xrefEntry
 	updatedXrefEntries []xrefEntry
 	encryption         *EncryptionContext
+	embeddedFonts      map[string]uint32 // subset font name to Type0 font object ID
 
 	// Computed signature information
 	computedDocumentHash  string
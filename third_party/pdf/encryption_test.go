@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"testing"
 )
@@ -91,7 +95,7 @@ func TestAuthenticateV5(t *testing.T) {
 	u, _ := hex.DecodeString(uHex)
 	ue, _ := hex.DecodeString(ueHex)
 
-	fek, ok := authenticateV5Password(pwd, u, ue)
+	fek, ok := authenticateV5Password(5, pwd, u, ue, nil)
 	if !ok {
 		t.Fatal("Authentication failed")
 	}
@@ -189,3 +193,178 @@ func TestOkayV4(t *testing.T) {
 		t.Error("expected false for missing CF")
 	}
 }
+
+// standardSecurityR3 computes the O and U entries and the file key of the
+// 128-bit RC4 standard security handler (Algorithms 3 and 5).
+func standardSecurityR3(user, owner string, p uint32, id []byte) (o, u, key []byte) {
+	sum := md5.Sum(padPassword([]byte(owner)))
+	ownerKey := sum[:]
+	for i := 0; i < 50; i++ {
+		sum = md5.Sum(ownerKey)
+		ownerKey = sum[:]
+	}
+	o = padPassword([]byte(user))
+	for i := 0; i <= 19; i++ {
+		k := make([]byte, len(ownerKey))
+		for j := range k {
+			k[j] = ownerKey[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(k)
+		c.XORKeyStream(o, o)
+	}
+
+	key, _ = userKeyV4([]byte(user), string(o), "", p, id, 3, 128, true)
+	sum = md5.Sum(append(append([]byte{}, passwordPad...), id...))
+	u = sum[:]
+	for i := 0; i <= 19; i++ {
+		k := make([]byte, len(key))
+		for j := range k {
+			k[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(k)
+		c.XORKeyStream(u, u)
+	}
+	return o, append(u, make([]byte, 16)...), key
+}
+
+// encryptedTestPDF returns a one page document encrypted with 128-bit RC4.
+func encryptedTestPDF(user, owner string, p uint32) []byte {
+	id := []byte("0123456789abcdef")
+	o, u, key := standardSecurityR3(user, owner, p, id)
+	content, _ := EncryptStream(key, false, 2, 4, 0, []byte("BT /F1 24 Tf 72 700 Td (Protected) Tj ET"))
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		fmt.Sprintf("<< /Filter /Standard /V 2 /R 3 /Length 128 /P %d /O <%x> /U <%x> >>", int32(p), o, u),
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Encrypt 5 0 R /ID [<%x> <%x>] >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, id, id, xref)
+	return buf.Bytes()
+}
+
+func TestNewReaderPassword(t *testing.T) {
+	const restricted = 0xfffff0c0 // no printing, changes, copying or form filling
+	data := encryptedTestPDF("user", "owner", restricted)
+	open := func(password string) (*Reader, error) {
+		return NewReaderPassword(bytes.NewReader(data), int64(len(data)), password)
+	}
+
+	if _, err := open(""); err != ErrInvalidPassword {
+		t.Errorf("NewReaderPassword() without password: error = %v, want %v", err, ErrInvalidPassword)
+	}
+	if _, err := open("wrong"); err != ErrInvalidPassword {
+		t.Errorf("NewReaderPassword() with a wrong password: error = %v, want %v", err, ErrInvalidPassword)
+	}
+
+	for _, tt := range []struct {
+		password string
+		owner    bool
+		perm     uint32
+	}{
+		{"user", false, restricted},
+		{"owner", true, 0xffffffff},
+	} {
+		r, err := open(tt.password)
+		if err != nil {
+			t.Fatalf("NewReaderPassword(%q) error = %v", tt.password, err)
+		}
+		if r.OwnerAuthenticated() != tt.owner || r.Permissions() != tt.perm {
+			t.Errorf("NewReaderPassword(%q): owner = %v, permissions = %#x", tt.password, r.OwnerAuthenticated(), r.Permissions())
+		}
+		var content bytes.Buffer
+		if _, err := io.Copy(&content, r.Page(1).V.Key("Contents").Reader()); err != nil || !bytes.Contains(content.Bytes(), []byte("(Protected)")) {
+			t.Errorf("NewReaderPassword(%q): content = %q, %v", tt.password, content.Bytes(), err)
+		}
+	}
+
+	// Without a user password the document opens for everyone; the owner
+	// password still grants owner access.
+	data = encryptedTestPDF("", "owner", restricted)
+	if r, err := open(""); err != nil || r.OwnerAuthenticated() || r.Permissions() != restricted {
+		t.Errorf("NewReaderPassword() with an empty user password: %v", err)
+	}
+	if r, err := open("owner"); err != nil || !r.OwnerAuthenticated() {
+		t.Errorf("NewReaderPassword(owner) with an empty user password: %v", err)
+	}
+}
+
+func TestStandardSecurityV5(t *testing.T) {
+	p := uint32(0xfffff0c4) // printing only
+	sec, err := NewStandardSecurityV5("user", "owner", p, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sec.U) != 48 || len(sec.O) != 48 || len(sec.UE) != 32 || len(sec.OE) != 32 || len(sec.Perms) != 16 {
+		t.Fatalf("entry lengths: U %d, O %d, UE %d, OE %d, Perms %d", len(sec.U), len(sec.O), len(sec.UE), len(sec.OE), len(sec.Perms))
+	}
+	if fek, ok := authenticateV5Password(6, "user", sec.U, sec.UE, nil); !ok || !bytes.Equal(fek, sec.Key) {
+		t.Error("user password does not recover the file encryption key")
+	}
+	if fek, ok := authenticateV5Password(6, "owner", sec.O, sec.OE, sec.U); !ok || !bytes.Equal(fek, sec.Key) {
+		t.Error("owner password does not recover the file encryption key")
+	}
+	if _, ok := authenticateV5Password(6, "owner", sec.U, sec.UE, nil); ok {
+		t.Error("owner password accepted as the user password")
+	}
+	if _, ok := authenticateV5Password(5, "user", sec.U, sec.UE, nil); ok {
+		t.Error("revision 6 entries accepted with the revision 5 hash")
+	}
+
+	block, _ := aes.NewCipher(sec.Key)
+	perms := make([]byte, 16)
+	block.Decrypt(perms, sec.Perms)
+	if binary.LittleEndian.Uint32(perms) != p || string(perms[8:12]) != "Tadb" {
+		t.Errorf("Perms decrypts to %x", perms)
+	}
+
+	content, _ := EncryptStream(sec.Key, true, 5, 4, 0, []byte("BT (Protected) Tj ET"))
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		fmt.Sprintf("<< /Filter /Standard /V 5 /R 6 /Length 256 /P %d /O <%x> /U <%x> /OE <%x> /UE <%x> /Perms <%x>"+
+			" /CF << /StdCF << /CFM /AESV3 /AuthEvent /DocOpen /Length 32 >> >> /StmF /StdCF /StrF /StdCF >>",
+			int32(p), sec.O, sec.U, sec.OE, sec.UE, sec.Perms),
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-2.0\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Encrypt 5 0 R /ID [<00> <00>] >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	r, err := NewReaderPassword(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "owner")
+	if err != nil {
+		t.Fatalf("NewReaderPassword() error = %v", err)
+	}
+	if !r.OwnerAuthenticated() {
+		t.Error("owner password did not give owner access")
+	}
+	stream := r.Page(1).V.Key("Contents")
+	if data, err := stream.RawData(); err != nil || string(data) != "BT (Protected) Tj ET" {
+		t.Errorf("RawData() = %q, %v", data, err)
+	}
+}
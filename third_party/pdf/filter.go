@@ -0,0 +1,362 @@
+package pdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxPredictorRow limits the row size of a predictor, so that malformed
+// parameters do not allocate unbounded memory.
+const maxPredictorRow = 1 << 24
+
+// newPredictorReader undoes the predictor of the DecodeParms param of a
+// FlateDecode or LZWDecode filter: TIFF predictor 2 or the PNG predictors
+// 10 to 15, where each row names its own PNG filter type (ISO 32000-2,
+// 7.4.4.4).
+func newPredictorReader(rd io.Reader, param Value) (io.Reader, error) {
+	pred := param.Key("Predictor").Int64()
+	if pred <= 1 {
+		return rd, nil
+	}
+	colors := intParam(param, "Colors", 1)
+	bpc := intParam(param, "BitsPerComponent", 8)
+	columns := intParam(param, "Columns", 1)
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("invalid predictor BitsPerComponent %d", bpc)
+	}
+	// Each factor is bounded before they are multiplied, so that the row
+	// size cannot overflow.
+	if colors < 1 || columns < 1 || colors > maxPredictorRow || columns > maxPredictorRow || colors*bpc*columns/8 > maxPredictorRow {
+		return nil, fmt.Errorf("invalid predictor Colors %d and Columns %d", colors, columns)
+	}
+	rowBytes := (colors*bpc*columns + 7) / 8
+	if rowBytes == 0 {
+		return nil, errors.New("invalid predictor: empty rows")
+	}
+
+	r := &predictorReader{r: rd, colors: colors, bpc: bpc}
+	switch {
+	case pred == 2:
+		r.row = make([]byte, rowBytes)
+	case pred >= 10 && pred <= 15:
+		r.png = true
+		r.bpp = max(1, colors*bpc/8)
+		r.row = make([]byte, 1+rowBytes)
+		r.prev = make([]byte, 1+rowBytes)
+	default:
+		return nil, fmt.Errorf("unknown predictor %d", pred)
+	}
+	return r, nil
+}
+
+// intParam returns the integer key of the filter parameters param, or def
+// when it is missing.
+func intParam(param Value, key string, def int) int {
+	v := param.Key(key)
+	if v.Kind() == Null {
+		return def
+	}
+	return int(v.Int64())
+}
+
+// predictorReader decodes a predictor row by row. A truncated last row is
+// decoded as far as it goes.
+type predictorReader struct {
+	r      io.Reader
+	png    bool
+	colors int
+	bpc    int
+	bpp    int    // bytes per pixel, at least 1 (PNG)
+	row    []byte // PNG rows start with their filter type
+	prev   []byte // previous PNG row
+	pend   []byte
+	err    error
+}
+
+func (r *predictorReader) Read(b []byte) (int, error) {
+	for len(r.pend) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.nextRow()
+	}
+	n := copy(b, r.pend)
+	r.pend = r.pend[n:]
+	return n, nil
+}
+
+func (r *predictorReader) nextRow() error {
+	n, err := io.ReadFull(r.r, r.row)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if n == 0 || r.png && n == 1 {
+		return err
+	}
+	row := r.row[:n]
+	if r.png {
+		if err := r.unpng(row); err != nil {
+			return err
+		}
+		r.pend = row[1:]
+		r.row, r.prev = r.prev, r.row
+	} else {
+		r.untiff(row)
+		r.pend = row
+	}
+	return err
+}
+
+// unpng undoes the PNG filter of row, the filter type followed by the data.
+func (r *predictorReader) unpng(row []byte) error {
+	data, prev := row[1:], r.prev[1:]
+	for i := range data {
+		var a, c byte
+		if i >= r.bpp {
+			a, c = data[i-r.bpp], prev[i-r.bpp]
+		}
+		b := prev[i]
+		switch row[0] {
+		case 0: // None
+		case 1: // Sub
+			data[i] += a
+		case 2: // Up
+			data[i] += b
+		case 3: // Average
+			data[i] += byte((int(a) + int(b)) / 2)
+		case 4:
+			data[i] += paeth(a, b, c)
+		default:
+			return fmt.Errorf("malformed PNG predictor: row filter type %d", row[0])
+		}
+	}
+	return nil
+}
+
+// paeth returns the Paeth predictor of a (left), b (above) and c (upper
+// left).
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// untiff undoes TIFF predictor 2: each component is the difference with
+// the same component of the pixel to its left.
+func (r *predictorReader) untiff(row []byte) {
+	samples := len(row) * 8 / r.bpc
+	mask := 1<<r.bpc - 1
+	for i := r.colors; i < samples; i++ {
+		setSample(row, i, r.bpc, (sample(row, i, r.bpc)+sample(row, i-r.colors, r.bpc))&mask)
+	}
+}
+
+// sample returns the sample i of row, of bpc bits.
+func sample(row []byte, i, bpc int) int {
+	switch bpc {
+	case 8:
+		return int(row[i])
+	case 16:
+		return int(row[2*i])<<8 | int(row[2*i+1])
+	}
+	bit := i * bpc
+	return int(row[bit/8]>>(8-bpc-bit%8)) & (1<<bpc - 1)
+}
+
+// setSample sets the sample i of row, of bpc bits, to v.
+func setSample(row []byte, i, bpc, v int) {
+	switch bpc {
+	case 8:
+		row[i] = byte(v)
+		return
+	case 16:
+		row[2*i], row[2*i+1] = byte(v>>8), byte(v)
+		return
+	}
+	bit := i * bpc
+	shift := 8 - bpc - bit%8
+	row[bit/8] = row[bit/8]&^byte((1<<bpc-1)<<shift) | byte(v<<shift)
+}
+
+const (
+	lzwClear = 256
+	lzwEOD   = 257
+	lzwFirst = 258
+	lzwMax   = 4096
+)
+
+// lzwReader decodes LZWDecode data: codes of 9 to 12 bits, most significant
+// bit first. With early change, the code width grows one code earlier than
+// in GIF (ISO 32000-2, 7.4.4.2).
+type lzwReader struct {
+	r      *bufio.Reader
+	early  int
+	bits   uint32
+	nbits  int
+	width  int
+	next   int // next code of the table
+	prev   int // previous code, or -1 after a clear code
+	prefix [lzwMax]uint16
+	suffix [lzwMax]byte
+	length [lzwMax]int
+	out    []byte
+	pend   []byte
+	err    error
+}
+
+func newLZWReader(rd io.Reader, earlyChange bool) *lzwReader {
+	r := &lzwReader{r: bufio.NewReader(rd)}
+	if earlyChange {
+		r.early = 1
+	}
+	for i := range 256 {
+		r.suffix[i] = byte(i)
+		r.length[i] = 1
+	}
+	r.clear()
+	return r
+}
+
+func (r *lzwReader) clear() {
+	r.width = 9
+	r.next = lzwFirst
+	r.prev = -1
+}
+
+func (r *lzwReader) Read(b []byte) (int, error) {
+	for len(r.pend) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.decode()
+	}
+	n := copy(b, r.pend)
+	r.pend = r.pend[n:]
+	return n, nil
+}
+
+// decode decodes the next code into r.pend.
+func (r *lzwReader) decode() error {
+	for r.nbits < r.width {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			return err // io.EOF for data without an EOD code
+		}
+		r.bits = r.bits<<8 | uint32(c)
+		r.nbits += 8
+	}
+	code := int(r.bits>>(r.nbits-r.width)) & (1<<r.width - 1)
+	r.nbits -= r.width
+
+	switch {
+	case code == lzwClear:
+		r.clear()
+		return nil
+	case code == lzwEOD:
+		return io.EOF
+	case code < 256 || code < r.next && code >= lzwFirst:
+		r.pend = r.expand(code)
+	case code == r.next && r.prev >= 0:
+		// The code being defined: the previous string and its first byte.
+		r.pend = r.expand(r.prev)
+		r.pend = append(r.pend, r.pend[0])
+	default:
+		return fmt.Errorf("malformed LZW data: invalid code %d", code)
+	}
+
+	if r.prev >= 0 && r.next < lzwMax {
+		r.prefix[r.next] = uint16(r.prev)
+		r.suffix[r.next] = r.pend[0]
+		r.length[r.next] = r.length[r.prev] + 1
+		r.next++
+	}
+	r.prev = code
+	if r.next+r.early >= 1<<r.width && r.width < 12 {
+		r.width++
+	}
+	return nil
+}
+
+// expand returns the string of code.
+func (r *lzwReader) expand(code int) []byte {
+	n := r.length[code]
+	if cap(r.out) < n+1 {
+		r.out = make([]byte, n+1, 2*n+1)
+	}
+	out := r.out[:n]
+	for i := n - 1; i >= 0; i-- {
+		out[i] = r.suffix[code]
+		code = int(r.prefix[code])
+	}
+	return out
+}
+
+// runLengthReader decodes RunLengthDecode data (ISO 32000-2, 7.4.5).
+type runLengthReader struct {
+	r    *bufio.Reader
+	buf  [128]byte
+	pend []byte
+	err  error
+}
+
+func (r *runLengthReader) Read(b []byte) (int, error) {
+	for len(r.pend) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.decode()
+	}
+	n := copy(b, r.pend)
+	r.pend = r.pend[n:]
+	return n, nil
+}
+
+// decode decodes the next run into r.pend.
+func (r *runLengthReader) decode() error {
+	n, err := r.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case n == 128:
+		return io.EOF
+	case n < 128:
+		// Copy the next n+1 bytes.
+		m, err := io.ReadFull(r.r, r.buf[:int(n)+1])
+		r.pend = r.buf[:m]
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return err
+	}
+	// Repeat the next byte 257-n times.
+	c, err := r.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = errors.New("malformed RunLength data: truncated run")
+		}
+		return err
+	}
+	r.pend = r.buf[:257-int(n)]
+	for i := range r.pend {
+		r.pend[i] = c
+	}
+	return nil
+}
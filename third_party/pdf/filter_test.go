@@ -2,6 +2,12 @@ package pdf
 
 import (
 	"bytes"
+	"compress/lzw"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"testing"
 )
@@ -76,3 +82,277 @@ func TestFlateDecode(t *testing.T) {
 		t.Errorf("got %q, want %q", string(got), want)
 	}
 }
+
+// params returns filter parameters with the integer entries kv.
+func params(kv map[string]int64) Value {
+	dict := map[string]Object{}
+	for k, v := range kv {
+		dict[k] = Object{Kind: Integer, Int64Val: v}
+	}
+	return Value{obj: Object{Kind: Dict, DictVal: dict}}
+}
+
+func TestLZWDecode(t *testing.T) {
+	var long bytes.Buffer
+	for i := range 20000 {
+		long.WriteByte(byte(i*i>>7) % 61)
+	}
+	var gif bytes.Buffer
+	w := lzw.NewWriter(&gif, lzw.MSB, 8)
+	_, _ = w.Write(long.Bytes())
+	_ = w.Close()
+
+	tests := []struct {
+		name  string
+		input []byte
+		param Value
+		want  []byte
+	}{
+		// ISO 32000-2, 7.4.4.2, example 2.
+		{"spec example", []byte{0x80, 0x0b, 0x60, 0x50, 0x22, 0x0c, 0x0c, 0x85, 0x01}, Value{}, []byte("-----A---B")},
+		// compress/lzw grows the code width as with EarlyChange 0, and
+		// clears the table when it is full.
+		{"EarlyChange 0", gif.Bytes(), params(map[string]int64{"EarlyChange": 0}), long.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rd, err := applyFilter(bytes.NewReader(tt.input), "LZWDecode", tt.param)
+			if err != nil {
+				t.Fatalf("applyFilter failed: %v", err)
+			}
+			got, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %d bytes %q, want %d bytes", len(got), got[:min(len(got), 32)], len(tt.want))
+			}
+		})
+	}
+
+	// A code that is not yet defined.
+	rd, _ := applyFilter(bytes.NewReader([]byte{0x80, 0x40, 0x80}), "LZWDecode", Value{})
+	if _, err := io.ReadAll(rd); err == nil {
+		t.Error("invalid LZW code: error = nil")
+	}
+}
+
+func TestRunLengthDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    string
+		wantErr bool
+	}{
+		{"literal", []byte{2, 'a', 'b', 'c', 128}, "abc", false},
+		{"repeat", []byte{254, 'x', 0, 'y', 128}, "xxxy", false},
+		{"longest runs", append(append([]byte{129, 'z', 127}, bytes.Repeat([]byte{'w'}, 128)...), 128), string(bytes.Repeat([]byte{'z'}, 128)) + string(bytes.Repeat([]byte{'w'}, 128)), false},
+		{"no EOD", []byte{1, 'o', 'k'}, "ok", false},
+		{"truncated run", []byte{1, 'o', 'k', 250}, "ok", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rd, err := applyFilter(bytes.NewReader(tt.input), "RunLengthDecode", Value{})
+			if err != nil {
+				t.Fatalf("applyFilter failed: %v", err)
+			}
+			got, err := io.ReadAll(rd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("read error = %v, want error %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// pngImageData returns the zlib compressed image data of the PNG encoding
+// of img, whose rows use all PNG filter types.
+func pngImageData(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	var idat []byte
+	data := buf.Bytes()[8:]
+	for len(data) >= 12 {
+		n := binary.BigEndian.Uint32(data)
+		if string(data[4:8]) == "IDAT" {
+			idat = append(idat, data[8:8+n]...)
+		}
+		data = data[12+n:]
+	}
+	return idat
+}
+
+func TestPNGPredictor(t *testing.T) {
+	const w, h = 37, 23
+	rgb := image.NewRGBA(image.Rect(0, 0, w, h))
+	rgb16 := image.NewRGBA64(image.Rect(0, 0, w, h))
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	var wantRGB, wantRGB16, wantGray []byte
+	for y := range h {
+		for x := range w {
+			r, g, b := uint8(x*7+y), uint8(x*y), uint8(255-x*3)
+			rgb.Set(x, y, color.RGBA{r, g, b, 255})
+			wantRGB = append(wantRGB, r, g, b)
+			c := color.RGBA64{uint16(x * 1000), uint16(y * 2000), uint16(x*y + 7), 0xffff}
+			rgb16.Set(x, y, c)
+			wantRGB16 = binary.BigEndian.AppendUint16(wantRGB16, c.R)
+			wantRGB16 = binary.BigEndian.AppendUint16(wantRGB16, c.G)
+			wantRGB16 = binary.BigEndian.AppendUint16(wantRGB16, c.B)
+			gray.SetGray(x, y, color.Gray{uint8(x ^ y*5)})
+			wantGray = append(wantGray, uint8(x^y*5))
+		}
+	}
+
+	tests := []struct {
+		name   string
+		img    image.Image
+		colors int64
+		bpc    int64
+		want   []byte
+	}{
+		{"RGB", rgb, 3, 8, wantRGB},
+		{"RGB 16 bits", rgb16, 3, 16, wantRGB16},
+		{"gray", gray, 1, 8, wantGray},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param := params(map[string]int64{"Predictor": 15, "Colors": tt.colors, "BitsPerComponent": tt.bpc, "Columns": w})
+			rd, err := applyFilter(bytes.NewReader(pngImageData(t, tt.img)), "FlateDecode", param)
+			if err != nil {
+				t.Fatalf("applyFilter failed: %v", err)
+			}
+			got, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decoded image differs (%d bytes, want %d)", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestTIFFPredictor(t *testing.T) {
+	tests := []struct {
+		name    string
+		colors  int64
+		bpc     int64
+		columns int64
+		input   []byte
+		want    []byte
+	}{
+		{"gray", 1, 8, 4, []byte{10, 1, 1, 254, 5, 0, 0, 0}, []byte{10, 11, 12, 10, 5, 5, 5, 5}},
+		{"RGB", 3, 8, 2, []byte{1, 2, 3, 1, 1, 1}, []byte{1, 2, 3, 2, 3, 4}},
+		{"16 bits", 1, 16, 2, []byte{0x01, 0xff, 0x00, 0x02}, []byte{0x01, 0xff, 0x02, 0x01}},
+		{"4 bits", 1, 4, 4, []byte{0x31, 0x1f}, []byte{0x34, 0x54}},
+		{"1 bit", 1, 1, 8, []byte{0b10000001}, []byte{0b11111110}},
+		{"truncated row", 1, 8, 4, []byte{1, 1}, []byte{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var z bytes.Buffer
+			zw := zlib.NewWriter(&z)
+			_, _ = zw.Write(tt.input)
+			_ = zw.Close()
+			param := params(map[string]int64{"Predictor": 2, "Colors": tt.colors, "BitsPerComponent": tt.bpc, "Columns": tt.columns})
+			rd, err := applyFilter(&z, "FlateDecode", param)
+			if err != nil {
+				t.Fatalf("applyFilter failed: %v", err)
+			}
+			got, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPredictorErrors(t *testing.T) {
+	for _, param := range []map[string]int64{
+		{"Predictor": 3},
+		{"Predictor": 12, "BitsPerComponent": 3},
+		{"Predictor": 12, "Columns": 0},
+		{"Predictor": 12, "Columns": 1 << 40},
+		{"Predictor": 2, "Columns": 1 << 61},
+		{"Predictor": 2, "Colors": 1 << 61, "Columns": 4},
+	} {
+		if _, err := newPredictorReader(bytes.NewReader(nil), params(param)); err == nil {
+			t.Errorf("newPredictorReader(%v): error = nil", param)
+		}
+	}
+
+	// Row filter type 5 does not exist.
+	rd, _ := newPredictorReader(bytes.NewReader([]byte{5, 1, 2}), params(map[string]int64{"Predictor": 10, "Columns": 2}))
+	if _, err := io.ReadAll(rd); err == nil {
+		t.Error("invalid PNG row filter type: error = nil")
+	}
+}
+
+func TestPassThroughFilters(t *testing.T) {
+	data := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00}
+	for _, name := range []string{"DCTDecode", "JPXDecode", "CCITTFaxDecode", "JBIG2Decode", "Crypt"} {
+		rd, err := applyFilter(bytes.NewReader(data), name, Value{})
+		if err != nil {
+			t.Fatalf("%s: applyFilter failed: %v", name, err)
+		}
+		if got, _ := io.ReadAll(rd); !bytes.Equal(got, data) {
+			t.Errorf("%s: got %x, want %x", name, got, data)
+		}
+	}
+}
+
+func TestCryptFilter(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	_, _ = zw.Write([]byte("BT (Crypt) Tj ET"))
+	_ = zw.Close()
+	encrypted, err := EncryptStream(key, true, 5, 4, 0, z.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := func(n string) Object { return Object{Kind: Name, NameVal: n} }
+	cf := map[string]Object{
+		"StdCF":  {Kind: Dict, DictVal: map[string]Object{"CFM": name("AESV3")}},
+		"NoneCF": {Kind: Dict, DictVal: map[string]Object{"CFM": name("None")}},
+	}
+	trailer := Object{Kind: Dict, DictVal: map[string]Object{
+		"Encrypt": {Kind: Dict, DictVal: map[string]Object{"CF": {Kind: Dict, DictVal: cf}}},
+	}}
+	cryptFilter := func(n string) map[string]Object {
+		return map[string]Object{
+			"Filter":      {Kind: Array, ArrayVal: []Object{name("Crypt"), name("FlateDecode")}},
+			"DecodeParms": {Kind: Array, ArrayVal: []Object{{Kind: Dict, DictVal: map[string]Object{"Type": name("CryptFilterDecodeParms"), "Name": name(n)}}, {Kind: Null}}},
+		}
+	}
+	tests := []struct {
+		name   string
+		header map[string]Object
+		data   []byte
+	}{
+		{"default crypt filter", map[string]Object{"Filter": name("FlateDecode")}, encrypted},
+		{"Identity", cryptFilter("Identity"), z.Bytes()},
+		{"named crypt filter", cryptFilter("StdCF"), encrypted},
+		{"CFM None", cryptFilter("NoneCF"), z.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reader{f: bytes.NewReader(tt.data), key: key, useAES: true, encVersion: 5, trailer: trailer}
+			tt.header["Length"] = Object{Kind: Integer, Int64Val: int64(len(tt.data))}
+			s := Object{Kind: Stream, DictVal: tt.header, PtrVal: objptr{id: 4}}
+			got, err := io.ReadAll(newStreamReader(s, r))
+			if err != nil || string(got) != "BT (Crypt) Tj ET" {
+				t.Errorf("got %q, %v", got, err)
+			}
+		})
+	}
+}
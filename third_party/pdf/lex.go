@@ -31,6 +31,7 @@ type buffer struct {
 	encVersion  int
 	objptr      objptr
 	line        int
+	err         error // first syntax or read error; reading stops there
 }
 
 var bufferPool = sync.Pool{
@@ -59,6 +60,7 @@ func newBuffer(r io.Reader, offset int64, encVersion int) *buffer {
 	b.encVersion = encVersion
 	b.objptr = objptr{}
 	b.line = 1
+	b.err = nil
 	return b
 }
 
@@ -83,11 +85,23 @@ func (b *buffer) readByte() byte {
 	return c
 }
 
+// errorf records a syntax or read error. The buffered data is dropped and
+// the buffer reports end of file from then on, so the object being read
+// ends early; callers check b.err once they are done reading.
 func (b *buffer) errorf(format string, args ...interface{}) {
-	panic(fmt.Errorf(format, args...))
+	if b.err == nil {
+		b.err = fmt.Errorf(format, args...)
+	}
+	b.buf = b.buf[:0]
+	b.pos = 0
+	b.unread = b.unread[:0]
+	b.eof = true
 }
 
 func (b *buffer) reload() bool {
+	if b.err != nil {
+		return false
+	}
 	n := cap(b.buf) - int(b.offset%int64(cap(b.buf)))
 	n, err := b.r.Read(b.buf[:n])
 	if n == 0 && err != nil {
@@ -215,7 +229,7 @@ func (b *buffer) readHexString() Object {
 	for {
 	Loop:
 		c := b.readByte()
-		if c == '>' {
+		if c == '>' || b.eof {
 			break
 		}
 		if isSpace(c) {
@@ -223,7 +237,7 @@ func (b *buffer) readHexString() Object {
 		}
 	Loop2:
 		c2 := b.readByte()
-		if c2 == '>' {
+		if c2 == '>' || b.eof {
 			x := unhex(c) << 4
 			if x >= 0 {
 				tmp = append(tmp, byte(x))
@@ -571,7 +585,8 @@ func (b *buffer) readObject() Object {
 		str := tok.StringVal
 		decrypted, err := decryptString(b.key, b.useAES, b.encVersion, b.objptr, str)
 		if err != nil {
-			panic(err)
+			b.errorf("%w", err)
+			return Object{Kind: Null}
 		}
 		return Object{Kind: String, StringVal: decrypted}
 	}
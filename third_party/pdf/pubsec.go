@@ -0,0 +1,313 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ErrCertificateRequired is returned when a document encrypted with the
+// public-key security handler is opened without a recipient certificate
+// (see NewReaderCertificate).
+var ErrCertificateRequired = errors.New("encrypted PDF: a recipient certificate is required")
+
+// ErrKeyRequired is returned when a recipient certificate is given without
+// the private key that decrypts its envelope.
+var ErrKeyRequired = errors.New("encrypted PDF: the private key of the recipient certificate is required")
+
+// ErrNotRecipient is returned when the certificate passed to
+// NewReaderCertificate is not among the recipients of the document.
+var ErrNotRecipient = errors.New("encrypted PDF: the certificate is not a recipient")
+
+// NewReaderCertificate opens a file for reading like NewReader. Documents
+// encrypted with the public-key security handler (/Adobe.PubSec) are
+// decrypted as the recipient cert, whose private key decrypts the seed of
+// the file key; their access permissions are those granted to the
+// recipient (see Permissions).
+func NewReaderCertificate(f io.ReaderAt, size int64, cert *x509.Certificate, key crypto.Decrypter) (*Reader, error) {
+	return NewReaderOptions(f, size, ReaderOptions{Certificate: cert, Key: key})
+}
+
+// initEncryptPubSec derives the file key of the public-key security
+// handler (ISO 32000-2, 7.6.5.3).
+func (r *Reader) initEncryptPubSec(encrypt map[string]Object, cert *x509.Certificate, key crypto.Decrypter) error {
+	if key == nil {
+		return ErrKeyRequired
+	}
+	V := encrypt["V"].Int64Val
+	length := encrypt["Length"].Int64Val
+	if length == 0 {
+		length = 40
+	}
+	recipients := encrypt["Recipients"]
+	encryptMetadata := encrypt["EncryptMetadata"]
+	useAES := false
+	switch V {
+	case 1, 2:
+	case 4, 5:
+		// adbe.pkcs7.s5: the recipients are in the crypt filter.
+		cf := encrypt["CF"].DictVal[encrypt["StmF"].NameVal]
+		if cf.Kind != Dict || encrypt["StrF"].NameVal != encrypt["StmF"].NameVal {
+			return fmt.Errorf("unsupported PDF: public-key encryption without a common crypt filter")
+		}
+		if cf.DictVal["Recipients"].Kind != Null {
+			recipients = cf.DictVal["Recipients"]
+		}
+		if cf.DictVal["EncryptMetadata"].Kind != Null {
+			encryptMetadata = cf.DictVal["EncryptMetadata"]
+		}
+		switch cf.DictVal["CFM"].NameVal {
+		case "V2":
+			if l := cf.DictVal["Length"].Int64Val; l > 0 {
+				length = l
+			}
+		case "AESV2":
+			useAES, length = true, 128
+		case "AESV3":
+			useAES, length = true, 256
+		default:
+			return fmt.Errorf("unsupported PDF: crypt filter method %s", cf.DictVal["CFM"].NameVal)
+		}
+	default:
+		return fmt.Errorf("unsupported PDF: encryption version V=%d", V)
+	}
+	if length%8 != 0 || length < 40 || length > 256 || (V < 5 && length > 128) {
+		return fmt.Errorf("malformed PDF: %d-bit encryption key", length)
+	}
+	if recipients.Kind == String {
+		recipients = Object{Kind: Array, ArrayVal: []Object{recipients}}
+	}
+	if recipients.Kind != Array || len(recipients.ArrayVal) == 0 {
+		return fmt.Errorf("malformed PDF: public-key encryption without recipients")
+	}
+
+	// Each entry is a PKCS#7 envelope for a group of recipients sharing the
+	// same permissions; all of them carry the same seed.
+	var content []byte
+	for _, env := range recipients.ArrayVal {
+		data, err := decryptEnvelope([]byte(env.StringVal), cert, key)
+		if errors.Is(err, ErrNotRecipient) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		content = data
+		break
+	}
+	if content == nil {
+		return ErrNotRecipient
+	}
+	if len(content) < 24 {
+		return fmt.Errorf("malformed PDF: public-key envelope of %d bytes", len(content))
+	}
+
+	h := sha1.New()
+	if length == 256 {
+		h = sha256.New()
+	}
+	h.Write(content[:20]) // seed
+	for _, env := range recipients.ArrayVal {
+		h.Write([]byte(env.StringVal))
+	}
+	if encryptMetadata.Kind == Bool && !encryptMetadata.BoolVal {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	r.key = h.Sum(nil)[:length/8]
+	r.encKey = r.key
+	r.useAES = useAES
+	r.encVersion = int(V)
+	r.perm = binary.BigEndian.Uint32(content[20:24])
+	r.ownerAuth = false
+	return nil
+}
+
+// CMS structures (RFC 5652) used by the public-key security handler.
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// cmsEnvelopedData omits the optional originatorInfo and
+// unprotectedAttrs; see parseEnvelopedData.
+type cmsEnvelopedData struct {
+	Version              int
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo cmsEncryptedContentInfo
+}
+
+type cmsEncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type cmsKeyTransRecipientInfo struct {
+	Version                int
+	RID                    asn1.RawValue
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type cmsIssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+var (
+	oidEnvelopedData  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidDESCBC         = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 7}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	errMalformedCMS   = errors.New("malformed PDF: invalid public-key envelope")
+	errUnsupportedCMS = errors.New("unsupported PDF: public-key envelope algorithm")
+)
+
+// decryptEnvelope returns the content of the CMS EnvelopedData env, or
+// ErrNotRecipient when it is not addressed to cert.
+func decryptEnvelope(env []byte, cert *x509.Certificate, key crypto.Decrypter) ([]byte, error) {
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(env, &ci); err != nil || !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, errMalformedCMS
+	}
+	ed, err := parseEnvelopedData(ci.Content.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var contentKey []byte
+	for _, raw := range ed.RecipientInfos {
+		var ktri cmsKeyTransRecipientInfo
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+			continue // not a key transport recipient
+		}
+		if _, err := asn1.Unmarshal(raw.FullBytes, &ktri); err != nil {
+			return nil, errMalformedCMS
+		}
+		if !isRecipient(ktri.RID, cert) {
+			continue
+		}
+		if !ktri.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSAEncryption) {
+			return nil, fmt.Errorf("%w %v", errUnsupportedCMS, ktri.KeyEncryptionAlgorithm.Algorithm)
+		}
+		k, err := key.Decrypt(rand.Reader, ktri.EncryptedKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted PDF: decrypt content key: %w", err)
+		}
+		contentKey = k
+		break
+	}
+	if contentKey == nil {
+		return nil, ErrNotRecipient
+	}
+
+	eci := ed.EncryptedContentInfo
+	ciphertext := eci.EncryptedContent.Bytes
+	if eci.EncryptedContent.IsCompound {
+		// Constructed OCTET STRING: concatenate the segments.
+		var buf bytes.Buffer
+		rest := ciphertext
+		for len(rest) > 0 {
+			var seg []byte
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &seg); err != nil {
+				return nil, errMalformedCMS
+			}
+			buf.Write(seg)
+		}
+		ciphertext = buf.Bytes()
+	}
+
+	var block cipher.Block
+	alg := eci.ContentEncryptionAlgorithm.Algorithm
+	switch {
+	case alg.Equal(oidAES128CBC), alg.Equal(oidAES192CBC), alg.Equal(oidAES256CBC):
+		block, err = aes.NewCipher(contentKey)
+	case alg.Equal(oidDESEDE3CBC):
+		block, err = des.NewTripleDESCipher(contentKey)
+	case alg.Equal(oidDESCBC):
+		block, err = des.NewCipher(contentKey)
+	default:
+		return nil, fmt.Errorf("%w %v", errUnsupportedCMS, alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encrypted PDF: content key: %w", err)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil || len(iv) != block.BlockSize() {
+		return nil, errMalformedCMS
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errMalformedCMS
+	}
+	content := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(content, ciphertext)
+	return unpad(content), nil
+}
+
+// parseEnvelopedData parses an EnvelopedData, skipping its originatorInfo:
+// encoding/asn1 would match an optional asn1.RawValue field against any
+// element.
+func parseEnvelopedData(der []byte) (*cmsEnvelopedData, error) {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil || seq.Tag != asn1.TagSequence {
+		return nil, errMalformedCMS
+	}
+	var ed cmsEnvelopedData
+	rest, err := asn1.Unmarshal(seq.Bytes, &ed.Version)
+	if err != nil {
+		return nil, errMalformedCMS
+	}
+	var elem asn1.RawValue
+	if rest, err = asn1.Unmarshal(rest, &elem); err != nil {
+		return nil, errMalformedCMS
+	}
+	if elem.Class == asn1.ClassContextSpecific && elem.Tag == 0 {
+		if rest, err = asn1.Unmarshal(rest, &elem); err != nil {
+			return nil, errMalformedCMS
+		}
+	}
+	if elem.Tag != asn1.TagSet {
+		return nil, errMalformedCMS
+	}
+	for set := elem.Bytes; len(set) > 0; {
+		var ri asn1.RawValue
+		if set, err = asn1.Unmarshal(set, &ri); err != nil {
+			return nil, errMalformedCMS
+		}
+		ed.RecipientInfos = append(ed.RecipientInfos, ri)
+	}
+	if _, err := asn1.Unmarshal(rest, &ed.EncryptedContentInfo); err != nil {
+		return nil, errMalformedCMS
+	}
+	return &ed, nil
+}
+
+// isRecipient reports whether the recipient identifier rid designates
+// cert, by issuer and serial number or by subject key identifier.
+func isRecipient(rid asn1.RawValue, cert *x509.Certificate) bool {
+	if rid.Class == asn1.ClassContextSpecific && rid.Tag == 0 {
+		return len(cert.SubjectKeyId) > 0 && bytes.Equal(rid.Bytes, cert.SubjectKeyId)
+	}
+	var ias cmsIssuerAndSerialNumber
+	if _, err := asn1.Unmarshal(rid.FullBytes, &ias); err != nil || ias.SerialNumber == nil {
+		return false
+	}
+	return bytes.Equal(ias.Issuer.FullBytes, cert.RawIssuer) && ias.SerialNumber.Cmp(cert.SerialNumber) == 0
+}
@@ -0,0 +1,180 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testRecipient(t *testing.T, name string, serial int64) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// testEnvelope encrypts content to cert as a CMS EnvelopedData with
+// AES-256-CBC, as Acrobat does for the public-key security handler.
+func testEnvelope(t *testing.T, cert *x509.Certificate, content []byte) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	_, _ = rand.Read(key)
+	_, _ = rand.Read(iv)
+	encKey, err := rsa.EncryptPKCS1v15(rand.Reader, cert.PublicKey.(*rsa.PublicKey), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padLen := aes.BlockSize - len(content)%aes.BlockSize
+	ciphertext := append(append([]byte{}, content...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	rid, _ := asn1.Marshal(cmsIssuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+	ktri, _ := asn1.Marshal(cmsKeyTransRecipientInfo{
+		RID:                    asn1.RawValue{FullBytes: rid},
+		KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+		EncryptedKey:           encKey,
+	})
+	ivParam, _ := asn1.Marshal(iv)
+	ed, err := asn1.Marshal(cmsEnvelopedData{
+		RecipientInfos: []asn1.RawValue{{FullBytes: ktri}},
+		EncryptedContentInfo: cmsEncryptedContentInfo{
+			ContentType:                asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1},
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ciphertext},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: ed})
+	env, err := asn1.Marshal(cmsContentInfo{ContentType: oidEnvelopedData, Content: asn1.RawValue{FullBytes: wrapped}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func TestNewReaderCertificate(t *testing.T) {
+	full, fullKey := testRecipient(t, "Full access", 1)
+	restricted, restrictedKey := testRecipient(t, "Print only", 2)
+	other, otherKey := testRecipient(t, "Other", 3)
+	const restrictedPerm = 0xfffff0c4
+
+	seed := make([]byte, 20)
+	_, _ = rand.Read(seed)
+	envelope := func(cert *x509.Certificate, perm uint32) []byte {
+		return testEnvelope(t, cert, binary.BigEndian.AppendUint32(append([]byte{}, seed...), perm))
+	}
+	recipients := [][]byte{envelope(full, 0xffffffff), envelope(restricted, restrictedPerm)}
+
+	for _, tt := range []struct {
+		cfm string
+		v   int
+	}{
+		{"AESV3", 5},
+		{"AESV2", 4},
+	} {
+		h := sha1.New()
+		if tt.cfm == "AESV3" {
+			h = sha256.New()
+		}
+		h.Write(seed)
+		for _, env := range recipients {
+			h.Write(env)
+		}
+		key := h.Sum(nil)[:16]
+		if tt.cfm == "AESV3" {
+			key = h.Sum(nil)
+		}
+		content, _ := EncryptStream(key, true, tt.v, 4, 0, []byte("BT (Recipients only) Tj ET"))
+		objects := []string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+			fmt.Sprintf("<< /Filter /Adobe.PubSec /SubFilter /adbe.pkcs7.s5 /V %d /Length %d"+
+				" /CF << /DefaultCryptFilter << /CFM /%s /Recipients [<%x> <%x>] >> >>"+
+				" /StmF /DefaultCryptFilter /StrF /DefaultCryptFilter >>",
+				tt.v, len(key)*8, tt.cfm, recipients[0], recipients[1]),
+		}
+		var buf bytes.Buffer
+		buf.WriteString("%PDF-1.7\n")
+		offsets := make([]int, len(objects))
+		for i, obj := range objects {
+			offsets[i] = buf.Len()
+			fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+		}
+		xref := buf.Len()
+		fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+		for _, off := range offsets {
+			fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+		}
+		fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Encrypt 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+		data := buf.Bytes()
+
+		if _, err := NewReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrCertificateRequired) {
+			t.Errorf("%s: NewReader() error = %v, want %v", tt.cfm, err, ErrCertificateRequired)
+		}
+		if _, err := NewReaderCertificate(bytes.NewReader(data), int64(len(data)), full, nil); !errors.Is(err, ErrKeyRequired) {
+			t.Errorf("%s: NewReaderCertificate() without a key: error = %v, want %v", tt.cfm, err, ErrKeyRequired)
+		}
+		if _, err := NewReaderOptions(bytes.NewReader(data), int64(len(data)), ReaderOptions{Certificate: full}); !errors.Is(err, ErrKeyRequired) {
+			t.Errorf("%s: NewReaderOptions() without a key: error = %v, want %v", tt.cfm, err, ErrKeyRequired)
+		}
+		if _, err := NewReaderCertificate(bytes.NewReader(data), int64(len(data)), other, otherKey); !errors.Is(err, ErrNotRecipient) {
+			t.Errorf("%s: NewReaderCertificate() with another certificate: error = %v, want %v", tt.cfm, err, ErrNotRecipient)
+		}
+		for _, rc := range []struct {
+			cert *x509.Certificate
+			key  *rsa.PrivateKey
+			perm uint32
+		}{
+			{full, fullKey, 0xffffffff},
+			{restricted, restrictedKey, restrictedPerm},
+		} {
+			r, err := NewReaderCertificate(bytes.NewReader(data), int64(len(data)), rc.cert, rc.key)
+			if err != nil {
+				t.Fatalf("%s: NewReaderCertificate(%s) error = %v", tt.cfm, rc.cert.Subject.CommonName, err)
+			}
+			if r.Permissions() != rc.perm || r.EncVersion() != tt.v {
+				t.Errorf("%s: %s: permissions = %#x, V = %d", tt.cfm, rc.cert.Subject.CommonName, r.Permissions(), r.EncVersion())
+			}
+			got, err := io.ReadAll(r.Page(1).V.Key("Contents").Reader())
+			if err != nil || string(got) != "BT (Recipients only) Tj ET" {
+				t.Errorf("%s: %s: content = %q, %v", tt.cfm, rc.cert.Subject.CommonName, got, err)
+			}
+		}
+	}
+}
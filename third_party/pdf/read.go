@@ -5,6 +5,7 @@
 package pdf
 
 import (
+	"bufio"
 	"bytes"
 	"compress/zlib"
 	"crypto/aes"
@@ -13,7 +14,9 @@ import (
 	"crypto/rand"
 	"crypto/rc4"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/ascii85"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -34,6 +37,9 @@ type Reader struct {
 	useAES          bool
 	encVersion      int    // encryption version (V), 0 if not encrypted
 	encKey          []byte // File Encryption Key (FEK) - for V=5 calls this is the final key
+	perm            uint32 // access permissions (P) of an encrypted document
+	ownerAuth       bool   // opened with the owner password
+	repairs         []string
 	XrefInformation ReaderXrefInformation
 	PDFVersion      string
 	closer          io.Closer
@@ -41,6 +47,10 @@ type Reader struct {
 	// objCache caches resolved objects to prevent repetitive disk I/O.
 	// Map key is the object ID.
 	objCache map[uint32]Value
+
+	// sections caches the cross-reference sections read by Revision, by
+	// offset; it is shared with the readers Revision returns.
+	sections map[int64]*xrefSection
 }
 
 type ReaderXrefInformation struct {
@@ -84,12 +94,25 @@ func (x *xref) Stream() objptr {
 	return x.stream
 }
 
-func GetDict() Object {
-	return Object{Kind: Dict, DictVal: make(map[string]Object)}
+// Offset returns the file offset of the object, its index in the object
+// stream when InStream is true, or 0 for free entries.
+func (x *xref) Offset() int64 {
+	return x.offset
+}
+
+// InStream reports whether the object is stored in an object stream.
+func (x *xref) InStream() bool {
+	return x.inStream
+}
+
+// StreamID returns the object number of the object stream holding the
+// object, or 0 when InStream is false.
+func (x *xref) StreamID() uint32 {
+	return x.stream.id
 }
 
-func (r *Reader) errorf(format string, args ...interface{}) {
-	panic(fmt.Errorf(format, args...))
+func GetDict() Object {
+	return Object{Kind: Dict, DictVal: make(map[string]Object)}
 }
 
 func (r *Reader) Xref() []xref {
@@ -114,7 +137,8 @@ func (r *Reader) GetObject(id uint32) (Value, error) {
 		ptr.id = id
 	}
 
-	return r.resolve(objptr{}, Object{Kind: Indirect, PtrVal: ptr}), nil
+	v := r.resolve(objptr{}, Object{Kind: Indirect, PtrVal: ptr})
+	return v, v.Err()
 }
 
 // Open opens a file for reading.
@@ -137,11 +161,54 @@ func NewReader(f io.ReaderAt, size int64) (*Reader, error) {
 	return NewReaderEncrypted(f, size, nil)
 }
 
+// NewReaderPassword opens a file for reading like NewReader, decrypting it
+// with password when it is encrypted. The password may be the user or the
+// owner password; documents with an empty user password also open with any
+// other password, without owner access (see OwnerAuthenticated).
+func NewReaderPassword(f io.ReaderAt, size int64, password string) (*Reader, error) {
+	return NewReaderOptions(f, size, ReaderOptions{Password: password})
+}
+
 // NewReaderEncrypted opens a file for reading, using the data in f with the given total size.
 // If the PDF is encrypted, NewReaderEncrypted calls pw repeatedly to obtain passwords
 // to try. If pw returns the empty string, NewReaderEncrypted stops trying to decrypt
 // the file and returns an error.
 func NewReaderEncrypted(f io.ReaderAt, size int64, pw func() string) (*Reader, error) {
+	r, err := openReader(f, size)
+	if err != nil {
+		return nil, err
+	}
+	if r.trailer.Kind == Dict && r.trailer.DictVal["Encrypt"].Kind == Null {
+		return r, nil
+	}
+	// Check if Encrypt is present properly
+	enc := r.trailer.DictVal["Encrypt"]
+	if enc.Kind == Null {
+		return r, nil
+	}
+
+	err = r.initEncrypt("")
+	if err == nil {
+		return r, nil
+	}
+	if pw == nil || err != ErrInvalidPassword {
+		return nil, err
+	}
+	for {
+		next := pw()
+		if next == "" {
+			break
+		}
+		if r.initEncrypt(next) == nil {
+			return r, nil
+		}
+	}
+	return nil, err
+}
+
+// openReader reads the cross-reference table and trailer of a file,
+// without setting up decryption.
+func openReader(f io.ReaderAt, size int64) (*Reader, error) {
 	buf := make([]byte, 10)
 	f.ReadAt(buf, 0)
 	if (!bytes.HasPrefix(buf, []byte("%PDF-1.")) || buf[7] < '0' || buf[7] > '7') && (!bytes.HasPrefix(buf, []byte("%PDF-2.")) || buf[7] < '0' || buf[7] > '0') {
@@ -161,12 +228,12 @@ EOFDetect:
 		buf = make([]byte, searchSize)
 
 		searchSizeRead, _ = f.ReadAt(buf, end-searchSize)
-		for len(buf) > 0 && buf[len(buf)-1] == '\n' || buf[len(buf)-1] == '\r' {
+		for len(buf) > 0 && (buf[len(buf)-1] == '\n' || buf[len(buf)-1] == '\r') {
 			buf = buf[:len(buf)-1]
 		}
 		buf = bytes.TrimRight(buf, "\r\n\t ")
 		for {
-			if len(buf) == 5 {
+			if len(buf) <= 5 {
 				break
 			}
 
@@ -218,7 +285,10 @@ EOFDetect:
 	}
 
 	startXRefObj := b.readToken()
-	if startXRefObj.Kind != Integer {
+	if b.err != nil {
+		return nil, fmt.Errorf("malformed PDF: %w", b.err)
+	}
+	if startXRefObj.Kind != Integer || startXRefObj.Int64Val < 0 {
 		return nil, fmt.Errorf("malformed PDF file: startxref not followed by integer")
 	}
 	startxref := startXRefObj.Int64Val
@@ -240,32 +310,7 @@ EOFDetect:
 	r.xref = xref
 	r.trailer = trailer
 	r.trailerptr = trailerptr
-	if trailer.Kind == Dict && trailer.DictVal["Encrypt"].Kind == Null {
-		return r, nil
-	}
-	// Check if Encrypt is present properly
-	enc := trailer.DictVal["Encrypt"]
-	if enc.Kind == Null {
-		return r, nil
-	}
-
-	err = r.initEncrypt("")
-	if err == nil {
-		return r, nil
-	}
-	if pw == nil || err != ErrInvalidPassword {
-		return nil, err
-	}
-	for {
-		next := pw()
-		if next == "" {
-			break
-		}
-		if r.initEncrypt(next) == nil {
-			return r, nil
-		}
-	}
-	return nil, err
+	return r, nil
 }
 
 // Trailer returns the file's Trailer value.
@@ -275,6 +320,9 @@ func (r *Reader) Trailer() Value {
 
 func readXref(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 	tok := b.readToken()
+	if b.err != nil {
+		return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: %w", b.err)
+	}
 	if tok.Kind == Keyword && tok.KeywordVal == "xref" {
 		return readXrefTable(r, b)
 	}
@@ -282,11 +330,14 @@ func readXref(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 		b.unreadToken(tok)
 		return readXrefStream(r, b)
 	}
-	return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: cross-reference table not found: %v", tok)
+	return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: cross-reference table not found: %v", objfmt(tok))
 }
 
 func readXrefStream(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 	obj1 := b.readObject()
+	if b.err != nil {
+		return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: %w", b.err)
+	}
 	// readObject returns the object. If it was an indirect definition, it has PtrVal set.
 	strmptr := obj1.PtrVal
 	if obj1.Kind != Stream {
@@ -301,6 +352,9 @@ func readXrefStream(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 		return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: xref stream missing Size")
 	}
 	size := sizeObj.Int64Val
+	if size < 0 || size > maxObjectID(r.end) {
+		return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: invalid xref stream Size %d", size)
+	}
 
 	table := make([]xref, size)
 
@@ -326,6 +380,9 @@ func readXrefStream(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 
 		b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off, r.encVersion)
 		obj1 := b.readObject()
+		if b.err != nil {
+			return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: %w", b.err)
+		}
 		if obj1.Kind != Stream {
 			return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: xref prev stream not found: %v", objfmt(obj1))
 		}
@@ -373,7 +430,7 @@ func readXrefStreamData(r *Reader, strm Object, table []xref, size int64) ([]xre
 	var w []int
 	for _, x := range ww.ArrayVal {
 		i := x.Int64Val
-		if x.Kind != Integer || int64(int(i)) != i {
+		if x.Kind != Integer || i < 0 || i > 8 {
 			return nil, fmt.Errorf("invalid W array %v", objfmt(ww))
 		}
 		w = append(w, int(i))
@@ -394,7 +451,7 @@ func readXrefStreamData(r *Reader, strm Object, table []xref, size int64) ([]xre
 	for len(idxArr) > 0 {
 		start := idxArr[0].Int64Val
 		n := idxArr[1].Int64Val
-		if idxArr[0].Kind != Integer || idxArr[1].Kind != Integer {
+		if idxArr[0].Kind != Integer || idxArr[1].Kind != Integer || start < 0 || n > maxObjectID(r.end)-start {
 			return nil, fmt.Errorf("malformed Index pair %v %v", objfmt(idxArr[0]), objfmt(idxArr[1]))
 		}
 		idxArr = idxArr[2:]
@@ -444,7 +501,7 @@ func decodeInt(b []byte) int {
 func readXrefTable(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 	var table []xref
 
-	table, err := readXrefTableData(b, table)
+	table, err := readXrefTableData(b, table, maxObjectID(r.end))
 	if err != nil {
 		return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: %v", err)
 	}
@@ -459,6 +516,9 @@ func readXrefTable(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 	r.XrefInformation.Length = (b.realPos - trailer_length) + 1
 
 	trailer := b.readObject()
+	if b.err != nil {
+		return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: %w", b.err)
+	}
 	if trailer.Kind != Dict {
 		return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: xref table not followed by trailer dictionary")
 	}
@@ -483,12 +543,15 @@ func readXrefTable(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 		if tok.Kind != Keyword || tok.KeywordVal != "xref" {
 			return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: xref Prev does not point to xref")
 		}
-		table, err = readXrefTableData(b, table)
+		table, err = readXrefTableData(b, table, maxObjectID(r.end))
 		if err != nil {
 			return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: %v", err)
 		}
 
 		t := b.readObject()
+		if b.err != nil {
+			return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: %w", b.err)
+		}
 		if t.Kind != Dict {
 			return nil, objptr{}, Object{Kind: Null}, fmt.Errorf("malformed PDF: xref Prev table not followed by trailer dictionary")
 		}
@@ -520,13 +583,16 @@ func readXrefTable(r *Reader, b *buffer) ([]xref, objptr, Object, error) {
 	return table, objptr{}, trailer, nil
 }
 
-func readXrefTableData(b *buffer, table []xref) ([]xref, error) {
+func readXrefTableData(b *buffer, table []xref, maxID int64) ([]xref, error) {
 	for {
 		tok := b.readToken()
 		if tok.Kind == Keyword && tok.KeywordVal == "trailer" {
 			break
 		}
-		if tok.Kind != Integer {
+		if b.err != nil {
+			return nil, b.err
+		}
+		if tok.Kind != Integer || tok.Int64Val < 0 {
 			return nil, fmt.Errorf("malformed xref table: expected integer start")
 		}
 		start := tok.Int64Val
@@ -534,6 +600,9 @@ func readXrefTableData(b *buffer, table []xref) ([]xref, error) {
 		if nObj.Kind != Integer {
 			return nil, fmt.Errorf("malformed xref table: expected integer count")
 		}
+		if nObj.Int64Val > maxID-start {
+			return nil, fmt.Errorf("malformed xref table: object numbers %d to %d exceed the file size", start, start+nObj.Int64Val)
+		}
 		n := nObj.Int64Val
 
 		for i := 0; i < int(n); i++ {
@@ -565,6 +634,14 @@ func readXrefTableData(b *buffer, table []xref) ([]xref, error) {
 	return table, nil
 }
 
+// maxObjectID returns the largest object number accepted in a file of size
+// bytes. Writers number objects from 1 without large gaps, so a larger
+// number can only come from damaged data, and accepting it would size the
+// cross-reference table after it.
+func maxObjectID(size int64) int64 {
+	return max(size, 1024)
+}
+
 func findLastLine(buf []byte, s string) int {
 	bs := []byte(s)
 	max := len(buf)
@@ -639,13 +716,7 @@ func objfmt(x Object) string {
 	}
 }
 
-func (r *Reader) resolve(parent objptr, x Object) (v Value) {
-	defer func() {
-		if e := recover(); e != nil {
-			v = Value{err: fmt.Errorf("panic resolving %v: %v", x, e)}
-		}
-	}()
-
+func (r *Reader) resolve(parent objptr, x Object) Value {
 	if x.Kind == Indirect {
 		ptr := x.PtrVal
 		// Check cache first
@@ -665,21 +736,24 @@ func (r *Reader) resolve(parent objptr, x Object) (v Value) {
 			strm := r.resolve(parent, Object{Kind: Indirect, PtrVal: xref.stream})
 		Search:
 			for {
+				if strm.Err() != nil {
+					return Value{err: fmt.Errorf("loading %v: %w", ptr, strm.Err())}
+				}
 				if strm.Kind() != Stream {
-					panic("not a stream")
+					return Value{err: fmt.Errorf("loading %v: object %v is not a stream", ptr, xref.stream)}
 				}
 				if strm.Key("Type").Name() != "ObjStm" {
-					panic("not an object stream")
+					return Value{err: fmt.Errorf("loading %v: object %v is not an object stream", ptr, xref.stream)}
 				}
 				n := int(strm.Key("N").Int64())
 				first := strm.Key("First").Int64()
 				if first == 0 {
-					panic("missing First")
+					return Value{err: fmt.Errorf("loading %v: object stream %v is missing First", ptr, xref.stream)}
 				}
 				b := newBuffer(strm.Reader(), 0, r.encVersion)
 				defer bufferPool.Put(b)
 				b.allowEOF = true
-				for i := 0; i < n; i++ {
+				for i := 0; i < n && !b.eof; i++ {
 					idObj := b.readToken()
 					offObj := b.readToken()
 					id := idObj.Int64Val
@@ -688,12 +762,15 @@ func (r *Reader) resolve(parent objptr, x Object) (v Value) {
 					if uint32(id) == ptr.id {
 						b.seekForward(first + off)
 						x = b.readObject()
+						if b.err != nil {
+							return Value{err: fmt.Errorf("malformed PDF: loading %v from object stream %v: %w", ptr, xref.stream, b.err)}
+						}
 						break Search
 					}
 				}
 				ext := strm.Key("Extends")
 				if ext.Kind() != Stream {
-					panic("cannot find object in stream")
+					return Value{err: fmt.Errorf("loading %v: object not found in object stream %v", ptr, xref.stream)}
 				}
 				strm = ext
 			}
@@ -704,6 +781,9 @@ func (r *Reader) resolve(parent objptr, x Object) (v Value) {
 			b.useAES = r.useAES
 
 			obj = b.readObject()
+			if b.err != nil {
+				return Value{err: fmt.Errorf("malformed PDF: loading %v: %w", ptr, b.err)}
+			}
 			// readObject handles the "objdef" structure internally by returning the Object
 			// but storing the definition ID in PtrVal if it was an indirect definition.
 			// Let's verify it matches the pointer we expected.
@@ -716,21 +796,23 @@ func (r *Reader) resolve(parent objptr, x Object) (v Value) {
 			// But readObject for a definition returns the defined object (not Kind=Indirect).
 			if obj.Kind != Indirect && obj.PtrVal != (objptr{}) {
 				if obj.PtrVal.id != ptr.id || obj.PtrVal.gen != ptr.gen {
-					panic(fmt.Errorf("loading %v: found %v", ptr, obj.PtrVal))
+					return Value{err: fmt.Errorf("loading %v: found %v", ptr, obj.PtrVal)}
 				}
 			} else if obj.Kind == Indirect && obj.PtrVal != ptr {
 				// It turned out to be a reference? A definition cannot act as a reference directly unless it's a stream?
-				panic(fmt.Errorf("loading %v: found reference %v", ptr, obj.PtrVal))
+				return Value{err: fmt.Errorf("loading %v: found reference %v", ptr, obj.PtrVal)}
 			}
 			x = obj
 		}
 
 		// Per ISO 32000-1:2008 §7.6.2, the Contents value of a Signature
 		// dictionary must not be decrypted. If this object is a signature dict
-		// (identified by /Filter /Adobe.PPKLite), re-read Contents without
-		// decryption so the raw PKCS7 data is preserved.
-		if r.key != nil && x.Kind == Dict {
-			if filter, ok := x.DictVal["Filter"]; ok && filter.Kind == Name && filter.NameVal == "Adobe.PPKLite" {
+		// (a /Filter naming the signature handler and a /ByteRange), re-read
+		// Contents without decryption so the raw PKCS7 data is preserved.
+		// Strings of objects in object streams are not encrypted.
+		if r.key != nil && x.Kind == Dict && !xref.inStream {
+			_, hasByteRange := x.DictVal["ByteRange"]
+			if filter, ok := x.DictVal["Filter"]; ok && filter.Kind == Name && hasByteRange {
 				if _, hasContents := x.DictVal["Contents"]; hasContents {
 					b2 := newBuffer(io.NewSectionReader(r.f, xref.offset, r.end-xref.offset), xref.offset, r.encVersion)
 					defer bufferPool.Put(b2)
@@ -767,6 +849,22 @@ func (r *Reader) EncryptionKey() []byte {
 	return r.key
 }
 
+// Permissions returns the access permissions (/P) of an encrypted
+// document, or all permissions when it is not encrypted or was opened with
+// the owner password.
+func (r *Reader) Permissions() uint32 {
+	if r.key == nil || r.ownerAuth {
+		return 0xffffffff
+	}
+	return r.perm
+}
+
+// OwnerAuthenticated reports whether an encrypted document was opened with
+// its owner password.
+func (r *Reader) OwnerAuthenticated() bool {
+	return r.ownerAuth
+}
+
 // UseAES returns whether AES encryption is used (vs RC4).
 func (r *Reader) UseAES() bool {
 	return r.useAES
@@ -818,6 +916,78 @@ func EncryptStream(key []byte, useAES bool, encVersion int, objID uint32, objGen
 	return out, nil
 }
 
+// StandardSecurityV5 holds the entries of an AES-256 standard security
+// handler dictionary (V 5, R 6) and the file encryption key they protect.
+type StandardSecurityV5 struct {
+	Key             []byte // file encryption key
+	O, U            []byte // 48 bytes each
+	OE, UE          []byte // 32 bytes each
+	Perms           []byte // 16 bytes
+	P               uint32
+	EncryptMetadata bool
+}
+
+// NewStandardSecurityV5 generates a random file encryption key and the
+// entries that protect it with the user and owner passwords (ISO 32000-2,
+// algorithms 8, 9 and 10). Passwords longer than 127 bytes are truncated.
+func NewStandardSecurityV5(userPassword, ownerPassword string, p uint32, encryptMetadata bool) (*StandardSecurityV5, error) {
+	sec := &StandardSecurityV5{Key: make([]byte, 32), P: p, EncryptMetadata: encryptMetadata}
+	salts := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, sec.Key); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, salts); err != nil {
+		return nil, err
+	}
+
+	var err error
+	sec.U, sec.UE, err = passwordEntriesV5([]byte(userPassword), salts[:16], nil, sec.Key)
+	if err != nil {
+		return nil, err
+	}
+	sec.O, sec.OE, err = passwordEntriesV5([]byte(ownerPassword), salts[16:], sec.U, sec.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make([]byte, 16)
+	binary.LittleEndian.PutUint32(perms, p)
+	copy(perms[4:], []byte{0xff, 0xff, 0xff, 0xff})
+	perms[8] = 'F'
+	if encryptMetadata {
+		perms[8] = 'T'
+	}
+	copy(perms[9:], "adb")
+	if _, err := io.ReadFull(rand.Reader, perms[12:]); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sec.Key)
+	if err != nil {
+		return nil, err
+	}
+	sec.Perms = make([]byte, 16)
+	block.Encrypt(sec.Perms, perms) // ECB: a single block
+	return sec, nil
+}
+
+// passwordEntriesV5 returns the 48 byte hash entry (U or O) of password and
+// the file encryption key encrypted with it (UE or OE).
+func passwordEntriesV5(password, salts, udata, key []byte) (entry, encKey []byte, err error) {
+	if len(password) > 127 {
+		password = password[:127]
+	}
+	valSalt, keySalt := salts[:8], salts[8:16]
+	entry = append(hashV5(6, password, valSalt, udata), salts...)
+
+	block, err := aes.NewCipher(hashV5(6, password, keySalt, udata))
+	if err != nil {
+		return nil, nil, err
+	}
+	encKey = make([]byte, len(key))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(encKey, key)
+	return entry, encKey, nil
+}
+
 func (r *Reader) createValue(ptr objptr, obj Object) Value {
 	return Value{r: r, ptr: ptr, obj: obj}
 }
@@ -834,9 +1004,9 @@ func (e *errorReadCloser) Close() error {
 	return e.err
 }
 
-// newStreamReader returns a reader for the stream s.
-func newStreamReader(s Object, r *Reader) io.ReadCloser {
-	var rd io.Reader
+// newRawStreamReader returns a reader for the data of the stream s,
+// decrypted but not decoded by its filters.
+func newRawStreamReader(s Object, r *Reader) (io.Reader, error) {
 	// s is Object(Stream). DictVal is header. StreamOffset is offset.
 
 	// Need "Length" from header.
@@ -844,20 +1014,55 @@ func newStreamReader(s Object, r *Reader) io.ReadCloser {
 	val := Value{r: r, obj: s}
 	length := val.Key("Length").Int64()
 
-	rd = io.NewSectionReader(r.f, s.StreamOffset, length)
+	var rd io.Reader = io.NewSectionReader(r.f, s.StreamOffset, length)
 
 	if r.key != nil {
-		var err error
+		useAES, encrypted := r.streamCrypt(val)
+		if !encrypted {
+			return rd, nil
+		}
 		// We need the stream's object ID for decryption.
 		// Use s.PtrVal which should be set to definition ID if it was read via readObject.
 		// If s was created manually, PtrVal might be empty.
 		// But newStreamReader is usually called from resolved objects.
+		return decryptStream(r.key, useAES, r.encVersion, s.PtrVal, rd)
+	}
+	return rd, nil
+}
 
-		rd, err = decryptStream(r.key, r.useAES, r.encVersion, s.PtrVal, rd)
-		if err != nil {
-			return &errorReadCloser{err}
-		}
+// streamCrypt reports whether and how the stream val is encrypted. A /Crypt
+// filter, which must be the first filter, selects a crypt filter of the
+// encryption dictionary instead of the default one (ISO 32000-2, 7.4.10).
+func (r *Reader) streamCrypt(val Value) (useAES, encrypted bool) {
+	filter, param := val.Key("Filter"), val.Key("DecodeParms")
+	if filter.Kind() == Array {
+		filter, param = filter.Index(0), param.Index(0)
+	}
+	if filter.Name() != "Crypt" {
+		return r.useAES, true
+	}
+	name := param.Key("Name").Name()
+	if name == "" || name == "Identity" {
+		return false, false
+	}
+	switch r.Trailer().Key("Encrypt").Key("CF").Key(name).Key("CFM").Name() {
+	case "None":
+		return false, false
+	case "V2":
+		return false, true
+	case "AESV2", "AESV3":
+		return true, true
+	}
+	return r.useAES, true
+}
+
+// newStreamReader returns a reader for the stream s.
+func newStreamReader(s Object, r *Reader) io.ReadCloser {
+	rd, err := newRawStreamReader(s, r)
+	if err != nil {
+		return &errorReadCloser{err}
 	}
+	val := Value{r: r, obj: s}
 
 	filters := val.Key("Filter")
 	if filters.Kind() == Name {
@@ -892,17 +1097,18 @@ func applyFilter(rd io.Reader, name string, param Value) (io.Reader, error) {
 		if err != nil {
 			return nil, err
 		}
-		pred := param.Key("Predictor")
-		if pred.Kind() == Null {
-			return zr, nil
-		}
-		columns := param.Key("Columns").Int64()
-		switch pred.Int64() {
-		default:
-			return nil, fmt.Errorf("unknown predictor %v", pred)
-		case 12:
-			return &pngUpReader{r: zr, hist: make([]byte, 1+columns), tmp: make([]byte, 1+columns)}, nil
-		}
+		return newPredictorReader(zr, param)
+	case "LZWDecode":
+		early := param.Key("EarlyChange")
+		return newPredictorReader(newLZWReader(rd, early.Kind() == Null || early.Int64() != 0), param)
+	case "RunLengthDecode":
+		return &runLengthReader{r: bufio.NewReader(rd)}, nil
+	case "Crypt":
+		// The stream was decrypted, or not, by newRawStreamReader.
+		return rd, nil
+	case "DCTDecode", "JPXDecode", "CCITTFaxDecode", "JBIG2Decode":
+		// Image data is returned encoded, for an image decoder.
+		return rd, nil
 	}
 }
 
@@ -957,38 +1163,6 @@ func (r asciiHexReader) Read(dst []byte) (int, error) {
 	return n, nil
 }
 
-type pngUpReader struct {
-	r    io.Reader
-	hist []byte
-	tmp  []byte
-	pend []byte
-}
-
-func (r *pngUpReader) Read(b []byte) (int, error) {
-	n := 0
-	for len(b) > 0 {
-		if len(r.pend) > 0 {
-			m := copy(b, r.pend)
-			n += m
-			b = b[m:]
-			r.pend = r.pend[m:]
-			continue
-		}
-		_, err := io.ReadFull(r.r, r.tmp)
-		if err != nil {
-			return n, err
-		}
-		if r.tmp[0] != 2 {
-			return n, fmt.Errorf("malformed PNG-Up encoding")
-		}
-		for i, b := range r.tmp {
-			r.hist[i] += b
-		}
-		r.pend = r.hist[1:]
-	}
-	return n, nil
-}
-
 var passwordPad = []byte{
 	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
 	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
@@ -1000,6 +1174,9 @@ func (r *Reader) initEncrypt(password string) error {
 	encrypt := r.resolve(objptr{}, r.trailer.DictVal["Encrypt"]).obj.DictVal
 	// Encrypt is a dict Object, so DictVal
 
+	if encrypt["Filter"].NameVal == "Adobe.PubSec" {
+		return ErrCertificateRequired
+	}
 	if encrypt["Filter"].NameVal != "Standard" {
 		return fmt.Errorf("unsupported PDF: encryption filter %v", objfmt(Object{Kind: Name, NameVal: encrypt["Filter"].NameVal}))
 	}
@@ -1048,26 +1225,55 @@ func (r *Reader) initEncrypt(password string) error {
 	}
 	P := uint32(encrypt["P"].Int64Val)
 
+	// Per PDF 32000-1:2008 §7.6.3.3 Algorithm 2 step (e): for R >= 4, append
+	// 0xFFFFFFFF when /EncryptMetadata is false. Without this, empty-password
+	// key derivation fails on PDFs that lock permissions but set no user password.
+	encryptMetadata := true
+	if em, ok := encrypt["EncryptMetadata"]; ok && em.Kind == Bool && !em.BoolVal {
+		encryptMetadata = false
+	}
+
 	// TODO: Password should be converted to Latin-1.
-	pw := []byte(password)
-	h := md5.New()
+	key, ok := userKeyV4([]byte(password), O, U, P, ID, R, n, encryptMetadata)
+	owner := false
+	if !ok {
+		// Algorithm 7: the owner password decrypts O to the user password.
+		key, ok = userKeyV4(ownerDecryptO([]byte(password), O, R, n), O, U, P, ID, R, n, encryptMetadata)
+		owner = ok
+	}
+	if !ok {
+		return ErrInvalidPassword
+	}
+
+	r.key = key
+	r.useAES = V == 4
+	r.encVersion = int(V)
+	r.perm = P
+	r.ownerAuth = owner
+
+	return nil
+}
+
+// padPassword pads or truncates a password to 32 bytes (Algorithm 2 step a).
+func padPassword(pw []byte) []byte {
+	out := make([]byte, 0, 32)
 	if len(pw) >= 32 {
-		h.Write(pw[:32])
-	} else {
-		h.Write(pw)
-		h.Write(passwordPad[:32-len(pw)])
+		return append(out, pw[:32]...)
 	}
+	out = append(out, pw...)
+	return append(out, passwordPad[:32-len(pw)]...)
+}
+
+// userKeyV4 computes the file key of a user password (Algorithm 2) and
+// checks it against the U entry (Algorithms 4 and 5).
+func userKeyV4(pw []byte, O, U string, P uint32, ID []byte, R, n int64, encryptMetadata bool) ([]byte, bool) {
+	h := md5.New()
+	h.Write(padPassword(pw))
 	h.Write([]byte(O))
 	h.Write([]byte{byte(P), byte(P >> 8), byte(P >> 16), byte(P >> 24)})
-	h.Write([]byte(ID))
-
-	// Per PDF 32000-1:2008 §7.6.3.3 Algorithm 2 step (e): for R >= 4, append
-	// 0xFFFFFFFF when /EncryptMetadata is false. Without this, empty-password
-	// key derivation fails on PDFs that lock permissions but set no user password.
-	if R >= 4 {
-		if em, ok := encrypt["EncryptMetadata"]; ok && em.Kind == Bool && !em.BoolVal {
-			h.Write([]byte{0xff, 0xff, 0xff, 0xff})
-		}
+	h.Write(ID)
+	if R >= 4 && !encryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
 	}
 	key := h.Sum(nil)
 
@@ -1084,7 +1290,7 @@ func (r *Reader) initEncrypt(password string) error {
 
 	c, err := rc4.NewCipher(key)
 	if err != nil {
-		return fmt.Errorf("malformed PDF: invalid RC4 key: %v", err)
+		return nil, false
 	}
 
 	var u []byte
@@ -1095,7 +1301,7 @@ func (r *Reader) initEncrypt(password string) error {
 	} else {
 		h.Reset()
 		h.Write(passwordPad)
-		h.Write([]byte(ID))
+		h.Write(ID)
 		u = h.Sum(nil)
 		c.XORKeyStream(u, u)
 
@@ -1110,15 +1316,39 @@ func (r *Reader) initEncrypt(password string) error {
 		}
 	}
 
-	if !bytes.HasPrefix([]byte(U), u) {
-		return ErrInvalidPassword
-	}
+	return key, bytes.HasPrefix([]byte(U), u)
+}
 
-	r.key = key
-	r.useAES = V == 4
-	r.encVersion = int(V)
+// ownerDecryptO recovers the padded user password from the O entry with an
+// owner password (Algorithm 7).
+func ownerDecryptO(pw []byte, O string, R, n int64) []byte {
+	sum := md5.Sum(padPassword(pw))
+	key := sum[:]
+	if R >= 3 {
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(key)
+			key = sum[:]
+		}
+		key = key[:n/8]
+	} else {
+		key = key[:40/8]
+	}
 
-	return nil
+	user := []byte(O)
+	if R == 2 {
+		c, _ := rc4.NewCipher(key)
+		c.XORKeyStream(user, user)
+		return user
+	}
+	key1 := make([]byte, len(key))
+	for i := 19; i >= 0; i-- {
+		for j := range key {
+			key1[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(key1)
+		c.XORKeyStream(user, user)
+	}
+	return user
 }
 
 func (r *Reader) initEncryptV5(password string, encrypt map[string]Object) error {
@@ -1138,10 +1368,13 @@ func (r *Reader) initEncryptV5(password string, encrypt map[string]Object) error
 
 	// Authenticate
 	// Try User Password (U)
-	key, ok := authenticateV5Password(password, []byte(U), []byte(UE))
+	rev := int(encrypt["R"].Int64Val)
+	key, ok := authenticateV5Password(rev, password, []byte(U), []byte(UE), nil)
+	owner := false
 	if !ok {
-		// Try Owner Password (O)
-		key, ok = authenticateV5Password(password, []byte(O), []byte(OE))
+		// Try Owner Password (O), whose hashes also cover the U entry.
+		key, ok = authenticateV5Password(rev, password, []byte(O), []byte(OE), []byte(U))
+		owner = ok
 	}
 
 	if !ok {
@@ -1152,10 +1385,12 @@ func (r *Reader) initEncryptV5(password string, encrypt map[string]Object) error
 	r.encKey = key
 	r.useAES = true
 	r.encVersion = 5
+	r.perm = uint32(encrypt["P"].Int64Val)
+	r.ownerAuth = owner
 	return nil
 }
 
-func authenticateV5Password(password string, entry []byte, payload []byte) (fek []byte, ok bool) {
+func authenticateV5Password(rev int, password string, entry []byte, payload []byte, udata []byte) (fek []byte, ok bool) {
 	// entry is 48 bytes: 32 hash + 8 val salt + 8 key salt
 	if len(entry) != 48 {
 		return nil, false
@@ -1171,21 +1406,15 @@ func authenticateV5Password(password string, entry []byte, payload []byte) (fek
 	}
 
 	// 1. Validate Password
-	h := sha256.New()
-	h.Write(pwdBytes)
-	h.Write(valSalt)
-	hashComputed := h.Sum(nil)
+	hashComputed := hashV5(rev, pwdBytes, valSalt, udata)
 
 	if !bytes.Equal(hashComputed, hashStored) {
 		return nil, false
 	}
 
 	// 2. Decrypt FEK (payload) using derived key
-	// Key = SHA256(pwd + KeySalt)
-	h.Reset()
-	h.Write(pwdBytes)
-	h.Write(keySalt)
-	kdk := h.Sum(nil) // 32 bytes Key Derivation Key
+	// Key = hash(pwd + KeySalt)
+	kdk := hashV5(rev, pwdBytes, keySalt, udata) // 32 bytes Key Derivation Key
 
 	// Decrypt payload (UE or OE) using AES-256-CBC with zero IV
 	block, err := aes.NewCipher(kdk)
@@ -1202,6 +1431,52 @@ func authenticateV5Password(password string, entry []byte, payload []byte) (fek
 	return plaintext, true
 }
 
+// hashV5 computes the password hash of AES-256 encryption: SHA-256 for
+// revision 5 and the iterated hash of ISO 32000-2, algorithm 2.B, for
+// revision 6.
+func hashV5(rev int, password, salt, udata []byte) []byte {
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(udata)
+	k := h.Sum(nil)
+	if rev < 6 {
+		return k
+	}
+
+	for i := 0; ; i++ {
+		k1 := make([]byte, 0, 64*(len(password)+len(k)+len(udata)))
+		for j := 0; j < 64; j++ {
+			k1 = append(k1, password...)
+			k1 = append(k1, k...)
+			k1 = append(k1, udata...)
+		}
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		// The first 16 bytes of E as a number modulo 3 select the hash.
+		sum := 0
+		for _, b := range e[:16] {
+			sum += int(b)
+		}
+		switch sum % 3 {
+		case 0:
+			s := sha256.Sum256(e)
+			k = s[:]
+		case 1:
+			s := sha512.Sum384(e)
+			k = s[:]
+		case 2:
+			s := sha512.Sum512(e)
+			k = s[:]
+		}
+		if i >= 63 && int(e[len(e)-1]) <= i+1-32 {
+			return k[:32]
+		}
+	}
+}
+
 var ErrInvalidPassword = fmt.Errorf("encrypted PDF: invalid password")
 
 func okayV4(encrypt map[string]Object) bool {
@@ -1333,10 +1608,13 @@ func decryptStream(key []byte, useAES bool, encVersion int, ptr objptr, rd io.Re
 	return &rc4Reader{cipher: c, rd: rd}, nil
 }
 
+// cbcReader decrypts an AES-CBC stream, removing the PKCS#7 padding of its
+// last block.
 type cbcReader struct {
 	cbc  cipher.BlockMode
 	rd   io.Reader
-	buf  []byte
+	buf  []byte // next ciphertext block, read ahead to detect the last one
+	full bool   // buf holds a block
 	pend []byte
 }
 
@@ -1347,25 +1625,53 @@ func (r *cbcReader) Read(b []byte) (n int, err error) {
 		return n, nil
 	}
 
-	_, err = io.ReadFull(r.rd, r.buf)
-	if err != nil {
-		if err == io.EOF {
-			return 0, io.EOF
-		}
-		if err == io.ErrUnexpectedEOF {
-			return 0, fmt.Errorf("encrypted stream not a multiple of block size")
+	if !r.full {
+		if err := r.readBlock(); err != nil {
+			return 0, err
 		}
+	}
+	block := make([]byte, len(r.buf))
+	r.cbc.CryptBlocks(block, r.buf)
+	err = r.readBlock()
+	if err == io.EOF {
+		block = unpad(block)
+	} else if err != nil {
 		return 0, err
 	}
-
-	r.cbc.CryptBlocks(r.buf, r.buf)
-	r.pend = r.buf
+	r.pend = block
 
 	n = copy(b, r.pend)
 	r.pend = r.pend[n:]
+	if n == 0 && err == io.EOF {
+		return 0, io.EOF
+	}
 	return n, nil
 }
 
+// readBlock reads the next ciphertext block into buf.
+func (r *cbcReader) readBlock() error {
+	_, err := io.ReadFull(r.rd, r.buf)
+	r.full = err == nil
+	if err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("encrypted stream not a multiple of block size")
+	}
+	return err
+}
+
+// unpad removes valid PKCS#7 padding from the last block of a stream.
+func unpad(block []byte) []byte {
+	padLen := int(block[len(block)-1])
+	if padLen == 0 || padLen > len(block) {
+		return block
+	}
+	for _, c := range block[len(block)-padLen:] {
+		if int(c) != padLen {
+			return block
+		}
+	}
+	return block[:len(block)-padLen]
+}
+
 type rc4Reader struct {
 	cipher *rc4.Cipher
 	rd     io.Reader
@@ -185,14 +185,15 @@ func TestNewReaderEncryptedV5(t *testing.T) {
 	}
 }
 
-func TestReader_Errorf(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("errorf did not panic")
-		}
-	}()
-	r := &Reader{}
-	r.errorf("test error")
+func TestBufferErrorf(t *testing.T) {
+	b := newBuffer(strings.NewReader("<< /Key (a) /Bad#zz 1 >> 2 0 R"), 0, 0)
+	b.readObject()
+	if b.err == nil || !strings.Contains(b.err.Error(), "malformed name") {
+		t.Errorf("readObject() error = %v, want a malformed name", b.err)
+	}
+	if tok := b.readToken(); tok.Kind != Null || !b.eof {
+		t.Errorf("readToken() after an error = %v, want end of file", objfmt(tok))
+	}
 }
 
 func TestReaderXrefInformation_PrintDebug(t *testing.T) {
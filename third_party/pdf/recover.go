@@ -0,0 +1,413 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReaderOptions configures NewReaderOptions.
+type ReaderOptions struct {
+	// Password opens a document encrypted with the standard security
+	// handler; it may be the user or the owner password.
+	Password string
+	// Certificate and Key open a document encrypted for recipient
+	// certificates (see NewReaderCertificate).
+	Certificate *x509.Certificate
+	Key         crypto.Decrypter
+	// Recover opens documents whose cross-reference data is missing or
+	// broken, by rebuilding it from the object definitions found in the
+	// file. Repairs reports what was repaired.
+	Recover bool
+}
+
+// recoveryChunk is the size of the blocks in which a file is scanned for
+// object definitions.
+const recoveryChunk = 1 << 20
+
+// NewReaderOptions opens a file for reading like NewReaderPassword, or like
+// NewReaderCertificate when opts.Certificate is set.
+func NewReaderOptions(f io.ReaderAt, size int64, opts ReaderOptions) (*Reader, error) {
+	r, err := openReader(f, size)
+	var objStms []scannedObject
+	if opts.Recover {
+		if err != nil {
+			r, objStms, err = rebuildReader(f, size, err)
+		} else {
+			r.checkXref()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.openEncrypted(opts); err != nil {
+		return nil, err
+	}
+	// Object streams are read once the document can be decrypted.
+	r.addObjectStreams(objStms)
+	return r, nil
+}
+
+// openEncrypted sets up the decryption of an encrypted document.
+func (r *Reader) openEncrypted(opts ReaderOptions) error {
+	if r.trailer.DictVal["Encrypt"].Kind == Null {
+		return nil
+	}
+	if opts.Certificate != nil {
+		encrypt := r.resolve(objptr{}, r.trailer.DictVal["Encrypt"]).obj.DictVal
+		if encrypt["Filter"].NameVal != "Adobe.PubSec" {
+			return fmt.Errorf("unsupported PDF: encryption filter %s is not the public-key security handler", encrypt["Filter"].NameVal)
+		}
+		return r.initEncryptPubSec(encrypt, opts.Certificate, opts.Key)
+	}
+	err := r.initEncrypt("")
+	if opts.Password == "" {
+		return err
+	}
+	if err == nil {
+		// Opened with the empty user password: the password may still be
+		// the owner password. A failed attempt leaves the reader unchanged.
+		_ = r.initEncrypt(opts.Password)
+		return nil
+	}
+	if err != ErrInvalidPassword {
+		return err
+	}
+	return r.initEncrypt(opts.Password)
+}
+
+// Repairs describes what was repaired to open a document in recovery mode
+// (see ReaderOptions.Recover). It is empty when nothing was repaired.
+func (r *Reader) Repairs() []string {
+	return r.repairs
+}
+
+func (r *Reader) repaired(format string, args ...interface{}) {
+	r.repairs = append(r.repairs, fmt.Sprintf(format, args...))
+}
+
+// readObjectAt parses the object defined at offset.
+func (r *Reader) readObjectAt(offset int64) (Object, error) {
+	b := newBuffer(io.NewSectionReader(r.f, offset, r.end-offset), offset, r.encVersion)
+	defer bufferPool.Put(b)
+	obj := b.readObject()
+	if b.err != nil {
+		return Object{}, fmt.Errorf("malformed PDF: object at offset %d: %w", offset, b.err)
+	}
+	return obj, nil
+}
+
+// checkXref checks that the cross-reference entries of objects outside
+// object streams point to their definitions, and corrects those that do
+// not from a scan of the file.
+func (r *Reader) checkXref() {
+	var wrong []uint32
+	header := make([]byte, 32)
+	for id, x := range r.xref {
+		if x.inStream || x.offset == 0 || x.ptr.id != uint32(id) {
+			continue
+		}
+		n, _ := r.f.ReadAt(header, x.offset)
+		if hid, hgen, ok := parseObjectHeader(header[:n]); !ok || hid != x.ptr.id || hgen != x.ptr.gen {
+			wrong = append(wrong, uint32(id))
+		}
+	}
+	if len(wrong) == 0 {
+		return
+	}
+
+	found := map[uint32]scannedObject{}
+	for _, obj := range scanObjects(r.f, r.end) {
+		found[obj.ptr.id] = obj
+	}
+	var moved, missing []uint32
+	for _, id := range wrong {
+		if obj, ok := found[id]; ok {
+			r.xref[id] = xref{ptr: obj.ptr, offset: obj.offset}
+			moved = append(moved, id)
+		} else {
+			r.xref[id] = xref{}
+			missing = append(missing, id)
+		}
+	}
+	if len(moved) > 0 {
+		r.repaired("corrected the cross-reference offsets of %d object(s) from a scan of the file: %s", len(moved), idList(moved))
+	}
+	if len(missing) > 0 {
+		r.repaired("%d object(s) of the cross-reference table were not found in the file and are treated as deleted: %s", len(missing), idList(missing))
+	}
+}
+
+// idList formats object numbers, eliding all but the first ten.
+func idList(ids []uint32) string {
+	var s []string
+	for i, id := range ids {
+		if i == 10 {
+			s = append(s, fmt.Sprintf("and %d more", len(ids)-i))
+			break
+		}
+		s = append(s, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(s, ", ")
+}
+
+// scannedObject is an object definition found by scanning a file.
+type scannedObject struct {
+	ptr    objptr
+	offset int64
+}
+
+// scanObjects returns the "N G obj" definitions of a file in file order.
+func scanObjects(f io.ReaderAt, size int64) []scannedObject {
+	var found []scannedObject
+	const margin = 32 // for definitions across chunk boundaries
+	buf := make([]byte, recoveryChunk+2*margin)
+	for base := int64(0); base < size; base += recoveryChunk {
+		start := max(base-margin, 0)
+		data := buf
+		if size-start < int64(len(data)) {
+			data = data[:size-start]
+		}
+		n, _ := f.ReadAt(data, start)
+		data = data[:n]
+		for i := 0; ; {
+			j := bytes.Index(data[i:], []byte("obj"))
+			if j < 0 {
+				break
+			}
+			i += j + 3
+			pos := start + int64(i-3)
+			if pos < base || pos >= base+recoveryChunk || i < len(data) && !isSpace(data[i]) && !isDelim(data[i]) {
+				continue
+			}
+			if obj, ok := objectHeaderBefore(data[:i-3]); ok {
+				obj.offset += start
+				found = append(found, obj)
+			}
+		}
+	}
+	return found
+}
+
+// objectHeaderBefore parses the "N G " that data ends with, returning the
+// offset of N in data.
+func objectHeaderBefore(data []byte) (scannedObject, bool) {
+	i := len(data)
+	var nums [2]int64
+	for k := 1; k >= 0; k-- {
+		end := i
+		for i > 0 && isSpace(data[i-1]) {
+			i--
+		}
+		if i == end {
+			return scannedObject{}, false
+		}
+		end = i
+		for i > 0 && isDigit(data[i-1]) {
+			i--
+		}
+		if i == end || end-i > 10 {
+			return scannedObject{}, false
+		}
+		nums[k], _ = strconv.ParseInt(string(data[i:end]), 10, 64)
+	}
+	if i > 0 && !isSpace(data[i-1]) && !isDelim(data[i-1]) {
+		return scannedObject{}, false
+	}
+	if nums[0] <= 0 || nums[0] > 1<<31 || nums[1] > 65535 {
+		return scannedObject{}, false
+	}
+	return scannedObject{ptr: objptr{uint32(nums[0]), uint16(nums[1])}, offset: int64(i)}, true
+}
+
+// parseObjectHeader parses the "N G obj" that data starts with.
+func parseObjectHeader(data []byte) (uint32, uint16, bool) {
+	i := bytes.Index(data, []byte("obj"))
+	if i < 0 {
+		return 0, 0, false
+	}
+	obj, ok := objectHeaderBefore(data[:i])
+	if !ok || len(bytes.TrimLeft(data[:obj.offset], "\x00\t\n\f\r ")) > 0 {
+		return 0, 0, false
+	}
+	return obj.ptr.id, obj.ptr.gen, true
+}
+
+// rebuildReader opens a file whose cross-reference data cannot be read
+// (cause) from the object definitions found in it. It returns the object
+// streams, whose objects are added once the document can be decrypted.
+func rebuildReader(f io.ReaderAt, size int64, cause error) (*Reader, []scannedObject, error) {
+	head := make([]byte, 1024)
+	n, _ := f.ReadAt(head, 0)
+	start := bytes.Index(head[:n], []byte("%PDF-"))
+	if start < 0 || start+8 > n {
+		return nil, nil, cause
+	}
+	r := &Reader{
+		f:          f,
+		end:        size,
+		PDFVersion: string(head[start+5 : start+8]),
+		objCache:   make(map[uint32]Value),
+	}
+	if c, ok := f.(io.Closer); ok {
+		r.closer = c
+	}
+	r.repaired("the cross-reference data could not be read (%v) and was rebuilt from a scan of the file", cause)
+	if start > 0 {
+		r.repaired("ignored %d bytes before the PDF header", start)
+	}
+
+	// Later definitions replace earlier ones, as in incremental updates.
+	// Matches inside the data of a stream are skipped.
+	var objStms []scannedObject
+	trailer := map[string]Object{}
+	catalog := objptr{}
+	skipUntil := int64(0)
+	maxID := maxObjectID(size)
+	var outOfRange []uint32
+	for _, def := range scanObjects(f, size) {
+		if def.offset < skipUntil {
+			continue
+		}
+		if int64(def.ptr.id) > maxID {
+			outOfRange = append(outOfRange, def.ptr.id)
+			continue
+		}
+		obj, err := r.readObjectAt(def.offset)
+		if err != nil || obj.PtrVal != def.ptr {
+			continue
+		}
+		for int(def.ptr.id) >= len(r.xref) {
+			r.xref = append(r.xref, xref{})
+		}
+		r.xref[def.ptr.id] = xref{ptr: def.ptr, offset: def.offset}
+		if obj.Kind == Stream {
+			if length := obj.DictVal["Length"]; length.Kind == Integer {
+				skipUntil = obj.StreamOffset + length.Int64Val
+			}
+			switch obj.DictVal["Type"].NameVal {
+			case "ObjStm":
+				objStms = append(objStms, def)
+			case "XRef":
+				mergeTrailer(trailer, obj.DictVal)
+			}
+		}
+		if obj.Kind == Dict && obj.DictVal["Type"].NameVal == "Catalog" {
+			catalog = def.ptr
+		}
+	}
+	if len(outOfRange) > 0 {
+		r.repaired("ignored the definitions of objects %s, whose numbers exceed the file size", idList(outOfRange))
+	}
+	if len(r.xref) == 0 {
+		return nil, nil, cause
+	}
+	for _, t := range scanTrailers(r) {
+		mergeTrailer(trailer, t)
+	}
+
+	if trailer["Root"].Kind != Indirect {
+		if catalog == (objptr{}) {
+			return nil, nil, fmt.Errorf("%v; no document catalog found by recovery", cause)
+		}
+		trailer["Root"] = Object{Kind: Indirect, PtrVal: catalog}
+		r.repaired("no trailer found: used the document catalog %d %d R", catalog.id, catalog.gen)
+	}
+	trailer["Size"] = Object{Kind: Integer, Int64Val: int64(len(r.xref))}
+	r.trailer = Object{Kind: Dict, DictVal: trailer}
+	r.XrefInformation.Type = "table"
+	r.XrefInformation.ItemCount = int64(len(r.xref))
+	return r, objStms, nil
+}
+
+// mergeTrailer copies the entries of a trailer dictionary that describe
+// the document into trailer.
+func mergeTrailer(trailer, t map[string]Object) {
+	for _, key := range []string{"Root", "Info", "ID", "Encrypt"} {
+		if v, ok := t[key]; ok && v.Kind != Null {
+			trailer[key] = v
+		}
+	}
+}
+
+// scanTrailers returns the trailer dictionaries of a file in file order.
+func scanTrailers(r *Reader) []map[string]Object {
+	var trailers []map[string]Object
+	buf := make([]byte, recoveryChunk+len("trailer"))
+	for base := int64(0); base < r.end; base += recoveryChunk {
+		data := buf
+		if r.end-base < int64(len(data)) {
+			data = data[:r.end-base]
+		}
+		n, _ := r.f.ReadAt(data, base)
+		data = data[:n]
+		for i := 0; ; {
+			j := bytes.Index(data[i:], []byte("trailer"))
+			if j < 0 || i+j >= recoveryChunk {
+				break
+			}
+			i += j + len("trailer")
+			obj, err := r.readObjectAt(base + int64(i))
+			if err == nil && obj.Kind == Dict {
+				trailers = append(trailers, obj.DictVal)
+			}
+		}
+	}
+	return trailers
+}
+
+// addObjectStreams adds the objects of the object streams found by a scan
+// that are not defined later in the file.
+func (r *Reader) addObjectStreams(objStms []scannedObject) {
+	added := false
+	for _, stm := range objStms {
+		strm := r.resolve(objptr{}, Object{Kind: Indirect, PtrVal: stm.ptr})
+		n := int(strm.Key("N").Int64())
+		if strm.Kind() != Stream || n <= 0 {
+			continue
+		}
+		members, err := objectStreamMembers(strm, n, maxObjectID(r.end))
+		if err != nil {
+			r.repaired("object stream %d %d R could not be read: %v", stm.ptr.id, stm.ptr.gen, err)
+			continue
+		}
+		for _, id := range members {
+			for int(id) >= len(r.xref) {
+				r.xref = append(r.xref, xref{})
+			}
+			if x := r.xref[id]; x.offset != 0 && !x.inStream && x.offset > stm.offset {
+				continue
+			}
+			r.xref[id] = xref{ptr: objptr{id, 0}, inStream: true, stream: stm.ptr}
+			added = true
+		}
+	}
+	if added {
+		r.trailer.DictVal["Size"] = Object{Kind: Integer, Int64Val: int64(len(r.xref))}
+		r.XrefInformation.ItemCount = int64(len(r.xref))
+	}
+}
+
+// objectStreamMembers returns the object numbers of the n objects of an
+// object stream.
+func objectStreamMembers(strm Value, n int, maxID int64) ([]uint32, error) {
+	b := newBuffer(strm.Reader(), 0, 0)
+	defer bufferPool.Put(b)
+	b.allowEOF = true
+	var ids []uint32
+	for range n {
+		id, off := b.readToken(), b.readToken()
+		if b.err != nil {
+			return ids, b.err
+		}
+		if id.Kind != Integer || off.Kind != Integer || id.Int64Val <= 0 || id.Int64Val > maxID {
+			return ids, fmt.Errorf("malformed object stream header")
+		}
+		ids = append(ids, uint32(id.Int64Val))
+	}
+	return ids, nil
+}
@@ -0,0 +1,157 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// breakStartxref points the final startxref of data past the end of the
+// file.
+func breakStartxref(data []byte) []byte {
+	i := bytes.LastIndex(data, []byte("startxref"))
+	return append(append([]byte{}, data[:i]...), "startxref\n99999999\n%%EOF\n"...)
+}
+
+func TestNewReaderOptionsRecover(t *testing.T) {
+	minimal := minimalCatalogPagePDF()
+	// Bytes inserted before object 2 shift object 3 away from the offset of
+	// the cross-reference table; startxref is adjusted.
+	shifted := bytes.Replace(minimal, []byte("2 0 obj"), []byte("\n\n\n2 0 obj"), 1)
+	xrefAt := bytes.Index(shifted, []byte("xref"))
+	shifted = append(shifted[:bytes.LastIndex(shifted, []byte("startxref"))], fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xrefAt)...)
+	noTrailer := append(append([]byte{}, minimal[:bytes.Index(minimal, []byte("xref"))]...), "%%EOF\n"...)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantOpenErr bool
+		wantRepairs []string
+	}{
+		{"intact", minimal, false, nil},
+		{"broken startxref", breakStartxref(minimal), true, []string{"rebuilt from a scan of the file"}},
+		{"shifted objects", shifted, false, []string{"corrected the cross-reference offsets of 1 object(s) from a scan of the file: 3"}},
+		{"garbage before header", append([]byte("HTTP/1.1 200 OK\r\n\r\n"), minimal...), true, []string{"rebuilt from a scan", "ignored 19 bytes before the PDF header"}},
+		{"no cross-reference table or trailer", noTrailer, true, []string{"rebuilt from a scan", "no trailer found: used the document catalog 1 0 R"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewReader(bytes.NewReader(tt.data), int64(len(tt.data))); (err != nil) != tt.wantOpenErr {
+				t.Errorf("NewReader() without recovery: error = %v, want error %v", err, tt.wantOpenErr)
+			}
+			r, err := NewReaderOptions(bytes.NewReader(tt.data), int64(len(tt.data)), ReaderOptions{Recover: true})
+			if err != nil {
+				t.Fatalf("NewReaderOptions() error = %v", err)
+			}
+			if r.NumPage() != 1 || r.Page(1).V.Key("MediaBox").Len() != 4 {
+				t.Errorf("recovered document has %d pages", r.NumPage())
+			}
+			repairs := r.Repairs()
+			if len(repairs) != len(tt.wantRepairs) {
+				t.Fatalf("Repairs() = %q, want %d repairs", repairs, len(tt.wantRepairs))
+			}
+			for i, want := range tt.wantRepairs {
+				if !strings.Contains(repairs[i], want) {
+					t.Errorf("Repairs()[%d] = %q, want it to contain %q", i, repairs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverObjectStreams(t *testing.T) {
+	// testfile30.pdf keeps most objects in object streams.
+	data, err := os.ReadFile("../../testfiles/testfile30.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := breakStartxref(data)
+	if _, err := NewReader(bytes.NewReader(broken), int64(len(broken))); err == nil {
+		t.Fatal("NewReader() error = nil for a broken startxref")
+	}
+	r, err := NewReaderOptions(bytes.NewReader(broken), int64(len(broken)), ReaderOptions{Recover: true})
+	if err != nil {
+		t.Fatalf("NewReaderOptions() error = %v", err)
+	}
+	if got, want := r.NumPage(), original.NumPage(); got != want {
+		t.Errorf("%d pages, want %d", got, want)
+	}
+	got, _ := io.ReadAll(r.Page(1).V.Key("Contents").Reader())
+	want, _ := io.ReadAll(original.Page(1).V.Key("Contents").Reader())
+	if len(want) == 0 || !bytes.Equal(got, want) {
+		t.Errorf("page content differs after recovery (%d bytes, want %d)", len(got), len(want))
+	}
+}
+
+func TestRecoverEncrypted(t *testing.T) {
+	data, err := os.ReadFile("../../testfiles/testfile_password.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := breakStartxref(data)
+	r, err := NewReaderOptions(bytes.NewReader(broken), int64(len(broken)), ReaderOptions{Password: "user", Recover: true})
+	if err != nil {
+		t.Fatalf("NewReaderOptions() error = %v", err)
+	}
+	if r.EncryptionKey() == nil || r.NumPage() == 0 {
+		t.Errorf("recovered encrypted document: key %x, %d pages", r.EncryptionKey(), r.NumPage())
+	}
+	if _, err := NewReaderOptions(bytes.NewReader(broken), int64(len(broken)), ReaderOptions{Password: "wrong", Recover: true}); err != ErrInvalidPassword {
+		t.Errorf("wrong password: error = %v, want %v", err, ErrInvalidPassword)
+	}
+}
+
+func TestMalformedInputReturnsErrors(t *testing.T) {
+	minimal := minimalCatalogPagePDF()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated xref", regexp.MustCompile(`(?s)xref\n.*trailer`).ReplaceAll(minimal, []byte("xref\n0 4\n0000000000 65535 f \n0000"))},
+		{"startxref to garbage", breakStartxref(minimal)},
+		{"no objects", []byte("%PDF-1.7\nstartxref\n9\n%%EOF\n")},
+		{"malformed trailer", bytes.Replace(minimal, []byte("/Root"), []byte("/Ro#zz"), 1)},
+		{"object number beyond the file size", bytes.Replace(minimal, []byte("xref\n0 "), []byte("xref\n2147483000 "), 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewReader(bytes.NewReader(tt.data), int64(len(tt.data))); err == nil {
+				t.Error("NewReader() error = nil")
+			}
+		})
+	}
+
+	// Object 3 is listed in object stream 2, which is not an object stream.
+	r, err := NewReader(bytes.NewReader(minimal), int64(len(minimal)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.xref[3] = xref{ptr: objptr{3, 0}, inStream: true, stream: objptr{2, 0}}
+	if _, err := r.GetObject(3); err == nil || !strings.Contains(err.Error(), "not a stream") {
+		t.Errorf("GetObject() error = %v, want an error for a missing object stream", err)
+	}
+}
+
+func TestRecoverLargeObjectNumber(t *testing.T) {
+	data := []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n" +
+		"2147483000 0 obj\n<< >>\nendobj\n%%EOF\n")
+	r, err := NewReaderOptions(bytes.NewReader(data), int64(len(data)), ReaderOptions{Recover: true})
+	if err != nil {
+		t.Fatalf("NewReaderOptions() error = %v", err)
+	}
+	if len(r.Xref()) != 3 {
+		t.Errorf("recovered cross-reference table has %d entries, want 3", len(r.Xref()))
+	}
+	if repairs := strings.Join(r.Repairs(), "\n"); !strings.Contains(repairs, "objects 2147483000, whose numbers exceed the file size") {
+		t.Errorf("Repairs() = %q, want the object beyond the file size", repairs)
+	}
+}
@@ -0,0 +1,155 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+)
+
+// xrefSection is one cross-reference table or stream of a file and its
+// trailer dictionary.
+type xrefSection struct {
+	typ        string // "table" or "stream"
+	table      []xref
+	size       int64
+	trailer    Object
+	trailerptr objptr
+}
+
+// Revision returns a reader of the document as of an earlier revision: the
+// one whose last cross-reference section starts at startxref and whose
+// sections all lie in the first end bytes of the file. The revision is
+// read with the decryption key of r, and each cross-reference section is
+// parsed once for all the revisions of r, so listing the revisions of a
+// file does not reread every prefix of it.
+func (r *Reader) Revision(startxref, end int64) (*Reader, error) {
+	if end <= 0 || end > r.end {
+		return nil, fmt.Errorf("revision end %d is outside the file", end)
+	}
+	if r.sections == nil {
+		r.sections = make(map[int64]*xrefSection)
+	}
+	var chain []*xrefSection
+	seen := map[int64]bool{}
+	for off := startxref; ; {
+		if off < 0 || off >= end {
+			return nil, fmt.Errorf("malformed PDF: cross-reference section at %d is outside the revision", off)
+		}
+		if seen[off] {
+			return nil, fmt.Errorf("malformed PDF: xref Prev loop detected: %v", off)
+		}
+		seen[off] = true
+		s, err := r.xrefSection(off)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, s)
+		prev := s.trailer.DictVal["Prev"]
+		if prev.Kind == Null {
+			break
+		}
+		if prev.Kind != Integer {
+			return nil, fmt.Errorf("malformed PDF: xref Prev is not integer: %v", objfmt(prev))
+		}
+		off = prev.Int64Val
+	}
+
+	// Newer sections take precedence over older ones, as when reading the
+	// whole file.
+	last := chain[0]
+	var table []xref
+	for _, s := range chain {
+		for id, x := range s.table {
+			for len(table) <= id {
+				table = append(table, xref{})
+			}
+			if table[id] == (xref{}) {
+				table[id] = x
+			}
+		}
+	}
+	if last.typ == "table" && last.size < int64(len(table)) {
+		table = table[:last.size]
+	}
+
+	rev := &Reader{
+		f:          r.f,
+		end:        end,
+		xref:       table,
+		trailer:    last.trailer,
+		trailerptr: last.trailerptr,
+		key:        r.key,
+		useAES:     r.useAES,
+		encVersion: r.encVersion,
+		encKey:     r.encKey,
+		perm:       r.perm,
+		ownerAuth:  r.ownerAuth,
+		XrefInformation: ReaderXrefInformation{
+			StartPos:  startxref,
+			Type:      last.typ,
+			ItemCount: int64(len(table)),
+		},
+		PDFVersion: r.PDFVersion,
+		objCache:   make(map[uint32]Value),
+		sections:   r.sections,
+	}
+	return rev, nil
+}
+
+// xrefSection returns the cross-reference section at offset off, without
+// following its /Prev entry.
+func (r *Reader) xrefSection(off int64) (*xrefSection, error) {
+	if s, ok := r.sections[off]; ok {
+		return s, nil
+	}
+	b := newBuffer(io.NewSectionReader(r.f, off, r.end-off), off, r.encVersion)
+	tok := b.readToken()
+	if b.err != nil {
+		return nil, fmt.Errorf("malformed PDF: %w", b.err)
+	}
+	s := &xrefSection{}
+	switch {
+	case tok.Kind == Keyword && tok.KeywordVal == "xref":
+		table, err := readXrefTableData(b, nil, maxObjectID(r.end))
+		if err != nil {
+			return nil, fmt.Errorf("malformed PDF: %v", err)
+		}
+		trailer := b.readObject()
+		if b.err != nil {
+			return nil, fmt.Errorf("malformed PDF: %w", b.err)
+		}
+		if trailer.Kind != Dict {
+			return nil, fmt.Errorf("malformed PDF: xref table not followed by trailer dictionary")
+		}
+		sizeObj := trailer.DictVal["Size"]
+		if sizeObj.Kind != Integer {
+			return nil, fmt.Errorf("malformed PDF: trailer missing /Size entry")
+		}
+		s.typ, s.table, s.size, s.trailer = "table", table, sizeObj.Int64Val, trailer
+	case tok.Kind == Integer:
+		b.unreadToken(tok)
+		strm := b.readObject()
+		if b.err != nil {
+			return nil, fmt.Errorf("malformed PDF: %w", b.err)
+		}
+		if strm.Kind != Stream || strm.DictVal["Type"].NameVal != "XRef" {
+			return nil, fmt.Errorf("malformed PDF: no xref stream at %d", off)
+		}
+		sizeObj := strm.DictVal["Size"]
+		size := sizeObj.Int64Val
+		if sizeObj.Kind != Integer || size < 0 || size > maxObjectID(r.end) {
+			return nil, fmt.Errorf("malformed PDF: invalid xref stream Size %v", objfmt(sizeObj))
+		}
+		// Cross-reference streams are not encrypted.
+		plain := *r
+		plain.key = nil
+		table, err := readXrefStreamData(&plain, strm, make([]xref, size), size)
+		if err != nil {
+			return nil, fmt.Errorf("malformed PDF: %v", err)
+		}
+		s.typ, s.table, s.size, s.trailer, s.trailerptr = "stream", table, size, strm, strm.PtrVal
+	default:
+		return nil, fmt.Errorf("malformed PDF: cross-reference table not found: %v", objfmt(tok))
+	}
+	r.sections[off] = s
+	return s, nil
+}
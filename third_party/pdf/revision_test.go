@@ -0,0 +1,56 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestReaderRevision(t *testing.T) {
+	original := minimalCatalogPagePDF()
+	firstXref := int64(bytes.Index(original, []byte("xref")))
+	firstEnd := int64(len(original))
+
+	// An incremental update replacing the page.
+	data := bytes.NewBuffer(append([]byte(nil), original...))
+	page := data.Len()
+	data.WriteString("3 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>\nendobj\n")
+	secondXref := int64(data.Len())
+	fmt.Fprintf(data, "xref\n0 1\n0000000000 65535 f \n3 1\n%010d 00000 n \n", page)
+	fmt.Fprintf(data, "trailer\n<< /Size 4 /Root 1 0 R /Prev %d >>\nstartxref\n%d\n%%%%EOF\n", firstXref, secondXref)
+
+	r, err := NewReader(bytes.NewReader(data.Bytes()), int64(data.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		startxref, end int64
+		width          int64
+	}{
+		{firstXref, firstEnd, 612},
+		{secondXref, int64(data.Len()), 100},
+	} {
+		rev, err := r.Revision(tt.startxref, tt.end)
+		if err != nil {
+			t.Fatalf("Revision(%d, %d) error = %v", tt.startxref, tt.end, err)
+		}
+		if got := rev.Page(1).V.Key("MediaBox").Index(2).Int64(); got != tt.width {
+			t.Errorf("Revision(%d, %d): page width = %d, want %d", tt.startxref, tt.end, got, tt.width)
+		}
+		if rev.XrefInformation.StartPos != tt.startxref || rev.XrefInformation.Type != "table" || len(rev.Xref()) != 4 {
+			t.Errorf("Revision(%d, %d): XrefInformation = %+v with %d entries", tt.startxref, tt.end, rev.XrefInformation, len(rev.Xref()))
+		}
+	}
+	if len(r.sections) != 2 {
+		t.Errorf("read %d cross-reference sections, want each of the 2 read once", len(r.sections))
+	}
+
+	// The cross-reference section of the update lies after the first
+	// revision.
+	if _, err := r.Revision(secondXref, firstEnd); err == nil {
+		t.Error("Revision() with a section outside the revision: error = nil")
+	}
+	if _, err := r.Revision(firstXref, int64(data.Len())+1); err == nil {
+		t.Error("Revision() past the end of the file: error = nil")
+	}
+}
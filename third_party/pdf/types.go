@@ -6,6 +6,7 @@ package pdf
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"sort"
@@ -159,6 +160,22 @@ func (v Value) Reader() io.ReadCloser {
 	return ioutil.NopCloser(bytes.NewReader(nil))
 }
 
+// RawData returns the data of the stream v decrypted but still encoded
+// by its filters.
+func (v Value) RawData() ([]byte, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if v.obj.Kind != Stream {
+		return nil, fmt.Errorf("stream not present")
+	}
+	rd, err := newRawStreamReader(v.obj, v.r)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(rd)
+}
+
 // Data returns the raw data of the stream v.
 func (v Value) Data() []byte {
 	if v.err != nil {
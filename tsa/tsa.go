@@ -0,0 +1,473 @@
+// Package tsa implements an RFC 3161 time-stamp authority: an http.Handler
+// answering time-stamp requests with tokens signed by a configurable
+// certificate and key, for offline tests and internal deployments.
+package tsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+const (
+	// DefaultMaxRequestSize is the default limit for the size of a request.
+	DefaultMaxRequestSize = 64 << 10
+
+	// ContentTypeQuery and ContentTypeReply are the media types of
+	// time-stamp requests and responses (RFC 3161, section 3.4).
+	ContentTypeQuery = "application/timestamp-query"
+	ContentTypeReply = "application/timestamp-reply"
+)
+
+// DefaultPolicy is the policy under which tokens are issued when
+// Authority.Policy is not set. It is a placeholder: an authority whose tokens
+// are relied upon outside of tests must identify its own policy.
+var DefaultPolicy = asn1.ObjectIdentifier{1, 2, 3, 4, 1}
+
+// PKIStatus values of a time-stamp response (RFC 3161, section 2.4.2).
+const (
+	StatusGranted   = 0
+	StatusRejection = 2
+)
+
+// FailureInfo is the reason a request is rejected (RFC 3161, section 2.4.2).
+type FailureInfo int
+
+const (
+	BadAlgorithm        FailureInfo = 0
+	BadRequest          FailureInfo = 2
+	BadDataFormat       FailureInfo = 5
+	TimeNotAvailable    FailureInfo = 14
+	UnacceptedPolicy    FailureInfo = 15
+	UnacceptedExtension FailureInfo = 16
+	SystemFailure       FailureInfo = 25
+)
+
+var (
+	oidTSTInfo              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidExtKeyUsage          = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidTimeStamping         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+)
+
+// hashOIDs are the message imprint algorithms the authority can recognise.
+var hashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA224: {2, 16, 840, 1, 101, 3, 4, 2, 4},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+// TimeStampReq (RFC 3161, section 2.4.1).
+type request struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+	Extensions     []pkix.Extension      `asn1:"tag:0,optional"`
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// TimeStampResp (RFC 3161, section 2.4.2).
+type response struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []asn1.RawValue `asn1:"optional"` // PKIFreeText, UTF8Strings
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"tag:0,optional"`
+}
+
+type accuracy struct {
+	Seconds int64 `asn1:"optional"`
+	Millis  int64 `asn1:"tag:0,optional"`
+	Micros  int64 `asn1:"tag:1,optional"`
+}
+
+// SigningCertificateV2 and ESSCertIDv2 (RFC 5035). The hash algorithm is
+// omitted, meaning SHA-256.
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+type essCertIDv2 struct {
+	CertHash     []byte
+	IssuerSerial issuerSerial
+}
+
+type issuerSerial struct {
+	Issuer       []asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// Rejection is the failure reported in a rejected time-stamp response.
+type Rejection struct {
+	Failure FailureInfo
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("time-stamp request rejected: %s (failure %d)", r.Message, r.Failure)
+}
+
+func reject(failure FailureInfo, format string, args ...interface{}) *Rejection {
+	return &Rejection{Failure: failure, Message: fmt.Sprintf(format, args...)}
+}
+
+// Authority is an RFC 3161 time-stamp authority. It is an http.Handler
+// serving requests POSTed as application/timestamp-query. Certificate and
+// Signer are required; the other fields have defaults. An Authority must not
+// be modified once it serves requests.
+type Authority struct {
+	// Certificate is the TSA certificate. It must carry the critical
+	// timeStamping extended key usage.
+	Certificate *x509.Certificate
+	// Chain holds the issuers of Certificate, returned with it when the
+	// request asks for certificates.
+	Chain []*x509.Certificate
+	// Signer is the private key of Certificate.
+	Signer crypto.Signer
+
+	// Policy is the policy of the tokens; requests naming another policy
+	// are rejected unless it is in AcceptedPolicies. If nil, DefaultPolicy
+	// is used.
+	Policy           asn1.ObjectIdentifier
+	AcceptedPolicies []asn1.ObjectIdentifier
+
+	// Accuracy is the accuracy of the time in the tokens. If zero, no
+	// accuracy is stated.
+	Accuracy time.Duration
+
+	// Hash is the digest algorithm of the token signature. If zero,
+	// SHA-256 is used.
+	Hash crypto.Hash
+	// Hashes are the accepted message imprint algorithms. If empty,
+	// SHA-256, SHA-384 and SHA-512 are accepted.
+	Hashes []crypto.Hash
+
+	// SerialNumber returns the serial number of the next token, unique for
+	// the authority. If nil, random 128-bit serial numbers are used.
+	SerialNumber func() (*big.Int, error)
+
+	// Now returns the time of the tokens. If nil, time.Now is used.
+	Now func() time.Time
+
+	// MaxRequestSize limits the size in bytes of a request. If zero,
+	// DefaultMaxRequestSize is used.
+	MaxRequestSize int64
+}
+
+// SequentialSerialNumbers returns a source of serial numbers for
+// Authority.SerialNumber counting up from first. Serial numbers are only
+// unique as long as the counter is not reset, so it suits tests and
+// authorities that persist the next serial number across restarts.
+func SequentialSerialNumbers(first int64) func() (*big.Int, error) {
+	var mu sync.Mutex
+	next := big.NewInt(first)
+	return func() (*big.Int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		serial := new(big.Int).Set(next)
+		next.Add(next, big.NewInt(1))
+		return serial, nil
+	}
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, err
+	}
+	// Zero is not a valid serial number.
+	return serial.Add(serial, big.NewInt(1)), nil
+}
+
+// ServeHTTP answers a time-stamp request. Requests that are not RFC 3161
+// requests fail with an HTTP error; requests the authority does not accept
+// are answered with a rejected time-stamp response.
+func (a *Authority) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "time-stamp requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != ContentTypeQuery {
+			http.Error(w, "content type must be "+ContentTypeQuery, http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+	limit := a.MaxRequestSize
+	if limit == 0 {
+		limit = DefaultMaxRequestSize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "time-stamp request too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "failed to read the time-stamp request", http.StatusBadRequest)
+		}
+		return
+	}
+
+	resp, err := a.Respond(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeReply)
+	_, _ = w.Write(resp)
+}
+
+// Respond returns the DER time-stamp response to the DER time-stamp
+// request req: a token, or a rejection with the failure. An error is
+// returned only when no response can be encoded.
+func (a *Authority) Respond(req []byte) ([]byte, error) {
+	token, err := a.Issue(req)
+	if err == nil {
+		return asn1.Marshal(response{
+			Status:         pkiStatusInfo{Status: StatusGranted},
+			TimeStampToken: asn1.RawValue{FullBytes: token},
+		})
+	}
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		rejection = reject(SystemFailure, "%v", err)
+	}
+	return asn1.Marshal(response{Status: pkiStatusInfo{
+		Status:       StatusRejection,
+		StatusString: []asn1.RawValue{{Tag: asn1.TagUTF8String, Bytes: []byte(rejection.Message)}},
+		FailInfo:     failInfoBits(rejection.Failure),
+	}})
+}
+
+// failInfoBits encodes failure as a PKIFailureInfo bit string.
+func failInfoBits(failure FailureInfo) asn1.BitString {
+	bits := make([]byte, int(failure)/8+1)
+	bits[failure/8] = 0x80 >> (failure % 8)
+	return asn1.BitString{Bytes: bits, BitLength: int(failure) + 1}
+}
+
+// Issue returns the DER time-stamp token (a CMS SignedData of a TSTInfo)
+// answering the DER time-stamp request req. Requests the authority does not
+// accept fail with a *Rejection.
+func (a *Authority) Issue(req []byte) ([]byte, error) {
+	if a.Certificate == nil || a.Signer == nil {
+		return nil, errors.New("tsa: the authority has no certificate or key")
+	}
+
+	var tsq request
+	rest, err := asn1.Unmarshal(req, &tsq)
+	if err != nil || len(rest) > 0 {
+		return nil, reject(BadDataFormat, "malformed time-stamp request")
+	}
+	if tsq.Version != 1 {
+		return nil, reject(BadRequest, "unsupported request version %d", tsq.Version)
+	}
+	hash, ok := a.imprintHash(tsq.MessageImprint.HashAlgorithm.Algorithm)
+	if !ok {
+		return nil, reject(BadAlgorithm, "unsupported message imprint algorithm %s", tsq.MessageImprint.HashAlgorithm.Algorithm)
+	}
+	if len(tsq.MessageImprint.HashedMessage) != hash.Size() {
+		return nil, reject(BadDataFormat, "message imprint of %d bytes for a %d-byte digest", len(tsq.MessageImprint.HashedMessage), hash.Size())
+	}
+	// No request extension is supported (RFC 3161, section 2.4.1).
+	if len(tsq.Extensions) > 0 {
+		return nil, reject(UnacceptedExtension, "unsupported request extension %s", tsq.Extensions[0].Id)
+	}
+	policy := a.Policy
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+	if tsq.ReqPolicy != nil && !tsq.ReqPolicy.Equal(policy) {
+		if !slices.ContainsFunc(a.AcceptedPolicies, tsq.ReqPolicy.Equal) {
+			return nil, reject(UnacceptedPolicy, "unaccepted policy %s", tsq.ReqPolicy)
+		}
+		policy = tsq.ReqPolicy
+	}
+
+	serialNumber := a.SerialNumber
+	if serialNumber == nil {
+		serialNumber = randomSerialNumber
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, fmt.Errorf("tsa: serial number: %w", err)
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	tsaName, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: a.Certificate.RawSubject})
+	if err != nil {
+		return nil, err
+	}
+	info := tstInfo{
+		Version: 1,
+		Policy:  policy,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: hashOIDs[hash], Parameters: asn1.NullRawValue},
+			HashedMessage: tsq.MessageImprint.HashedMessage,
+		},
+		SerialNumber: serial,
+		GenTime:      now().UTC().Truncate(time.Second),
+		Accuracy:     accuracyOf(a.Accuracy),
+		Nonce:        tsq.Nonce,
+		TSA:          asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: tsaName},
+	}
+	content, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return a.sign(content, tsq.CertReq)
+}
+
+// imprintHash returns the accepted message imprint algorithm identified by
+// oid.
+func (a *Authority) imprintHash(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	accepted := a.Hashes
+	if len(accepted) == 0 {
+		accepted = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+	}
+	for _, h := range accepted {
+		if hashOIDs[h].Equal(oid) {
+			return h, h.Available()
+		}
+	}
+	return 0, false
+}
+
+// accuracyOf splits d into the seconds, milliseconds and microseconds of
+// an Accuracy, rounding up to a microsecond.
+func accuracyOf(d time.Duration) accuracy {
+	if d <= 0 {
+		return accuracy{}
+	}
+	micros := int64((d + time.Microsecond - 1) / time.Microsecond)
+	return accuracy{Seconds: micros / 1e6, Millis: micros / 1e3 % 1e3, Micros: micros % 1e3}
+}
+
+// sign wraps the TSTInfo content in a CMS SignedData with the
+// SigningCertificateV2 attribute identifying the TSA certificate, and with
+// the certificates when withCerts is set.
+func (a *Authority) sign(content []byte, withCerts bool) ([]byte, error) {
+	hash := a.Hash
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	digestOID, ok := hashOIDs[hash]
+	if !ok || hash == crypto.SHA1 || hash == crypto.SHA224 {
+		return nil, fmt.Errorf("tsa: unsupported signature digest %v", hash)
+	}
+
+	certHash := crypto.SHA256.New()
+	certHash.Write(a.Certificate.Raw)
+	signingCertificate, err := asn1.Marshal(signingCertificateV2{Certs: []essCertIDv2{{
+		CertHash: certHash.Sum(nil),
+		IssuerSerial: issuerSerial{
+			Issuer:       []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: a.Certificate.RawIssuer}},
+			SerialNumber: a.Certificate.SerialNumber,
+		},
+	}}})
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetContentType(oidTSTInfo)
+	sd.SetDigestAlgorithm(digestOID)
+	sd.GetSignedData().Version = 3
+	config := pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{{Type: oidSigningCertificateV2, Value: asn1.RawValue{FullBytes: signingCertificate}}},
+		// The certificates are returned only when requested (RFC 3161,
+		// section 2.4.1).
+		SkipCertificates: !withCerts,
+	}
+	if len(a.Chain) > 0 {
+		err = sd.AddSignerChain(a.Certificate, a.Signer, a.Chain, config)
+	} else {
+		err = sd.AddSigner(a.Certificate, a.Signer, config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tsa: %w", err)
+	}
+	return sd.Finish()
+}
+
+// GenerateCertificate returns a self-signed TSA certificate for name, valid
+// for the given duration, with a new ECDSA P-256 key. It suits tests and
+// trials; the certificate is not trusted by validators.
+func GenerateCertificate(name string, validity time.Duration) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	// RFC 3161 requires the timeStamping extended key usage to be the only
+	// one and critical, which x509.CreateCertificate does not mark.
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidTimeStamping})
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtraExtensions:       []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: eku}},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
@@ -0,0 +1,206 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+)
+
+var testPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+func newTestAuthority(t *testing.T) *Authority {
+	t.Helper()
+	cert, key, err := GenerateCertificate("Test TSA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Authority{
+		Certificate:  cert,
+		Signer:       key,
+		Policy:       testPolicy,
+		Accuracy:     1500 * time.Millisecond,
+		SerialNumber: SequentialSerialNumbers(1),
+	}
+}
+
+func post(t *testing.T, url string, req []byte) []byte {
+	t.Helper()
+	resp, err := http.Post(url, ContentTypeQuery, bytes.NewReader(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentTypeReply {
+		t.Fatalf("HTTP %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAuthority(t *testing.T) {
+	a := newTestAuthority(t)
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	digest := sha256.Sum256([]byte("document"))
+	nonce := big.NewInt(424242)
+	req, err := (&timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: digest[:], Nonce: nonce, Certificates: true}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	for serial := int64(1); serial <= 2; serial++ {
+		ts, err := timestamp.ParseResponse(post(t, server.URL, req))
+		if err != nil {
+			t.Fatalf("ParseResponse() error = %v", err)
+		}
+		if ts.SerialNumber.Int64() != serial || ts.Nonce.Cmp(nonce) != 0 || !ts.Policy.Equal(testPolicy) {
+			t.Errorf("serial %v, nonce %v, policy %v; want %d, %v and %v", ts.SerialNumber, ts.Nonce, ts.Policy, serial, nonce, testPolicy)
+		}
+		if ts.Accuracy != 1500*time.Millisecond || ts.HashAlgorithm != crypto.SHA256 || !bytes.Equal(ts.HashedMessage, digest[:]) {
+			t.Errorf("accuracy %v, imprint %v %x", ts.Accuracy, ts.HashAlgorithm, ts.HashedMessage)
+		}
+		if ts.Time.Before(before) || ts.Time.After(time.Now()) {
+			t.Errorf("time %v is not now", ts.Time)
+		}
+		if len(ts.Certificates) != 1 || !ts.Certificates[0].Equal(a.Certificate) {
+			t.Errorf("token certificates = %d, want the TSA certificate", len(ts.Certificates))
+		}
+	}
+
+	// The token identifies the TSA certificate with ESSCertIDv2.
+	token, err := a.Issue(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sc signingCertificateV2
+	if err := p7.UnmarshalSignedAttribute(oidSigningCertificateV2, &sc); err != nil {
+		t.Fatalf("SigningCertificateV2: %v", err)
+	}
+	certHash := sha256.Sum256(a.Certificate.Raw)
+	if len(sc.Certs) != 1 || !bytes.Equal(sc.Certs[0].CertHash, certHash[:]) || sc.Certs[0].IssuerSerial.SerialNumber.Cmp(a.Certificate.SerialNumber) != 0 {
+		t.Errorf("SigningCertificateV2 = %+v", sc)
+	}
+
+	// Without certReq the token carries no certificate.
+	req, err = (&timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: digest[:]}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := timestamp.ParseResponse(post(t, server.URL, req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.Certificates) != 0 || ts.Nonce != nil {
+		t.Errorf("token has %d certificates and nonce %v, want none", len(ts.Certificates), ts.Nonce)
+	}
+}
+
+func TestAuthorityRejections(t *testing.T) {
+	a := newTestAuthority(t)
+	a.AcceptedPolicies = []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 99999, 2}}
+	sha256Digest := sha256.Sum256([]byte("document"))
+	sha1Digest := sha1.Sum([]byte("document"))
+
+	tests := []struct {
+		name    string
+		req     timestamp.Request
+		raw     []byte
+		failure FailureInfo
+	}{
+		{name: "malformed", raw: []byte("not a request"), failure: BadDataFormat},
+		{name: "SHA-1 imprint", req: timestamp.Request{HashAlgorithm: crypto.SHA1, HashedMessage: sha1Digest[:]}, failure: BadAlgorithm},
+		{name: "short imprint", req: timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: sha1Digest[:]}, failure: BadDataFormat},
+		{name: "other policy", req: timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: sha256Digest[:], TSAPolicyOID: asn1.ObjectIdentifier{1, 2, 3}}, failure: UnacceptedPolicy},
+		{name: "extension", req: timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: sha256Digest[:], ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3}, Value: []byte{5, 0}}}}, failure: UnacceptedExtension},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.raw
+			if req == nil {
+				var err error
+				if req, err = tt.req.Marshal(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			der, err := a.Respond(req)
+			if err != nil {
+				t.Fatalf("Respond() error = %v", err)
+			}
+			var resp response
+			if _, err := asn1.Unmarshal(der, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status.Status != StatusRejection || resp.Status.FailInfo.At(int(tt.failure)) != 1 || len(resp.TimeStampToken.FullBytes) != 0 {
+				t.Errorf("response status %+v, want a rejection with failure %d", resp.Status, tt.failure)
+			}
+		})
+	}
+
+	// An accepted policy other than the default one is used for the token.
+	req, err := (&timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: sha256Digest[:], TSAPolicyOID: a.AcceptedPolicies[0]}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := a.Respond(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts, err := timestamp.ParseResponse(der); err != nil || !ts.Policy.Equal(a.AcceptedPolicies[0]) {
+		t.Errorf("token for an accepted policy: %v, %v", ts, err)
+	}
+}
+
+func TestAuthorityHTTPErrors(t *testing.T) {
+	a := newTestAuthority(t)
+	a.MaxRequestSize = 16
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET: HTTP %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	resp, err = http.Post(server.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain: HTTP %d, want %d", resp.StatusCode, http.StatusUnsupportedMediaType)
+	}
+
+	resp, err = http.Post(server.URL, ContentTypeQuery, bytes.NewReader(make([]byte, 17)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized request: HTTP %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,49 @@
+package verify
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/subnoto/pdfsign/aia"
+)
+
+// aiaCache is shared between verifications so repeated checks of documents
+// from the same issuer do not download the same certificates again.
+var aiaCache = aia.NewCache()
+
+// completeChainsFromAIA returns a pool holding the embedded certificates plus
+// any missing issuers discovered through their caIssuers URLs. Discovered
+// certificates are only ever used as intermediates; they are recorded in
+// validation.FetchedIssuers and failures in validation.ChainWarnings.
+func completeChainsFromAIA(certs []*x509.Certificate, validation *SignatureValidation, options *VerifyOptions) *x509.CertPool {
+	pool := x509.NewCertPool()
+	known := make(map[string]bool, len(certs))
+	for _, cert := range certs {
+		pool.AddCert(cert)
+		known[string(cert.Raw)] = true
+	}
+
+	fetcher := &aia.Fetcher{
+		Client:   getHTTPClient(options),
+		MaxDepth: options.AIAMaxDepth,
+		Cache:    aiaCache,
+	}
+
+	for _, cert := range certs {
+		chain, err := fetcher.CompleteChain(cert, certs)
+		for _, issuer := range chain[1:] {
+			if known[string(issuer.Raw)] {
+				continue
+			}
+			known[string(issuer.Raw)] = true
+			pool.AddCert(issuer)
+			validation.FetchedIssuers = append(validation.FetchedIssuers, issuer.Subject.String())
+		}
+		if err != nil {
+			validation.ChainWarnings = append(validation.ChainWarnings,
+				fmt.Sprintf("AIA issuer discovery for %q failed: %v", cert.Subject.String(), err))
+		}
+	}
+
+	return pool
+}
@@ -0,0 +1,115 @@
+package verify
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/subnoto/pdfsign/common"
+	"github.com/subnoto/pdfsign/revocation"
+)
+
+func TestBuildCertificateChainsWithAIAFetching(t *testing.T) {
+	newCert := func(template, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+
+	var intermediateDER []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(intermediateDER)
+	}))
+	defer server.Close()
+
+	caTemplate := func(serial int64, name string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+	root, rootKey := newCert(caTemplate(1, "AIA Root"), nil, nil)
+	intermediate, intermediateKey := newCert(caTemplate(2, "AIA Intermediate"), root, rootKey)
+	intermediateDER = intermediate.Raw
+	leaf, leafKey := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "AIA Signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		IssuingCertificateURL: []string{server.URL + "/intermediate.cer"},
+	}, intermediate, intermediateKey)
+
+	// The signature embeds the leaf and the root but not the intermediate.
+	sd, err := pkcs7.NewSignedData([]byte("aia"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.AddSigner(leaf, leafKey, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	sd.AddCertificate(root)
+	der, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, enabled := range []bool{false, true} {
+		options := DefaultVerifyOptions()
+		options.ValidateTimestampCertificates = false
+		options.EnableAIAFetching = enabled
+		options.HTTPClient = server.Client()
+
+		var validation SignatureValidation
+		if _, err := buildCertificateChainsWithOptions(p7, &common.SignatureInfo{}, &validation, revocation.InfoArchival{}, options); err != nil {
+			t.Fatalf("buildCertificateChainsWithOptions() error = %v", err)
+		}
+
+		if enabled {
+			if len(validation.FetchedIssuers) != 1 || validation.FetchedIssuers[0] != intermediate.Subject.String() {
+				t.Errorf("with AIA: FetchedIssuers = %v", validation.FetchedIssuers)
+			}
+			if len(validation.ChainWarnings) != 0 {
+				t.Errorf("with AIA: ChainWarnings = %v", validation.ChainWarnings)
+			}
+		} else if len(validation.FetchedIssuers) != 0 {
+			t.Errorf("without AIA: FetchedIssuers = %v", validation.FetchedIssuers)
+		}
+
+		// Downloaded certificates complete the path but never make the
+		// embedded root trusted.
+		if validation.TrustedIssuer {
+			t.Error("TrustedIssuer = true for a private root")
+		}
+	}
+}
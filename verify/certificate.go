@@ -2,8 +2,6 @@ package verify
 
 import (
 	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/asn1"
 	"fmt"
 	"time"
 
@@ -43,6 +41,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 			validation.TimestampTrusted = timestampTrusted
 			if timestampWarning != "" {
 				validation.TimeWarnings = append(validation.TimeWarnings, timestampWarning)
+				validation.addFinding(CodeTimestampNotTrusted, SeverityWarning, SubjectTimestamp, "%s", timestampWarning)
 			}
 		}
 	} else if options.TrustSignatureTime && info.SignatureTime != nil {
@@ -54,6 +53,13 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 	}
 	// If verificationTime is nil, x509.Verify will use current time (default behavior)
 
+	// Intermediates may be completed from caIssuers URLs; those certificates
+	// never end up in a root pool.
+	intermediates := certPool
+	if options.EnableAIAFetching {
+		intermediates = completeChainsFromAIA(p7.Certificates, validation, options)
+	}
+
 	// Set the verification time used
 	if verificationTime != nil {
 		validation.VerificationTime = verificationTime
@@ -72,6 +78,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 			// We can't get the serial number if parsing failed, so we can't store it
 			// But we should track the error for reporting
 			ocspParseErrors = append(ocspParseErrors, fmt.Sprintf("Failed to parse OCSP response: %v", err))
+			validation.addFinding(CodeRevocationDataInvalid, SeverityWarning, SubjectSignature, "failed to parse embedded OCSP response: %v", err)
 			continue
 		} else {
 			ocspStatus[fmt.Sprintf("%x", resp.SerialNumber)] = resp
@@ -85,6 +92,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 		crl, err := x509.ParseRevocationList(c.FullBytes)
 		if err != nil {
 			crlParseErrors = append(crlParseErrors, fmt.Sprintf("Failed to parse CRL: %v", err))
+			validation.addFinding(CodeRevocationDataInvalid, SeverityWarning, SubjectSignature, "failed to parse embedded CRL: %v", err)
 			continue
 		}
 
@@ -119,14 +127,8 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 	// The signing certificate is the one that matches the signer's issuer and serial number
 	signingCertificates := make(map[string]bool)
 	for _, signer := range p7.Signers {
-		// Parse the issuer name from RawValue
-		var issuerName pkix.Name
-		_, err := asn1.Unmarshal(signer.IssuerAndSerialNumber.IssuerName.FullBytes, &issuerName)
-		if err == nil {
-			// Create a key from issuer and serial number to identify the signing certificate
-			signerKey := fmt.Sprintf("%s-%x", issuerName.String(), signer.IssuerAndSerialNumber.SerialNumber)
-			signingCertificates[signerKey] = true
-		}
+		signerKey := fmt.Sprintf("%x-%x", signer.IssuerAndSerialNumber.IssuerName.FullBytes, signer.IssuerAndSerialNumber.SerialNumber)
+		signingCertificates[signerKey] = true
 	}
 
 	// Load system root CAs explicitly to ensure newly added certificates are included
@@ -155,15 +157,16 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 		c.Certificate = cert
 
 		// Check if this is a signing certificate by matching issuer and serial number
-		certKey := fmt.Sprintf("%s-%x", cert.Issuer.String(), cert.SerialNumber)
+		certKey := fmt.Sprintf("%x-%x", cert.RawIssuer, cert.SerialNumber)
 		isSigningCert := signingCertificates[certKey]
+		c.SigningCertificate = isSigningCert
 
 		// Validate Key Usage and Extended Key Usage for PDF signing
 		// Only the signing certificate needs Digital Signature key usage; parent certificates don't need it
 		c.KeyUsageValid, c.KeyUsageError, c.ExtKeyUsageValid, c.ExtKeyUsageError = validateKeyUsage(cert, options, isSigningCert)
 
 		// Try to verify with system root CAs first
-		chain, err := cert.Verify(createVerifyOptions(systemRoots, certPool))
+		chain, err := cert.Verify(createVerifyOptions(systemRoots, intermediates))
 
 		if err == nil {
 			// Successfully verified against system trusted roots
@@ -171,7 +174,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 		} else {
 			// If verification fails with system roots, only try embedded certificates if explicitly allowed
 			if options.AllowUntrustedRoots {
-				altChain, verifyErr := cert.Verify(createVerifyOptions(certPool, certPool))
+				altChain, verifyErr := cert.Verify(createVerifyOptions(certPool, intermediates))
 
 				// If embedded cert verification fails, record the original system root error
 				if verifyErr != nil {
@@ -195,6 +198,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 		if resp, ok := ocspStatus[fmt.Sprintf("%x", cert.SerialNumber)]; ok {
 			c.OCSPResponse = resp
 			c.OCSPEmbedded = true
+			c.OCSPSource = "embedded"
 
 			if resp.Status != ocsp.Good {
 				c.RevocationTime = &resp.RevokedAt
@@ -225,12 +229,14 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 					err = resp.Certificate.CheckSignatureFrom(issuer)
 					if err != nil {
 						errorMsg = fmt.Sprintf("OCSP signing certificate not from certificate issuer: %v", err)
+						validation.addCertificateFinding(CodeRevocationDataInvalid, SeverityError, cert.Subject.String(), "OCSP signing certificate not from certificate issuer: %v", err)
 					}
 				} else {
 					// CA Signed response
 					err = resp.CheckSignatureFrom(issuer)
 					if err != nil {
 						errorMsg = fmt.Sprintf("Failed to verify OCSP response signature: %v", err)
+						validation.addCertificateFinding(CodeRevocationDataInvalid, SeverityError, cert.Subject.String(), "failed to verify OCSP response signature: %v", err)
 					}
 				}
 			}
@@ -240,6 +246,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 		serialStr := fmt.Sprintf("%x", cert.SerialNumber)
 		if revocationTime, ok := crlStatus[serialStr]; ok && revocationTime != nil {
 			c.CRLEmbedded = true
+			c.CRLSource = "embedded"
 			c.RevocationTime = revocationTime
 
 			// Check if revocation occurred before signing
@@ -264,25 +271,18 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 		} else if len(revInfo.CRL) > 0 {
 			// CRL is embedded but this certificate is not in it (so it's not revoked via CRL)
 			c.CRLEmbedded = true
+			c.CRLSource = "embedded"
 		}
 
+		// Consult externally supplied revocation material (offline CRLs and
+		// OCSP responses) for anything the PDF does not answer itself.
+		suppliedWarning := checkSuppliedRevocation(&c, cert, findIssuer(cert, chain, p7.Certificates), validation, options)
+
 		// Perform external revocation checks if enabled
 		if options.EnableExternalRevocationCheck {
 			// External OCSP check
-			if !c.OCSPEmbedded && len(cert.OCSPServer) > 0 {
-				// Try to get issuer from chain first
-				var issuer *x509.Certificate
-				if len(chain) > 0 && len(chain[0]) > 1 {
-					issuer = chain[0][1]
-				} else {
-					// If chain validation failed, try to find issuer from certificate pool
-					for _, candidate := range p7.Certificates {
-						if cert.Issuer.String() == candidate.Subject.String() {
-							issuer = candidate
-							break
-						}
-					}
-				}
+			if !c.OCSPEmbedded && c.OCSPSource == "" && len(cert.OCSPServer) > 0 {
+				issuer := findIssuer(cert, chain, p7.Certificates)
 
 				// Perform OCSP check if we have an issuer certificate
 				if issuer != nil {
@@ -296,6 +296,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 					if ocspResult.Valid && ocspResult.Response != nil {
 						c.OCSPResponse = ocspResult.Response
 						c.OCSPExternal = true
+						c.OCSPSource = "external"
 
 						if ocspResult.Response.Status != ocsp.Good {
 							c.RevocationTime = &ocspResult.Response.RevokedAt
@@ -329,7 +330,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 			}
 
 			// External CRL check
-			if !c.CRLEmbedded && len(cert.CRLDistributionPoints) > 0 {
+			if !c.CRLEmbedded && c.CRLSource == "" && len(cert.CRLDistributionPoints) > 0 {
 				crlResult := performExternalCRLCheck(cert, options)
 				c.CRLExternalChecked = crlResult.Checked
 				c.CRLExternalValid = crlResult.Valid
@@ -339,6 +340,7 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 
 				if crlResult.Valid {
 					c.CRLExternal = true
+					c.CRLSource = "external"
 					if crlResult.IsRevoked && crlResult.RevocationTime != nil {
 						c.RevocationTime = crlResult.RevocationTime
 						// Check if revocation occurred before signing
@@ -366,8 +368,8 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 		}
 
 		// Generate revocation warnings
-		hasOCSP := c.OCSPEmbedded || c.OCSPExternal
-		hasCRL := c.CRLEmbedded || c.CRLExternal
+		hasOCSP := c.OCSPEmbedded || c.OCSPExternal || c.OCSPSource != ""
+		hasCRL := c.CRLEmbedded || c.CRLExternal || c.CRLSource != ""
 		hasRevocationInfo := hasOCSP || hasCRL
 
 		// Check if certificate has revocation distribution points
@@ -406,16 +408,37 @@ func buildCertificateChainsWithOptions(p7 *pkcs7.PKCS7, info *common.SignatureIn
 			}
 		}
 
+		if suppliedWarning != "" {
+			c.RevocationWarning = appendWarning(c.RevocationWarning, suppliedWarning)
+		}
+
+		addCertificateFindings(validation, &c, isSigningCert)
+
 		// Add certificate to result
 		validation.Certificates = append(validation.Certificates, c)
 	}
 
 	// Set trusted issuer flag based on whether any certificate was verified against system roots
 	validation.TrustedIssuer = trustedIssuer
+	addChainFindings(validation, options)
 
 	return errorMsg, nil
 }
 
+// findIssuer returns the issuer of cert, preferring the verified chain and
+// falling back to a subject match among the certificates embedded in the PDF.
+func findIssuer(cert *x509.Certificate, chain [][]*x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	if len(chain) > 0 && len(chain[0]) > 1 {
+		return chain[0][1]
+	}
+	for _, candidate := range candidates {
+		if cert.Issuer.String() == candidate.Subject.String() {
+			return candidate
+		}
+	}
+	return nil
+}
+
 // validateTimestampCertificate validates the timestamp token's signing certificate
 func validateTimestampCertificate(ts *timestamp.Timestamp, options *VerifyOptions) (bool, string) {
 	if ts == nil {
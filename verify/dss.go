@@ -0,0 +1,82 @@
+package verify
+
+import (
+	"crypto/x509"
+	"io"
+
+	"github.com/digitorus/pdf"
+	"golang.org/x/crypto/ocsp"
+)
+
+// documentRevocationSource returns the OCSP responses and CRLs of the
+// Document Security Store (/DSS) of the document catalog, reported with the
+// origin "dss", or nil when the document has none. Entries that cannot be
+// read or parsed are skipped; like supplied data, DSS entries are only used
+// once they verify against the issuer of the certificate they cover.
+func documentRevocationSource(rdr *pdf.Reader) *StaticRevocationSource {
+	dss := rdr.Trailer().Key("Root").Key("DSS")
+	if dss.IsNull() {
+		return nil
+	}
+
+	source := NewStaticRevocationSource()
+	found := false
+	for _, entry := range []struct {
+		key string
+		add func(der []byte, origin string) error
+	}{
+		{"OCSPs", source.AddOCSP},
+		{"CRLs", source.AddCRL},
+	} {
+		streams := dss.Key(entry.key)
+		for i := 0; i < streams.Len(); i++ {
+			rc := streams.Index(i).Reader()
+			der, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil || len(der) == 0 {
+				continue
+			}
+			if entry.add(der, "dss") == nil {
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return source
+}
+
+// revocationSources consults several revocation sources in order and returns
+// the first answer.
+type revocationSources []RevocationSource
+
+// LookupOCSP implements RevocationSource.
+func (s revocationSources) LookupOCSP(cert, issuer *x509.Certificate) (*ocsp.Response, string, error) {
+	var lastErr error
+	for _, source := range s {
+		resp, origin, err := source.LookupOCSP(cert, issuer)
+		if resp != nil {
+			return resp, origin, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return nil, "", lastErr
+}
+
+// LookupCRL implements RevocationSource.
+func (s revocationSources) LookupCRL(cert, issuer *x509.Certificate) (*x509.RevocationList, string, error) {
+	var lastErr error
+	for _, source := range s {
+		crl, origin, err := source.LookupCRL(cert, issuer)
+		if crl != nil {
+			return crl, origin, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return nil, "", lastErr
+}
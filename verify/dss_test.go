@@ -0,0 +1,120 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"github.com/subnoto/pdfsign/common"
+	"github.com/subnoto/pdfsign/revocation"
+	"github.com/subnoto/pdfsign/sign"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestDocumentSecurityStoreRevocation(t *testing.T) {
+	pki := newRevocationTestPKI(t)
+
+	input, err := os.ReadFile(filepath.Join("..", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Skipf("test file not available: %v", err)
+	}
+	rdr, err := pdf.NewReader(bytes.NewReader(input), int64(len(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed bytes.Buffer
+	if _, err := sign.Sign(bytes.NewReader(input), &signed, rdr, int64(len(input)), sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info:     sign.SignDataSignatureInfo{Name: "DSS", Date: time.Now()},
+			CertType: sign.ApprovalSignature,
+		},
+		DigestAlgorithm:   crypto.SHA256,
+		Signer:            pki.leafKey,
+		Certificate:       pki.leaf,
+		CertificateChains: [][]*x509.Certificate{{pki.leaf, pki.ca}},
+		RevocationFunction: func(cert, issuer *x509.Certificate, i *revocation.InfoArchival) error {
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	data, err := sign.AddValidationData(signed.Bytes(), []*x509.Certificate{pki.ca},
+		[][]byte{pki.ocspResponse(t, ocsp.Good, time.Time{})}, nil, nil)
+	if err != nil {
+		t.Fatalf("AddValidationData() error = %v", err)
+	}
+
+	options := DefaultVerifyOptions()
+	options.AllowUntrustedRoots = true
+	resp, err := VerifyBytes(data, options)
+	if err != nil {
+		t.Fatalf("VerifyBytes() error = %v", err)
+	}
+	var leaf *common.Certificate
+	for i, c := range resp.Signatures[0].Validation.Certificates {
+		if c.Certificate.Equal(pki.leaf) {
+			leaf = &resp.Signatures[0].Validation.Certificates[i]
+		}
+	}
+	if leaf == nil {
+		t.Fatal("leaf certificate missing from validation result")
+	}
+	if leaf.OCSPSource != "dss" || leaf.OCSPResponse == nil || leaf.OCSPResponse.Status != ocsp.Good {
+		t.Errorf("OCSPSource = %q, response %v; want a good response from the DSS", leaf.OCSPSource, leaf.OCSPResponse)
+	}
+}
+
+func TestPAdESLevelRevocationSource(t *testing.T) {
+	resp := verifyBytesForTest(t, readTestFile(t, "testfile30.pdf"))
+	if PAdESLevel(resp, 0) == PAdESBB {
+		t.Skip("signature has no valid timestamp")
+	}
+
+	for source, want := range map[string]string{
+		"external":            PAdESBT,
+		"file:/tmp/leaf.ocsp": PAdESBT,
+		"embedded":            PAdESBLT,
+		"dss":                 PAdESBLT,
+	} {
+		certs := resp.Signatures[0].Validation.Certificates
+		for i := range certs {
+			certs[i].OCSPSource, certs[i].CRLSource = source, ""
+		}
+		if got := PAdESLevel(resp, 0); got != want {
+			t.Errorf("PAdESLevel() with %s revocation data = %q, want %s", source, got, want)
+		}
+	}
+}
+
+func TestPAdESLevelDocumentTimestampOrder(t *testing.T) {
+	resp := verifyBytesForTest(t, readTestFile(t, "testfile30.pdf"))
+	if PAdESLevel(resp, 0) == PAdESBB {
+		t.Skip("signature has no valid timestamp")
+	}
+	certs := resp.Signatures[0].Validation.Certificates
+	for i := range certs {
+		certs[i].OCSPSource = "dss"
+	}
+
+	// A valid document timestamp counts only when it covers the signed
+	// revision.
+	timestamp := resp.Signatures[0]
+	timestamp.Info.SubFilter = "ETSI.RFC3161"
+	resp.Signatures = append(resp.Signatures, timestamp)
+	signed := resp.Signatures[0].Info.SignedLength
+	if signed == 0 {
+		t.Fatal("SignedLength = 0")
+	}
+	for length, want := range map[int64]string{signed - 1: PAdESBLT, signed + 100: PAdESBLTA} {
+		resp.Signatures[1].Info.SignedLength = length
+		if got := PAdESLevel(resp, 0); got != want {
+			t.Errorf("PAdESLevel() with a document timestamp over %d of %d bytes = %q, want %s", length, signed, got, want)
+		}
+	}
+}
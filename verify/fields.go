@@ -0,0 +1,158 @@
+package verify
+
+import (
+	"sort"
+
+	"github.com/digitorus/pdf"
+	"github.com/subnoto/pdfsign/common"
+)
+
+// Annotation flags hiding a widget (ISO 32000-1, table 165).
+const (
+	annotationHidden = 1 << 1
+	annotationNoView = 1 << 5
+)
+
+// maxFieldDepth limits the recursion into the /Kids of the field tree.
+const maxFieldDepth = 32
+
+// signatureField is a signed signature field of the AcroForm field tree.
+type signatureField struct {
+	name    string
+	sig     pdf.Value // the signature dictionary, the field's /V
+	widgets []common.SignatureWidget
+	visible bool
+}
+
+// signatureFields returns the signed signature fields of the document in
+// signing order, by the end of their byte range.
+func signatureFields(rdr *pdf.Reader) []signatureField {
+	pageOf := make(map[uint32]int)
+	for i := 1; i <= rdr.NumPage(); i++ {
+		annots := rdr.Page(i).V.Key("Annots")
+		for j := 0; j < annots.Len(); j++ {
+			if id := annots.Index(j).GetPtr().GetID(); id != 0 {
+				pageOf[id] = i
+			}
+		}
+	}
+
+	var fields []signatureField
+	visited := make(map[uint32]bool)
+	var walk func(node pdf.Value, parent, ft string, depth int)
+	walk = func(node pdf.Value, parent, ft string, depth int) {
+		if depth > maxFieldDepth || node.Kind() != pdf.Dict {
+			return
+		}
+		if id := node.GetPtr().GetID(); id != 0 {
+			if visited[id] {
+				return
+			}
+			visited[id] = true
+		}
+		name := node.Key("T").Text()
+		if parent != "" {
+			name = parent + "." + name
+		}
+		if t := node.Key("FT").Name(); t != "" {
+			ft = t
+		}
+
+		var widgets []pdf.Value
+		kids := node.Key("Kids")
+		for i := 0; i < kids.Len(); i++ {
+			// Kids without a partial name are the widgets of this field.
+			if kid := kids.Index(i); kid.Key("T").IsNull() {
+				widgets = append(widgets, kid)
+			} else {
+				walk(kid, name, ft, depth+1)
+			}
+		}
+		if kids.Len() == 0 {
+			widgets = []pdf.Value{node}
+		}
+		sig := inheritedValue(node, "V")
+		if ft != "Sig" || len(widgets) == 0 || sig.Kind() != pdf.Dict {
+			return
+		}
+
+		f := signatureField{name: name, sig: sig}
+		for _, w := range widgets {
+			sw := common.SignatureWidget{Page: pageOf[w.GetPtr().GetID()]}
+			rect := w.Key("Rect")
+			for i := 0; i < 4 && i < rect.Len(); i++ {
+				sw.Rect[i] = rect.Index(i).Float64()
+			}
+			flags := w.Key("F").Int64()
+			if sw.Page != 0 && sw.Rect[0] != sw.Rect[2] && sw.Rect[1] != sw.Rect[3] && flags&(annotationHidden|annotationNoView) == 0 {
+				f.visible = true
+			}
+			f.widgets = append(f.widgets, sw)
+		}
+		fields = append(fields, f)
+	}
+
+	acroFields := rdr.Trailer().Key("Root").Key("AcroForm").Key("Fields")
+	for i := 0; i < acroFields.Len(); i++ {
+		walk(acroFields.Index(i), "", "", 0)
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return signedEnd(fields[i].sig) < signedEnd(fields[j].sig)
+	})
+	return fields
+}
+
+// inheritedValue returns the entry key of a field or of its nearest
+// ancestor that has it.
+func inheritedValue(field pdf.Value, key string) pdf.Value {
+	for depth := 0; depth <= maxFieldDepth && field.Kind() == pdf.Dict; depth++ {
+		if v := field.Key(key); !v.IsNull() {
+			return v
+		}
+		field = field.Key("Parent")
+	}
+	return pdf.Value{}
+}
+
+// signedEnd returns the byte after the range signed by signature sig.
+func signedEnd(sig pdf.Value) int64 {
+	br := sig.Key("ByteRange")
+	if br.Len() != 4 {
+		return 0
+	}
+	return br.Index(2).Int64() + br.Index(3).Int64()
+}
+
+// isSignatureDictionary reports whether v looks like a signature
+// dictionary: a signature handler, a byte range and the signature value.
+func isSignatureDictionary(v pdf.Value) bool {
+	return v.Kind() == pdf.Dict && v.Key("Filter").Kind() == pdf.Name &&
+		v.Key("ByteRange").Kind() == pdf.Array && v.Key("Contents").Kind() == pdf.String
+}
+
+// orphanSignatures returns the signature dictionaries of the document that
+// no signature field refers to.
+func orphanSignatures(rdr *pdf.Reader, fields []signatureField) []OrphanSignature {
+	referenced := make(map[uint32]bool, len(fields))
+	for _, f := range fields {
+		referenced[f.sig.GetPtr().GetID()] = true
+	}
+	var orphans []OrphanSignature
+	for _, x := range rdr.Xref() {
+		id := x.Ptr().GetID()
+		if id == 0 || referenced[id] {
+			continue
+		}
+		v, err := rdr.GetObject(id)
+		if err != nil || !isSignatureDictionary(v) {
+			continue
+		}
+		orphans = append(orphans, OrphanSignature{
+			Object:    id,
+			Filter:    v.Key("Filter").Name(),
+			SubFilter: v.Key("SubFilter").Name(),
+			Name:      v.Key("Name").Text(),
+		})
+	}
+	return orphans
+}
@@ -0,0 +1,108 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestSignatureFields(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testfiles", "testfile50.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := Verify(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(response.Signatures) != 3 || len(response.OrphanSignatures) != 0 {
+		t.Fatalf("%d signatures and %d orphans, want 3 and 0", len(response.Signatures), len(response.OrphanSignatures))
+	}
+	for i, sig := range response.Signatures {
+		info := sig.Info
+		if want := fmt.Sprintf("Signature %d", i+1); info.Field != want {
+			t.Errorf("signature %d is field %q, want %q in signing order", i+1, info.Field, want)
+		}
+		if info.Filter != "Adobe.PPKLite" || len(info.Widgets) != 1 || info.Widgets[0].Page != 1 || !info.Visible {
+			t.Errorf("signature %d: filter %q, widgets %+v, visible %v", i+1, info.Filter, info.Widgets, info.Visible)
+		}
+	}
+}
+
+func TestSignatureFieldsOtherHandler(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testfiles", "testfile50.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signatures of other handlers are reported, although changing the
+	// handler breaks the signed bytes.
+	data = bytes.ReplaceAll(data, []byte("/Adobe.PPKLite"), []byte("/Entrust.PPKEF"))
+	response, err := Verify(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(response.Signatures) != 3 {
+		t.Fatalf("%d signatures, want 3", len(response.Signatures))
+	}
+	for i, sig := range response.Signatures {
+		if sig.Info.Filter != "Entrust.PPKEF" || sig.Info.Field == "" {
+			t.Errorf("signature %d: field %q, filter %q", i+1, sig.Info.Field, sig.Info.Filter)
+		}
+	}
+}
+
+// appendUpdate appends an incremental update with a cross-reference table
+// defining the objects to data, of size objects in total.
+func appendUpdate(t *testing.T, data []byte, size int, objects map[int]string) []byte {
+	t.Helper()
+	startxref := regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`).FindSubmatch(data)
+	if startxref == nil {
+		t.Fatal("no startxref")
+	}
+	prev, _ := strconv.Atoi(string(startxref[1]))
+	root := regexp.MustCompile(`/Root\s*(\d+ \d+ R)`).FindAllSubmatch(data, -1)
+
+	buf := bytes.NewBuffer(append(data[:len(data):len(data)], '\n'))
+	var xref bytes.Buffer
+	for id := 1; len(objects) > 0; id++ {
+		obj, ok := objects[id]
+		if !ok {
+			continue
+		}
+		delete(objects, id)
+		fmt.Fprintf(&xref, "%d 1\n%010d 00000 n \n", id, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", id, obj)
+	}
+	offset := buf.Len()
+	fmt.Fprintf(buf, "xref\n%strailer\n<< /Size %d /Root %s /Prev %d >>\nstartxref\n%d\n%%%%EOF\n",
+		xref.String(), size, root[len(root)-1][1], prev, offset)
+	return buf.Bytes()
+}
+
+func TestOrphanSignature(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, _ := strconv.Atoi(string(regexp.MustCompile(`/Size\s*(\d+)`).FindSubmatch(data)[1]))
+	// A signature dictionary of another handler that no field refers to.
+	orphaned := appendUpdate(t, data, size+1, map[int]string{
+		size: "<< /Type /Sig /Filter /Entrust.PPKEF /SubFilter /adbe.pkcs7.detached /ByteRange [0 10 20 5] /Contents <3080> /Name (Mallory) >>",
+	})
+
+	response, err := Verify(bytes.NewReader(orphaned), int64(len(orphaned)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(response.Signatures) != 0 {
+		t.Errorf("%d signatures, want the orphan not to be verified", len(response.Signatures))
+	}
+	want := OrphanSignature{Object: uint32(size), Filter: "Entrust.PPKEF", SubFilter: "adbe.pkcs7.detached", Name: "Mallory"}
+	if len(response.OrphanSignatures) != 1 || response.OrphanSignatures[0] != want {
+		t.Errorf("OrphanSignatures = %+v, want %+v", response.OrphanSignatures, want)
+	}
+}
@@ -0,0 +1,245 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/subnoto/pdfsign/common"
+)
+
+// Sentinel errors identifying the kinds of problems verification can find.
+// Every Finding maps to one of them through Finding.Err, so callers can
+// branch with errors.Is on Response.Err or SignatureValidation.Err.
+var (
+	ErrInvalidDocument         = errors.New("invalid document")
+	ErrNoSignature             = errors.New("no digital signature in document")
+	ErrMalformedSignature      = errors.New("malformed signature")
+	ErrByteRangeInvalid        = errors.New("invalid byte range")
+	ErrByteRangeMismatch       = errors.New("signed bytes do not match byte range")
+	ErrSignatureInvalid        = errors.New("signature invalid")
+	ErrTimestampInvalid        = errors.New("timestamp invalid")
+	ErrChainUntrusted          = errors.New("certificate chain untrusted")
+	ErrChainIncomplete         = errors.New("certificate chain incomplete")
+	ErrCertificateRevoked      = errors.New("certificate revoked")
+	ErrKeyUsage                = errors.New("certificate key usage not permitted")
+	ErrRevocationDataInvalid   = errors.New("revocation data invalid")
+	ErrRevocationUnavailable   = errors.New("revocation status unavailable")
+	ErrSigningTimeUntrusted    = errors.New("signing time untrusted")
+	ErrTimestampNotTrusted     = errors.New("timestamp certificate untrusted")
+	ErrRevokedAfterSigningTime = errors.New("certificate revoked after signing time")
+	ErrModifiedAfterSigning    = errors.New("document modified after signing")
+	ErrTrailingBytes           = errors.New("trailing bytes after the last revision")
+)
+
+// Finding codes, one per sentinel error.
+const (
+	CodeInvalidDocument         = "invalid_document"
+	CodeNoSignature             = "no_signature"
+	CodeMalformedSignature      = "malformed_signature"
+	CodeByteRangeInvalid        = "byte_range_invalid"
+	CodeByteRangeMismatch       = "byte_range_mismatch"
+	CodeSignatureInvalid        = "signature_invalid"
+	CodeTimestampInvalid        = "timestamp_invalid"
+	CodeChainUntrusted          = "chain_untrusted"
+	CodeChainIncomplete         = "chain_incomplete"
+	CodeCertificateRevoked      = "certificate_revoked"
+	CodeKeyUsage                = "key_usage"
+	CodeRevocationDataInvalid   = "revocation_data_invalid"
+	CodeRevocationUnavailable   = "revocation_unavailable"
+	CodeSigningTimeUntrusted    = "signing_time_untrusted"
+	CodeTimestampNotTrusted     = "timestamp_not_trusted"
+	CodeRevokedAfterSigningTime = "revoked_after_signing_time"
+	CodeModifiedAfterSigning    = "modified_after_signing"
+	CodeTrailingBytes           = "trailing_bytes"
+)
+
+var codeErrors = map[string]error{
+	CodeInvalidDocument:         ErrInvalidDocument,
+	CodeNoSignature:             ErrNoSignature,
+	CodeMalformedSignature:      ErrMalformedSignature,
+	CodeByteRangeInvalid:        ErrByteRangeInvalid,
+	CodeByteRangeMismatch:       ErrByteRangeMismatch,
+	CodeSignatureInvalid:        ErrSignatureInvalid,
+	CodeTimestampInvalid:        ErrTimestampInvalid,
+	CodeChainUntrusted:          ErrChainUntrusted,
+	CodeChainIncomplete:         ErrChainIncomplete,
+	CodeCertificateRevoked:      ErrCertificateRevoked,
+	CodeKeyUsage:                ErrKeyUsage,
+	CodeRevocationDataInvalid:   ErrRevocationDataInvalid,
+	CodeRevocationUnavailable:   ErrRevocationUnavailable,
+	CodeSigningTimeUntrusted:    ErrSigningTimeUntrusted,
+	CodeTimestampNotTrusted:     ErrTimestampNotTrusted,
+	CodeRevokedAfterSigningTime: ErrRevokedAfterSigningTime,
+	CodeModifiedAfterSigning:    ErrModifiedAfterSigning,
+	CodeTrailingBytes:           ErrTrailingBytes,
+}
+
+// Severity classifies how a Finding affects the verification outcome.
+type Severity string
+
+const (
+	// SeverityError findings make the signature invalid or untrusted.
+	SeverityError Severity = "error"
+	// SeverityWarning findings weaken the result without invalidating it.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo findings are purely informational.
+	SeverityInfo Severity = "info"
+)
+
+// Subject identifies what a Finding is about.
+type Subject string
+
+const (
+	SubjectSignature   Subject = "signature"
+	SubjectCertificate Subject = "certificate"
+	SubjectTimestamp   Subject = "timestamp"
+	SubjectRevision    Subject = "revision"
+)
+
+// Finding is a single machine-readable verification result.
+type Finding struct {
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	Subject  Subject  `json:"subject"`
+	Message  string   `json:"message"`
+
+	// Certificate is the subject DN of the certificate concerned, for
+	// findings with SubjectCertificate.
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// Err returns the finding as an error wrapping its sentinel error.
+func (f Finding) Err() error {
+	sentinel, ok := codeErrors[f.Code]
+	if !ok {
+		return errors.New(f.Message)
+	}
+	return fmt.Errorf("%w: %s", sentinel, f.Message)
+}
+
+// addFinding records a finding on the signature validation.
+func (v *SignatureValidation) addFinding(code string, severity Severity, subject Subject, format string, args ...any) {
+	v.Findings = append(v.Findings, Finding{
+		Code:     code,
+		Severity: severity,
+		Subject:  subject,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// addCertificateFinding records a finding concerning the certificate with
+// the given subject DN.
+func (v *SignatureValidation) addCertificateFinding(code string, severity Severity, certificate, format string, args ...any) {
+	v.Findings = append(v.Findings, Finding{
+		Code:        code,
+		Severity:    severity,
+		Subject:     SubjectCertificate,
+		Message:     fmt.Sprintf(format, args...),
+		Certificate: certificate,
+	})
+}
+
+// Err joins the error-severity findings of the signature, or returns nil when
+// there are none.
+func (v SignatureValidation) Err() error {
+	var errs []error
+	for _, f := range v.Findings {
+		if f.Severity == SeverityError {
+			errs = append(errs, f.Err())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Err joins the error-severity findings of all signatures, or returns nil
+// when every signature verified without errors.
+func (r *Response) Err() error {
+	var errs []error
+	for _, s := range r.Signatures {
+		if err := s.Validation.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addCertificateFindings records findings for one certificate once its
+// chain, key usage and revocation status have been evaluated. Extended Key
+// Usage only matters for the signing certificate; CA certificates commonly
+// carry none.
+func addCertificateFindings(validation *SignatureValidation, c *common.Certificate, isSigningCert bool) {
+	subject := c.Certificate.Subject.String()
+
+	if c.VerifyError != "" {
+		validation.addCertificateFinding(CodeChainUntrusted, SeverityError, subject, "%s", c.VerifyError)
+	}
+	if !c.KeyUsageValid && c.KeyUsageError != "" {
+		validation.addCertificateFinding(CodeKeyUsage, SeverityError, subject, "%s", c.KeyUsageError)
+	}
+	if isSigningCert && c.ExtKeyUsageError != "" {
+		validation.addCertificateFinding(CodeKeyUsage, SeverityWarning, subject, "%s", c.ExtKeyUsageError)
+	}
+	if c.RevocationTime != nil {
+		if c.RevokedBeforeSigning {
+			validation.addCertificateFinding(CodeCertificateRevoked, SeverityError, subject,
+				"revoked at %s, before the signing time", c.RevocationTime.UTC().Format(time.RFC3339))
+		} else {
+			validation.addCertificateFinding(CodeRevokedAfterSigningTime, SeverityWarning, subject,
+				"revoked at %s", c.RevocationTime.UTC().Format(time.RFC3339))
+		}
+	}
+	for _, warning := range []string{c.OCSPExternalWarning, c.CRLExternalWarning, c.RevocationWarning} {
+		if warning != "" {
+			validation.addCertificateFinding(CodeRevocationUnavailable, SeverityWarning, subject, "%s", warning)
+		}
+	}
+}
+
+// addChainFindings records the signature-level findings derived from the
+// overall chain and time evaluation. Every TimeWarning is covered by a
+// finding: revocation after the signing time by the certificate findings,
+// the signing time fallback and the timestamp certificate here, and
+// revocation data predating the signing time where it is checked.
+func addChainFindings(validation *SignatureValidation, options *VerifyOptions) {
+	// Revocation without a determinable time ordering is reported on the
+	// signature so it is not lost when no certificate carries the time.
+	if validation.RevokedCertificate && !hasFinding(validation, CodeCertificateRevoked) {
+		validation.addFinding(CodeCertificateRevoked, SeverityError, SubjectSignature,
+			"a certificate in the chain is revoked and revocation cannot be placed after the signing time")
+	}
+
+	if !validation.TrustedIssuer && !hasFinding(validation, CodeChainUntrusted) {
+		// The chain verified against embedded certificates only
+		// (AllowUntrustedRoots); it is usable but not anchored in a trusted root.
+		severity := SeverityError
+		if options.AllowUntrustedRoots {
+			severity = SeverityWarning
+		}
+		validation.addFinding(CodeChainUntrusted, severity, SubjectSignature,
+			"certificate chain is not anchored in a trusted root")
+	}
+
+	for _, w := range validation.ChainWarnings {
+		validation.addFinding(CodeChainIncomplete, SeverityWarning, SubjectCertificate, "%s", w)
+	}
+
+	if validation.TimeSource == "signature_time" {
+		validation.addFinding(CodeSigningTimeUntrusted, SeverityWarning, SubjectSignature,
+			"verification uses the signing time claimed by the signer")
+	}
+	if validation.TimestampStatus == "valid" && !validation.TimestampTrusted && options.ValidateTimestampCertificates &&
+		!hasFinding(validation, CodeTimestampNotTrusted) {
+		validation.addFinding(CodeTimestampNotTrusted, SeverityWarning, SubjectTimestamp,
+			"timestamp token certificate could not be validated")
+	}
+}
+
+func hasFinding(validation *SignatureValidation, code string) bool {
+	for _, f := range validation.Findings {
+		if f.Code == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"github.com/subnoto/pdfsign/sign"
+)
+
+func verifyBytesForTest(t *testing.T, data []byte) *Response {
+	t.Helper()
+	resp, err := Verify(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	return resp
+}
+
+func readTestFile(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "testfiles", name))
+	if err != nil {
+		t.Skipf("test file %s not available: %v", name, err)
+	}
+	return data
+}
+
+func hasFindingCode(v SignatureValidation, code string, severity Severity) bool {
+	for _, f := range v.Findings {
+		if f.Code == code && f.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFindingsUntrustedChain(t *testing.T) {
+	resp := verifyBytesForTest(t, readTestFile(t, "testfile30.pdf"))
+	if len(resp.Signatures) == 0 {
+		t.Fatal("no signatures")
+	}
+	v := resp.Signatures[0].Validation
+	if !hasFindingCode(v, CodeChainUntrusted, SeverityError) {
+		t.Errorf("expected %s finding, got %+v", CodeChainUntrusted, v.Findings)
+	}
+	if !errors.Is(resp.Err(), ErrChainUntrusted) {
+		t.Errorf("Response.Err() = %v, want ErrChainUntrusted", resp.Err())
+	}
+	for _, f := range v.Findings {
+		if f.Subject == SubjectCertificate && f.Certificate == "" && f.Code == CodeChainUntrusted {
+			t.Errorf("certificate finding without certificate: %+v", f)
+		}
+	}
+}
+
+func TestFindingsByteRangeMismatch(t *testing.T) {
+	data := readTestFile(t, "testfile30.pdf")
+
+	// Change the binary marker comment on the second line: it is covered by
+	// the signature but ignored by the parser.
+	idx := bytes.IndexByte(data, '%') + 1
+	idx += bytes.IndexByte(data[idx:], '%') + 1
+	tampered := append([]byte(nil), data...)
+	tampered[idx] ^= 0x01
+
+	resp := verifyBytesForTest(t, tampered)
+	if len(resp.Signatures) == 0 {
+		t.Fatal("tampered signature was dropped from the response")
+	}
+	v := resp.Signatures[0].Validation
+	if v.ValidSignature {
+		t.Error("ValidSignature = true for a tampered document")
+	}
+	if !errors.Is(resp.Err(), ErrByteRangeMismatch) {
+		t.Errorf("Response.Err() = %v, want ErrByteRangeMismatch", resp.Err())
+	}
+}
+
+func TestFindingsMalformedSignatureIsReported(t *testing.T) {
+	data := readTestFile(t, "testfile30.pdf")
+
+	start := bytes.Index(data, []byte("/Contents<"))
+	if start < 0 {
+		t.Fatal("no /Contents in test file")
+	}
+	start += len("/Contents<")
+	corrupted := append([]byte(nil), data...)
+	copy(corrupted[start:], bytes.Repeat([]byte("0"), 64))
+
+	resp := verifyBytesForTest(t, corrupted)
+	if len(resp.Signatures) != 1 {
+		t.Fatalf("got %d signatures, want the malformed one reported", len(resp.Signatures))
+	}
+	v := resp.Signatures[0].Validation
+	if !hasFindingCode(v, CodeMalformedSignature, SeverityError) {
+		t.Errorf("expected %s finding, got %+v", CodeMalformedSignature, v.Findings)
+	}
+	if !errors.Is(v.Err(), ErrMalformedSignature) {
+		t.Errorf("SignatureValidation.Err() = %v, want ErrMalformedSignature", v.Err())
+	}
+}
+
+func TestNoSignatureSentinel(t *testing.T) {
+	data := readTestFile(t, "testfile12.pdf")
+	if _, err := Verify(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("Verify() error = %v, want ErrNoSignature", err)
+	}
+}
+
+func TestFindingsRevision(t *testing.T) {
+	pki := newRevocationTestPKI(t)
+	input := readTestFile(t, "testfile20.pdf")
+	rdr, err := pdf.NewReader(bytes.NewReader(input), int64(len(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed bytes.Buffer
+	if _, err := sign.Sign(bytes.NewReader(input), &signed, rdr, int64(len(input)), sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info:     sign.SignDataSignatureInfo{Name: "Revision", Date: time.Now()},
+			CertType: sign.ApprovalSignature,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pki.leafKey,
+		Certificate:     pki.leaf,
+	}); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	data := signed.Bytes()
+	// A later incremental update adding validation data.
+	updated, err := sign.AddValidationData(data, []*x509.Certificate{pki.ca}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("AddValidationData() error = %v", err)
+	}
+
+	tests := []struct {
+		name               string
+		data               []byte
+		modified, trailing bool
+	}{
+		{"signed", data, false, false},
+		{"trailing whitespace", append(append([]byte(nil), data...), "\r\n\n"...), false, false},
+		{"trailing bytes", append(append([]byte(nil), data...), "garbage"...), false, true},
+		{"later revision", updated, true, false},
+		{"later revision and trailing bytes", append(append([]byte(nil), updated...), "garbage"...), true, true},
+	}
+	for _, tt := range tests {
+		v := verifyBytesForTest(t, tt.data).Signatures[0].Validation
+		if got := hasFindingCode(v, CodeModifiedAfterSigning, SeverityInfo); got != tt.modified {
+			t.Errorf("%s: %s finding = %v, want %v", tt.name, CodeModifiedAfterSigning, got, tt.modified)
+		}
+		if got := hasFindingCode(v, CodeTrailingBytes, SeverityWarning); got != tt.trailing {
+			t.Errorf("%s: %s finding = %v, want %v", tt.name, CodeTrailingBytes, got, tt.trailing)
+		}
+		for _, f := range v.Findings {
+			if (f.Code == CodeModifiedAfterSigning || f.Code == CodeTrailingBytes) && f.Subject != SubjectRevision {
+				t.Errorf("%s: %s finding subject = %s, want %s", tt.name, f.Code, f.Subject, SubjectRevision)
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/subnoto/pdfsign/common"
+)
+
+// PAdES baseline levels (ETSI EN 319 142-1) in increasing order.
+const (
+	PAdESNone = ""
+	PAdESBB   = "B-B"
+	PAdESBT   = "B-T"
+	PAdESBLT  = "B-LT"
+	PAdESBLTA = "B-LTA"
+)
+
+var padesRank = map[string]int{PAdESNone: 0, PAdESBB: 1, PAdESBT: 2, PAdESBLT: 3, PAdESBLTA: 4}
+
+// Policy states which verification results are acceptable. The zero value
+// only requires every signature to be cryptographically intact.
+type Policy struct {
+	// RequireTrustedChain requires every signer chain to end in a trusted
+	// root (no chain_untrusted error finding).
+	RequireTrustedChain bool `json:"require_trusted_chain"`
+
+	// RequireTimestamp requires a valid RFC 3161 signature timestamp.
+	RequireTimestamp bool `json:"require_timestamp"`
+
+	// RequireNotRevoked rejects signatures whose chain contains a
+	// certificate revoked before the signing time.
+	RequireNotRevoked bool `json:"require_not_revoked"`
+
+	// RequireFullCoverage requires the last signature or document timestamp
+	// to cover the whole file, so no unsigned changes were appended.
+	RequireFullCoverage bool `json:"require_full_coverage"`
+
+	// MinPAdESLevel is the lowest acceptable PAdES baseline level of each
+	// signature: "B-B", "B-T", "B-LT" or "B-LTA".
+	MinPAdESLevel string `json:"min_pades_level"`
+
+	// MinSignatures is the minimum number of signatures, not counting
+	// document timestamps.
+	MinSignatures int `json:"min_signatures"`
+
+	// AllowedSigners lists glob patterns (see path.Match) matched against
+	// the signing certificate's subject DN and common name. Unlike in
+	// path.Match, * and ? also match a "/", which DNs may contain. Empty
+	// allows any signer.
+	AllowedSigners []string `json:"allowed_signers"`
+}
+
+// DefaultPolicy returns the policy applied when none is configured: at least
+// one intact signature from a trusted, non-revoked chain.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		RequireTrustedChain: true,
+		RequireNotRevoked:   true,
+		MinSignatures:       1,
+	}
+}
+
+// LoadPolicy reads a policy from a JSON or YAML file.
+func LoadPolicy(name string) (*Policy, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return p, nil
+}
+
+// ParsePolicy parses a policy from a JSON object or, when data does not
+// start with "{", from a YAML mapping of the same keys (see
+// parseYAMLPolicy for the YAML accepted). Unknown keys are rejected.
+func ParsePolicy(data []byte) (*Policy, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+		values, err := parseYAMLPolicy(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy: %w", err)
+		}
+		if data, err = json.Marshal(values); err != nil {
+			return nil, fmt.Errorf("invalid policy: %w", err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid policy: unexpected data after the policy object")
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	p.MinPAdESLevel = strings.TrimPrefix(strings.ToUpper(p.MinPAdESLevel), "PADES-")
+	if _, ok := padesRank[p.MinPAdESLevel]; !ok {
+		return fmt.Errorf("invalid policy: unknown PAdES level %q", p.MinPAdESLevel)
+	}
+	if p.MinSignatures < 0 {
+		return fmt.Errorf("invalid policy: min_signatures must not be negative")
+	}
+	for _, pattern := range p.AllowedSigners {
+		if _, err := matchSigner(pattern, ""); err != nil {
+			return fmt.Errorf("invalid policy: allowed signer pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// VerdictStatus is the overall outcome of evaluating a policy.
+type VerdictStatus string
+
+const (
+	// VerdictPassed means every signature is intact and the policy holds.
+	VerdictPassed VerdictStatus = "passed"
+	// VerdictInvalid means at least one signature is broken: it is
+	// malformed, does not match the signed bytes or does not verify.
+	VerdictInvalid VerdictStatus = "invalid"
+	// VerdictPolicyFailed means the signatures are intact but do not
+	// satisfy the policy.
+	VerdictPolicyFailed VerdictStatus = "policy_failed"
+)
+
+// Policy rule names used in Violation.Rule.
+const (
+	RuleIntegrity      = "integrity"
+	RuleTrustedChain   = "require_trusted_chain"
+	RuleTimestamp      = "require_timestamp"
+	RuleNotRevoked     = "require_not_revoked"
+	RuleFullCoverage   = "require_full_coverage"
+	RuleMinPAdESLevel  = "min_pades_level"
+	RuleMinSignatures  = "min_signatures"
+	RuleAllowedSigners = "allowed_signers"
+)
+
+// Violation is one policy rule a document or signature does not satisfy.
+type Violation struct {
+	Rule string `json:"rule"`
+	// Signature is the index in Response.Signatures, or -1 for rules about
+	// the whole document.
+	Signature int    `json:"signature"`
+	Message   string `json:"message"`
+}
+
+// Verdict is the result of evaluating a Policy against a Response.
+type Verdict struct {
+	Status     VerdictStatus `json:"status"`
+	Violations []Violation   `json:"violations,omitempty"`
+}
+
+// Passed reports whether the verdict is VerdictPassed.
+func (v *Verdict) Passed() bool {
+	return v.Status == VerdictPassed
+}
+
+func (v *Verdict) add(rule string, signature int, format string, args ...any) {
+	v.Violations = append(v.Violations, Violation{Rule: rule, Signature: signature, Message: fmt.Sprintf(format, args...)})
+}
+
+// integrityCodes are the finding codes that make a signature invalid
+// regardless of policy.
+var integrityCodes = map[string]bool{
+	CodeMalformedSignature: true,
+	CodeByteRangeInvalid:   true,
+	CodeByteRangeMismatch:  true,
+	CodeSignatureInvalid:   true,
+	CodeTimestampInvalid:   true,
+}
+
+// Evaluate checks resp against the policy. A nil resp is treated as a
+// document without signatures.
+func (p *Policy) Evaluate(resp *Response) *Verdict {
+	verdict := &Verdict{Status: VerdictPassed}
+	if resp == nil {
+		resp = &Response{}
+	}
+
+	var signatures int
+	var invalid, covered bool
+	for i, sig := range resp.Signatures {
+		broken := false
+		for _, f := range sig.Validation.Findings {
+			if f.Severity == SeverityError && integrityCodes[f.Code] {
+				broken = true
+				verdict.add(RuleIntegrity, i, "%s", f.Message)
+			}
+		}
+		if !sig.Validation.ValidSignature && !broken {
+			broken = true
+			verdict.add(RuleIntegrity, i, "signature does not verify")
+		}
+		invalid = invalid || broken
+		covered = covered || sig.Info.CoversWholeDocument
+
+		if isDocumentTimestamp(sig.Info) {
+			continue
+		}
+		signatures++
+		p.evaluateSignature(verdict, i, sig.Info, sig.Validation, resp)
+	}
+
+	if signatures < p.MinSignatures {
+		verdict.add(RuleMinSignatures, -1, "document has %d signature(s), policy requires at least %d", signatures, p.MinSignatures)
+	}
+	if p.RequireFullCoverage && !covered {
+		verdict.add(RuleFullCoverage, -1, "document was modified after the last signature")
+	}
+
+	switch {
+	case invalid:
+		verdict.Status = VerdictInvalid
+	case len(verdict.Violations) > 0:
+		verdict.Status = VerdictPolicyFailed
+	}
+	return verdict
+}
+
+func (p *Policy) evaluateSignature(verdict *Verdict, i int, info common.SignatureInfo, validation SignatureValidation, resp *Response) {
+	if p.RequireTrustedChain && hasErrorFinding(validation, CodeChainUntrusted) {
+		verdict.add(RuleTrustedChain, i, "certificate chain is not anchored in a trusted root")
+	}
+	if p.RequireTimestamp && (info.TimeStamp == nil || validation.TimestampStatus != "valid") {
+		verdict.add(RuleTimestamp, i, "signature has no valid timestamp")
+	}
+	if p.RequireNotRevoked && hasErrorFinding(validation, CodeCertificateRevoked) {
+		verdict.add(RuleNotRevoked, i, "a certificate in the chain is revoked")
+	}
+	if p.MinPAdESLevel != PAdESNone {
+		level := PAdESLevel(resp, i)
+		if padesRank[level] < padesRank[p.MinPAdESLevel] {
+			if level == PAdESNone {
+				level = "none"
+			}
+			verdict.add(RuleMinPAdESLevel, i, "signature is PAdES %s, policy requires %s", level, p.MinPAdESLevel)
+		}
+	}
+	if len(p.AllowedSigners) > 0 {
+		subject, cn := signerNames(validation)
+		if !matchesAny(p.AllowedSigners, subject, cn) {
+			verdict.add(RuleAllowedSigners, i, "signer %q is not allowed", subject)
+		}
+	}
+}
+
+// PAdESLevel returns the PAdES baseline level reached by the signature at
+// index i of resp: B-B for a detached CMS signature, B-T with a valid
+// signature timestamp, B-LT when the document embeds revocation data for every
+// non-root certificate and B-LTA when a valid document timestamp was added
+// after the signature, i.e. its ByteRange covers the signed revision.
+func PAdESLevel(resp *Response, i int) string {
+	sig := resp.Signatures[i]
+	switch sig.Info.SubFilter {
+	case "ETSI.CAdES.detached", "adbe.pkcs7.detached":
+	default:
+		return PAdESNone
+	}
+	if !sig.Validation.ValidSignature {
+		return PAdESNone
+	}
+	if sig.Info.TimeStamp == nil || sig.Validation.TimestampStatus != "valid" {
+		return PAdESBB
+	}
+	for _, c := range sig.Validation.Certificates {
+		if c.Certificate == nil || bytes.Equal(c.Certificate.RawIssuer, c.Certificate.RawSubject) {
+			continue
+		}
+		if !inDocumentRevocation(c.OCSPSource) && !inDocumentRevocation(c.CRLSource) {
+			return PAdESBT
+		}
+	}
+	for _, other := range resp.Signatures {
+		if isDocumentTimestamp(other.Info) && other.Validation.ValidSignature && other.Info.SignedLength > sig.Info.SignedLength {
+			return PAdESBLTA
+		}
+	}
+	return PAdESBLT
+}
+
+// inDocumentRevocation reports whether revocation data with the given source
+// was found in the document itself, in the signature or the DSS. Data
+// fetched during verification or supplied by the caller does not make the
+// document long-term verifiable.
+func inDocumentRevocation(source string) bool {
+	return source == "embedded" || source == "dss"
+}
+
+func isDocumentTimestamp(info common.SignatureInfo) bool {
+	return info.SubFilter == "ETSI.RFC3161"
+}
+
+func hasErrorFinding(validation SignatureValidation, code string) bool {
+	for _, f := range validation.Findings {
+		if f.Code == code && f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func signerNames(validation SignatureValidation) (subject, commonName string) {
+	for _, c := range validation.Certificates {
+		if c.SigningCertificate && c.Certificate != nil {
+			return c.Certificate.Subject.String(), c.Certificate.Subject.CommonName
+		}
+	}
+	return "", ""
+}
+
+func matchesAny(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if ok, _ := matchSigner(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSigner matches a signer name against a glob pattern with path.Match
+// syntax, where "/" is an ordinary character.
+func matchSigner(pattern, name string) (bool, error) {
+	const slash = "\uffff" // a noncharacter, never part of a DN
+	return path.Match(strings.ReplaceAll(pattern, "/", slash), strings.ReplaceAll(name, "/", slash))
+}
@@ -0,0 +1,171 @@
+package verify
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	jsonPolicy := `{"require_trusted_chain": true, "require_timestamp": true, "require_full_coverage": true,
+		"min_pades_level": "PAdES-b-t", "min_signatures": 2, "allowed_signers": ["CN=Jane Doe,*", "ACME * Signing"]}`
+	yamlPolicy := `---
+# CI gate
+require_trusted_chain: true
+require_timestamp: True
+require_not_revoked: false
+require_full_coverage: true   # nothing appended
+min_pades_level: PAdES-B-T
+min_signatures: 2
+allowed_signers:
+  - "CN=Jane Doe,*"
+  - 'ACME * Signing'
+`
+	flowPolicy := "require_trusted_chain: true\nrequire_timestamp: true\nrequire_full_coverage: true\n" +
+		"min_pades_level: B-T\nmin_signatures: 2\nallowed_signers: [\"CN=Jane Doe,*\", ACME * Signing]\n"
+
+	for name, data := range map[string]string{"json": jsonPolicy, "yaml": yamlPolicy, "flow": flowPolicy} {
+		t.Run(name, func(t *testing.T) {
+			p, err := ParsePolicy([]byte(data))
+			if err != nil {
+				t.Fatalf("ParsePolicy() error = %v", err)
+			}
+			if !p.RequireTrustedChain || !p.RequireTimestamp || !p.RequireFullCoverage || p.RequireNotRevoked {
+				t.Errorf("unexpected flags: %+v", p)
+			}
+			if p.MinPAdESLevel != PAdESBT || p.MinSignatures != 2 {
+				t.Errorf("MinPAdESLevel = %q, MinSignatures = %d", p.MinPAdESLevel, p.MinSignatures)
+			}
+			if len(p.AllowedSigners) != 2 || p.AllowedSigners[0] != "CN=Jane Doe,*" || p.AllowedSigners[1] != "ACME * Signing" {
+				t.Errorf("AllowedSigners = %q", p.AllowedSigners)
+			}
+		})
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown key":        `{"require_everything": true}`,
+		"unknown level":      `{"min_pades_level": "B-Z"}`,
+		"negative count":     `{"min_signatures": -1}`,
+		"bad pattern":        `{"allowed_signers": ["["]}`,
+		"wrong value type":   `{"min_signatures": "many"}`,
+		"trailing data":      `{"min_signatures": 1} {}`,
+		"yaml unknown key":   "require_everything: true\n",
+		"yaml 1.1 boolean":   "require_timestamp: yes\n",
+		"yaml nested":        "timestamp:\n  required: true\n",
+		"yaml anchor":        "min_pades_level: &level B-T\n",
+		"yaml alias":         "min_pades_level: *level\n",
+		"yaml flow mapping":  "allowed_signers: [{cn: Jane}]\n",
+		"yaml block scalar":  "min_pades_level: |\n  B-T\n",
+		"yaml two documents": "min_signatures: 1\n---\nmin_signatures: 2\n",
+		"yaml duplicate key": "min_signatures: 1\nmin_signatures: 2\n",
+		"yaml open quote":    "allowed_signers: [\"CN=Jane]\n",
+		"yaml tab indent":    "allowed_signers:\n\t- Jane\n",
+	} {
+		if _, err := ParsePolicy([]byte(data)); err == nil {
+			t.Errorf("%s: ParsePolicy() error = nil", name)
+		}
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	data := readTestFile(t, "testfile30.pdf")
+	resp := verifyBytesForTest(t, data)
+
+	if v := (&Policy{}).Evaluate(resp); !v.Passed() {
+		t.Errorf("zero policy: verdict = %+v, want passed", v)
+	}
+
+	v := DefaultPolicy().Evaluate(resp)
+	if v.Status != VerdictPolicyFailed || !hasViolation(v, RuleTrustedChain) {
+		t.Errorf("default policy: verdict = %+v, want %s with %s", v, VerdictPolicyFailed, RuleTrustedChain)
+	}
+
+	v = (&Policy{MinSignatures: 2, AllowedSigners: []string{"Jane *"}, MinPAdESLevel: PAdESBLTA}).Evaluate(resp)
+	for _, rule := range []string{RuleMinSignatures, RuleAllowedSigners, RuleMinPAdESLevel} {
+		if !hasViolation(v, rule) {
+			t.Errorf("missing %s violation: %+v", rule, v.Violations)
+		}
+	}
+
+	v = (&Policy{AllowedSigners: []string{"John B Harris"}, RequireTimestamp: true, RequireFullCoverage: true}).Evaluate(resp)
+	if !v.Passed() {
+		t.Errorf("verdict = %+v, want passed", v)
+	}
+
+	if v := (&Policy{MinSignatures: 1}).Evaluate(nil); v.Status != VerdictPolicyFailed || !hasViolation(v, RuleMinSignatures) {
+		t.Errorf("no signatures: verdict = %+v", v)
+	}
+}
+
+func TestMatchSigner(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"CN=Jane Doe,*", "CN=Jane Doe,O=ACME/Research,C=US", true},
+		{"*,O=ACME/Research,*", "CN=Jane Doe,O=ACME/Research,C=US", true},
+		{"CN=Jane Doe,O=ACME?Research,*", "CN=Jane Doe,O=ACME/Research,C=US", true},
+		{"CN=John *", "CN=Jane Doe,O=ACME/Research,C=US", false},
+	} {
+		if got, err := matchSigner(tt.pattern, tt.name); got != tt.want || err != nil {
+			t.Errorf("matchSigner(%q, %q) = %v, %v; want %v", tt.pattern, tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestPolicyEvaluateInvalidSignature(t *testing.T) {
+	data := readTestFile(t, "testfile30.pdf")
+	idx := bytes.IndexByte(data, '%') + 1
+	idx += bytes.IndexByte(data[idx:], '%') + 1
+	tampered := append([]byte(nil), data...)
+	tampered[idx] ^= 0x01
+
+	v := (&Policy{}).Evaluate(verifyBytesForTest(t, tampered))
+	if v.Status != VerdictInvalid || !hasViolation(v, RuleIntegrity) {
+		t.Errorf("verdict = %+v, want %s", v, VerdictInvalid)
+	}
+}
+
+func TestPolicyFullCoverage(t *testing.T) {
+	data := readTestFile(t, "testfile30.pdf")
+	if resp := verifyBytesForTest(t, data); !resp.Signatures[0].Info.CoversWholeDocument {
+		t.Fatal("CoversWholeDocument = false for an unmodified document")
+	}
+
+	appended := append(append([]byte(nil), data...), []byte("% unsigned trailing data\n")...)
+	resp := verifyBytesForTest(t, appended)
+	if resp.Signatures[0].Info.CoversWholeDocument {
+		t.Error("CoversWholeDocument = true after appending data")
+	}
+	if v := (&Policy{RequireFullCoverage: true}).Evaluate(resp); !hasViolation(v, RuleFullCoverage) {
+		t.Errorf("verdict = %+v, want %s violation", v, RuleFullCoverage)
+	}
+}
+
+func TestPAdESLevel(t *testing.T) {
+	resp := verifyBytesForTest(t, readTestFile(t, "testfile30.pdf"))
+	level := PAdESLevel(resp, 0)
+	if level != PAdESBT && level != PAdESBLT {
+		t.Errorf("PAdESLevel() = %q, want a timestamped level", level)
+	}
+
+	resp.Signatures[0].Info.TimeStamp = nil
+	if level := PAdESLevel(resp, 0); level != PAdESBB {
+		t.Errorf("PAdESLevel() without timestamp = %q, want %s", level, PAdESBB)
+	}
+
+	resp.Signatures[0].Info.SubFilter = "adbe.x509.rsa_sha1"
+	if level := PAdESLevel(resp, 0); level != PAdESNone {
+		t.Errorf("PAdESLevel() for %s = %q, want none", resp.Signatures[0].Info.SubFilter, level)
+	}
+}
+
+func hasViolation(v *Verdict, rule string) bool {
+	for _, violation := range v.Violations {
+		if violation.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
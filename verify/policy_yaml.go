@@ -0,0 +1,203 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlPolicyKey matches the keys of a YAML policy.
+var yamlPolicyKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// yamlInteger matches the integers of the YAML 1.2 core schema.
+var yamlInteger = regexp.MustCompile(`^[-+]?[0-9]+$`)
+
+// parseYAMLPolicy parses the YAML form of a policy: a mapping of top-level
+// keys to scalars, block sequences of scalars or flow sequences of scalars.
+// Scalars are typed with the YAML 1.2 core schema. Everything else YAML
+// offers (nested mappings, anchors, aliases, tags, block scalars, several
+// documents) is rejected rather than guessed at.
+func parseYAMLPolicy(data string) (map[string]any, error) {
+	values := map[string]any{}
+	var listKey string // key whose block sequence is being read
+	for n, raw := range strings.Split(data, "\n") {
+		line, err := stripYAMLComment(strings.TrimRight(raw, " \t\r"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "\t"):
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n+1)
+		case line == "---" && len(values) == 0 && listKey == "":
+			continue
+		case line == "---" || line == "...":
+			return nil, fmt.Errorf("line %d: only one YAML document is allowed", n+1)
+		}
+
+		if trimmed != line {
+			// Indented lines continue the block sequence of listKey.
+			item, ok := strings.CutPrefix(trimmed, "-")
+			if listKey == "" || !ok || item != "" && item[0] != ' ' {
+				return nil, fmt.Errorf("line %d: only top-level keys and sequences of scalars are supported", n+1)
+			}
+			v, err := yamlScalar(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			items, _ := values[listKey].([]any)
+			values[listKey] = append(items, v)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !yamlPolicyKey.MatchString(key) || value != "" && value[0] != ' ' {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n+1)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n+1, key)
+		}
+		listKey = ""
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			// A block sequence may follow; without one the value is null.
+			values[key] = nil
+			listKey = key
+		case value[0] == '[':
+			items, err := yamlFlowSequence(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			values[key] = items
+		default:
+			v, err := yamlScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+// stripYAMLComment removes a comment, a # at the start of the line or
+// after a space, outside quoted scalars.
+func stripYAMLComment(line string) (string, error) {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			if quote == '\'' && i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#' && (i == 0 || line[i-1] == ' '):
+			return strings.TrimRight(line[:i], " "), nil
+		}
+	}
+	if quote != 0 {
+		return "", errors.New("unterminated quoted scalar")
+	}
+	return line, nil
+}
+
+// yamlFlowSequence parses a flow sequence of scalars such as [a, "b, c"].
+func yamlFlowSequence(s string) ([]any, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated flow sequence %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	items := []any{}
+	if inner == "" {
+		return items, nil
+	}
+	var quote byte
+	start := 0
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) {
+			c := inner[i]
+			switch {
+			case quote == '"' && c == '\\':
+				i++
+				continue
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c == '[' || c == '{':
+				return nil, fmt.Errorf("nested collections are not supported in %s", s)
+			case c != ',':
+				continue
+			}
+		}
+		item := strings.TrimSpace(inner[start:i])
+		if item == "" {
+			return nil, fmt.Errorf("empty item in flow sequence %s", s)
+		}
+		v, err := yamlScalar(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		start = i + 1
+	}
+	return items, nil
+}
+
+// yamlScalar parses a quoted or plain scalar.
+func yamlScalar(s string) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	switch s[0] {
+	case '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted scalar %s", s)
+		}
+		return v, nil
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' || strings.Contains(strings.ReplaceAll(s[1:len(s)-1], "''", ""), "'") {
+			return nil, fmt.Errorf("invalid single-quoted scalar %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case '[', ']', '{', '}', '&', '*', '!', '|', '>', '%', '@', '`', ',', '?':
+		return nil, fmt.Errorf("unsupported YAML syntax %s", s)
+	case '-':
+		if s == "-" || strings.HasPrefix(s, "- ") {
+			return nil, fmt.Errorf("nested sequences are not supported")
+		}
+	}
+	if strings.Contains(s, ": ") || strings.HasSuffix(s, ":") {
+		return nil, fmt.Errorf("nested mappings are not supported")
+	}
+	switch s {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if yamlInteger.MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,320 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/subnoto/pdfsign/common"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationSource supplies revocation material (CRLs and OCSP responses)
+// obtained outside of the PDF, for example through a separate offline channel
+// to an air-gapped validator. Sources are consulted alongside the data embedded
+// in the signature and never perform network requests themselves.
+type RevocationSource interface {
+	// LookupOCSP returns an OCSP response covering cert, or a nil response when
+	// the source has none. origin identifies where the response came from and
+	// is reported in the verification result.
+	LookupOCSP(cert, issuer *x509.Certificate) (resp *ocsp.Response, origin string, err error)
+
+	// LookupCRL returns the most recent CRL issued by cert's issuer, or a nil
+	// CRL when the source has none. origin identifies where the CRL came from.
+	LookupCRL(cert, issuer *x509.Certificate) (crl *x509.RevocationList, origin string, err error)
+}
+
+type storedOCSP struct {
+	der    []byte
+	serial string
+	origin string
+}
+
+type storedCRL struct {
+	crl    *x509.RevocationList
+	origin string
+}
+
+// StaticRevocationSource is a RevocationSource backed by an in-memory set of
+// DER-encoded CRLs and OCSP responses.
+type StaticRevocationSource struct {
+	ocsps []storedOCSP
+	crls  []storedCRL
+}
+
+// NewStaticRevocationSource returns an empty StaticRevocationSource.
+func NewStaticRevocationSource() *StaticRevocationSource {
+	return &StaticRevocationSource{}
+}
+
+// AddOCSP adds a DER-encoded OCSP response. origin is reported when the
+// response answers a lookup.
+func (s *StaticRevocationSource) AddOCSP(der []byte, origin string) error {
+	// The signature is checked against the issuer at lookup time; parse without
+	// an issuer here only to index the response by serial number.
+	resp, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		return fmt.Errorf("failed to parse OCSP response: %v", err)
+	}
+	s.ocsps = append(s.ocsps, storedOCSP{
+		der:    der,
+		serial: fmt.Sprintf("%x", resp.SerialNumber),
+		origin: origin,
+	})
+	return nil
+}
+
+// AddCRL adds a DER- or PEM-encoded CRL. origin is reported when the CRL
+// answers a lookup.
+func (s *StaticRevocationSource) AddCRL(data []byte, origin string) error {
+	if block, _ := pem.Decode(data); block != nil && block.Type == "X509 CRL" {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse CRL: %v", err)
+	}
+	s.crls = append(s.crls, storedCRL{crl: crl, origin: origin})
+	return nil
+}
+
+// add detects whether data is a CRL or an OCSP response and stores it.
+func (s *StaticRevocationSource) add(data []byte, origin string) error {
+	crlErr := s.AddCRL(data, origin)
+	if crlErr == nil {
+		return nil
+	}
+	if ocspErr := s.AddOCSP(data, origin); ocspErr != nil {
+		return fmt.Errorf("%s is neither a CRL (%v) nor an OCSP response (%v)", origin, crlErr, ocspErr)
+	}
+	return nil
+}
+
+// LookupOCSP implements RevocationSource. The response signature must verify
+// against issuer, directly or through a delegated responder certificate issued
+// by it; without a known issuer no response is returned, since its origin
+// cannot be established.
+func (s *StaticRevocationSource) LookupOCSP(cert, issuer *x509.Certificate) (*ocsp.Response, string, error) {
+	serial := fmt.Sprintf("%x", cert.SerialNumber)
+
+	var best *ocsp.Response
+	var bestOrigin string
+	var lastErr error
+	for _, stored := range s.ocsps {
+		if stored.serial != serial {
+			continue
+		}
+		if issuer == nil {
+			lastErr = fmt.Errorf("OCSP response from %s: the issuer certificate is unknown, the response cannot be verified", stored.origin)
+			continue
+		}
+
+		resp, err := ocsp.ParseResponseForCert(stored.der, cert, issuer)
+		if err != nil {
+			lastErr = fmt.Errorf("OCSP response from %s: %v", stored.origin, err)
+			continue
+		}
+
+		if best == nil || resp.ThisUpdate.After(best.ThisUpdate) {
+			best = resp
+			bestOrigin = stored.origin
+		}
+	}
+
+	if best == nil {
+		return nil, "", lastErr
+	}
+	return best, bestOrigin, nil
+}
+
+// LookupCRL implements RevocationSource. CRLs are matched on the issuer name
+// of cert and must carry a valid signature from issuer; without a known
+// issuer no CRL is returned.
+func (s *StaticRevocationSource) LookupCRL(cert, issuer *x509.Certificate) (*x509.RevocationList, string, error) {
+	var best *x509.RevocationList
+	var bestOrigin string
+	var lastErr error
+	for _, stored := range s.crls {
+		if !bytes.Equal(stored.crl.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		if issuer == nil {
+			lastErr = fmt.Errorf("CRL from %s: the issuer certificate is unknown, the CRL cannot be verified", stored.origin)
+			continue
+		}
+		if err := stored.crl.CheckSignatureFrom(issuer); err != nil {
+			lastErr = fmt.Errorf("CRL from %s: signature invalid: %v", stored.origin, err)
+			continue
+		}
+		if best == nil || stored.crl.ThisUpdate.After(best.ThisUpdate) {
+			best = stored.crl
+			bestOrigin = stored.origin
+		}
+	}
+
+	if best == nil {
+		return nil, "", lastErr
+	}
+	return best, bestOrigin, nil
+}
+
+// LoadRevocationFiles returns a StaticRevocationSource holding the given CRL
+// and OCSP response files. The type of each file is detected from its content.
+func LoadRevocationFiles(paths ...string) (*StaticRevocationSource, error) {
+	source := NewStaticRevocationSource()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := source.add(data, "file:"+path); err != nil {
+			return nil, err
+		}
+	}
+	return source, nil
+}
+
+// LoadRevocationDirectory returns a StaticRevocationSource holding every CRL
+// and OCSP response found directly in dir. Files that are neither are skipped
+// so the directory may also contain notes or checksums.
+func LoadRevocationDirectory(dir string) (*StaticRevocationSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	source := NewStaticRevocationSource()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		_ = source.add(data, "file:"+path)
+	}
+	return source, nil
+}
+
+// checkSuppliedRevocation consults options.RevocationSource for a certificate
+// that has no embedded status of the corresponding kind and records the answer.
+// It returns a warning when the source only held material that was rejected.
+func checkSuppliedRevocation(c *common.Certificate, cert, issuer *x509.Certificate, validation *SignatureValidation, options *VerifyOptions) string {
+	if options.RevocationSource == nil {
+		return ""
+	}
+
+	var warning string
+	if !c.OCSPEmbedded {
+		resp, origin, err := options.RevocationSource.LookupOCSP(cert, issuer)
+		if resp == nil && err != nil {
+			warning = appendWarning(warning, fmt.Sprintf("Supplied OCSP response rejected: %v.", err))
+		}
+		switch {
+		case resp == nil:
+		case resp.Status == ocsp.Unknown:
+			// The responder does not know the certificate: the status
+			// remains indeterminate.
+			warning = appendWarning(warning, fmt.Sprintf("Supplied OCSP response from %s reports the status as unknown.", origin))
+		case expiredBefore(resp.NextUpdate, validation.VerificationTime):
+			warning = appendWarning(warning, fmt.Sprintf("Supplied OCSP response from %s expired before the signing time (next update: %v).", origin, resp.NextUpdate))
+		default:
+			c.OCSPResponse = resp
+			c.OCSPSource = origin
+			warnIfIssuedBeforeSigning(validation, cert, resp.ThisUpdate, origin)
+			if resp.Status == ocsp.Revoked {
+				markRevoked(c, validation, resp.RevokedAt, origin)
+			}
+		}
+	}
+
+	if !c.CRLEmbedded {
+		crl, origin, err := options.RevocationSource.LookupCRL(cert, issuer)
+		if crl == nil && err != nil {
+			warning = appendWarning(warning, fmt.Sprintf("Supplied CRL rejected: %v.", err))
+		}
+		if crl != nil && expiredBefore(crl.NextUpdate, validation.VerificationTime) {
+			warning = appendWarning(warning, fmt.Sprintf("Supplied CRL from %s expired before the signing time (next update: %v).", origin, crl.NextUpdate))
+			crl = nil
+		}
+		if crl != nil {
+			c.CRLSource = origin
+			warnIfIssuedBeforeSigning(validation, cert, crl.ThisUpdate, origin)
+			for _, revoked := range crl.RevokedCertificateEntries {
+				if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					markRevoked(c, validation, revoked.RevocationTime, origin)
+					break
+				}
+			}
+		}
+	}
+
+	return warning
+}
+
+// expiredBefore reports whether revocation data valid until nextUpdate had
+// expired at time t. Data without a next update does not expire.
+func expiredBefore(nextUpdate time.Time, t *time.Time) bool {
+	if nextUpdate.IsZero() {
+		return false
+	}
+	if t == nil {
+		return nextUpdate.Before(time.Now())
+	}
+	return nextUpdate.Before(*t)
+}
+
+// markRevoked records a revocation reported by a supplied source, applying the
+// same signing-time rules as embedded and external revocation data.
+func markRevoked(c *common.Certificate, validation *SignatureValidation, revokedAt time.Time, origin string) {
+	c.RevocationTime = &revokedAt
+	c.RevokedBeforeSigning = isRevokedBeforeSigning(revokedAt, validation.VerificationTime, validation.TimeSource)
+
+	if c.RevokedBeforeSigning {
+		validation.RevokedCertificate = true
+		return
+	}
+
+	if validation.TimeSource == "embedded_timestamp" {
+		validation.TimeWarnings = append(validation.TimeWarnings,
+			fmt.Sprintf("Certificate was revoked after signing time (%s - revoked: %v, signed: %v)",
+				origin, revokedAt, validation.VerificationTime))
+	} else {
+		validation.RevokedCertificate = true
+		validation.TimeWarnings = append(validation.TimeWarnings,
+			fmt.Sprintf("Certificate revoked (%s), but cannot determine if revocation occurred before or after signing without trusted timestamp", origin))
+	}
+}
+
+// warnIfIssuedBeforeSigning notes when supplied revocation data predates the
+// trusted signing time, in which case it cannot prove the status at signing.
+func warnIfIssuedBeforeSigning(validation *SignatureValidation, cert *x509.Certificate, thisUpdate time.Time, origin string) {
+	if validation.TimeSource != "embedded_timestamp" || validation.VerificationTime == nil {
+		return
+	}
+	if thisUpdate.Before(*validation.VerificationTime) {
+		warning := fmt.Sprintf("Revocation data from %s was issued before the signing time (issued: %v, signed: %v)",
+			origin, thisUpdate, validation.VerificationTime)
+		validation.TimeWarnings = append(validation.TimeWarnings, warning)
+		validation.addCertificateFinding(CodeRevocationUnavailable, SeverityWarning, cert.Subject.String(), "%s", warning)
+	}
+}
+
+func appendWarning(existing, warning string) string {
+	if existing == "" {
+		return warning
+	}
+	return existing + " " + warning
+}
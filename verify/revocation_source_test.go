@@ -0,0 +1,310 @@
+package verify
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+	"github.com/subnoto/pdfsign/common"
+	"github.com/subnoto/pdfsign/revocation"
+	"golang.org/x/crypto/ocsp"
+)
+
+type revocationTestPKI struct {
+	ca      *x509.Certificate
+	caKey   *rsa.PrivateKey
+	leaf    *x509.Certificate
+	leafKey *rsa.PrivateKey
+}
+
+func newRevocationTestPKI(t *testing.T) *revocationTestPKI {
+	t.Helper()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Offline Test CA"},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              time.Now().Add(48 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "Offline Signer"},
+		NotBefore:    time.Now().Add(-24 * time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		OCSPServer:   []string{"http://ocsp.invalid"},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &revocationTestPKI{ca: ca, caKey: caKey, leaf: leaf, leafKey: leafKey}
+}
+
+func (p *revocationTestPKI) ocspResponse(t *testing.T, status int, revokedAt time.Time) []byte {
+	t.Helper()
+	der, err := ocsp.CreateResponse(p.ca, p.ca, ocsp.Response{
+		Status:       status,
+		SerialNumber: p.leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Hour),
+		NextUpdate:   time.Now().Add(time.Hour),
+		RevokedAt:    revokedAt,
+	}, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func (p *revocationTestPKI) crl(t *testing.T, revoked ...x509.RevocationListEntry) []byte {
+	t.Helper()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-time.Hour),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: revoked,
+	}, p.ca, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func (p *revocationTestPKI) signedData(t *testing.T) *pkcs7.PKCS7 {
+	t.Helper()
+	sd, err := pkcs7.NewSignedData([]byte("offline revocation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.AddSignerChain(p.leaf, p.leafKey, []*x509.Certificate{p.ca}, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	der, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p7
+}
+
+func TestLoadRevocationDirectory(t *testing.T) {
+	pki := newRevocationTestPKI(t)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "leaf.ocsp"), pki.ocspResponse(t, ocsp.Good, time.Time{}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ca.crl"), pki.crl(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("delivered 2024-01-15"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := LoadRevocationDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadRevocationDirectory() error = %v", err)
+	}
+
+	resp, origin, err := source.LookupOCSP(pki.leaf, pki.ca)
+	if err != nil || resp == nil {
+		t.Fatalf("LookupOCSP() = %v, %v", resp, err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Errorf("OCSP status = %d, want Good", resp.Status)
+	}
+	if origin != "file:"+filepath.Join(dir, "leaf.ocsp") {
+		t.Errorf("OCSP origin = %q", origin)
+	}
+
+	crl, origin, err := source.LookupCRL(pki.leaf, pki.ca)
+	if err != nil || crl == nil {
+		t.Fatalf("LookupCRL() = %v, %v", crl, err)
+	}
+	if origin != "file:"+filepath.Join(dir, "ca.crl") {
+		t.Errorf("CRL origin = %q", origin)
+	}
+
+	// Without the issuer the data cannot be verified and is not used.
+	if resp, _, err := source.LookupOCSP(pki.leaf, nil); resp != nil || err == nil {
+		t.Errorf("LookupOCSP() without issuer = %v, %v; want an error", resp, err)
+	}
+	if crl, _, err := source.LookupCRL(pki.leaf, nil); crl != nil || err == nil {
+		t.Errorf("LookupCRL() without issuer = %v, %v; want an error", crl, err)
+	}
+
+	// A certificate from another issuer is not answered.
+	other := newRevocationTestPKI(t)
+	if resp, _, _ := source.LookupOCSP(other.leaf, other.ca); resp != nil {
+		t.Error("LookupOCSP() answered for an unrelated issuer")
+	}
+	if crl, _, _ := source.LookupCRL(other.leaf, other.ca); crl != nil {
+		t.Error("LookupCRL() answered for an unrelated issuer")
+	}
+}
+
+func TestLoadRevocationFilesRejectsUnknownContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.der")
+	if err := os.WriteFile(path, []byte("not revocation data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRevocationFiles(path); err == nil {
+		t.Error("LoadRevocationFiles() expected error for unknown content")
+	}
+}
+
+func TestBuildCertificateChainsWithRevocationSource(t *testing.T) {
+	pki := newRevocationTestPKI(t)
+	signingTime := time.Now().Add(-2 * time.Hour)
+
+	tests := []struct {
+		name        string
+		setup       func(s *StaticRevocationSource) error
+		wantSource  string
+		wantRevoked bool
+		wantFinding string
+	}{
+		{
+			name: "good OCSP response",
+			setup: func(s *StaticRevocationSource) error {
+				return s.AddOCSP(pki.ocspResponse(t, ocsp.Good, time.Time{}), "archive")
+			},
+			wantSource: "archive",
+		},
+		{
+			name: "revoked after signing",
+			setup: func(s *StaticRevocationSource) error {
+				return s.AddOCSP(pki.ocspResponse(t, ocsp.Revoked, time.Now().Add(-time.Minute)), "archive")
+			},
+			wantSource: "archive",
+		},
+		{
+			name: "revoked before signing via CRL",
+			setup: func(s *StaticRevocationSource) error {
+				return s.AddCRL(pki.crl(t, x509.RevocationListEntry{
+					SerialNumber:   pki.leaf.SerialNumber,
+					RevocationTime: signingTime.Add(-time.Hour),
+				}), "crl-drop")
+			},
+			wantRevoked: true,
+		},
+		{
+			name: "unknown OCSP status",
+			setup: func(s *StaticRevocationSource) error {
+				return s.AddOCSP(pki.ocspResponse(t, ocsp.Unknown, time.Time{}), "archive")
+			},
+		},
+		{
+			name: "CRL expired before signing",
+			setup: func(s *StaticRevocationSource) error {
+				der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+					Number:     big.NewInt(2),
+					ThisUpdate: signingTime.Add(-2 * time.Hour),
+					NextUpdate: signingTime.Add(-time.Hour),
+					RevokedCertificateEntries: []x509.RevocationListEntry{{
+						SerialNumber:   pki.leaf.SerialNumber,
+						RevocationTime: signingTime.Add(-90 * time.Minute),
+					}},
+				}, pki.ca, pki.caKey)
+				if err != nil {
+					return err
+				}
+				return s.AddCRL(der, "crl-drop")
+			},
+		},
+		{
+			name: "CRL issued before signing",
+			setup: func(s *StaticRevocationSource) error {
+				der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+					Number:     big.NewInt(3),
+					ThisUpdate: signingTime.Add(-time.Hour),
+					NextUpdate: time.Now().Add(time.Hour),
+				}, pki.ca, pki.caKey)
+				if err != nil {
+					return err
+				}
+				return s.AddCRL(der, "crl-drop")
+			},
+			wantFinding: CodeRevocationUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewStaticRevocationSource()
+			if err := tt.setup(source); err != nil {
+				t.Fatal(err)
+			}
+
+			options := DefaultVerifyOptions()
+			options.AllowUntrustedRoots = true
+			options.RevocationSource = source
+
+			info := &common.SignatureInfo{TimeStamp: &timestamp.Timestamp{Time: signingTime}}
+			options.ValidateTimestampCertificates = false
+			var validation SignatureValidation
+			if _, err := buildCertificateChainsWithOptions(pki.signedData(t), info, &validation, revocation.InfoArchival{}, options); err != nil {
+				t.Fatalf("buildCertificateChainsWithOptions() error = %v", err)
+			}
+
+			var leaf *common.Certificate
+			for i := range validation.Certificates {
+				if validation.Certificates[i].Certificate.Equal(pki.leaf) {
+					leaf = &validation.Certificates[i]
+				}
+			}
+			if leaf == nil {
+				t.Fatal("leaf certificate missing from validation result")
+			}
+			if leaf.OCSPSource != tt.wantSource {
+				t.Errorf("OCSPSource = %q, want %q", leaf.OCSPSource, tt.wantSource)
+			}
+			if leaf.OCSPExternalChecked {
+				t.Error("external OCSP check attempted although disabled")
+			}
+			if validation.RevokedCertificate != tt.wantRevoked {
+				t.Errorf("RevokedCertificate = %v, want %v", validation.RevokedCertificate, tt.wantRevoked)
+			}
+			if tt.wantFinding != "" && !hasFindingCode(validation, tt.wantFinding, SeverityWarning) {
+				t.Errorf("findings %+v, want a %s warning", validation.Findings, tt.wantFinding)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/x509"
 	"encoding/asn1"
+	"errors"
 	"fmt"
 	"io"
 
@@ -40,15 +41,18 @@ func processSignature(v pdf.Value, file io.ReaderAt, options *VerifyOptions) (co
 	info.HashAlgorithm = "sha256" // default
 
 	// Parse PKCS#7 signature
+	var validation SignatureValidation
 	p7, err := pkcs7.Parse([]byte(v.Key("Contents").RawString()))
 	if err != nil {
-		return info, SignatureValidation{}, "", fmt.Errorf("failed to parse PKCS#7: %v", err)
+		validation.addFinding(CodeMalformedSignature, SeverityError, SubjectSignature, "failed to parse PKCS#7: %v", err)
+		return info, validation, "", fmt.Errorf("failed to parse PKCS#7: %v", err)
 	}
 
 	// Process byte range for signature verification
 	err = processByteRange(v, file, p7)
 	if err != nil {
-		return info, SignatureValidation{}, fmt.Sprintf("Failed to process ByteRange: %v", err), nil
+		validation.addFinding(CodeByteRangeInvalid, SeverityError, SubjectSignature, "%v", err)
+		return info, validation, fmt.Sprintf("Failed to process ByteRange: %v", err), nil
 	}
 
 	// Calculate document hash (SHA256 by default)
@@ -66,13 +70,19 @@ func processSignature(v pdf.Value, file io.ReaderAt, options *VerifyOptions) (co
 	// Process timestamp if present
 	err = processTimestamp(p7, &info)
 	if err != nil {
-		return info, SignatureValidation{}, fmt.Sprintf("Failed to process timestamp: %v", err), nil
+		validation.addFinding(CodeTimestampInvalid, SeverityError, SubjectTimestamp, "%v", err)
+		return info, validation, fmt.Sprintf("Failed to process timestamp: %v", err), nil
 	}
 
 	// Verify the digital signature
-	var validation SignatureValidation
 	err = verifySignature(p7, &validation)
 	if err != nil {
+		var mismatch *pkcs7.MessageDigestMismatchError
+		if errors.As(err, &mismatch) {
+			validation.addFinding(CodeByteRangeMismatch, SeverityError, SubjectSignature, "%v", err)
+		} else {
+			validation.addFinding(CodeSignatureInvalid, SeverityError, SubjectSignature, "%v", err)
+		}
 		return info, validation, fmt.Sprintf("Failed to verify signature: %v", err), nil
 	}
 
@@ -82,6 +92,7 @@ func processSignature(v pdf.Value, file io.ReaderAt, options *VerifyOptions) (co
 
 	certError, err := buildCertificateChainsWithOptions(p7, &info, &validation, revInfo, options)
 	if err != nil {
+		validation.addFinding(CodeChainUntrusted, SeverityError, SubjectCertificate, "%v", err)
 		return info, validation, fmt.Sprintf("Failed to build certificate chains: %v", err), nil
 	}
 
@@ -119,11 +130,13 @@ func processDocTimeStamp(v pdf.Value, file io.ReaderAt) (common.SignatureInfo, S
 
 	content, err := readSignatureByteRange(v, file)
 	if err != nil {
+		validation.addFinding(CodeByteRangeInvalid, SeverityError, SubjectSignature, "%v", err)
 		return info, validation, fmt.Sprintf("Failed to process ByteRange: %v", err), nil
 	}
 
 	ts, err := timestamp.Parse([]byte(v.Key("Contents").RawString()))
 	if err != nil {
+		validation.addFinding(CodeMalformedSignature, SeverityError, SubjectTimestamp, "failed to parse timestamp token: %v", err)
 		return info, validation, fmt.Sprintf("Failed to parse timestamp token: %v", err), nil
 	}
 	info.TimeStamp = ts
@@ -132,6 +145,7 @@ func processDocTimeStamp(v pdf.Value, file io.ReaderAt) (common.SignatureInfo, S
 	h := ts.HashAlgorithm.New()
 	h.Write(content)
 	if !bytes.Equal(h.Sum(nil), ts.HashedMessage) {
+		validation.addFinding(CodeByteRangeMismatch, SeverityError, SubjectTimestamp, "timestamp message imprint does not match document hash")
 		return info, validation, "timestamp message imprint does not match document hash", nil
 	}
 
@@ -139,6 +153,8 @@ func processDocTimeStamp(v pdf.Value, file io.ReaderAt) (common.SignatureInfo, S
 	validation.TrustedIssuer = false
 	validation.TimeSource = "embedded_timestamp"
 	validation.TimestampStatus = "valid"
+	// Document timestamps are not chain-validated yet; say so explicitly.
+	validation.addFinding(CodeTimestampNotTrusted, SeverityWarning, SubjectTimestamp, "document timestamp certificate chain is not validated")
 	return info, validation, "", nil
 }
 
@@ -203,7 +219,7 @@ func verifySignature(p7 *pkcs7.PKCS7, validation *SignatureValidation) error {
 			validation.ValidSignature = true
 			validation.TrustedIssuer = false
 		} else {
-			return fmt.Errorf("signature verification failed: %v", err)
+			return fmt.Errorf("signature verification failed: %w", err)
 		}
 	} else {
 		validation.ValidSignature = true
@@ -4,6 +4,7 @@ package verify
 // Types are defined in verify.go to maintain backward compatibility.
 
 import (
+	"crypto"
 	"crypto/x509"
 	"net/http"
 	"net/url"
@@ -75,6 +76,36 @@ type VerifyOptions struct {
 	// If nil, proxy settings from HTTP_PROXY/HTTPS_PROXY environment variables will be used
 	// This is useful when you need to override environment proxy settings or set a proxy programmatically
 	ProxyURL *url.URL
+
+	// RevocationSource supplies CRLs and OCSP responses obtained outside of the
+	// PDF (see LoadRevocationDirectory). It is consulted for certificates whose
+	// status is not embedded, before and independently of external checking,
+	// so offline validators can establish revocation status without network access.
+	RevocationSource RevocationSource
+
+	// EnableAIAFetching when true, downloads missing intermediate certificates
+	// from the caIssuers URLs of the Authority Information Access extension.
+	// Downloaded certificates are used as intermediates only, never as roots.
+	EnableAIAFetching bool
+
+	// AIAMaxDepth limits how many issuers are followed above each embedded
+	// certificate when EnableAIAFetching is set. If zero, aia.DefaultMaxDepth is used.
+	AIAMaxDepth int
+
+	// DecryptionCertificate and DecryptionKey open documents encrypted for
+	// recipients with the public-key security handler (/Adobe.PubSec).
+	DecryptionCertificate *x509.Certificate
+	DecryptionKey         crypto.Decrypter
+
+	// Password opens documents encrypted with the standard security handler.
+	// It may be the user or the owner password; documents with an empty user
+	// password open without one.
+	Password string
+
+	// Recover opens documents with missing or broken cross-reference data
+	// by rebuilding it from the objects found in the file. What was
+	// repaired is reported in Response.Repairs.
+	Recover bool
 }
 
 // SignatureValidation contains validation results and technical details
@@ -89,14 +120,39 @@ type SignatureValidation struct {
 	VerificationTime   *time.Time           `json:"verification_time"`
 	TimeSource         string               `json:"time_source"`
 	TimeWarnings       []string             `json:"time_warnings,omitempty"`
+	FetchedIssuers     []string             `json:"fetched_issuers,omitempty"`
+	ChainWarnings      []string             `json:"chain_warnings,omitempty"`
+
+	// Findings lists every problem found for this signature in
+	// machine-readable form; see Finding and the Err* sentinel errors.
+	Findings []Finding `json:"findings,omitempty"`
 }
 
 type Response struct {
+	// Error holds the first error message of any signature. Use the
+	// per-signature Validation.Findings or Response.Err for structured results.
 	Error string
 
 	DocumentInfo common.DocumentInfo
-	Signatures   []struct {
+	// Repairs describes what was repaired to read the document when
+	// VerifyOptions.Recover is set.
+	Repairs []string `json:"repairs,omitempty"`
+	// Signatures are the signed signature fields of the AcroForm, in
+	// signing order.
+	Signatures []struct {
 		Info       common.SignatureInfo `json:"info"`
 		Validation SignatureValidation  `json:"validation"`
 	}
+	// OrphanSignatures are signature dictionaries that no signature field
+	// refers to. They are not verified: a viewer does not show them, and
+	// they may have been added to make a document look signed.
+	OrphanSignatures []OrphanSignature `json:"orphan_signatures,omitempty"`
+}
+
+// OrphanSignature is a signature dictionary outside the AcroForm field tree.
+type OrphanSignature struct {
+	Object    uint32 `json:"object"`
+	Filter    string `json:"filter"`
+	SubFilter string `json:"sub_filter,omitempty"`
+	Name      string `json:"name,omitempty"`
 }
@@ -1,6 +1,7 @@
 package verify
 
 import (
+	"bytes"
 	"crypto/x509"
 	"fmt"
 	"io"
@@ -50,20 +51,78 @@ func Verify(file io.ReaderAt, size int64) (apiResp *Response, err error) {
 	return VerifyWithOptions(file, size, DefaultVerifyOptions())
 }
 
-func VerifyWithOptions(file io.ReaderAt, size int64, options *VerifyOptions) (apiResp *Response, err error) {
-	var documentInfo common.DocumentInfo
+// VerifyBytes verifies the PDF document held in data. If options is nil,
+// DefaultVerifyOptions is used.
+func VerifyBytes(data []byte, options *VerifyOptions) (apiResp *Response, err error) {
+	if options == nil {
+		options = DefaultVerifyOptions()
+	}
+	return VerifyWithOptions(bytes.NewReader(data), int64(len(data)), options)
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			apiResp = nil
-			err = fmt.Errorf("failed to verify file (%v)", r)
+// VerifyReader verifies the PDF document read from r. Readers that support
+// random access with a known size, such as *os.File, *bytes.Reader and
+// *io.SectionReader, are read in place; other readers, such as network
+// streams, are first spooled to a temporary file, which is removed when
+// VerifyReader returns. If options is nil, DefaultVerifyOptions is used.
+func VerifyReader(r io.Reader, options *VerifyOptions) (apiResp *Response, err error) {
+	if options == nil {
+		options = DefaultVerifyOptions()
+	}
+	switch r := r.(type) {
+	case *os.File:
+		if st, err := r.Stat(); err == nil && st.Mode().IsRegular() {
+			return VerifyWithOptions(r, st.Size(), options)
 		}
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return VerifyWithOptions(r, r.Size(), options)
+	}
+
+	spool, err := os.CreateTemp("", "pdfsign-verify-*.pdf")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
 	}()
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to spool the document: %w", err)
+	}
+	return VerifyWithOptions(spool, size, options)
+}
+
+func VerifyWithOptions(file io.ReaderAt, size int64, options *VerifyOptions) (apiResp *Response, err error) {
+	var documentInfo common.DocumentInfo
+
 	apiResp = &Response{}
 
-	rdr, err := pdf.NewReader(file, size)
+	rdr, err := pdf.NewReaderOptions(file, size, pdf.ReaderOptions{
+		Certificate: options.DecryptionCertificate,
+		Key:         options.DecryptionKey,
+		Password:    options.Password,
+		Recover:     options.Recover,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %v", err)
+		// Wrapping the reader error lets callers detect pdf.ErrInvalidPassword
+		// and ask for a password.
+		return nil, fmt.Errorf("%w: failed to open file: %w", ErrInvalidDocument, err)
+	}
+	apiResp.Repairs = rdr.Repairs()
+
+	// Revocation data of the Document Security Store is consulted before the
+	// data supplied by the caller.
+	if dss := documentRevocationSource(rdr); dss != nil {
+		withDSS := *options
+		withDSS.RevocationSource = dss
+		if options.RevocationSource != nil {
+			withDSS.RevocationSource = revocationSources{dss, options.RevocationSource}
+		}
+		options = &withDSS
 	}
 
 	// Parse document info from the PDF Info dictionary
@@ -78,30 +137,36 @@ func VerifyWithOptions(file io.ReaderAt, size int64, options *VerifyOptions) (ap
 		documentInfo.Pages = int(pages.Int64())
 	}
 
-	// AcroForm will contain a SigFlags value if the form contains a digital signature
-	t := rdr.Trailer().Key("Root").Key("AcroForm").Key("SigFlags")
-	if t.IsNull() {
-		return nil, fmt.Errorf("no digital signature in document")
+	// Signatures are the values of the signature fields of the AcroForm.
+	fields := signatureFields(rdr)
+	apiResp.OrphanSignatures = orphanSignatures(rdr, fields)
+	if len(fields) == 0 && len(apiResp.OrphanSignatures) == 0 {
+		return nil, ErrNoSignature
 	}
 
-	// Walk over the cross references in the document
-	for _, x := range rdr.Xref() {
-		// Get the xref object Value
-		v, err := rdr.GetObject(x.Ptr().GetID())
+	for _, field := range fields {
+		v := field.sig
+		info, validation, errorMsg, err := processSignatureSafely(v, file, options)
 		if err != nil {
-			continue
+			// Report signatures that cannot be processed at all instead of
+			// dropping them, so a tampered signature cannot hide.
+			validation.ValidSignature = false
+			if len(validation.Findings) == 0 {
+				validation.addFinding(CodeSignatureInvalid, SeverityError, SubjectSignature, "%v", err)
+			}
+			errorMsg = err.Error()
 		}
-
-		// We must have a Filter Adobe.PPKLite
-		if v.Key("Filter").Name() != "Adobe.PPKLite" {
-			continue
+		info.Field = field.name
+		info.Filter = v.Key("Filter").Name()
+		info.SubFilter = v.Key("SubFilter").Name()
+		info.Widgets = field.widgets
+		info.Visible = field.visible
+		info.CoversWholeDocument = byteRangeCoversDocument(v, size)
+		if br := v.Key("ByteRange"); br.Len() == 4 {
+			info.SignedLength = br.Index(2).Int64() + br.Index(3).Int64()
 		}
-
-		// Use the new modular signature processing function
-		info, validation, errorMsg, err := processSignature(v, file, options)
-		if err != nil {
-			// Skip this signature if there's a critical error
-			continue
+		if !info.CoversWholeDocument && info.SignedLength > 0 && info.SignedLength <= size {
+			addRevisionFindings(&validation, file, info.SignedLength, size)
 		}
 
 		// Set any error message if present
@@ -126,3 +191,53 @@ func VerifyWithOptions(file io.ReaderAt, size int64, options *VerifyOptions) (ap
 
 	return
 }
+
+// processSignatureSafely calls processSignature, turning a panic on a
+// malformed signature into an error so that the other signatures are still
+// reported.
+func processSignatureSafely(v pdf.Value, file io.ReaderAt, options *VerifyOptions) (info common.SignatureInfo, validation SignatureValidation, errorMsg string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: malformed signature (%v)", ErrInvalidDocument, r)
+		}
+	}()
+	return processSignature(v, file, options)
+}
+
+// byteRangeCoversDocument reports whether the ByteRange of signature v covers
+// the whole file of the given size except for a single gap holding the
+// signature value.
+func byteRangeCoversDocument(v pdf.Value, size int64) bool {
+	br := v.Key("ByteRange")
+	if br.Len() != 4 {
+		return false
+	}
+	start, length1 := br.Index(0).Int64(), br.Index(1).Int64()
+	offset2, length2 := br.Index(2).Int64(), br.Index(3).Int64()
+	return start == 0 && length1 > 0 && offset2 > length1 && offset2+length2 == size
+}
+
+// addRevisionFindings reports what follows the revision covered by a
+// signature, the first signedLength bytes of the file: later incremental
+// updates, which the signature does not cover, and bytes after the end of
+// the last revision that belong to no revision at all.
+func addRevisionFindings(validation *SignatureValidation, file io.ReaderAt, signedLength, size int64) {
+	rest, err := io.ReadAll(io.NewSectionReader(file, signedLength, size-signedLength))
+	if err != nil {
+		validation.addFinding(CodeInvalidDocument, SeverityWarning, SubjectRevision, "failed to read the data after the signed revision: %v", err)
+		return
+	}
+	rest = bytes.TrimRight(rest, "\x00\t\n\f\r ")
+	end := bytes.LastIndex(rest, []byte("%%EOF"))
+	if end >= 0 {
+		end += len("%%EOF")
+		validation.addFinding(CodeModifiedAfterSigning, SeverityInfo, SubjectRevision,
+			"%d bytes of later revisions were added after signing and are not covered by the signature", end)
+	} else {
+		end = 0
+	}
+	if trailing := len(rest) - end; trailing > 0 {
+		validation.addFinding(CodeTrailingBytes, SeverityWarning, SubjectRevision,
+			"%d bytes after the end of the last revision belong to no revision", trailing)
+	}
+}
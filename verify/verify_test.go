@@ -1,10 +1,15 @@
 package verify
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/digitorus/pdf"
 )
 
 func TestFile(t *testing.T) {
@@ -204,6 +209,92 @@ func TestReader(t *testing.T) {
 	t.Logf("Reader test: Found %d signer(s)", len(response.Signatures))
 }
 
+func TestVerifyRecover(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testfiles", "testfile30.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A broken update after the signed revision leaves the signature intact.
+	broken := append(data[:len(data):len(data)], "\nstartxref\n99999999\n%%EOF\n"...)
+
+	if _, err := Verify(bytes.NewReader(broken), int64(len(broken))); !errors.Is(err, ErrInvalidDocument) {
+		t.Fatalf("Verify() without recovery: error = %v, want %v", err, ErrInvalidDocument)
+	}
+
+	options := DefaultVerifyOptions()
+	options.Recover = true
+	response, err := VerifyWithOptions(bytes.NewReader(broken), int64(len(broken)), options)
+	if err != nil {
+		t.Fatalf("VerifyWithOptions() with recovery: error = %v", err)
+	}
+	if len(response.Repairs) == 0 {
+		t.Error("no repairs reported")
+	}
+	if len(response.Signatures) != 1 {
+		t.Fatalf("%d signatures, want 1", len(response.Signatures))
+	}
+	for _, f := range response.Signatures[0].Validation.Findings {
+		if f.Code == CodeSignatureInvalid {
+			t.Errorf("signature of the recovered document is invalid: %s", f.Message)
+		}
+	}
+}
+
+func TestVerifyBytesAndReader(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testfiles", "testfile_encrypted_signed.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Verify(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(want.Signatures) != 1 || !want.Signatures[0].Validation.ValidSignature || want.DocumentInfo.Title == "" {
+		t.Fatalf("Verify() of the encrypted document = %+v", want)
+	}
+
+	fromBytes, err := VerifyBytes(data, nil)
+	if err != nil {
+		t.Fatalf("VerifyBytes() error = %v", err)
+	}
+	// io.MultiReader hides the random access of the bytes.Reader, so the
+	// document is spooled to a temporary file.
+	options := DefaultVerifyOptions()
+	options.Password = "not needed"
+	fromReader, err := VerifyReader(io.MultiReader(bytes.NewReader(data)), options)
+	if err != nil {
+		t.Fatalf("VerifyReader() error = %v", err)
+	}
+	for name, got := range map[string]*Response{"VerifyBytes": fromBytes, "VerifyReader": fromReader} {
+		if len(got.Signatures) != 1 {
+			t.Errorf("%s() found %d signatures, want 1", name, len(got.Signatures))
+			continue
+		}
+		if got.Signatures[0].Info.DocumentHash != want.Signatures[0].Info.DocumentHash ||
+			got.Signatures[0].Validation.ValidSignature != want.Signatures[0].Validation.ValidSignature ||
+			got.DocumentInfo.Title != want.DocumentInfo.Title {
+			t.Errorf("%s() = %+v, want the same result as Verify() %+v", name, got.Signatures[0].Info, want.Signatures[0].Info)
+		}
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	// testfile_password.pdf is encrypted with user password "user" and has
+	// no signature.
+	data, err := os.ReadFile(filepath.Join("..", "testfiles", "testfile_password.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBytes(data, nil); !errors.Is(err, ErrInvalidDocument) || !errors.Is(err, pdf.ErrInvalidPassword) {
+		t.Errorf("VerifyBytes() without password: error = %v, want %v and %v", err, ErrInvalidDocument, pdf.ErrInvalidPassword)
+	}
+	options := DefaultVerifyOptions()
+	options.Password = "user"
+	if _, err := VerifyBytes(data, options); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("VerifyBytes() with the user password: error = %v, want %v", err, ErrNoSignature)
+	}
+}
+
 func TestFileWithInvalidFile(t *testing.T) {
 	// Create a temporary invalid file
 	tmpFile, err := os.CreateTemp("", "invalid_*.pdf")